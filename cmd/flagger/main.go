@@ -2,21 +2,29 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	_ "github.com/istio/glog"
+	"github.com/weaveworks/flagger/pkg/aws"
 	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
 	informers "github.com/weaveworks/flagger/pkg/client/informers/externalversions"
 	"github.com/weaveworks/flagger/pkg/controller"
 	"github.com/weaveworks/flagger/pkg/logging"
 	"github.com/weaveworks/flagger/pkg/notifier"
+	"github.com/weaveworks/flagger/pkg/router"
 	"github.com/weaveworks/flagger/pkg/server"
 	"github.com/weaveworks/flagger/pkg/signals"
 	"github.com/weaveworks/flagger/pkg/version"
 	"go.uber.org/zap"
+	"io/ioutil"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -24,34 +32,90 @@ var (
 	masterURL           string
 	kubeconfig          string
 	metricsServer       string
+	metricsConcurrency  int
+	metricsTimeout      time.Duration
+	metricsRetries      int
+	metricsBackoff      time.Duration
+	metricsProvider     string
+	metricsTenant       string
+	metricsStaleness    time.Duration
+	metricsCacheTTL     time.Duration
+	metricsRemoteRead   string
+	metricsRetention    time.Duration
+	istioTelemetry      string
+	logsServer          string
+	sloServer           string
+	alertmanagerURL     string
+	killSwitchConfigMap string
+	defaultTelemetry    string
+	defaultSuccessRate  float64
+	defaultDuration     float64
+	defaultInterval     string
 	controlLoopInterval time.Duration
 	logLevel            string
 	port                string
 	slackURL            string
 	slackUser           string
 	slackChannel        string
+	slackChannels       string
+	slackURLs           string
+	sentryDSN           string
+	googleChatURL       string
 	threadiness         int
 	zapReplaceGlobals   bool
 	zapEncoding         string
 	namespace           string
 	meshProvider        string
+	remoteKubeconfigs   string
+	webhookTLSCert      string
+	webhookTLSKey       string
+	webhookTLSCA        string
+	eventVerbosity      string
 )
 
 func init() {
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&metricsServer, "metrics-server", "http://prometheus:9090", "Prometheus URL")
+	flag.StringVar(&metricsProvider, "metrics-server-provider", "prometheus", "Metrics server provider, can be prometheus, amp (Amazon Managed Prometheus with SigV4 signed requests), thanos (metrics aggregated across clusters) or victoriametrics (MetricsQL queries).")
+	flag.StringVar(&metricsTenant, "metrics-server-tenant", "", "VictoriaMetrics cluster tenant (accountID or accountID:projectID), the queries are sent to the tenant path of the vmselect metrics server.")
+	flag.DurationVar(&metricsStaleness, "metrics-staleness", 0, "Maximum age of the latest metric sample, the canary advancement is held if the metrics are older, zero disables the check.")
+	flag.DurationVar(&metricsCacheTTL, "metrics-cache-ttl", 0, "Duration the metric checks of a canary revision are cached, zero disables the cache.")
+	flag.StringVar(&metricsRemoteRead, "metrics-remote-read-url", "", "Prometheus remote-read endpoint queried for the long-range baselines older than the metrics retention.")
+	flag.DurationVar(&metricsRetention, "metrics-retention", 360*time.Hour, "Retention of the metrics server, the long-range baselines within the retention use the query API.")
+	flag.StringVar(&istioTelemetry, "istio-telemetry", "v1", "Istio telemetry version, can be v1 or v2 (request duration histogram in milliseconds).")
+	flag.StringVar(&logsServer, "logs-server", "", "Loki URL used by the log metrics.")
+	flag.StringVar(&sloServer, "slo-server", "", "SLO platform API URL used by the SLO metrics.")
+	flag.StringVar(&alertmanagerURL, "alertmanager-url", "", "Alertmanager URL used to silence the canary alerts during the analysis.")
+	flag.StringVar(&killSwitchConfigMap, "kill-switch-configmap", "", "ConfigMap (<namespace>/<name>) that holds all canaries at their current weight while its engaged key is set to true.")
+	flag.StringVar(&defaultTelemetry, "default-metrics-telemetry", "", "Telemetry (istio or envoy) of the success rate and request duration metrics injected in the canaries without metrics, the flagger.app/telemetry annotation overrides it per canary.")
+	flag.Float64Var(&defaultSuccessRate, "default-metrics-success-rate", 99, "Minimum request success rate percentage of the default metrics, zero leaves the success rate out.")
+	flag.Float64Var(&defaultDuration, "default-metrics-request-duration", 500, "Maximum request duration P99 in milliseconds of the default metrics, zero leaves the request duration out.")
+	flag.StringVar(&defaultInterval, "default-metrics-interval", "1m", "Interval of the default metrics.")
+	flag.IntVar(&metricsConcurrency, "metrics-server-concurrency", 10, "Maximum number of concurrent metrics server queries, zero means unlimited.")
+	flag.DurationVar(&metricsTimeout, "metrics-query-timeout", 5*time.Second, "Deadline of a metrics server query, the timed-out queries halt the canary advancement.")
+	flag.IntVar(&metricsRetries, "metrics-query-retries", 0, "Number of times a metrics server query is retried on timeouts, network errors and 5xx responses, zero disables the retries.")
+	flag.DurationVar(&metricsBackoff, "metrics-query-backoff", 500*time.Millisecond, "Wait before the first retry of a metrics server query, doubled on every retry.")
 	flag.DurationVar(&controlLoopInterval, "control-loop-interval", 10*time.Second, "Kubernetes API sync interval")
 	flag.StringVar(&logLevel, "log-level", "debug", "Log level can be: debug, info, warning, error.")
 	flag.StringVar(&port, "port", "8080", "Port to listen on.")
 	flag.StringVar(&slackURL, "slack-url", "", "Slack hook URL.")
 	flag.StringVar(&slackUser, "slack-user", "flagger", "Slack user name.")
 	flag.StringVar(&slackChannel, "slack-channel", "", "Slack channel.")
+	flag.StringVar(&slackChannels, "slack-severity-channels", "", "Slack channels by notification severity e.g. warn=ops,error=on-call")
+	flag.StringVar(&slackURLs, "slack-severity-urls", "", "Slack hook URLs by notification severity e.g. error=https://hooks.slack.com/services/ID")
+	flag.StringVar(&sentryDSN, "sentry-dsn", os.Getenv("SENTRY_DSN"), "Sentry DSN, defaults to the SENTRY_DSN environment variable.")
+	flag.StringVar(&googleChatURL, "googlechat-url", "", "Google Chat incoming webhook URL.")
 	flag.IntVar(&threadiness, "threadiness", 2, "Worker concurrency.")
 	flag.BoolVar(&zapReplaceGlobals, "zap-replace-globals", false, "Whether to change the logging level of the global zap logger.")
 	flag.StringVar(&zapEncoding, "zap-encoding", "json", "Zap logger encoding.")
 	flag.StringVar(&namespace, "namespace", "", "Namespace that flagger would watch canary object")
-	flag.StringVar(&meshProvider, "mesh-provider", "istio", "Service mesh provider, can be istio or appmesh")
+	flag.StringVar(&meshProvider, "mesh-provider", "istio", "Service mesh provider, can be istio, appmesh, smi, osm, ambassador, externaldns, gatewayapi, cilium or a comma separated list of providers")
+	flag.StringVar(&remoteKubeconfigs, "remote-kubeconfigs", "", "Comma separated list of kubeconfig paths of the remote clusters where the mesh routes are kept in sync, requires the thanos metrics server provider.")
+	flag.StringVar(&webhookTLSCert, "webhook-tls-cert", "", "Path to the client certificate presented to the webhooks without a TLS secret.")
+	flag.StringVar(&webhookTLSKey, "webhook-tls-key", "", "Path to the client certificate key presented to the webhooks without a TLS secret.")
+	flag.StringVar(&webhookTLSCA, "webhook-tls-ca", "", "Path to the CA certificate used to verify the webhooks without a TLS secret, defaults to the system roots.")
+	flag.StringVar(&eventVerbosity, "event-verbosity", controller.EventVerbosityInfo, "Minimum type of the recorded Kubernetes events, can be info or warning (the progress events are only logged).")
 }
 
 func main() {
@@ -105,23 +169,107 @@ func main() {
 		logger.Infof("Watching namespace %s", namespace)
 	}
 
-	ok, err := controller.CheckMetricsServer(metricsServer)
+	var metricsClient *http.Client
+	switch metricsProvider {
+	case "prometheus", "thanos":
+	case "victoriametrics":
+		metricsServer, err = controller.VictoriaMetricsURL(metricsServer, metricsTenant)
+		if err != nil {
+			logger.Fatalf("Error building the VictoriaMetrics URL: %v", err)
+		}
+	case "amp":
+		// the query API is resolved relative to the workspace URL
+		if !strings.HasSuffix(metricsServer, "/") {
+			metricsServer = metricsServer + "/"
+		}
+		metricsClient, err = aws.NewAMPClient(metricsServer)
+		if err != nil {
+			logger.Fatalf("Error building the Amazon Managed Prometheus client: %v", err)
+		}
+	default:
+		logger.Fatalf("Metrics server provider %s not supported", metricsProvider)
+	}
+	if metricsTenant != "" && metricsProvider != "victoriametrics" {
+		logger.Fatalf("The metrics server tenant requires the victoriametrics metrics server provider")
+	}
+	if istioTelemetry != "v1" && istioTelemetry != "v2" {
+		logger.Fatalf("Istio telemetry version %s not supported", istioTelemetry)
+	}
+
+	var remoteClusters []router.RemoteCluster
+	if remoteKubeconfigs != "" {
+		if metricsProvider != "thanos" {
+			logger.Fatalf("Remote clusters require the thanos metrics server provider to aggregate the canary metrics")
+		}
+		remoteClusters, err = newRemoteClusters(strings.Split(remoteKubeconfigs, ","))
+		if err != nil {
+			logger.Fatalf("Error building the remote clusters clients: %v", err)
+		}
+		for _, cluster := range remoteClusters {
+			logger.Infof("Mesh routes synced with remote cluster %s", cluster.Name)
+		}
+	}
+
+	ok, err := controller.CheckMetricsServer(metricsServer, metricsClient)
 	if ok {
 		logger.Infof("Connected to metrics server %s", metricsServer)
 	} else {
 		logger.Errorf("Metrics server %s unreachable %v", metricsServer, err)
 	}
 
-	var slack *notifier.Slack
+	var notifiers []notifier.Interface
 	if slackURL != "" {
-		slack, err = notifier.NewSlack(slackURL, slackUser, slackChannel)
+		slack, err := newSlack()
 		if err != nil {
 			logger.Errorf("Notifier %v", err)
 		} else {
+			notifiers = append(notifiers, slack)
 			logger.Infof("Slack notifications enabled for channel %s", slack.Channel)
 		}
 	}
 
+	if googleChatURL != "" {
+		googleChat, err := notifier.NewGoogleChat(googleChatURL)
+		if err != nil {
+			logger.Errorf("Notifier %v", err)
+		} else {
+			notifiers = append(notifiers, googleChat)
+			logger.Infof("Google Chat notifications enabled")
+		}
+	}
+
+	if sentryDSN != "" {
+		sentry, err := notifier.NewSentry(sentryDSN)
+		if err != nil {
+			logger.Errorf("Notifier %v", err)
+		} else {
+			notifiers = append(notifiers, sentry)
+			logger.Infof("Sentry notifications enabled for rollback events")
+		}
+	}
+
+	if eventVerbosity != controller.EventVerbosityInfo && eventVerbosity != controller.EventVerbosityWarning {
+		logger.Fatalf("Invalid event verbosity %s, can be info or warning", eventVerbosity)
+	}
+
+	webhookClient, err := newWebhookClient()
+	if err != nil {
+		logger.Fatalf("Error loading the webhook TLS certificates: %v", err)
+	}
+
+	killSwitch, err := controller.NewKillSwitch(kubeClient, killSwitchConfigMap)
+	if err != nil {
+		logger.Fatalf("Error building the kill switch: %v", err)
+	}
+
+	defaultMetrics, err := controller.NewDefaultMetrics(defaultTelemetry, defaultSuccessRate, defaultDuration, defaultInterval)
+	if err != nil {
+		logger.Fatalf("Error building the default metrics: %v", err)
+	}
+	if defaultTelemetry != "" {
+		logger.Infof("Default %s metrics enabled for the canaries without metrics", defaultTelemetry)
+	}
+
 	// start HTTP server
 	go server.ListenAndServe(port, 3*time.Second, logger, stopCh)
 
@@ -132,11 +280,33 @@ func main() {
 		canaryInformer,
 		controlLoopInterval,
 		metricsServer,
+		metricsConcurrency,
+		metricsTimeout,
+		metricsRetries,
+		metricsBackoff,
+		metricsClient,
+		metricsProvider,
+		metricsStaleness,
+		metricsCacheTTL,
+		metricsRemoteRead,
+		metricsRetention,
+		istioTelemetry,
+		logsServer,
+		sloServer,
+		alertmanagerURL,
 		logger,
-		slack,
+		notifier.NewMulti(notifiers...),
 		meshProvider,
+		remoteClusters,
+		webhookClient,
+		eventVerbosity,
+		killSwitch,
+		defaultMetrics,
 	)
 
+	// the HTTP server serves the default mux
+	http.Handle("/dry-run", c.DryRunHandler())
+
 	flaggerInformerFactory.Start(stopCh)
 
 	logger.Info("Waiting for informer caches to sync")
@@ -157,3 +327,85 @@ func main() {
 
 	<-stopCh
 }
+
+// newSlack returns the Slack notifier with the severity routes
+func newSlack() (*notifier.Slack, error) {
+	slack, err := notifier.NewSlack(slackURL, slackUser, slackChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := notifier.ParseSeverityMap(slackChannels)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := notifier.ParseSeverityMap(slackURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, severity := range []notifier.Severity{notifier.SeverityInfo, notifier.SeverityWarn, notifier.SeverityError} {
+		if err := slack.SetRoute(severity, urls[severity], channels[severity]); err != nil {
+			return nil, err
+		}
+	}
+	return slack, nil
+}
+
+// newRemoteClusters returns the Kubernetes and mesh clients of the remote clusters,
+// the kubeconfig file name is used as the cluster name
+// newWebhookClient returns a client presenting the webhook certificate files,
+// nil if no certificate or CA is set
+func newWebhookClient() (*http.Client, error) {
+	if webhookTLSCert == "" && webhookTLSKey == "" && webhookTLSCA == "" {
+		return nil, nil
+	}
+
+	var files [3][]byte
+	for i, path := range []string{webhookTLSCert, webhookTLSKey, webhookTLSCA} {
+		if path == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = b
+	}
+
+	config, err := controller.NewWebhookTLSConfig(files[0], files[1], files[2])
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: config,
+		},
+	}, nil
+}
+
+func newRemoteClusters(paths []string) ([]router.RemoteCluster, error) {
+	clusters := make([]router.RemoteCluster, 0, len(paths))
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		cfg, err := clientcmd.BuildConfigFromFlags("", path)
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s %v", path, err)
+		}
+		kubeClient, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s kubernetes clientset %v", path, err)
+		}
+		meshClient, err := clientset.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s mesh clientset %v", path, err)
+		}
+		clusters = append(clusters, router.RemoteCluster{
+			Name:       strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			KubeClient: kubeClient,
+			MeshClient: meshClient,
+		})
+	}
+	return clusters, nil
+}
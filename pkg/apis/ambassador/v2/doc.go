@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+
+// Package v2 is the v2 version of the Ambassador (Emissary-ingress) mapping API.
+// +groupName=getambassador.io
+// +groupGoName=Ambassador
+package v2
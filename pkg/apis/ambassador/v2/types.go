@@ -0,0 +1,53 @@
+package v2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Mapping associates a URL prefix with a backend service,
+// mappings with the same prefix split the traffic based on their weight
+type Mapping struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MappingSpec `json:"spec"`
+}
+
+// MappingSpec is the specification for a Mapping
+type MappingSpec struct {
+	// +optional
+	AmbassadorID []string `json:"ambassador_id,omitempty"`
+	Prefix       string   `json:"prefix"`
+	// +optional
+	PrefixRegex bool `json:"prefix_regex,omitempty"`
+	// +optional
+	Host string `json:"host,omitempty"`
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+	// the prefix is rewritten to / if not set, an empty value disables the rewrite
+	// +optional
+	Rewrite *string `json:"rewrite,omitempty"`
+	// +optional
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// Service is the backend in the <name>.<namespace>:<port> format
+	Service string `json:"service"`
+	// Weight is the percentage of the prefix traffic routed to this mapping,
+	// the rest is routed to the mappings without a weight
+	// +optional
+	Weight int `json:"weight,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MappingList is a list of Mapping resources
+type MappingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Mapping `json:"items"`
+}
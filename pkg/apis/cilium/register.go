@@ -0,0 +1,5 @@
+package cilium
+
+const (
+	GroupName = "cilium.io"
+)
@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+
+// Package v2 is the v2 version of the Cilium API.
+// +groupName=cilium.io
+// +groupGoName=Cilium
+package v2
@@ -0,0 +1,58 @@
+package v2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CiliumEnvoyConfig redirects the traffic of Kubernetes services to Envoy listeners,
+// the Envoy resources are applied by the Cilium agents
+type CiliumEnvoyConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CiliumEnvoyConfigSpec `json:"spec"`
+}
+
+// CiliumEnvoyConfigSpec is the specification for a CiliumEnvoyConfig
+type CiliumEnvoyConfigSpec struct {
+	// services whose traffic is redirected to an Envoy listener
+	// +optional
+	Services []ServiceListener `json:"services,omitempty"`
+	// services whose endpoints are made available to Envoy as clusters named <namespace>/<name>
+	// +optional
+	BackendServices []Service `json:"backendServices,omitempty"`
+	// Envoy xDS resources (listeners, route configurations and clusters) in the protobuf JSON format
+	// +optional
+	Resources []runtime.RawExtension `json:"resources,omitempty"`
+}
+
+// ServiceListener is a service redirected to an Envoy listener
+type ServiceListener struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// name of the Envoy listener, defaults to the first listener of the config
+	// +optional
+	Listener string `json:"listener,omitempty"`
+}
+
+// Service is a reference to a Kubernetes service
+type Service struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CiliumEnvoyConfigList is a list of CiliumEnvoyConfig resources
+type CiliumEnvoyConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []CiliumEnvoyConfig `json:"items"`
+}
@@ -0,0 +1,6 @@
+// +k8s:deepcopy-gen=package
+
+// Package v1alpha1 is the v1alpha1 version of the external-dns DNSEndpoint API.
+// +groupName=externaldns.k8s.io
+// +groupGoName=ExternalDNS
+package v1alpha1
@@ -0,0 +1,57 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DNSEndpoint holds the DNS records managed by external-dns
+type DNSEndpoint struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DNSEndpointSpec `json:"spec"`
+}
+
+// DNSEndpointSpec is the specification for a DNSEndpoint
+type DNSEndpointSpec struct {
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+}
+
+// Endpoint is a DNS record, records with the same name and different
+// set identifiers are weighted records if the provider supports them
+type Endpoint struct {
+	DNSName string   `json:"dnsName,omitempty"`
+	Targets []string `json:"targets,omitempty"`
+	// +optional
+	RecordType string `json:"recordType,omitempty"`
+	// +optional
+	SetIdentifier string `json:"setIdentifier,omitempty"`
+	// +optional
+	RecordTTL int64 `json:"recordTTL,omitempty"`
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// provider specific settings e.g. the Route53 aws/weight
+	// +optional
+	ProviderSpecific []ProviderSpecificProperty `json:"providerSpecific,omitempty"`
+}
+
+// ProviderSpecificProperty holds the name and value of a provider specific setting
+type ProviderSpecificProperty struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DNSEndpointList is a list of DNSEndpoint resources
+type DNSEndpointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []DNSEndpoint `json:"items"`
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	istiov1alpha3 "github.com/weaveworks/flagger/pkg/apis/istio/v1alpha3"
@@ -29,6 +31,25 @@ const (
 	ProgressDeadlineSeconds = 600
 	AnalysisInterval        = 60 * time.Second
 	MetricInterval          = "1m"
+	BaselineAnchorStart     = "start"
+	// AnalysisIntervalAnnotation overrides the analysis interval without changing the spec
+	AnalysisIntervalAnnotation = "flagger.app/analysis-interval"
+	// TelemetryAnnotation selects the default metrics of the canaries without metrics, istio, envoy or none,
+	// set on the target service and optionally overridden on the canary
+	TelemetryAnnotation = "flagger.app/telemetry"
+	// rollback audit trail annotations, updated on every rollback
+	RollbackReasonAnnotation    = "flagger.app/rollback-reason"
+	RollbackTimestampAnnotation = "flagger.app/rollback-timestamp"
+	RollbackRevisionAnnotation  = "flagger.app/rollback-revision"
+	// advancement event annotations
+	CanaryWeightAnnotation = "flagger.app/canary-weight"
+	TargetWeightAnnotation = "flagger.app/target-weight"
+	StepWeightAnnotation   = "flagger.app/step-weight"
+	IterationAnnotation    = "flagger.app/iteration"
+	// RolloutIDAnnotation correlates the events with the analysis run
+	RolloutIDAnnotation = "flagger.app/rollout-id"
+	// TeardownFinalizer blocks the canary deletion until the teardown hooks have run
+	TeardownFinalizer = "flagger.app/teardown"
 )
 
 // +genclient
@@ -65,6 +86,22 @@ type CanarySpec struct {
 	// promote the canary without analysing it
 	// +optional
 	SkipAnalysis bool `json:"skipAnalysis,omitempty"`
+
+	// cancel the analysis in progress and stop scheduling new ones
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// canaries that must finish their rollout before the analysis of this canary can run
+	// +optional
+	DependsOn []CanaryDependency `json:"dependsOn,omitempty"`
+}
+
+// CanaryDependency is a reference to a canary this canary depends on
+type CanaryDependency struct {
+	Name string `json:"name"`
+	// defaults to the namespace of the dependent canary
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -92,6 +129,31 @@ const (
 	// CanaryFailed means the canary analysis failed
 	// and the canary deployment has been scaled to zero
 	CanaryFailed CanaryPhase = "Failed"
+	// CanaryCancelled means the canary analysis was cancelled by suspending the canary
+	// and the canary deployment has been scaled to zero
+	CanaryCancelled CanaryPhase = "Cancelled"
+)
+
+// RollbackReason is the cause of a canary rollback
+type RollbackReason string
+
+const (
+	// RollbackMetricThreshold means the failed metric checks reached the threshold
+	RollbackMetricThreshold RollbackReason = "MetricThreshold"
+	// RollbackProgressDeadline means the canary deployment or a readiness gate exceeded the progress deadline
+	RollbackProgressDeadline RollbackReason = "ProgressDeadline"
+	// RollbackWebhookFailure means the failed webhook checks reached the threshold
+	RollbackWebhookFailure RollbackReason = "WebhookFailure"
+	// RollbackExternalAbort means an external system rejected the canary
+	RollbackExternalAbort RollbackReason = "ExternalAbort"
+	// RollbackNoTraffic means the metric checks without values reached the threshold
+	RollbackNoTraffic RollbackReason = "NoTraffic"
+	// RollbackKubernetesEvent means a canary pod emitted an event or a container terminated with a rollback reason
+	RollbackKubernetesEvent RollbackReason = "KubernetesEvent"
+	// RollbackVerificationFailure means the promoted primary failed the post-promotion verification
+	RollbackVerificationFailure RollbackReason = "VerificationFailure"
+	// RollbackRetryBudgetExceeded means the route mutations or the rollbacks of the rollout exceeded the retry budget
+	RollbackRetryBudgetExceeded RollbackReason = "RetryBudgetExceeded"
 )
 
 // CanaryStatus is used for state persistence (read-only)
@@ -100,12 +162,116 @@ type CanaryStatus struct {
 	FailedChecks int         `json:"failedChecks"`
 	CanaryWeight int         `json:"canaryWeight"`
 	Iterations   int         `json:"iterations"`
+	// percentage of the analysis completed based on the weight or the iterations
+	// +optional
+	Progress int `json:"progress,omitempty"`
+	// index of the current analysis stage, the iterations are counted per stage
+	// +optional
+	Stage int `json:"stage,omitempty"`
 	// +optional
 	TrackedConfigs *map[string]string `json:"trackedConfigs,omitempty"`
+	// checksum of every data key of the tracked configs, used to report the keys that changed
+	// +optional
+	TrackedConfigKeys *map[string]string `json:"trackedConfigKeys,omitempty"`
 	// +optional
 	LastAppliedSpec string `json:"lastAppliedSpec,omitempty"`
 	// +optional
 	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// +optional
+	AnalysisStartTime metav1.Time `json:"analysisStartTime,omitempty"`
+	// number of consecutive intervals the advancement has been halted
+	// +optional
+	HaltedIntervals int `json:"haltedIntervals,omitempty"`
+	// number of intervals the metrics have been analysed
+	// +optional
+	AnalysedIntervals int `json:"analysedIntervals,omitempty"`
+	// number of consecutive successful analyses since the last advancement
+	// +optional
+	ConsecutivePasses int `json:"consecutivePasses,omitempty"`
+	// time the canary advanced to the current weight or iteration
+	// +optional
+	StepStartTime metav1.Time `json:"stepStartTime,omitempty"`
+	// number of requests served by the canary at the current weight or iteration
+	// +optional
+	StepRequests int `json:"stepRequests,omitempty"`
+	// +optional
+	WarmupStartTime metav1.Time `json:"warmupStartTime,omitempty"`
+	// ID of the Alertmanager silence created for the analysis
+	// +optional
+	SilenceID string `json:"silenceID,omitempty"`
+	// ID of the current analysis run, regenerated when the analysis starts or restarts
+	// +optional
+	RolloutID string `json:"rolloutID,omitempty"`
+	// set while the first revision is analysed before its promotion to the primary
+	// +optional
+	InitialAnalysis bool `json:"initialAnalysis,omitempty"`
+	// time the readiness gates started failing, reset when all gates pass
+	// +optional
+	ReadinessGateFailureTime metav1.Time `json:"readinessGateFailureTime,omitempty"`
+	// time the metric checks started returning no values, reset when the values are back
+	// +optional
+	NoValuesStartTime metav1.Time `json:"noValuesStartTime,omitempty"`
+	// time the analysis was suspended outside the allowed window, reset when the window opens
+	// +optional
+	WindowSuspendTime metav1.Time `json:"windowSuspendTime,omitempty"`
+	// time the warmup of the promoted primary started, reset when the canary succeeds or fails
+	// +optional
+	PrimaryWarmupStartTime metav1.Time `json:"primaryWarmupStartTime,omitempty"`
+	// time the verification of the promoted primary started, reset when the canary succeeds or fails
+	// +optional
+	VerificationStartTime metav1.Time `json:"verificationStartTime,omitempty"`
+	// number of traffic weight changes made during the rollout
+	// +optional
+	RouteMutations int `json:"routeMutations,omitempty"`
+	// number of weight step downs during the rollout
+	// +optional
+	Rollbacks int `json:"rollbacks,omitempty"`
+	// approval IDs registered with the approval webhooks indexed by webhook name
+	// +optional
+	Approvals *map[string]string `json:"approvals,omitempty"`
+	// time the approvals were first seen approved indexed by webhook name
+	// +optional
+	ApprovalTimes *map[string]metav1.Time `json:"approvalTimes,omitempty"`
+	// last advancements of the canary, the oldest entries are dropped
+	// +optional
+	History []CanaryAdvance `json:"history,omitempty"`
+	// recent values of the metrics evaluated over multiple windows
+	// +optional
+	MetricWindows []CanaryMetricWindow `json:"metricWindows,omitempty"`
+	// recent values of the metrics the adaptive interval variation is computed over
+	// +optional
+	IntervalWindows []CanaryMetricWindow `json:"intervalWindows,omitempty"`
+	// analysis interval adapted to the metrics variation, reset when a new analysis starts
+	// +optional
+	AnalysisInterval string `json:"analysisInterval,omitempty"`
+	// regions excluded from the analysis by the region-health webhooks
+	// +optional
+	ExcludedRegions []string `json:"excludedRegions,omitempty"`
+	// cause of the last failed check, the rollback reason if the failed checks reach the threshold
+	// +optional
+	FailedCheckReason RollbackReason `json:"failedCheckReason,omitempty"`
+	// cause of the last rollback, reset when a new analysis starts
+	// +optional
+	RollbackReason RollbackReason `json:"rollbackReason,omitempty"`
+}
+
+// CanaryMetricWindow holds the values of a metric over the last analysis intervals, oldest first
+type CanaryMetricWindow struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// CanaryAdvance holds the traffic weight and iteration of a canary advancement
+type CanaryAdvance struct {
+	Time         metav1.Time `json:"time"`
+	CanaryWeight int         `json:"canaryWeight"`
+	// +optional
+	TargetWeight int `json:"targetWeight,omitempty"`
+	// +optional
+	StepWeight int `json:"stepWeight,omitempty"`
+	// +optional
+	Iteration int    `json:"iteration,omitempty"`
+	Message   string `json:"message"`
 }
 
 // CanaryService is used to create ClusterIP services
@@ -122,9 +288,96 @@ type CanaryService struct {
 	//Istio
 	Gateways []string `json:"gateways,omitempty"`
 	Hosts    []string `json:"hosts,omitempty"`
+	// gateways the canary traffic is routed from, the requests coming through
+	// the other gateways of the virtual service are routed to the primary
+	// +optional
+	CanaryGateways []string `json:"canaryGateways,omitempty"`
+	// route to the subsets of an existing destination rule instead of the primary and canary services
+	// +optional
+	Subsets *CanarySubsets `json:"subsets,omitempty"`
+	// generate a delegate virtual service without hosts and gateways,
+	// the traffic is routed to it by a root virtual service
+	// +optional
+	Delegation *CanaryDelegation `json:"delegation,omitempty"`
+	// route the primary or the canary traffic to an external service registered with a service entry
+	// +optional
+	External *CanaryExternal `json:"external,omitempty"`
 	// App Mesh
 	MeshName string   `json:"meshName,omitempty"`
 	Backends []string `json:"backends,omitempty"`
+	// External DNS
+	// +optional
+	DNS *CanaryDNS `json:"dns,omitempty"`
+}
+
+// CanaryDelegation holds the root virtual service the delegate virtual service is registered with
+type CanaryDelegation struct {
+	// name of the root virtual service bound to the gateways,
+	// if empty the delegate is not registered and the root is managed outside of Flagger
+	// +optional
+	Root string `json:"root,omitempty"`
+	// namespace of the root virtual service, defaults to the canary namespace
+	// +optional
+	RootNamespace string `json:"rootNamespace,omitempty"`
+	// match conditions of the root route delegating to the canary virtual service
+	// +optional
+	Match []istiov1alpha3.HTTPMatchRequest `json:"match,omitempty"`
+}
+
+// ExternalTarget is the route destination replaced by the external service
+type ExternalTarget string
+
+const (
+	// ExternalPrimary routes the primary traffic to the external service, used to migrate to the cluster
+	ExternalPrimary ExternalTarget = "primary"
+	// ExternalCanary routes the canary traffic to the external service, used to migrate out of the cluster
+	ExternalCanary ExternalTarget = "canary"
+)
+
+// CanaryExternal holds the external service the primary or canary traffic is routed to
+type CanaryExternal struct {
+	// route destination served by the external service, primary or canary
+	Target ExternalTarget `json:"target"`
+	// name of the service entry registering the external host
+	ServiceEntry string `json:"serviceEntry"`
+	// external host, must be one of the service entry hosts
+	Host string `json:"host"`
+	// external port, defaults to the service port
+	// +optional
+	Port uint32 `json:"port,omitempty"`
+	// name of the destination rule applying the traffic policy of the external host e.g. TLS origination
+	// +optional
+	DestinationRule string `json:"destinationRule,omitempty"`
+}
+
+// CanarySubsets holds the destination rule subsets used for the primary and canary routes
+type CanarySubsets struct {
+	// destination host of the routes, defaults to the target name
+	// +optional
+	Host string `json:"host,omitempty"`
+	// name of the destination rule that defines the subsets, defaults to the target name
+	// +optional
+	DestinationRule string `json:"destinationRule,omitempty"`
+	// name of the subset selecting the primary pods
+	Primary string `json:"primary"`
+	// name of the subset selecting the canary pods
+	Canary string `json:"canary"`
+}
+
+// CanaryDNS holds the weighted DNS records managed with external-dns
+type CanaryDNS struct {
+	// DNS name of the weighted records e.g. app.example.com
+	Name string `json:"name"`
+	// record type, defaults to CNAME
+	// +optional
+	RecordType string `json:"recordType,omitempty"`
+	// record TTL in seconds, a traffic shift takes up to the TTL to propagate
+	// +optional
+	TTL int64 `json:"ttl,omitempty"`
+	// targets of the primary record e.g. the primary load balancer hostname
+	PrimaryTargets []string `json:"primaryTargets"`
+	// targets of the canary record
+	CanaryTargets []string `json:"canaryTargets"`
 }
 
 // CanaryAnalysis is used to describe how the analysis should be done
@@ -137,6 +390,228 @@ type CanaryAnalysis struct {
 	Webhooks   []CanaryWebhook                  `json:"webhooks,omitempty"`
 	Match      []istiov1alpha3.HTTPMatchRequest `json:"match,omitempty"`
 	Iterations int                              `json:"iterations,omitempty"`
+	// percentage of the analysed intervals that can fail before rolling back,
+	// mutually exclusive with threshold
+	// +optional
+	ThresholdPercent float64 `json:"thresholdPercent,omitempty"`
+	// number of consecutive successful analyses required before advancing the canary
+	// +optional
+	ConsecutivePasses int `json:"consecutivePasses,omitempty"`
+	// number of failed checks forgiven on every passing interval, disabled by default
+	// +optional
+	FailedChecksDecay int `json:"failedChecksDecay,omitempty"`
+	// minimum number of requests the canary has to serve at the current weight before advancing
+	// +optional
+	MinRequests int `json:"minRequests,omitempty"`
+	// minimum time spent at a weight before advancing, a hold applies from its weight
+	// up to the weight of the next hold
+	// +optional
+	StepHolds []CanaryStepHold `json:"stepHolds,omitempty"`
+	// scale the step weight between a minimum and a maximum step based on the remaining error budget,
+	// replaces the fixed step weight when advancing the canary
+	// +optional
+	AdaptiveStep *CanaryAdaptiveStep `json:"adaptiveStep,omitempty"`
+	// lengthen the analysis interval when the recent metric values are noisy and shorten it when they are stable,
+	// replaces the fixed interval once enough values are collected
+	// +optional
+	AdaptiveInterval *CanaryAdaptiveInterval `json:"adaptiveInterval,omitempty"`
+	// canary weight of the first step, the next steps are incremented by the step weight
+	// +optional
+	StartWeight int `json:"startWeight,omitempty"`
+	// round the traffic weights to a multiple of this value
+	// +optional
+	WeightGranularity int `json:"weightGranularity,omitempty"`
+	// number of consecutive halted intervals after which a stalled notification is sent
+	// +optional
+	StalledThreshold int `json:"stalledThreshold,omitempty"`
+	// time to wait after the canary is ready before evaluating the metrics
+	// +optional
+	WarmupDuration string `json:"warmupDuration,omitempty"`
+	// traffic percentage routed to the canary during the warmup
+	// +optional
+	WarmupWeight int `json:"warmupWeight,omitempty"`
+	// minimum weighted percentage of passing metrics required to advance,
+	// if not set all metrics must pass
+	// +optional
+	ScoreThreshold float64 `json:"scoreThreshold,omitempty"`
+	// percentage of the matched traffic routed to the canary gets incremented
+	// by this step on every iteration, defaults to routing all matched traffic to the canary
+	// +optional
+	MatchStepWeight int `json:"matchStepWeight,omitempty"`
+	// weighted routing is applied only to the requests matching these conditions,
+	// the rest of the traffic is pinned to the primary
+	// +optional
+	WeightedMatch []istiov1alpha3.HTTPMatchRequest `json:"weightedMatch,omitempty"`
+	// pin the primary baseline metrics to the analysis start or to a RFC3339 timestamp
+	// +optional
+	BaselineAnchor string `json:"baselineAnchor,omitempty"`
+	// run a <name>-baseline deployment with the primary spec during the analysis,
+	// the baseline receives the canary weight and the thresholdPercent metrics are compared to it
+	// +optional
+	Baseline bool `json:"baseline,omitempty"`
+	// maximum age of the latest metric sample, the advancement is held if the metrics are older,
+	// overrides the controller metrics staleness
+	// +optional
+	MetricsStaleness string `json:"metricsStaleness,omitempty"`
+	// report the metric queries that exceeded the controller query timeout as metrics server failures,
+	// by default a timed-out query halts the advancement like a metric without values
+	// +optional
+	FailOnQueryTimeout bool `json:"failOnQueryTimeout,omitempty"`
+	// behavior of the metric checks without values: halt counts a failed check (default),
+	// pass skips the metric and fail rolls back the canary without waiting for the threshold
+	// once the metrics have had no values for the no values timeout
+	// +optional
+	NoValuesPolicy NoValuesPolicy `json:"noValuesPolicy,omitempty"`
+	// time without metric values after which the fail policy rolls back the canary,
+	// defaults to the progress deadline
+	// +optional
+	NoValuesTimeout string `json:"noValuesTimeout,omitempty"`
+	// behavior of the first reconciliation: promote initializes the primary
+	// with the first revision (default), analyze runs the analysis of the first revision
+	// +optional
+	InitializationMode InitializationMode `json:"initializationMode,omitempty"`
+	// silence the alerts of the canary service in Alertmanager during the analysis
+	// +optional
+	Silence *CanarySilence `json:"silence,omitempty"`
+	// maximum number of route mutations and rollbacks during a rollout before the canary is failed
+	// +optional
+	RetryBudget *CanaryRetryBudget `json:"retryBudget,omitempty"`
+	// ordered cohorts the canary is shipped to, each stage is analysed for its iterations
+	// before advancing to the next one, mutually exclusive with match and weightedMatch
+	// +optional
+	Stages []CanaryStage `json:"stages,omitempty"`
+	// snapshot the primary pod template before promotion and restore it when the next analysis fails,
+	// rolls back a primary promoted to a bad version
+	// +optional
+	RollbackPrimary bool `json:"rollbackPrimary,omitempty"`
+	// reasons of the canary pod events and container terminations that roll back the canary
+	// during the analysis e.g. FailedMount, OOMKilled
+	// +optional
+	RollbackEvents []string `json:"rollbackEvents,omitempty"`
+	// replicas the canary is scaled to after a rollback, a warm canary speeds up the next rollout,
+	// defaults to zero
+	// +optional
+	PostRollbackReplicas int32 `json:"postRollbackReplicas,omitempty"`
+	// metric label holding the region of the series, the regions reported unhealthy
+	// by the region-health webhooks are excluded from the built-in metric queries
+	// +optional
+	RegionLabel string `json:"regionLabel,omitempty"`
+	// daily hours the canary is allowed to advance in, the analysis is suspended outside the window
+	// and resumed from the same weight or iteration when the window opens
+	// +optional
+	AllowedWindow *CanaryAllowedWindow `json:"allowedWindow,omitempty"`
+	// analysis of the primary after promotion, the canary succeeds once the promoted primary
+	// passed the metric checks for the verification duration
+	// +optional
+	PromotionVerification *CanaryPromotionVerification `json:"promotionVerification,omitempty"`
+	// conditions the promoted primary must meet before it receives all the traffic
+	// and the canary is scaled down
+	// +optional
+	PrimaryWarmup *CanaryPrimaryWarmup `json:"primaryWarmup,omitempty"`
+	// groups of redundant rollout webhooks evaluated with a quorum,
+	// the ungrouped webhooks must all pass
+	// +optional
+	WebhookGroups []CanaryWebhookGroup `json:"webhookGroups,omitempty"`
+}
+
+// CanaryPrimaryWarmup holds the time the promoted primary must be ready for and the maximum
+// time to wait for the primary-warmup webhooks before the final traffic switch
+type CanaryPrimaryWarmup struct {
+	// minimum time the promoted primary is ready for before the traffic switch e.g. 1m
+	// +optional
+	Duration string `json:"duration,omitempty"`
+	// maximum time to wait for the warmup once the primary is ready,
+	// the traffic is switched once elapsed even if the webhooks are still failing e.g. 10m
+	// +optional
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// CanaryPromotionVerification holds the duration the promoted primary is analysed for
+type CanaryPromotionVerification struct {
+	// duration of the verification e.g. 5m, the metrics are checked on every interval
+	Duration string `json:"duration"`
+}
+
+// CanaryAllowedWindow holds the daily time window the canary analysis is allowed to run in
+type CanaryAllowedWindow struct {
+	// start of the window in the 24-hour HH:MM format
+	Start string `json:"start"`
+	// end of the window in the 24-hour HH:MM format, a window ending before its start spans midnight
+	End string `json:"end"`
+	// IANA time zone of the start and end times e.g. Europe/London, defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// days of the week the window opens on e.g. Mon, Tue, defaults to every day
+	// +optional
+	Days []string `json:"days,omitempty"`
+}
+
+// CanaryAdaptiveStep holds the error budget query and the bounds of the step weight
+type CanaryAdaptiveStep struct {
+	// Prometheus query returning the remaining error budget percentage (0 to 100)
+	Query string `json:"query"`
+	// step weight used when the error budget is exhausted or the query fails
+	MinStepWeight int `json:"minStepWeight"`
+	// step weight used when the error budget is intact
+	MaxStepWeight int `json:"maxStepWeight"`
+}
+
+// CanaryAdaptiveInterval holds the bounds of the analysis interval and the variation it adapts to
+type CanaryAdaptiveInterval struct {
+	// shortest analysis interval e.g. 30s
+	MinInterval string `json:"minInterval"`
+	// longest analysis interval e.g. 5m
+	MaxInterval string `json:"maxInterval"`
+	// coefficient of variation percentage of the recent metric values above which the interval is doubled,
+	// the interval is halved when the variation is below half of it
+	MaxVariation float64 `json:"maxVariation"`
+	// number of recent values per metric the variation is computed over (default 5)
+	// +optional
+	Samples int `json:"samples,omitempty"`
+}
+
+// CanaryStepHold holds the minimum duration of the steps starting at a canary weight
+type CanaryStepHold struct {
+	Weight int `json:"weight"`
+	// duration e.g. 10m
+	Duration string `json:"duration"`
+}
+
+// CanaryStage holds the routing and the metrics of a cohort
+type CanaryStage struct {
+	Name string `json:"name"`
+	// conditions of the requests routed to the canary, all requests if empty
+	// +optional
+	Match []istiov1alpha3.HTTPMatchRequest `json:"match,omitempty"`
+	// percentage of the matched requests routed to the canary, defaults to 100
+	// +optional
+	Weight int `json:"weight,omitempty"`
+	// number of analysis intervals before advancing to the next stage
+	Iterations int `json:"iterations"`
+	// metrics evaluated instead of the analysis metrics during the stage
+	// +optional
+	Metrics []CanaryMetric `json:"metrics,omitempty"`
+}
+
+// CanaryRetryBudget holds the limits of the route changes made during a rollout,
+// a zero value means no limit
+type CanaryRetryBudget struct {
+	// maximum number of traffic weight changes
+	// +optional
+	RouteMutations int `json:"routeMutations,omitempty"`
+	// maximum number of weight step downs that route the traffic back towards the primary
+	// +optional
+	Rollbacks int `json:"rollbacks,omitempty"`
+}
+
+// CanarySilence holds the matchers and the duration of the Alertmanager silence created for the analysis
+type CanarySilence struct {
+	// label matchers added to the namespace and service matchers of the canary
+	// +optional
+	Matchers map[string]string `json:"matchers,omitempty"`
+	// maximum duration of the silence if the analysis doesn't end, defaults to 1h
+	// +optional
+	Duration string `json:"duration,omitempty"`
 }
 
 // CanaryMetric holds the reference to Istio metrics used for canary analysis
@@ -144,26 +619,373 @@ type CanaryMetric struct {
 	Name      string  `json:"name"`
 	Interval  string  `json:"interval,omitempty"`
 	Threshold float64 `json:"threshold"`
+	// threshold expressed as a percentage of the primary baseline,
+	// mutually exclusive with threshold
+	// +optional
+	ThresholdPercent float64 `json:"thresholdPercent,omitempty"`
+	// weight of the metric in the analysis score, defaults to 1
+	// +optional
+	Weight float64 `json:"weight,omitempty"`
+	// soft threshold crossed before the threshold, the advancement is held
+	// and a warning is sent without failing the check
+	// +optional
+	WarnThreshold float64 `json:"warnThreshold,omitempty"`
+	// width of the degraded band past the threshold, a value within the band
+	// steps the canary weight down instead of failing the check
+	// +optional
+	DegradedBand float64 `json:"degradedBand,omitempty"`
+	// response code classes (e.g. 2xx) or codes counted as successful requests
+	// by the success rate built-in metrics, defaults to all non 5xx codes
+	// +optional
+	SuccessCodes []string `json:"successCodes,omitempty"`
+	// response code classes or codes counted as total requests
+	// by the success rate built-in metrics, defaults to all codes
+	// +optional
+	TotalCodes []string `json:"totalCodes,omitempty"`
+	// minimum number of requests during the interval for the success rate built-in metrics
+	// to be evaluated, the metric is skipped instead of failed below this floor
+	// +optional
+	MinRequests float64 `json:"minRequests,omitempty"`
+	// label matchers used by the built-in metrics instead of the workload labels,
+	// e.g. the grouping labels of series pushed to a Prometheus Pushgateway
+	// +optional
+	Selector map[string]string `json:"selector,omitempty"`
+	// label matchers added to the built-in metric queries,
+	// e.g. to target the metrics of a specific container
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// request paths left out of the success rate and request duration built-in metrics,
+	// e.g. the health checks, the paths are regular expressions matched against the path label
+	// +optional
+	ExcludedPaths []string `json:"excludedPaths,omitempty"`
+	// label holding the request path in the built-in metrics, defaults to request_path
+	// +optional
+	PathLabel string `json:"pathLabel,omitempty"`
+	// evaluate the error budget burn rate of the success rate built-in metrics
+	// instead of the success rate, the threshold is the maximum burn rate
+	// +optional
+	BurnRate *CanaryBurnRate `json:"burnRate,omitempty"`
+	// compare the P99 latency of the canary to the primary instead of the threshold,
+	// supported only by the request duration built-in metric
+	// +optional
+	LatencyDelta *CanaryLatencyDelta `json:"latencyDelta,omitempty"`
+	// count the log lines matching an error pattern instead of querying Prometheus,
+	// the threshold is the maximum number of matching lines over the interval
+	// +optional
+	Logs *CanaryLogs `json:"logs,omitempty"`
+	// query a GraphQL endpoint instead of Prometheus, the threshold is the maximum
+	// value found at the JSON path of the response
+	// +optional
+	GraphQL *CanaryGraphQL `json:"graphql,omitempty"`
+	// gate on an SLO managed by the SLO platform, the threshold is the minimum remaining
+	// error budget percentage or the maximum burn rate
+	// +optional
+	SLO *CanarySLO `json:"slo,omitempty"`
+	// evaluate the percentage of two queries e.g. the checkout conversion rate,
+	// the threshold is the minimum percentage
+	// +optional
+	Ratio *CanaryRatio `json:"ratio,omitempty"`
+	// metric and label names used by the built-in queries instead of the Istio and App Mesh ones,
+	// e.g. for the metrics exported through an OpenTelemetry collector
+	// +optional
+	Schema *CanaryMetricSchema `json:"schema,omitempty"`
+	// compute the thresholdPercent baseline from a recorded series averaged over a long window,
+	// windows older than the local retention are read from the Prometheus remote-read endpoint
+	// +optional
+	LongRange *CanaryLongRange `json:"longRange,omitempty"`
+	// evaluate the CPU or memory usage of the canary pods as a percentage of their limits or requests,
+	// the threshold is the maximum saturation percentage
+	// +optional
+	Resource *CanaryResource `json:"resource,omitempty"`
+	// evaluate the built-in metrics over the interval ending this long ago (PromQL offset),
+	// for metrics backends that ingest the samples with a delay
+	// +optional
+	QueryOffset string `json:"queryOffset,omitempty"`
+	// number of analysis intervals averaged before the threshold is checked,
+	// the recent values are weighted linearly with the current interval weighted the most, defaults to 1
+	// +optional
+	EvaluationWindows int `json:"evaluationWindows,omitempty"`
+	// compute the success rate and request duration built-in metrics per pod and aggregate the pod values
+	// instead of querying the whole fleet, catches a single bad pod masked by the fleet average
+	// +optional
+	Aggregation MetricAggregation `json:"aggregation,omitempty"`
 	// +optional
 	Query string `json:"query,omitempty"`
 }
 
+// NoValuesPolicy is the behavior of the metric checks when the query returns no values
+type NoValuesPolicy string
+
+const (
+	// NoValuesHalt halts the advancement and counts a failed check
+	NoValuesHalt NoValuesPolicy = "halt"
+	// NoValuesPass skips the metric, a quiet canary advances
+	NoValuesPass NoValuesPolicy = "pass"
+	// NoValuesFail rolls back the canary once the checks have had no values for the timeout
+	NoValuesFail NoValuesPolicy = "fail"
+)
+
+// InitializationMode is the behavior of the first reconciliation of a canary
+type InitializationMode string
+
+const (
+	// InitializationPromote initializes the primary and waits for the next revision
+	InitializationPromote InitializationMode = "promote"
+	// InitializationAnalyze starts the analysis of the first revision after the initialization
+	InitializationAnalyze InitializationMode = "analyze"
+)
+
+// MetricAggregation is the function applied to the per pod values of a metric
+type MetricAggregation string
+
+const (
+	// AggregationAvg averages the pod values
+	AggregationAvg MetricAggregation = "avg"
+	// AggregationMin returns the lowest pod value
+	AggregationMin MetricAggregation = "min"
+	// AggregationMax returns the highest pod value
+	AggregationMax MetricAggregation = "max"
+	// AggregationWorstPod returns the value of the worst pod,
+	// the lowest success rate or the highest request duration
+	AggregationWorstPod MetricAggregation = "worst-pod"
+)
+
+// CanaryResource holds the resource and the reference of a saturation metric
+type CanaryResource struct {
+	// resource name, cpu or memory
+	Name string `json:"name"`
+	// the usage is compared to the container limits or requests, defaults to limits
+	// +optional
+	Reference string `json:"reference,omitempty"`
+	// container name, defaults to all the containers of the canary pods
+	// +optional
+	Container string `json:"container,omitempty"`
+}
+
+// CanaryLongRange holds the recorded series and the window of a long-range baseline
+type CanaryLongRange struct {
+	// recorded series holding the metric value per workload e.g. workload:istio_requests:success_rate
+	Series string `json:"series"`
+	// window averaged for the primary or baseline workload e.g. 720h
+	Window string `json:"window"`
+}
+
+// CanaryMetricSchema holds the metric and label names of the built-in queries
+type CanaryMetricSchema struct {
+	// metric queried instead of the built-in one e.g. http_server_request_duration_seconds_bucket
+	// +optional
+	MetricName string `json:"metricName,omitempty"`
+	// label matching the workload name e.g. k8s_deployment_name
+	WorkloadLabel string `json:"workloadLabel"`
+	// label matching the namespace e.g. k8s_namespace_name
+	NamespaceLabel string `json:"namespaceLabel"`
+	// label holding the response code, required by the success rate metrics e.g. http_response_status_code
+	// +optional
+	CodeLabel string `json:"codeLabel,omitempty"`
+	// label holding the pod name, used by the per pod aggregation, defaults to pod
+	// +optional
+	PodLabel string `json:"podLabel,omitempty"`
+}
+
+// CanaryRatio holds the queries of a business metric where higher is better
+type CanaryRatio struct {
+	// query of the successful events e.g. the completed checkouts
+	Numerator string `json:"numerator"`
+	// query of the total events e.g. the started checkouts
+	Denominator string `json:"denominator"`
+	// minimum value of the denominator for the ratio to be evaluated,
+	// the metric is skipped instead of failed below this sample size
+	// +optional
+	MinSamples float64 `json:"minSamples,omitempty"`
+}
+
+// CanaryLogs holds the Loki log stream selector and the error pattern of a log metric
+type CanaryLogs struct {
+	// LogQL stream selector, defaults to the canary pods e.g. {namespace="test",app="podinfo"}
+	// +optional
+	Selector string `json:"selector,omitempty"`
+	// regular expression matched against the log lines e.g. (?i)error|panic
+	Pattern string `json:"pattern"`
+}
+
+// CanaryGraphQL holds the endpoint, the query and the response path of a GraphQL metric
+type CanaryGraphQL struct {
+	// address of the GraphQL endpoint e.g. http://analytics.internal/graphql
+	Address string `json:"address"`
+	// GraphQL query, the canary name, namespace and metric interval are passed as variables
+	// e.g. query($name: String!) { service(name: $name) { errorRate } }
+	Query string `json:"query"`
+	// dot separated path of the value in the response data e.g. service.errorRate or services.0.errorRate
+	Path string `json:"path"`
+	// secret in the canary namespace whose keys and values are sent as request headers e.g. Authorization
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// SLOIndicator is the SLO status value compared to the metric threshold
+type SLOIndicator string
+
+const (
+	// SLOErrorBudget is the remaining error budget percentage, the threshold is the minimum
+	SLOErrorBudget SLOIndicator = "error-budget"
+	// SLOBurnRate is the error budget burn rate, the threshold is the maximum
+	SLOBurnRate SLOIndicator = "burn-rate"
+)
+
+// CanarySLO holds the reference to an SLO of the SLO platform and the status value checked
+type CanarySLO struct {
+	// SLO name
+	Name string `json:"name"`
+	// SLO platform project of the SLO
+	// +optional
+	Project string `json:"project,omitempty"`
+	// status value compared to the threshold, defaults to error-budget
+	// +optional
+	Indicator SLOIndicator `json:"indicator,omitempty"`
+	// secret in the canary namespace whose keys and values are sent as request headers e.g. Authorization
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// CanaryBurnRate holds the objective and the windows of a multi-window burn rate check,
+// the check fails when the burn rate is above the threshold over both windows
+type CanaryBurnRate struct {
+	// success rate objective percentage e.g. 99.9
+	Objective float64 `json:"objective"`
+	// long window duration e.g. 1h
+	LongWindow string `json:"longWindow"`
+	// short window duration e.g. 5m
+	ShortWindow string `json:"shortWindow"`
+}
+
+// CanaryLatencyDelta holds the allowed difference between the canary and the primary P99 latency,
+// the check fails when the canary is slower than the primary by more than any of the set values
+type CanaryLatencyDelta struct {
+	// maximum difference in milliseconds
+	// +optional
+	MaxDelta float64 `json:"maxDelta,omitempty"`
+	// maximum difference as a percentage of the primary latency
+	// +optional
+	MaxDeltaPercent float64 `json:"maxDeltaPercent,omitempty"`
+}
+
+// HookType can be pre-rollout, rollout, approval, readiness-gate, promotion, region-health or teardown
+type HookType string
+
+const (
+	// PreRolloutHook is called before routing traffic to the canary and halts the advancement if it fails
+	PreRolloutHook HookType = "pre-rollout"
+	// RolloutHook is called on every analysis interval and halts the advancement if it fails
+	RolloutHook HookType = "rollout"
+	// ApprovalHook registers an approval with an external system and polls its decision
+	ApprovalHook HookType = "approval"
+	// ReadinessGateHook is called after the canary deployment is available and
+	// holds the analysis until the app reports healthy
+	ReadinessGateHook HookType = "readiness-gate"
+	// PromotionHook is called instead of copying the canary spec to the primary
+	// and holds the scale down of the canary until the external system confirms the promotion
+	PromotionHook HookType = "promotion"
+	// PrimaryWarmupHook is called after the promotion and holds the final traffic switch
+	// to the primary until the promoted pods report warm
+	PrimaryWarmupHook HookType = "primary-warmup"
+	// RegionHealthHook reports the unhealthy regions whose metrics are excluded from the analysis
+	RegionHealthHook HookType = "region-health"
+	// TeardownHook is called when the canary is deleted and blocks the deletion until it succeeds
+	TeardownHook HookType = "teardown"
+)
+
 // CanaryWebhook holds the reference to external checks used for canary analysis
 type CanaryWebhook struct {
 	Name    string `json:"name"`
 	URL     string `json:"url"`
 	Timeout string `json:"timeout"`
+	// type of the webhook, defaults to rollout
+	// +optional
+	Type HookType `json:"type,omitempty"`
+	// wait for the load tester to report that the load test is running
+	// before routing traffic to the canary or evaluating the metrics
+	// +optional
+	WaitForTraffic bool `json:"waitForTraffic,omitempty"`
+	// client certificate presented to the webhook and CA used to verify it,
+	// defaults to the controller webhook TLS settings
+	// +optional
+	TLS *CanaryWebhookTLS `json:"tls,omitempty"`
+	// name of the webhook group, the rollout webhooks of a group pass if the group quorum is reached
+	// +optional
+	Group string `json:"group,omitempty"`
+	// duration an approval is honored for, once expired a new approval is registered
+	// and the advancement is held until it's approved
+	// +optional
+	ApprovalExpiry string `json:"approvalExpiry,omitempty"`
 	// +optional
 	Metadata *map[string]string `json:"metadata,omitempty"`
 }
 
+// CanaryWebhookGroup holds the number of webhooks of the group that must pass
+type CanaryWebhookGroup struct {
+	Name string `json:"name"`
+	// minimum number of passed webhooks e.g. 2 of 3
+	Quorum int `json:"quorum"`
+}
+
+// CanaryWebhookTLS holds the secret used for the mutual TLS webhook calls
+type CanaryWebhookTLS struct {
+	// secret in the canary namespace with the client certificate and key (tls.crt, tls.key)
+	// and optionally the CA that signed the webhook server certificate (ca.crt)
+	SecretName string `json:"secretName"`
+}
+
 // CanaryWebhookPayload holds the deployment info and metadata sent to webhooks
 type CanaryWebhookPayload struct {
 	Name      string            `json:"name"`
 	Namespace string            `json:"namespace"`
+	RolloutID string            `json:"rolloutID,omitempty"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
 }
 
+// RegionHealthStatus is returned by a region-health webhook in response to the webhook payload
+type RegionHealthStatus struct {
+	// regions whose metrics are excluded from the analysis
+	Unhealthy []string `json:"unhealthy"`
+}
+
+// LoadTestStatus is returned by the load tester in response to the webhook payload
+type LoadTestStatus struct {
+	Canary string `json:"canary"`
+	// true if a load test is running for the canary
+	Running bool `json:"running"`
+}
+
+// CanaryApproval holds the approval registered with an approval webhook
+type CanaryApproval struct {
+	ID     string         `json:"id"`
+	Status ApprovalStatus `json:"status,omitempty"`
+}
+
+// ApprovalStatus is the decision returned by an approval webhook
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+)
+
+// CanaryPromotion is returned by a promotion webhook in response to the webhook payload
+type CanaryPromotion struct {
+	Status PromotionStatus `json:"status"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PromotionStatus is the progress of a promotion performed by a promotion webhook
+type PromotionStatus string
+
+const (
+	PromotionPending  PromotionStatus = "pending"
+	PromotionPromoted PromotionStatus = "promoted"
+)
+
 // GetProgressDeadlineSeconds returns the progress deadline (default 600s)
 func (c *Canary) GetProgressDeadlineSeconds() int {
 	if c.Spec.ProgressDeadlineSeconds != nil {
@@ -173,8 +995,27 @@ func (c *Canary) GetProgressDeadlineSeconds() int {
 	return ProgressDeadlineSeconds
 }
 
-// GetAnalysisInterval returns the canary analysis interval (default 60s)
+// IsKnativeService returns true if the canary target is a Knative Service
+func (c *Canary) IsKnativeService() bool {
+	return c.Spec.TargetRef.Kind == "Service" &&
+		strings.HasPrefix(c.Spec.TargetRef.APIVersion, "serving.knative.dev/")
+}
+
+// GetAnalysisInterval returns the canary analysis interval (default 60s),
+// the interval annotation takes precedence over the adaptive interval and the spec
 func (c *Canary) GetAnalysisInterval() time.Duration {
+	if v, ok := c.Annotations[AnalysisIntervalAnnotation]; ok {
+		if interval, err := time.ParseDuration(v); err == nil && interval > 0 {
+			return interval
+		}
+	}
+
+	if c.Spec.CanaryAnalysis.AdaptiveInterval != nil && c.Status.AnalysisInterval != "" {
+		if interval, err := time.ParseDuration(c.Status.AnalysisInterval); err == nil && interval > 0 {
+			return interval
+		}
+	}
+
 	if c.Spec.CanaryAnalysis.Interval == "" {
 		return AnalysisInterval
 	}
@@ -187,7 +1028,194 @@ func (c *Canary) GetAnalysisInterval() time.Duration {
 	return interval
 }
 
+// GetWarmupDuration returns the canary warmup duration, zero means no warmup
+func (c *Canary) GetWarmupDuration() time.Duration {
+	if c.Spec.CanaryAnalysis.WarmupDuration == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(c.Spec.CanaryAnalysis.WarmupDuration)
+	if err != nil {
+		return 0
+	}
+
+	return duration
+}
+
+// GetVerificationDuration returns the promotion verification duration, zero means no verification
+func (c *Canary) GetVerificationDuration() time.Duration {
+	verification := c.Spec.CanaryAnalysis.PromotionVerification
+	if verification == nil || verification.Duration == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(verification.Duration)
+	if err != nil {
+		return 0
+	}
+
+	return duration
+}
+
+// GetPrimaryWarmupDuration returns the time the promoted primary must be ready for, zero means no wait
+func (c *Canary) GetPrimaryWarmupDuration() time.Duration {
+	warmup := c.Spec.CanaryAnalysis.PrimaryWarmup
+	if warmup == nil || warmup.Duration == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(warmup.Duration)
+	if err != nil {
+		return 0
+	}
+
+	return duration
+}
+
+// GetPrimaryWarmupTimeout returns the maximum primary warmup time, zero means no timeout
+func (c *Canary) GetPrimaryWarmupTimeout() time.Duration {
+	warmup := c.Spec.CanaryAnalysis.PrimaryWarmup
+	if warmup == nil || warmup.Timeout == "" {
+		return 0
+	}
+
+	timeout, err := time.ParseDuration(warmup.Timeout)
+	if err != nil {
+		return 0
+	}
+
+	return timeout
+}
+
+// GetNoValuesTimeout returns the time the metrics can go without values
+// before the fail policy rolls back the canary (default progress deadline)
+func (c *Canary) GetNoValuesTimeout() time.Duration {
+	deadline := time.Duration(c.GetProgressDeadlineSeconds()) * time.Second
+	if c.Spec.CanaryAnalysis.NoValuesTimeout == "" {
+		return deadline
+	}
+
+	timeout, err := time.ParseDuration(c.Spec.CanaryAnalysis.NoValuesTimeout)
+	if err != nil {
+		return deadline
+	}
+
+	return timeout
+}
+
+// GetMetricsStaleness returns the metrics staleness tolerance,
+// zero means the controller default is used
+func (c *Canary) GetMetricsStaleness() time.Duration {
+	if c.Spec.CanaryAnalysis.MetricsStaleness == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(c.Spec.CanaryAnalysis.MetricsStaleness)
+	if err != nil {
+		return 0
+	}
+
+	return duration
+}
+
+// GetSilenceDuration returns the Alertmanager silence duration (default 1h)
+func (c *Canary) GetSilenceDuration() time.Duration {
+	silence := c.Spec.CanaryAnalysis.Silence
+	if silence == nil || silence.Duration == "" {
+		return time.Hour
+	}
+
+	duration, err := time.ParseDuration(silence.Duration)
+	if err != nil {
+		return time.Hour
+	}
+
+	return duration
+}
+
+// GetProgress returns the analysis completion percentage (0-100),
+// computed from the iterations for A/B testing and from the weight for weighted canaries
+func (c *Canary) GetProgress() int {
+	switch c.Status.Phase {
+	case CanarySucceeded:
+		return 100
+	case CanaryProgressing:
+	default:
+		return 0
+	}
+
+	progress := 0
+	if stages := c.Spec.CanaryAnalysis.Stages; len(stages) > 0 {
+		total, done := 0, c.Status.Iterations
+		for i, stage := range stages {
+			total += stage.Iterations
+			if i < c.Status.Stage {
+				done += stage.Iterations
+			}
+		}
+		if total > 0 {
+			progress = done * 100 / total
+		}
+	} else if len(c.Spec.CanaryAnalysis.Match) > 0 {
+		if c.Spec.CanaryAnalysis.Iterations > 0 {
+			progress = c.Status.Iterations * 100 / c.Spec.CanaryAnalysis.Iterations
+		}
+	} else {
+		maxWeight := 100
+		if c.Spec.CanaryAnalysis.MaxWeight > 0 {
+			maxWeight = c.Spec.CanaryAnalysis.MaxWeight
+		}
+		progress = c.Status.CanaryWeight * 100 / maxWeight
+	}
+
+	if progress > 100 {
+		return 100
+	}
+	return progress
+}
+
+// GetStage returns the current analysis stage, nil if the analysis has no stages
+func (c *Canary) GetStage() *CanaryStage {
+	stages := c.Spec.CanaryAnalysis.Stages
+	if len(stages) == 0 {
+		return nil
+	}
+	if c.Status.Stage >= len(stages) {
+		return &stages[len(stages)-1]
+	}
+	return &stages[c.Status.Stage]
+}
+
+// GetMetrics returns the metrics of the current stage, defaults to the analysis metrics
+func (c *Canary) GetMetrics() []CanaryMetric {
+	if stage := c.GetStage(); stage != nil && len(stage.Metrics) > 0 {
+		return stage.Metrics
+	}
+	return c.Spec.CanaryAnalysis.Metrics
+}
+
 // GetMetricInterval returns the metric interval default value (1m)
 func (c *Canary) GetMetricInterval() string {
 	return MetricInterval
 }
+
+// GetBaselineAnchor returns the time used to evaluate the primary baseline metrics,
+// a nil value means the baseline is evaluated at query time
+func (c *Canary) GetBaselineAnchor() (*time.Time, error) {
+	switch c.Spec.CanaryAnalysis.BaselineAnchor {
+	case "":
+		return nil, nil
+	case BaselineAnchorStart:
+		if c.Status.AnalysisStartTime.IsZero() {
+			return nil, nil
+		}
+		anchor := c.Status.AnalysisStartTime.Time
+		return &anchor, nil
+	default:
+		anchor, err := time.Parse(time.RFC3339, c.Spec.CanaryAnalysis.BaselineAnchor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid baseline anchor %s %v", c.Spec.CanaryAnalysis.BaselineAnchor, err)
+		}
+		return &anchor, nil
+	}
+}
@@ -23,6 +23,7 @@ package v1alpha3
 import (
 	istiov1alpha3 "github.com/weaveworks/flagger/pkg/apis/istio/v1alpha3"
 	v1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -54,86 +55,622 @@ func (in *Canary) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryAdvance) DeepCopyInto(out *CanaryAdvance) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryAdvance.
+func (in *CanaryAdvance) DeepCopy() *CanaryAdvance {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryAdvance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryAdaptiveInterval) DeepCopyInto(out *CanaryAdaptiveInterval) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryAdaptiveInterval.
+func (in *CanaryAdaptiveInterval) DeepCopy() *CanaryAdaptiveInterval {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryAdaptiveInterval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryAdaptiveStep) DeepCopyInto(out *CanaryAdaptiveStep) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryAdaptiveStep.
+func (in *CanaryAdaptiveStep) DeepCopy() *CanaryAdaptiveStep {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryAdaptiveStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryAllowedWindow) DeepCopyInto(out *CanaryAllowedWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryAllowedWindow.
+func (in *CanaryAllowedWindow) DeepCopy() *CanaryAllowedWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryAllowedWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryAnalysis) DeepCopyInto(out *CanaryAnalysis) {
 	*out = *in
 	if in.Metrics != nil {
 		in, out := &in.Metrics, &out.Metrics
 		*out = make([]CanaryMetric, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]CanaryWebhook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make([]istiov1alpha3.HTTPMatchRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StepHolds != nil {
+		in, out := &in.StepHolds, &out.StepHolds
+		*out = make([]CanaryStepHold, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdaptiveStep != nil {
+		in, out := &in.AdaptiveStep, &out.AdaptiveStep
+		*out = new(CanaryAdaptiveStep)
+		**out = **in
+	}
+	if in.AdaptiveInterval != nil {
+		in, out := &in.AdaptiveInterval, &out.AdaptiveInterval
+		*out = new(CanaryAdaptiveInterval)
+		**out = **in
+	}
+	if in.WeightedMatch != nil {
+		in, out := &in.WeightedMatch, &out.WeightedMatch
+		*out = make([]istiov1alpha3.HTTPMatchRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Silence != nil {
+		in, out := &in.Silence, &out.Silence
+		*out = new(CanarySilence)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetryBudget != nil {
+		in, out := &in.RetryBudget, &out.RetryBudget
+		*out = new(CanaryRetryBudget)
+		**out = **in
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]CanaryStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RollbackEvents != nil {
+		in, out := &in.RollbackEvents, &out.RollbackEvents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedWindow != nil {
+		in, out := &in.AllowedWindow, &out.AllowedWindow
+		*out = new(CanaryAllowedWindow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PromotionVerification != nil {
+		in, out := &in.PromotionVerification, &out.PromotionVerification
+		*out = new(CanaryPromotionVerification)
+		**out = **in
+	}
+	if in.PrimaryWarmup != nil {
+		in, out := &in.PrimaryWarmup, &out.PrimaryWarmup
+		*out = new(CanaryPrimaryWarmup)
+		**out = **in
+	}
+	if in.WebhookGroups != nil {
+		in, out := &in.WebhookGroups, &out.WebhookGroups
+		*out = make([]CanaryWebhookGroup, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryAnalysis.
+func (in *CanaryAnalysis) DeepCopy() *CanaryAnalysis {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryAnalysis)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryApproval) DeepCopyInto(out *CanaryApproval) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryApproval.
+func (in *CanaryApproval) DeepCopy() *CanaryApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryBurnRate) DeepCopyInto(out *CanaryBurnRate) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryBurnRate.
+func (in *CanaryBurnRate) DeepCopy() *CanaryBurnRate {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryBurnRate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryDNS) DeepCopyInto(out *CanaryDNS) {
+	*out = *in
+	if in.PrimaryTargets != nil {
+		in, out := &in.PrimaryTargets, &out.PrimaryTargets
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Webhooks != nil {
-		in, out := &in.Webhooks, &out.Webhooks
-		*out = make([]CanaryWebhook, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.CanaryTargets != nil {
+		in, out := &in.CanaryTargets, &out.CanaryTargets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryDNS.
+func (in *CanaryDNS) DeepCopy() *CanaryDNS {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryDNS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryDelegation) DeepCopyInto(out *CanaryDelegation) {
+	*out = *in
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make([]istiov1alpha3.HTTPMatchRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryDelegation.
+func (in *CanaryDelegation) DeepCopy() *CanaryDelegation {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryDelegation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryDependency) DeepCopyInto(out *CanaryDependency) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryDependency.
+func (in *CanaryDependency) DeepCopy() *CanaryDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryExternal) DeepCopyInto(out *CanaryExternal) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryExternal.
+func (in *CanaryExternal) DeepCopy() *CanaryExternal {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryExternal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryGraphQL) DeepCopyInto(out *CanaryGraphQL) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryGraphQL.
+func (in *CanaryGraphQL) DeepCopy() *CanaryGraphQL {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryGraphQL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryLatencyDelta) DeepCopyInto(out *CanaryLatencyDelta) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryLatencyDelta.
+func (in *CanaryLatencyDelta) DeepCopy() *CanaryLatencyDelta {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryLatencyDelta)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryList) DeepCopyInto(out *CanaryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Canary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryList.
+func (in *CanaryList) DeepCopy() *CanaryList {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CanaryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryLogs) DeepCopyInto(out *CanaryLogs) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryLogs.
+func (in *CanaryLogs) DeepCopy() *CanaryLogs {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryLogs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryLongRange) DeepCopyInto(out *CanaryLongRange) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryLongRange.
+func (in *CanaryLongRange) DeepCopy() *CanaryLongRange {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryLongRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryMetric) DeepCopyInto(out *CanaryMetric) {
+	*out = *in
+	if in.SuccessCodes != nil {
+		in, out := &in.SuccessCodes, &out.SuccessCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TotalCodes != nil {
+		in, out := &in.TotalCodes, &out.TotalCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExcludedPaths != nil {
+		in, out := &in.ExcludedPaths, &out.ExcludedPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BurnRate != nil {
+		in, out := &in.BurnRate, &out.BurnRate
+		*out = new(CanaryBurnRate)
+		**out = **in
+	}
+	if in.LatencyDelta != nil {
+		in, out := &in.LatencyDelta, &out.LatencyDelta
+		*out = new(CanaryLatencyDelta)
+		**out = **in
+	}
+	if in.Logs != nil {
+		in, out := &in.Logs, &out.Logs
+		*out = new(CanaryLogs)
+		**out = **in
+	}
+	if in.GraphQL != nil {
+		in, out := &in.GraphQL, &out.GraphQL
+		*out = new(CanaryGraphQL)
+		**out = **in
+	}
+	if in.SLO != nil {
+		in, out := &in.SLO, &out.SLO
+		*out = new(CanarySLO)
+		**out = **in
+	}
+	if in.Ratio != nil {
+		in, out := &in.Ratio, &out.Ratio
+		*out = new(CanaryRatio)
+		**out = **in
+	}
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = new(CanaryMetricSchema)
+		**out = **in
+	}
+	if in.LongRange != nil {
+		in, out := &in.LongRange, &out.LongRange
+		*out = new(CanaryLongRange)
+		**out = **in
+	}
+	if in.Resource != nil {
+		in, out := &in.Resource, &out.Resource
+		*out = new(CanaryResource)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryMetric.
+func (in *CanaryMetric) DeepCopy() *CanaryMetric {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryMetric)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryMetricSchema) DeepCopyInto(out *CanaryMetricSchema) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryMetricSchema.
+func (in *CanaryMetricSchema) DeepCopy() *CanaryMetricSchema {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryMetricSchema)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryMetricWindow) DeepCopyInto(out *CanaryMetricWindow) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]float64, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryMetricWindow.
+func (in *CanaryMetricWindow) DeepCopy() *CanaryMetricWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryMetricWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryPrimaryWarmup) DeepCopyInto(out *CanaryPrimaryWarmup) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryPrimaryWarmup.
+func (in *CanaryPrimaryWarmup) DeepCopy() *CanaryPrimaryWarmup {
+	if in == nil {
+		return nil
 	}
-	if in.Match != nil {
-		in, out := &in.Match, &out.Match
-		*out = make([]istiov1alpha3.HTTPMatchRequest, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	out := new(CanaryPrimaryWarmup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryPromotion) DeepCopyInto(out *CanaryPromotion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryPromotion.
+func (in *CanaryPromotion) DeepCopy() *CanaryPromotion {
+	if in == nil {
+		return nil
 	}
+	out := new(CanaryPromotion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryPromotionVerification) DeepCopyInto(out *CanaryPromotionVerification) {
+	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryAnalysis.
-func (in *CanaryAnalysis) DeepCopy() *CanaryAnalysis {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryPromotionVerification.
+func (in *CanaryPromotionVerification) DeepCopy() *CanaryPromotionVerification {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryAnalysis)
+	out := new(CanaryPromotionVerification)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanaryList) DeepCopyInto(out *CanaryList) {
+func (in *CanaryRatio) DeepCopyInto(out *CanaryRatio) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	out.ListMeta = in.ListMeta
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Canary, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRatio.
+func (in *CanaryRatio) DeepCopy() *CanaryRatio {
+	if in == nil {
+		return nil
 	}
+	out := new(CanaryRatio)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryResource) DeepCopyInto(out *CanaryResource) {
+	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryList.
-func (in *CanaryList) DeepCopy() *CanaryList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryResource.
+func (in *CanaryResource) DeepCopy() *CanaryResource {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryList)
+	out := new(CanaryResource)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CanaryList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRetryBudget) DeepCopyInto(out *CanaryRetryBudget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRetryBudget.
+func (in *CanaryRetryBudget) DeepCopy() *CanaryRetryBudget {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(CanaryRetryBudget)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CanaryMetric) DeepCopyInto(out *CanaryMetric) {
+func (in *CanarySLO) DeepCopyInto(out *CanarySLO) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryMetric.
-func (in *CanaryMetric) DeepCopy() *CanaryMetric {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanarySLO.
+func (in *CanarySLO) DeepCopy() *CanarySLO {
 	if in == nil {
 		return nil
 	}
-	out := new(CanaryMetric)
+	out := new(CanarySLO)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -178,11 +715,36 @@ func (in *CanaryService) DeepCopyInto(out *CanaryService) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.CanaryGateways != nil {
+		in, out := &in.CanaryGateways, &out.CanaryGateways
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subsets != nil {
+		in, out := &in.Subsets, &out.Subsets
+		*out = new(CanarySubsets)
+		**out = **in
+	}
+	if in.Delegation != nil {
+		in, out := &in.Delegation, &out.Delegation
+		*out = new(CanaryDelegation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(CanaryExternal)
+		**out = **in
+	}
 	if in.Backends != nil {
 		in, out := &in.Backends, &out.Backends
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(CanaryDNS)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -196,6 +758,29 @@ func (in *CanaryService) DeepCopy() *CanaryService {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanarySilence) DeepCopyInto(out *CanarySilence) {
+	*out = *in
+	if in.Matchers != nil {
+		in, out := &in.Matchers, &out.Matchers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanarySilence.
+func (in *CanarySilence) DeepCopy() *CanarySilence {
+	if in == nil {
+		return nil
+	}
+	out := new(CanarySilence)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanarySpec) DeepCopyInto(out *CanarySpec) {
 	*out = *in
@@ -212,6 +797,11 @@ func (in *CanarySpec) DeepCopyInto(out *CanarySpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]CanaryDependency, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -225,6 +815,36 @@ func (in *CanarySpec) DeepCopy() *CanarySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryStage) DeepCopyInto(out *CanaryStage) {
+	*out = *in
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make([]istiov1alpha3.HTTPMatchRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]CanaryMetric, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStage.
+func (in *CanaryStage) DeepCopy() *CanaryStage {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
 	*out = *in
@@ -239,7 +859,74 @@ func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
 			}
 		}
 	}
+	if in.TrackedConfigKeys != nil {
+		in, out := &in.TrackedConfigKeys, &out.TrackedConfigKeys
+		*out = new(map[string]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]string, len(*in))
+			for key, val := range *in {
+				(*out)[key] = val
+			}
+		}
+	}
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	in.AnalysisStartTime.DeepCopyInto(&out.AnalysisStartTime)
+	in.StepStartTime.DeepCopyInto(&out.StepStartTime)
+	in.WarmupStartTime.DeepCopyInto(&out.WarmupStartTime)
+	in.ReadinessGateFailureTime.DeepCopyInto(&out.ReadinessGateFailureTime)
+	in.NoValuesStartTime.DeepCopyInto(&out.NoValuesStartTime)
+	in.WindowSuspendTime.DeepCopyInto(&out.WindowSuspendTime)
+	in.PrimaryWarmupStartTime.DeepCopyInto(&out.PrimaryWarmupStartTime)
+	in.VerificationStartTime.DeepCopyInto(&out.VerificationStartTime)
+	if in.Approvals != nil {
+		in, out := &in.Approvals, &out.Approvals
+		*out = new(map[string]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]string, len(*in))
+			for key, val := range *in {
+				(*out)[key] = val
+			}
+		}
+	}
+	if in.ApprovalTimes != nil {
+		in, out := &in.ApprovalTimes, &out.ApprovalTimes
+		*out = new(map[string]metav1.Time)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make(map[string]metav1.Time, len(*in))
+			for key, val := range *in {
+				(*out)[key] = *val.DeepCopy()
+			}
+		}
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]CanaryAdvance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MetricWindows != nil {
+		in, out := &in.MetricWindows, &out.MetricWindows
+		*out = make([]CanaryMetricWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IntervalWindows != nil {
+		in, out := &in.IntervalWindows, &out.IntervalWindows
+		*out = make([]CanaryMetricWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExcludedRegions != nil {
+		in, out := &in.ExcludedRegions, &out.ExcludedRegions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -253,9 +940,46 @@ func (in *CanaryStatus) DeepCopy() *CanaryStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryStepHold) DeepCopyInto(out *CanaryStepHold) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStepHold.
+func (in *CanaryStepHold) DeepCopy() *CanaryStepHold {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryStepHold)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanarySubsets) DeepCopyInto(out *CanarySubsets) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanarySubsets.
+func (in *CanarySubsets) DeepCopy() *CanarySubsets {
+	if in == nil {
+		return nil
+	}
+	out := new(CanarySubsets)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryWebhook) DeepCopyInto(out *CanaryWebhook) {
 	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(CanaryWebhookTLS)
+		**out = **in
+	}
 	if in.Metadata != nil {
 		in, out := &in.Metadata, &out.Metadata
 		*out = new(map[string]string)
@@ -280,6 +1004,22 @@ func (in *CanaryWebhook) DeepCopy() *CanaryWebhook {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryWebhookGroup) DeepCopyInto(out *CanaryWebhookGroup) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryWebhookGroup.
+func (in *CanaryWebhookGroup) DeepCopy() *CanaryWebhookGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryWebhookGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CanaryWebhookPayload) DeepCopyInto(out *CanaryWebhookPayload) {
 	*out = *in
@@ -302,3 +1042,56 @@ func (in *CanaryWebhookPayload) DeepCopy() *CanaryWebhookPayload {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryWebhookTLS) DeepCopyInto(out *CanaryWebhookTLS) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryWebhookTLS.
+func (in *CanaryWebhookTLS) DeepCopy() *CanaryWebhookTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryWebhookTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadTestStatus) DeepCopyInto(out *LoadTestStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadTestStatus.
+func (in *LoadTestStatus) DeepCopy() *LoadTestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadTestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegionHealthStatus) DeepCopyInto(out *RegionHealthStatus) {
+	*out = *in
+	if in.Unhealthy != nil {
+		in, out := &in.Unhealthy, &out.Unhealthy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegionHealthStatus.
+func (in *RegionHealthStatus) DeepCopy() *RegionHealthStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RegionHealthStatus)
+	in.DeepCopyInto(out)
+	return out
+}
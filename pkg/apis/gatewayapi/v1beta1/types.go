@@ -0,0 +1,111 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HTTPRoute holds the routing rules of the HTTP requests accepted by the parent gateways
+type HTTPRoute struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HTTPRouteSpec `json:"spec"`
+}
+
+// HTTPRouteSpec is the specification for a HTTPRoute
+type HTTPRouteSpec struct {
+	// gateways the route is attached to
+	// +optional
+	ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+	// host names matched against the Host header of the requests
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+	// +optional
+	Rules []HTTPRouteRule `json:"rules,omitempty"`
+}
+
+// ParentReference identifies the gateway a route is attached to
+type ParentReference struct {
+	// +optional
+	Group *string `json:"group,omitempty"`
+	// +optional
+	Kind *string `json:"kind,omitempty"`
+	// +optional
+	Namespace *string `json:"namespace,omitempty"`
+	Name      string  `json:"name"`
+	// +optional
+	SectionName *string `json:"sectionName,omitempty"`
+}
+
+// HTTPRouteRule holds the backends the matching requests are forwarded to
+type HTTPRouteRule struct {
+	// +optional
+	Matches []HTTPRouteMatch `json:"matches,omitempty"`
+	// +optional
+	BackendRefs []HTTPBackendRef `json:"backendRefs,omitempty"`
+}
+
+// HTTPRouteMatch holds the conditions a request must meet to match a rule
+type HTTPRouteMatch struct {
+	// +optional
+	Path *HTTPPathMatch `json:"path,omitempty"`
+	// +optional
+	Headers []HTTPHeaderMatch `json:"headers,omitempty"`
+}
+
+// HTTPPathMatch matches the request path, the type can be Exact, PathPrefix or RegularExpression
+type HTTPPathMatch struct {
+	// +optional
+	Type *string `json:"type,omitempty"`
+	// +optional
+	Value *string `json:"value,omitempty"`
+}
+
+// HTTPHeaderMatch matches a request header, the type can be Exact or RegularExpression
+type HTTPHeaderMatch struct {
+	// +optional
+	Type  *string `json:"type,omitempty"`
+	Name  string  `json:"name"`
+	Value string  `json:"value"`
+}
+
+// HTTPBackendRef is a backend of a HTTP route rule
+type HTTPBackendRef struct {
+	BackendRef `json:",inline"`
+}
+
+// BackendRef is a reference to a service and the share of the requests it receives
+type BackendRef struct {
+	BackendObjectReference `json:",inline"`
+	// proportion of the requests forwarded to the backend, relative to the other backends of the rule
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// BackendObjectReference identifies a backend, defaults to a service in the route namespace
+type BackendObjectReference struct {
+	// +optional
+	Group *string `json:"group,omitempty"`
+	// +optional
+	Kind *string `json:"kind,omitempty"`
+	Name string  `json:"name"`
+	// +optional
+	Namespace *string `json:"namespace,omitempty"`
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HTTPRouteList is a list of HTTPRoute resources
+type HTTPRouteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []HTTPRoute `json:"items"`
+}
@@ -0,0 +1,65 @@
+package v1alpha3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DestinationRule
+type DestinationRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DestinationRuleSpec `json:"spec"`
+}
+
+// DestinationRuleSpec defines the policies that apply to the traffic intended
+// for a service after routing has occurred, only the subsets are used by Flagger
+type DestinationRuleSpec struct {
+	// REQUIRED. The name of a service from the service registry.
+	Host string `json:"host"`
+
+	// One or more named sets that represent individual versions of a
+	// service.
+	Subsets []Subset `json:"subsets,omitempty"`
+}
+
+// A subset of endpoints of a service. Subsets can be used for scenarios
+// like A/B testing, or routing to a specific version of a service.
+// For example, the following rule defines two subsets selecting
+// the pods labelled with version: v1 and version: v2
+//
+//	apiVersion: networking.istio.io/v1alpha3
+//	kind: DestinationRule
+//	metadata:
+//	  name: reviews
+//	spec:
+//	  host: reviews
+//	  subsets:
+//	  - name: v1
+//	    labels:
+//	      version: v1
+//	  - name: v2
+//	    labels:
+//	      version: v2
+type Subset struct {
+	// REQUIRED. Name of the subset. The service name and the subset name can
+	// be used for traffic splitting in a route rule.
+	Name string `json:"name"`
+
+	// REQUIRED. Labels apply a filter over the endpoints of a service in the
+	// service registry.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DestinationRuleList is a list of DestinationRule resources
+type DestinationRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []DestinationRule `json:"items"`
+}
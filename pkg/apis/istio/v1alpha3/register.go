@@ -30,6 +30,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&VirtualService{},
 		&VirtualServiceList{},
+		&DestinationRule{},
+		&DestinationRuleList{},
+		&ServiceEntry{},
+		&ServiceEntryList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,73 @@
+package v1alpha3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceEntry
+type ServiceEntry struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceEntrySpec `json:"spec"`
+}
+
+// ServiceEntrySpec adds an entry to the service registry of the mesh,
+// only the hosts and ports are used by Flagger to route to external services e.g.
+//
+//	apiVersion: networking.istio.io/v1alpha3
+//	kind: ServiceEntry
+//	metadata:
+//	  name: podinfo-external
+//	spec:
+//	  hosts:
+//	  - podinfo.example.com
+//	  location: MESH_EXTERNAL
+//	  resolution: DNS
+//	  ports:
+//	  - number: 443
+//	    name: https
+//	    protocol: TLS
+type ServiceEntrySpec struct {
+	// REQUIRED. The hosts associated with the ServiceEntry.
+	Hosts []string `json:"hosts"`
+
+	// The virtual IP addresses associated with the service.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// REQUIRED. The ports associated with the external service.
+	Ports []Port `json:"ports,omitempty"`
+
+	// Specify whether the service should be considered external to the mesh
+	// or part of the mesh, MESH_EXTERNAL or MESH_INTERNAL.
+	Location string `json:"location,omitempty"`
+
+	// REQUIRED: Service discovery mode for the hosts, NONE, STATIC or DNS.
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// Port describes the properties of a specific port of a service.
+type Port struct {
+	// REQUIRED: A valid non-negative integer port number.
+	Number uint32 `json:"number"`
+
+	// REQUIRED: The protocol exposed on the port.
+	// MUST BE one of HTTP|HTTPS|GRPC|HTTP2|MONGO|TCP|TLS.
+	Protocol string `json:"protocol,omitempty"`
+
+	// Label assigned to the port.
+	Name string `json:"name,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceEntryList is a list of ServiceEntry resources
+type ServiceEntryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ServiceEntry `json:"items"`
+}
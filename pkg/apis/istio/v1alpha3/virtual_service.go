@@ -339,6 +339,24 @@ type HTTPRoute struct {
 
 	// Header manipulation rules
 	Headers *Headers `json:"headers,omitempty"`
+
+	// Delegate is used to specify the particular VirtualService which
+	// can be used to define delegate HTTPRoute. It can be set only when
+	// Route and Redirect are empty, and the route rules of the delegate
+	// VirtualService will be merged with that in the current one.
+	Delegate *Delegate `json:"delegate,omitempty"`
+}
+
+// Describes the delegate VirtualService. The delegate VirtualService
+// must not have hosts and gateways, its routes are merged
+// with the route of the root VirtualService that references it.
+type Delegate struct {
+	// Name specifies the name of the delegate VirtualService.
+	Name string `json:"name,omitempty"`
+
+	// Namespace specifies the namespace where the delegate VirtualService resides.
+	// By default, it is same to the root's.
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // Header manipulation rules
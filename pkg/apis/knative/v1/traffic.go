@@ -0,0 +1,38 @@
+package v1
+
+const (
+	// PrimaryTrafficTag is the tag of the traffic target pinned to the primary revision
+	PrimaryTrafficTag = "primary"
+	// CanaryTrafficTag is the tag of the traffic target following the latest revision
+	CanaryTrafficTag = "canary"
+)
+
+// GetTrafficTarget returns the traffic target with the given tag and its index,
+// it returns nil and -1 if the tag is not found
+func (s *Service) GetTrafficTarget(tag string) (*TrafficTarget, int) {
+	for i := range s.Spec.Traffic {
+		if s.Spec.Traffic[i].Tag == tag {
+			return &s.Spec.Traffic[i], i
+		}
+	}
+	return nil, -1
+}
+
+// NewCanaryTraffic returns a traffic split between the primary revision and the latest revision
+func NewCanaryTraffic(primaryRevision string, primaryPercent int, canaryPercent int) []TrafficTarget {
+	latest := true
+	primary := int64(primaryPercent)
+	canary := int64(canaryPercent)
+	return []TrafficTarget{
+		{
+			Tag:          PrimaryTrafficTag,
+			RevisionName: primaryRevision,
+			Percent:      &primary,
+		},
+		{
+			Tag:            CanaryTrafficTag,
+			LatestRevision: &latest,
+			Percent:        &canary,
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Knative Serving API types, only the fields used by Flagger are defined,
+// the revision template is kept as raw JSON so that it round-trips unchanged.
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Service is a specification for a Knative Service resource
+type Service struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec ServiceSpec `json:"spec,omitempty"`
+	// +optional
+	Status ServiceStatus `json:"status,omitempty"`
+}
+
+// ServiceSpec holds the revision template and the traffic split
+type ServiceSpec struct {
+	// +optional
+	Template *runtime.RawExtension `json:"template,omitempty"`
+	// +optional
+	Traffic []TrafficTarget `json:"traffic,omitempty"`
+}
+
+// TrafficTarget holds a single entry of the Route traffic split
+type TrafficTarget struct {
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// +optional
+	RevisionName string `json:"revisionName,omitempty"`
+	// +optional
+	LatestRevision *bool `json:"latestRevision,omitempty"`
+	// +optional
+	Percent *int64 `json:"percent,omitempty"`
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+// ServiceStatus is the status for a Knative Service resource
+type ServiceStatus struct {
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+	// +optional
+	LatestReadyRevisionName string `json:"latestReadyRevisionName,omitempty"`
+	// +optional
+	LatestCreatedRevisionName string `json:"latestCreatedRevisionName,omitempty"`
+	// +optional
+	Traffic []TrafficTarget `json:"traffic,omitempty"`
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+// Condition describes the state of a Knative resource
+type Condition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceList is a list of Knative Service resources
+type ServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Service `json:"items"`
+}
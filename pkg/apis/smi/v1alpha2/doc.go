@@ -0,0 +1,5 @@
+// +k8s:deepcopy-gen=package
+
+// Package v1alpha2 is the v1alpha2 version of the SMI traffic split API.
+// +groupName=split.smi-spec.io
+package v1alpha2
@@ -0,0 +1,43 @@
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TrafficSplit allows users to incrementally direct percentages of traffic
+// between various services
+type TrafficSplit struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TrafficSplitSpec `json:"spec"`
+}
+
+// TrafficSplitSpec is the specification for a TrafficSplit
+type TrafficSplitSpec struct {
+	// Service represents the root service that clients use to connect to the destination application
+	Service string `json:"service"`
+	// Backends are the services that receive a weighted share of the root service traffic
+	Backends []TrafficSplitBackend `json:"backends"`
+}
+
+// TrafficSplitBackend defines a backend service and its weight
+type TrafficSplitBackend struct {
+	Service string `json:"service"`
+	Weight  int    `json:"weight"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TrafficSplitList is a list of TrafficSplit resources
+type TrafficSplitList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []TrafficSplit `json:"items"`
+}
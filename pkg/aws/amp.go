@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ampService is the signing name of Amazon Managed Service for Prometheus
+const ampService = "aps"
+
+// NewAMPClient returns a HTTP client that signs the requests sent to the
+// Amazon Managed Prometheus workspace, the region is read from the AWS_REGION env var
+// or from the workspace URL e.g. https://aps-workspaces.us-west-2.amazonaws.com/workspaces/ws-id
+func NewAMPClient(workspaceURL string) (*http.Client, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		r, err := regionFromURL(workspaceURL)
+		if err != nil {
+			return nil, err
+		}
+		region = r
+	}
+
+	return &http.Client{
+		Transport: &SigV4Transport{
+			Region:      region,
+			Service:     ampService,
+			Credentials: NewCredentialsProvider(region),
+		},
+	}, nil
+}
+
+func regionFromURL(address string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) < 4 || parts[0] != "aps-workspaces" {
+		return "", fmt.Errorf("AWS region not found in %s, set the AWS_REGION env var", address)
+	}
+	return parts[1], nil
+}
@@ -0,0 +1,144 @@
+package aws
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials holds the AWS access keys, the session token is set for temporary credentials
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// expiration time of temporary credentials, zero for static credentials
+	Expires time.Time
+}
+
+// CredentialsProvider returns the credentials used to sign the requests
+type CredentialsProvider interface {
+	Retrieve() (Credentials, error)
+}
+
+// NewCredentialsProvider returns a provider for the controller's IAM role,
+// the web identity provider is used if the IAM roles for service accounts env vars are set
+// otherwise the static credentials are read from the environment
+func NewCredentialsProvider(region string) CredentialsProvider {
+	roleARN := os.Getenv("AWS_ROLE_ARN")
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN != "" && tokenFile != "" {
+		return &WebIdentityProvider{
+			RoleARN:   roleARN,
+			TokenFile: tokenFile,
+			Endpoint:  stsEndpoint(region),
+		}
+	}
+	return &EnvProvider{}
+}
+
+// EnvProvider reads the credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN env vars
+type EnvProvider struct{}
+
+// Retrieve returns the credentials found in the environment
+func (p *EnvProvider) Retrieve() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, errors.New("AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY not found in environment")
+	}
+	return creds, nil
+}
+
+// WebIdentityProvider exchanges the service account token for temporary credentials
+// with STS AssumeRoleWithWebIdentity, the credentials are cached until they are about to expire
+type WebIdentityProvider struct {
+	RoleARN   string
+	TokenFile string
+	Endpoint  string
+
+	mu    sync.Mutex
+	creds Credentials
+}
+
+// expiryWindow is the time before expiration when the credentials are refreshed
+const expiryWindow = 5 * time.Minute
+
+type assumeRoleWithWebIdentityResponse struct {
+	Result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+// Retrieve returns the cached credentials or assumes the role if they are about to expire
+func (p *WebIdentityProvider) Retrieve() (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.creds.AccessKeyID != "" && time.Now().Add(expiryWindow).Before(p.creds.Expires) {
+		return p.creds, nil
+	}
+
+	token, err := ioutil.ReadFile(p.TokenFile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("reading web identity token failed %v", err)
+	}
+
+	params := url.Values{}
+	params.Set("Action", "AssumeRoleWithWebIdentity")
+	params.Set("Version", "2011-06-15")
+	params.Set("RoleArn", p.RoleARN)
+	params.Set("RoleSessionName", fmt.Sprintf("flagger-%d", time.Now().Unix()))
+	params.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(p.Endpoint + "/?" + params.Encode())
+	if err != nil {
+		return Credentials{}, fmt.Errorf("assuming role %s failed %v", p.RoleARN, err)
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error reading body: %s", err.Error())
+	}
+	if res.StatusCode != 200 {
+		return Credentials{}, fmt.Errorf("assuming role %s failed %s", p.RoleARN, string(b))
+	}
+
+	var out assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(b, &out); err != nil {
+		return Credentials{}, fmt.Errorf("error decoding STS response: %s", err.Error())
+	}
+
+	c := out.Result.Credentials
+	p.creds = Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		Expires:         c.Expiration,
+	}
+	return p.creds, nil
+}
+
+func stsEndpoint(region string) string {
+	if region == "" {
+		return "https://sts.amazonaws.com"
+	}
+	return fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+}
@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	shortDateFormat  = "20060102"
+)
+
+// SigV4Transport signs the HTTP requests with the AWS Signature Version 4
+type SigV4Transport struct {
+	Region      string
+	Service     string
+	Credentials CredentialsProvider
+	// underlying round tripper, defaults to http.DefaultTransport
+	Next http.RoundTripper
+	// returns the signing time, defaults to time.Now
+	now func() time.Time
+}
+
+// RoundTrip signs a copy of the request and sends it with the underlying round tripper
+func (t *SigV4Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	creds, err := t.Credentials.Retrieve()
+	if err != nil {
+		return nil, fmt.Errorf("retrieving AWS credentials failed %v", err)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signed := req.Clone(req.Context())
+	if req.Body != nil {
+		signed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	t.sign(signed, body, creds, now().UTC())
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(signed)
+}
+
+func (t *SigV4Transport) sign(req *http.Request, body []byte, creds Credentials, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	payloadHash := sha256Hex(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(shortDateFormat), t.Region, t.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), now.Format(shortDateFormat))
+	key = hmacSHA256(key, t.Region)
+	key = hmacSHA256(key, t.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalHeaders returns the host and the x-amz headers sorted by name
+// and the list of the signed header names
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": req.Host}
+	for name, v := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" {
+			values[name] = strings.Join(v, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(strings.Fields(values[name]), " ") + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalQuery returns the query parameters sorted by name and value
+// and encoded with the RFC 3986 unreserved characters
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, 0, len(query))
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(name)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func uriEncode(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,148 @@
+package aws
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticProvider struct {
+	creds Credentials
+}
+
+func (p *staticProvider) Retrieve() (Credentials, error) {
+	return p.creds, nil
+}
+
+// get-vanilla test case of the AWS Signature Version 4 test suite
+func TestSigV4Transport_Sign(t *testing.T) {
+	tr := &SigV4Transport{
+		Region:  "us-east-1",
+		Service: "service",
+	}
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now, _ := time.Parse(amzDateFormat, "20150830T123600Z")
+
+	tr.sign(req, nil, creds, now)
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Errorf("Got authorization %s wanted %s", auth, expected)
+	}
+}
+
+func TestSigV4Transport_RoundTrip(t *testing.T) {
+	var auth, token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		token = r.Header.Get("X-Amz-Security-Token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{
+		Transport: &SigV4Transport{
+			Region:  "us-west-2",
+			Service: ampService,
+			Credentials: &staticProvider{Credentials{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "secret",
+				SessionToken:    "session",
+			}},
+		},
+	}
+
+	res, err := client.Get(ts.URL + "/workspaces/ws-1/api/v1/query?query=up")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res.Body.Close()
+
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+		!strings.Contains(auth, "/us-west-2/aps/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token") {
+		t.Errorf("Got authorization %s", auth)
+	}
+	if token != "session" {
+		t.Errorf("Got security token %s wanted %s", token, "session")
+	}
+}
+
+func TestWebIdentityProvider_Retrieve(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("Action") != "AssumeRoleWithWebIdentity" || r.URL.Query().Get("WebIdentityToken") != "jwt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAEXAMPLE</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "flagger")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("jwt\n"), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p := &WebIdentityProvider{
+		RoleARN:   "arn:aws:iam::123456789012:role/flagger",
+		TokenFile: tokenFile,
+		Endpoint:  ts.URL,
+	}
+
+	creds, err := p.Retrieve()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if creds.AccessKeyID != "ASIAEXAMPLE" || creds.SessionToken != "session" {
+		t.Errorf("Got credentials %+v", creds)
+	}
+
+	// cached until about to expire
+	if _, err := p.Retrieve(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if calls != 1 {
+		t.Errorf("Got %v STS calls wanted %v", calls, 1)
+	}
+}
+
+func TestRegionFromURL(t *testing.T) {
+	region, err := regionFromURL("https://aps-workspaces.eu-west-1.amazonaws.com/workspaces/ws-1/")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if region != "eu-west-1" {
+		t.Errorf("Got region %s wanted %s", region, "eu-west-1")
+	}
+
+	if _, err := regionFromURL("http://prometheus:9090"); err == nil {
+		t.Errorf("Got no error wanted region not found")
+	}
+}
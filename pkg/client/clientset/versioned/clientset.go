@@ -19,9 +19,15 @@ limitations under the License.
 package versioned
 
 import (
+	ambassadorv2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/ambassador/v2"
 	appmeshv1alpha1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/appmesh/v1alpha1"
+	ciliumv2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/cilium/v2"
+	externaldnsv1alpha1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/externaldns/v1alpha1"
 	flaggerv1alpha3 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/flagger/v1alpha3"
+	gatewayapiv1beta1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/gatewayapi/v1beta1"
 	networkingv1alpha3 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/istio/v1alpha3"
+	servingv1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/knative/v1"
+	splitv1alpha2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha2"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
@@ -29,24 +35,59 @@ import (
 
 type Interface interface {
 	Discovery() discovery.DiscoveryInterface
+	AmbassadorV2() ambassadorv2.AmbassadorV2Interface
+	// Deprecated: please explicitly pick a version if possible.
+	Ambassador() ambassadorv2.AmbassadorV2Interface
 	AppmeshV1alpha1() appmeshv1alpha1.AppmeshV1alpha1Interface
 	// Deprecated: please explicitly pick a version if possible.
 	Appmesh() appmeshv1alpha1.AppmeshV1alpha1Interface
+	CiliumV2() ciliumv2.CiliumV2Interface
+	// Deprecated: please explicitly pick a version if possible.
+	Cilium() ciliumv2.CiliumV2Interface
+	ExternalDNSV1alpha1() externaldnsv1alpha1.ExternalDNSV1alpha1Interface
+	// Deprecated: please explicitly pick a version if possible.
+	ExternalDNS() externaldnsv1alpha1.ExternalDNSV1alpha1Interface
 	FlaggerV1alpha3() flaggerv1alpha3.FlaggerV1alpha3Interface
 	// Deprecated: please explicitly pick a version if possible.
 	Flagger() flaggerv1alpha3.FlaggerV1alpha3Interface
+	GatewayAPIV1beta1() gatewayapiv1beta1.GatewayAPIV1beta1Interface
+	// Deprecated: please explicitly pick a version if possible.
+	GatewayAPI() gatewayapiv1beta1.GatewayAPIV1beta1Interface
 	NetworkingV1alpha3() networkingv1alpha3.NetworkingV1alpha3Interface
 	// Deprecated: please explicitly pick a version if possible.
 	Networking() networkingv1alpha3.NetworkingV1alpha3Interface
+	ServingV1() servingv1.ServingV1Interface
+	// Deprecated: please explicitly pick a version if possible.
+	Serving() servingv1.ServingV1Interface
+	SplitV1alpha2() splitv1alpha2.SplitV1alpha2Interface
+	// Deprecated: please explicitly pick a version if possible.
+	Split() splitv1alpha2.SplitV1alpha2Interface
 }
 
 // Clientset contains the clients for groups. Each group has exactly one
 // version included in a Clientset.
 type Clientset struct {
 	*discovery.DiscoveryClient
-	appmeshV1alpha1    *appmeshv1alpha1.AppmeshV1alpha1Client
-	flaggerV1alpha3    *flaggerv1alpha3.FlaggerV1alpha3Client
-	networkingV1alpha3 *networkingv1alpha3.NetworkingV1alpha3Client
+	ambassadorV2        *ambassadorv2.AmbassadorV2Client
+	appmeshV1alpha1     *appmeshv1alpha1.AppmeshV1alpha1Client
+	ciliumV2            *ciliumv2.CiliumV2Client
+	externalDNSV1alpha1 *externaldnsv1alpha1.ExternalDNSV1alpha1Client
+	flaggerV1alpha3     *flaggerv1alpha3.FlaggerV1alpha3Client
+	gatewayAPIV1beta1   *gatewayapiv1beta1.GatewayAPIV1beta1Client
+	networkingV1alpha3  *networkingv1alpha3.NetworkingV1alpha3Client
+	servingV1           *servingv1.ServingV1Client
+	splitV1alpha2       *splitv1alpha2.SplitV1alpha2Client
+}
+
+// AmbassadorV2 retrieves the AmbassadorV2Client
+func (c *Clientset) AmbassadorV2() ambassadorv2.AmbassadorV2Interface {
+	return c.ambassadorV2
+}
+
+// Deprecated: Ambassador retrieves the default version of AmbassadorClient.
+// Please explicitly pick a version.
+func (c *Clientset) Ambassador() ambassadorv2.AmbassadorV2Interface {
+	return c.ambassadorV2
 }
 
 // AppmeshV1alpha1 retrieves the AppmeshV1alpha1Client
@@ -60,6 +101,28 @@ func (c *Clientset) Appmesh() appmeshv1alpha1.AppmeshV1alpha1Interface {
 	return c.appmeshV1alpha1
 }
 
+// CiliumV2 retrieves the CiliumV2Client
+func (c *Clientset) CiliumV2() ciliumv2.CiliumV2Interface {
+	return c.ciliumV2
+}
+
+// Deprecated: Cilium retrieves the default version of CiliumClient.
+// Please explicitly pick a version.
+func (c *Clientset) Cilium() ciliumv2.CiliumV2Interface {
+	return c.ciliumV2
+}
+
+// ExternalDNSV1alpha1 retrieves the ExternalDNSV1alpha1Client
+func (c *Clientset) ExternalDNSV1alpha1() externaldnsv1alpha1.ExternalDNSV1alpha1Interface {
+	return c.externalDNSV1alpha1
+}
+
+// Deprecated: ExternalDNS retrieves the default version of ExternalDNSClient.
+// Please explicitly pick a version.
+func (c *Clientset) ExternalDNS() externaldnsv1alpha1.ExternalDNSV1alpha1Interface {
+	return c.externalDNSV1alpha1
+}
+
 // FlaggerV1alpha3 retrieves the FlaggerV1alpha3Client
 func (c *Clientset) FlaggerV1alpha3() flaggerv1alpha3.FlaggerV1alpha3Interface {
 	return c.flaggerV1alpha3
@@ -71,6 +134,17 @@ func (c *Clientset) Flagger() flaggerv1alpha3.FlaggerV1alpha3Interface {
 	return c.flaggerV1alpha3
 }
 
+// GatewayAPIV1beta1 retrieves the GatewayAPIV1beta1Client
+func (c *Clientset) GatewayAPIV1beta1() gatewayapiv1beta1.GatewayAPIV1beta1Interface {
+	return c.gatewayAPIV1beta1
+}
+
+// Deprecated: GatewayAPI retrieves the default version of GatewayAPIClient.
+// Please explicitly pick a version.
+func (c *Clientset) GatewayAPI() gatewayapiv1beta1.GatewayAPIV1beta1Interface {
+	return c.gatewayAPIV1beta1
+}
+
 // NetworkingV1alpha3 retrieves the NetworkingV1alpha3Client
 func (c *Clientset) NetworkingV1alpha3() networkingv1alpha3.NetworkingV1alpha3Interface {
 	return c.networkingV1alpha3
@@ -82,6 +156,28 @@ func (c *Clientset) Networking() networkingv1alpha3.NetworkingV1alpha3Interface
 	return c.networkingV1alpha3
 }
 
+// ServingV1 retrieves the ServingV1Client
+func (c *Clientset) ServingV1() servingv1.ServingV1Interface {
+	return c.servingV1
+}
+
+// Deprecated: Serving retrieves the default version of ServingClient.
+// Please explicitly pick a version.
+func (c *Clientset) Serving() servingv1.ServingV1Interface {
+	return c.servingV1
+}
+
+// SplitV1alpha2 retrieves the SplitV1alpha2Client
+func (c *Clientset) SplitV1alpha2() splitv1alpha2.SplitV1alpha2Interface {
+	return c.splitV1alpha2
+}
+
+// Deprecated: Split retrieves the default version of SplitClient.
+// Please explicitly pick a version.
+func (c *Clientset) Split() splitv1alpha2.SplitV1alpha2Interface {
+	return c.splitV1alpha2
+}
+
 // Discovery retrieves the DiscoveryClient
 func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 	if c == nil {
@@ -98,18 +194,42 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 	}
 	var cs Clientset
 	var err error
+	cs.ambassadorV2, err = ambassadorv2.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 	cs.appmeshV1alpha1, err = appmeshv1alpha1.NewForConfig(&configShallowCopy)
 	if err != nil {
 		return nil, err
 	}
+	cs.ciliumV2, err = ciliumv2.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
+	cs.externalDNSV1alpha1, err = externaldnsv1alpha1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 	cs.flaggerV1alpha3, err = flaggerv1alpha3.NewForConfig(&configShallowCopy)
 	if err != nil {
 		return nil, err
 	}
+	cs.gatewayAPIV1beta1, err = gatewayapiv1beta1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 	cs.networkingV1alpha3, err = networkingv1alpha3.NewForConfig(&configShallowCopy)
 	if err != nil {
 		return nil, err
 	}
+	cs.servingV1, err = servingv1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
+	cs.splitV1alpha2, err = splitv1alpha2.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 
 	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfig(&configShallowCopy)
 	if err != nil {
@@ -122,9 +242,15 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 // panics if there is an error in the config.
 func NewForConfigOrDie(c *rest.Config) *Clientset {
 	var cs Clientset
+	cs.ambassadorV2 = ambassadorv2.NewForConfigOrDie(c)
 	cs.appmeshV1alpha1 = appmeshv1alpha1.NewForConfigOrDie(c)
+	cs.ciliumV2 = ciliumv2.NewForConfigOrDie(c)
+	cs.externalDNSV1alpha1 = externaldnsv1alpha1.NewForConfigOrDie(c)
 	cs.flaggerV1alpha3 = flaggerv1alpha3.NewForConfigOrDie(c)
+	cs.gatewayAPIV1beta1 = gatewayapiv1beta1.NewForConfigOrDie(c)
 	cs.networkingV1alpha3 = networkingv1alpha3.NewForConfigOrDie(c)
+	cs.servingV1 = servingv1.NewForConfigOrDie(c)
+	cs.splitV1alpha2 = splitv1alpha2.NewForConfigOrDie(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClientForConfigOrDie(c)
 	return &cs
@@ -133,9 +259,15 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 // New creates a new Clientset for the given RESTClient.
 func New(c rest.Interface) *Clientset {
 	var cs Clientset
+	cs.ambassadorV2 = ambassadorv2.New(c)
 	cs.appmeshV1alpha1 = appmeshv1alpha1.New(c)
+	cs.ciliumV2 = ciliumv2.New(c)
+	cs.externalDNSV1alpha1 = externaldnsv1alpha1.New(c)
 	cs.flaggerV1alpha3 = flaggerv1alpha3.New(c)
+	cs.gatewayAPIV1beta1 = gatewayapiv1beta1.New(c)
 	cs.networkingV1alpha3 = networkingv1alpha3.New(c)
+	cs.servingV1 = servingv1.New(c)
+	cs.splitV1alpha2 = splitv1alpha2.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
 	return &cs
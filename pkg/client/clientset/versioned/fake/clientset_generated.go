@@ -20,12 +20,24 @@ package fake
 
 import (
 	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	ambassadorv2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/ambassador/v2"
+	fakeambassadorv2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/ambassador/v2/fake"
 	appmeshv1alpha1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/appmesh/v1alpha1"
 	fakeappmeshv1alpha1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/appmesh/v1alpha1/fake"
+	ciliumv2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/cilium/v2"
+	fakeciliumv2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/cilium/v2/fake"
+	externaldnsv1alpha1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/externaldns/v1alpha1"
+	fakeexternaldnsv1alpha1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/externaldns/v1alpha1/fake"
 	flaggerv1alpha3 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/flagger/v1alpha3"
 	fakeflaggerv1alpha3 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/flagger/v1alpha3/fake"
+	gatewayapiv1beta1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/gatewayapi/v1beta1"
+	fakegatewayapiv1beta1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/gatewayapi/v1beta1/fake"
 	networkingv1alpha3 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/istio/v1alpha3"
 	fakenetworkingv1alpha3 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/istio/v1alpha3/fake"
+	servingv1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/knative/v1"
+	fakeservingv1 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/knative/v1/fake"
+	splitv1alpha2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha2"
+	fakesplitv1alpha2 "github.com/weaveworks/flagger/pkg/client/clientset/versioned/typed/smi/v1alpha2/fake"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
@@ -75,6 +87,16 @@ func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 
 var _ clientset.Interface = &Clientset{}
 
+// AmbassadorV2 retrieves the AmbassadorV2Client
+func (c *Clientset) AmbassadorV2() ambassadorv2.AmbassadorV2Interface {
+	return &fakeambassadorv2.FakeAmbassadorV2{Fake: &c.Fake}
+}
+
+// Ambassador retrieves the AmbassadorV2Client
+func (c *Clientset) Ambassador() ambassadorv2.AmbassadorV2Interface {
+	return &fakeambassadorv2.FakeAmbassadorV2{Fake: &c.Fake}
+}
+
 // AppmeshV1alpha1 retrieves the AppmeshV1alpha1Client
 func (c *Clientset) AppmeshV1alpha1() appmeshv1alpha1.AppmeshV1alpha1Interface {
 	return &fakeappmeshv1alpha1.FakeAppmeshV1alpha1{Fake: &c.Fake}
@@ -85,6 +107,26 @@ func (c *Clientset) Appmesh() appmeshv1alpha1.AppmeshV1alpha1Interface {
 	return &fakeappmeshv1alpha1.FakeAppmeshV1alpha1{Fake: &c.Fake}
 }
 
+// CiliumV2 retrieves the CiliumV2Client
+func (c *Clientset) CiliumV2() ciliumv2.CiliumV2Interface {
+	return &fakeciliumv2.FakeCiliumV2{Fake: &c.Fake}
+}
+
+// Cilium retrieves the CiliumV2Client
+func (c *Clientset) Cilium() ciliumv2.CiliumV2Interface {
+	return &fakeciliumv2.FakeCiliumV2{Fake: &c.Fake}
+}
+
+// ExternalDNSV1alpha1 retrieves the ExternalDNSV1alpha1Client
+func (c *Clientset) ExternalDNSV1alpha1() externaldnsv1alpha1.ExternalDNSV1alpha1Interface {
+	return &fakeexternaldnsv1alpha1.FakeExternalDNSV1alpha1{Fake: &c.Fake}
+}
+
+// ExternalDNS retrieves the ExternalDNSV1alpha1Client
+func (c *Clientset) ExternalDNS() externaldnsv1alpha1.ExternalDNSV1alpha1Interface {
+	return &fakeexternaldnsv1alpha1.FakeExternalDNSV1alpha1{Fake: &c.Fake}
+}
+
 // FlaggerV1alpha3 retrieves the FlaggerV1alpha3Client
 func (c *Clientset) FlaggerV1alpha3() flaggerv1alpha3.FlaggerV1alpha3Interface {
 	return &fakeflaggerv1alpha3.FakeFlaggerV1alpha3{Fake: &c.Fake}
@@ -95,6 +137,16 @@ func (c *Clientset) Flagger() flaggerv1alpha3.FlaggerV1alpha3Interface {
 	return &fakeflaggerv1alpha3.FakeFlaggerV1alpha3{Fake: &c.Fake}
 }
 
+// GatewayAPIV1beta1 retrieves the GatewayAPIV1beta1Client
+func (c *Clientset) GatewayAPIV1beta1() gatewayapiv1beta1.GatewayAPIV1beta1Interface {
+	return &fakegatewayapiv1beta1.FakeGatewayAPIV1beta1{Fake: &c.Fake}
+}
+
+// GatewayAPI retrieves the GatewayAPIV1beta1Client
+func (c *Clientset) GatewayAPI() gatewayapiv1beta1.GatewayAPIV1beta1Interface {
+	return &fakegatewayapiv1beta1.FakeGatewayAPIV1beta1{Fake: &c.Fake}
+}
+
 // NetworkingV1alpha3 retrieves the NetworkingV1alpha3Client
 func (c *Clientset) NetworkingV1alpha3() networkingv1alpha3.NetworkingV1alpha3Interface {
 	return &fakenetworkingv1alpha3.FakeNetworkingV1alpha3{Fake: &c.Fake}
@@ -104,3 +156,23 @@ func (c *Clientset) NetworkingV1alpha3() networkingv1alpha3.NetworkingV1alpha3In
 func (c *Clientset) Networking() networkingv1alpha3.NetworkingV1alpha3Interface {
 	return &fakenetworkingv1alpha3.FakeNetworkingV1alpha3{Fake: &c.Fake}
 }
+
+// ServingV1 retrieves the ServingV1Client
+func (c *Clientset) ServingV1() servingv1.ServingV1Interface {
+	return &fakeservingv1.FakeServingV1{Fake: &c.Fake}
+}
+
+// Serving retrieves the ServingV1Client
+func (c *Clientset) Serving() servingv1.ServingV1Interface {
+	return &fakeservingv1.FakeServingV1{Fake: &c.Fake}
+}
+
+// SplitV1alpha2 retrieves the SplitV1alpha2Client
+func (c *Clientset) SplitV1alpha2() splitv1alpha2.SplitV1alpha2Interface {
+	return &fakesplitv1alpha2.FakeSplitV1alpha2{Fake: &c.Fake}
+}
+
+// Split retrieves the SplitV1alpha2Client
+func (c *Clientset) Split() splitv1alpha2.SplitV1alpha2Interface {
+	return &fakesplitv1alpha2.FakeSplitV1alpha2{Fake: &c.Fake}
+}
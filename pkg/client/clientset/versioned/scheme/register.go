@@ -19,9 +19,15 @@ limitations under the License.
 package scheme
 
 import (
+	ambassadorv2 "github.com/weaveworks/flagger/pkg/apis/ambassador/v2"
 	appmeshv1alpha1 "github.com/weaveworks/flagger/pkg/apis/appmesh/v1alpha1"
+	ciliumv2 "github.com/weaveworks/flagger/pkg/apis/cilium/v2"
+	externaldnsv1alpha1 "github.com/weaveworks/flagger/pkg/apis/externaldns/v1alpha1"
 	flaggerv1alpha3 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	gatewayapiv1beta1 "github.com/weaveworks/flagger/pkg/apis/gatewayapi/v1beta1"
 	networkingv1alpha3 "github.com/weaveworks/flagger/pkg/apis/istio/v1alpha3"
+	servingv1 "github.com/weaveworks/flagger/pkg/apis/knative/v1"
+	splitv1alpha2 "github.com/weaveworks/flagger/pkg/apis/smi/v1alpha2"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
@@ -52,7 +58,13 @@ func init() {
 // After this, RawExtensions in Kubernetes types will serialize kube-aggregator types
 // correctly.
 func AddToScheme(scheme *runtime.Scheme) {
+	ambassadorv2.AddToScheme(scheme)
 	appmeshv1alpha1.AddToScheme(scheme)
+	ciliumv2.AddToScheme(scheme)
+	externaldnsv1alpha1.AddToScheme(scheme)
 	flaggerv1alpha3.AddToScheme(scheme)
+	gatewayapiv1beta1.AddToScheme(scheme)
 	networkingv1alpha3.AddToScheme(scheme)
+	servingv1.AddToScheme(scheme)
+	splitv1alpha2.AddToScheme(scheme)
 }
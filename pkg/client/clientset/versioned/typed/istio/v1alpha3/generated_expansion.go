@@ -18,4 +18,8 @@ limitations under the License.
 
 package v1alpha3
 
+type DestinationRuleExpansion interface{}
+
+type ServiceEntryExpansion interface{}
+
 type VirtualServiceExpansion interface{}
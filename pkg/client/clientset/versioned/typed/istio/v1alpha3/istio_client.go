@@ -27,6 +27,8 @@ import (
 
 type NetworkingV1alpha3Interface interface {
 	RESTClient() rest.Interface
+	DestinationRulesGetter
+	ServiceEntriesGetter
 	VirtualServicesGetter
 }
 
@@ -35,6 +37,14 @@ type NetworkingV1alpha3Client struct {
 	restClient rest.Interface
 }
 
+func (c *NetworkingV1alpha3Client) DestinationRules(namespace string) DestinationRuleInterface {
+	return newDestinationRules(c, namespace)
+}
+
+func (c *NetworkingV1alpha3Client) ServiceEntries(namespace string) ServiceEntryInterface {
+	return newServiceEntries(c, namespace)
+}
+
 func (c *NetworkingV1alpha3Client) VirtualServices(namespace string) VirtualServiceInterface {
 	return newVirtualServices(c, namespace)
 }
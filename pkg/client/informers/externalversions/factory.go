@@ -24,10 +24,16 @@ import (
 	time "time"
 
 	versioned "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	ambassador "github.com/weaveworks/flagger/pkg/client/informers/externalversions/ambassador"
 	appmesh "github.com/weaveworks/flagger/pkg/client/informers/externalversions/appmesh"
+	cilium "github.com/weaveworks/flagger/pkg/client/informers/externalversions/cilium"
+	externaldns "github.com/weaveworks/flagger/pkg/client/informers/externalversions/externaldns"
 	flagger "github.com/weaveworks/flagger/pkg/client/informers/externalversions/flagger"
+	gatewayapi "github.com/weaveworks/flagger/pkg/client/informers/externalversions/gatewayapi"
 	internalinterfaces "github.com/weaveworks/flagger/pkg/client/informers/externalversions/internalinterfaces"
 	istio "github.com/weaveworks/flagger/pkg/client/informers/externalversions/istio"
+	knative "github.com/weaveworks/flagger/pkg/client/informers/externalversions/knative"
+	smi "github.com/weaveworks/flagger/pkg/client/informers/externalversions/smi"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
@@ -174,19 +180,49 @@ type SharedInformerFactory interface {
 	ForResource(resource schema.GroupVersionResource) (GenericInformer, error)
 	WaitForCacheSync(stopCh <-chan struct{}) map[reflect.Type]bool
 
+	Ambassador() ambassador.Interface
 	Appmesh() appmesh.Interface
+	Cilium() cilium.Interface
+	ExternalDNS() externaldns.Interface
 	Flagger() flagger.Interface
+	GatewayAPI() gatewayapi.Interface
 	Networking() istio.Interface
+	Serving() knative.Interface
+	Split() smi.Interface
+}
+
+func (f *sharedInformerFactory) Ambassador() ambassador.Interface {
+	return ambassador.New(f, f.namespace, f.tweakListOptions)
 }
 
 func (f *sharedInformerFactory) Appmesh() appmesh.Interface {
 	return appmesh.New(f, f.namespace, f.tweakListOptions)
 }
 
+func (f *sharedInformerFactory) Cilium() cilium.Interface {
+	return cilium.New(f, f.namespace, f.tweakListOptions)
+}
+
+func (f *sharedInformerFactory) ExternalDNS() externaldns.Interface {
+	return externaldns.New(f, f.namespace, f.tweakListOptions)
+}
+
 func (f *sharedInformerFactory) Flagger() flagger.Interface {
 	return flagger.New(f, f.namespace, f.tweakListOptions)
 }
 
+func (f *sharedInformerFactory) GatewayAPI() gatewayapi.Interface {
+	return gatewayapi.New(f, f.namespace, f.tweakListOptions)
+}
+
 func (f *sharedInformerFactory) Networking() istio.Interface {
 	return istio.New(f, f.namespace, f.tweakListOptions)
 }
+
+func (f *sharedInformerFactory) Serving() knative.Interface {
+	return knative.New(f, f.namespace, f.tweakListOptions)
+}
+
+func (f *sharedInformerFactory) Split() smi.Interface {
+	return smi.New(f, f.namespace, f.tweakListOptions)
+}
@@ -21,9 +21,15 @@ package externalversions
 import (
 	"fmt"
 
+	v2 "github.com/weaveworks/flagger/pkg/apis/ambassador/v2"
 	v1alpha1 "github.com/weaveworks/flagger/pkg/apis/appmesh/v1alpha1"
+	ciliumv2 "github.com/weaveworks/flagger/pkg/apis/cilium/v2"
+	externaldnsv1alpha1 "github.com/weaveworks/flagger/pkg/apis/externaldns/v1alpha1"
 	v1alpha3 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	v1beta1 "github.com/weaveworks/flagger/pkg/apis/gatewayapi/v1beta1"
 	istiov1alpha3 "github.com/weaveworks/flagger/pkg/apis/istio/v1alpha3"
+	v1 "github.com/weaveworks/flagger/pkg/apis/knative/v1"
+	v1alpha2 "github.com/weaveworks/flagger/pkg/apis/smi/v1alpha2"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
 	cache "k8s.io/client-go/tools/cache"
 )
@@ -62,14 +68,42 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	case v1alpha1.SchemeGroupVersion.WithResource("virtualservices"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Appmesh().V1alpha1().VirtualServices().Informer()}, nil
 
+		// Group=cilium.io, Version=v2
+	case ciliumv2.SchemeGroupVersion.WithResource("ciliumenvoyconfigs"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cilium().V2().CiliumEnvoyConfigs().Informer()}, nil
+
+		// Group=externaldns.k8s.io, Version=v1alpha1
+	case externaldnsv1alpha1.SchemeGroupVersion.WithResource("dnsendpoints"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.ExternalDNS().V1alpha1().DNSEndpoints().Informer()}, nil
+
 		// Group=flagger.app, Version=v1alpha3
 	case v1alpha3.SchemeGroupVersion.WithResource("canaries"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Flagger().V1alpha3().Canaries().Informer()}, nil
 
+		// Group=getambassador.io, Version=v2
+	case v2.SchemeGroupVersion.WithResource("mappings"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Ambassador().V2().Mappings().Informer()}, nil
+
+		// Group=gateway.networking.k8s.io, Version=v1beta1
+	case v1beta1.SchemeGroupVersion.WithResource("httproutes"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.GatewayAPI().V1beta1().HTTPRoutes().Informer()}, nil
+
 		// Group=networking.istio.io, Version=v1alpha3
+	case istiov1alpha3.SchemeGroupVersion.WithResource("destinationrules"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Networking().V1alpha3().DestinationRules().Informer()}, nil
+	case istiov1alpha3.SchemeGroupVersion.WithResource("serviceentries"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Networking().V1alpha3().ServiceEntries().Informer()}, nil
 	case istiov1alpha3.SchemeGroupVersion.WithResource("virtualservices"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Networking().V1alpha3().VirtualServices().Informer()}, nil
 
+		// Group=serving.knative.dev, Version=v1
+	case v1.SchemeGroupVersion.WithResource("services"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Serving().V1().Services().Informer()}, nil
+
+		// Group=split.smi-spec.io, Version=v1alpha2
+	case v1alpha2.SchemeGroupVersion.WithResource("trafficsplits"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Split().V1alpha2().TrafficSplits().Informer()}, nil
+
 	}
 
 	return nil, fmt.Errorf("no informer found for %v", resource)
@@ -18,6 +18,22 @@ limitations under the License.
 
 package v1alpha3
 
+// DestinationRuleListerExpansion allows custom methods to be added to
+// DestinationRuleLister.
+type DestinationRuleListerExpansion interface{}
+
+// DestinationRuleNamespaceListerExpansion allows custom methods to be added to
+// DestinationRuleNamespaceLister.
+type DestinationRuleNamespaceListerExpansion interface{}
+
+// ServiceEntryListerExpansion allows custom methods to be added to
+// ServiceEntryLister.
+type ServiceEntryListerExpansion interface{}
+
+// ServiceEntryNamespaceListerExpansion allows custom methods to be added to
+// ServiceEntryNamespaceLister.
+type ServiceEntryNamespaceListerExpansion interface{}
+
 // VirtualServiceListerExpansion allows custom methods to be added to
 // VirtualServiceLister.
 type VirtualServiceListerExpansion interface{}
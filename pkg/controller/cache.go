@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+)
+
+// MetricsCache holds the metric checks of the current canary revisions for a limited time,
+// the checks are namespaced by canary and revision so that a new revision is never
+// evaluated with the metrics of the previous one
+type MetricsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedCheck
+}
+
+type cachedCheck struct {
+	check   metricCheck
+	expires time.Time
+}
+
+// NewMetricsCache creates a cache that keeps the checks for the given duration,
+// it returns nil if the duration is zero
+func NewMetricsCache(ttl time.Duration) *MetricsCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &MetricsCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedCheck),
+	}
+}
+
+// Get returns the cached check of the canary revision if it hasn't expired
+func (m *MetricsCache) Get(cd *flaggerv1.Canary, metric flaggerv1.CanaryMetric) (metricCheck, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := metricCacheKey(cd, metric)
+	entry, ok := m.entries[key]
+	if !ok {
+		return metricCheck{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return metricCheck{}, false
+	}
+	return entry.check, true
+}
+
+// Set stores the check of the canary revision, failed and timed-out queries are not cached
+func (m *MetricsCache) Set(cd *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) {
+	if check.queryError || check.timedOut {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[metricCacheKey(cd, metric)] = cachedCheck{check: check, expires: time.Now().Add(m.ttl)}
+}
+
+// Invalidate removes the cached checks of all the canary revisions
+func (m *MetricsCache) Invalidate(cd *flaggerv1.Canary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix := fmt.Sprintf("%s.%s/", cd.Name, cd.Namespace)
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// metricCacheKey returns the canary, revision and metric key,
+// the revision is the hash of the last applied pod spec or Knative revision name
+func metricCacheKey(cd *flaggerv1.Canary, metric flaggerv1.CanaryMetric) string {
+	h := fnv.New32a()
+	h.Write([]byte(cd.Status.LastAppliedSpec))
+	return fmt.Sprintf("%s.%s/%x/%s/%s/%s", cd.Name, cd.Namespace, h.Sum32(), metric.Name, metric.Interval, metric.Query)
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+)
+
+func TestMetricsCache_KeyRevision(t *testing.T) {
+	canary := newTestCanary()
+	metric := canary.Spec.CanaryAnalysis.Metrics[0]
+
+	canary.Status.LastAppliedSpec = "rev-1"
+	key1 := metricCacheKey(canary, metric)
+	canary.Status.LastAppliedSpec = "rev-2"
+	key2 := metricCacheKey(canary, metric)
+
+	if key1 == key2 {
+		t.Errorf("Got the same key %s for different revisions", key1)
+	}
+}
+
+func TestMetricsCache_Invalidate(t *testing.T) {
+	cache := NewMetricsCache(time.Minute)
+	canary := newTestCanary()
+	canary.Status.LastAppliedSpec = "rev-1"
+	metric := v1alpha3.CanaryMetric{Name: "errors", Query: "sum(errors)"}
+
+	cache.Set(canary, metric, metricCheck{name: "errors", passed: true})
+	if _, ok := cache.Get(canary, metric); !ok {
+		t.Fatalf("Got no cached check wanted the check of the current revision")
+	}
+
+	// a new revision doesn't see the checks of the previous one
+	canary.Status.LastAppliedSpec = "rev-2"
+	if _, ok := cache.Get(canary, metric); ok {
+		t.Errorf("Got the previous revision check wanted no cached check")
+	}
+
+	canary.Status.LastAppliedSpec = "rev-1"
+	cache.Invalidate(canary)
+	if _, ok := cache.Get(canary, metric); ok {
+		t.Errorf("Got a cached check wanted the cache invalidated")
+	}
+
+	// failed queries are not cached
+	cache.Set(canary, metric, metricCheck{name: "errors", queryError: true})
+	if _, ok := cache.Get(canary, metric); ok {
+		t.Errorf("Got a cached query error wanted no cached check")
+	}
+
+	if NewMetricsCache(0) != nil {
+		t.Errorf("Got a cache wanted caching disabled")
+	}
+}
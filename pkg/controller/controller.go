@@ -2,6 +2,9 @@ package controller
 
 import (
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,9 +15,11 @@ import (
 	flaggerinformers "github.com/weaveworks/flagger/pkg/client/informers/externalversions/flagger/v1alpha3"
 	flaggerlisters "github.com/weaveworks/flagger/pkg/client/listers/flagger/v1alpha3"
 	"github.com/weaveworks/flagger/pkg/notifier"
+	"github.com/weaveworks/flagger/pkg/router"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
@@ -29,24 +34,52 @@ const controllerAgentName = "flagger"
 
 // Controller is managing the canary objects and schedules canary deployments
 type Controller struct {
-	kubeClient    kubernetes.Interface
-	istioClient   clientset.Interface
-	flaggerClient clientset.Interface
-	flaggerLister flaggerlisters.CanaryLister
-	flaggerSynced cache.InformerSynced
-	flaggerWindow time.Duration
-	workqueue     workqueue.RateLimitingInterface
-	eventRecorder record.EventRecorder
-	logger        *zap.SugaredLogger
-	canaries      *sync.Map
-	jobs          map[string]CanaryJob
-	deployer      CanaryDeployer
-	observer      CanaryObserver
-	recorder      CanaryRecorder
-	notifier      *notifier.Slack
-	meshProvider  string
+	kubeClient      kubernetes.Interface
+	istioClient     clientset.Interface
+	flaggerClient   clientset.Interface
+	flaggerLister   flaggerlisters.CanaryLister
+	flaggerSynced   cache.InformerSynced
+	flaggerWindow   time.Duration
+	workqueue       workqueue.RateLimitingInterface
+	eventRecorder   record.EventRecorder
+	logger          *zap.SugaredLogger
+	canaries        *sync.Map
+	analysis        *sync.Map
+	jobs            map[string]CanaryJob
+	deployer        CanaryDeployer
+	knativeDeployer KnativeDeployer
+	observer        CanaryObserver
+	logsObserver    LogsObserver
+	sloObserver     SLOObserver
+	silencer        Silencer
+	recorder        CanaryRecorder
+	notifier        notifier.Interface
+	meshProvider    string
+	remoteClusters  []router.RemoteCluster
+	// maximum age of the latest metric sample, zero disables the freshness check
+	metricsStaleness time.Duration
+	// metric checks of the current canary revisions, nil if caching is disabled
+	metricsCache *MetricsCache
+	// client used by the webhooks without a TLS secret, defaults to http.DefaultClient
+	webhookClient *http.Client
+	// minimum type of the recorded Kubernetes events, the warning verbosity
+	// logs the routine progress without recording it as events
+	eventVerbosity string
+	// holds all canaries at their current weight while engaged, disabled if the ConfigMap is not set
+	killSwitch KillSwitch
+	// metrics injected in the analysis of the canaries without metrics, disabled if the telemetry is not set
+	defaultMetrics DefaultMetrics
+	// telemetry annotations of the target services, resolved once per analysis interval
+	serviceTelemetry *sync.Map
 }
 
+const (
+	// EventVerbosityInfo records the progress, warning and error events
+	EventVerbosityInfo = "info"
+	// EventVerbosityWarning records only the warning and error events
+	EventVerbosityWarning = "warning"
+)
+
 func NewController(
 	kubeClient kubernetes.Interface,
 	istioClient clientset.Interface,
@@ -54,10 +87,28 @@ func NewController(
 	flaggerInformer flaggerinformers.CanaryInformer,
 	flaggerWindow time.Duration,
 	metricServer string,
+	metricsQueryConcurrency int,
+	metricsQueryTimeout time.Duration,
+	metricsQueryRetries int,
+	metricsQueryBackoff time.Duration,
+	metricsClient *http.Client,
+	metricsProvider string,
+	metricsStaleness time.Duration,
+	metricsCacheTTL time.Duration,
+	metricsRemoteReadURL string,
+	metricsRetention time.Duration,
+	istioTelemetry string,
+	logsServer string,
+	sloServer string,
+	alertmanagerURL string,
 	logger *zap.SugaredLogger,
-	notifier *notifier.Slack,
+	notifier notifier.Interface,
 	meshProvider string,
-
+	remoteClusters []router.RemoteCluster,
+	webhookClient *http.Client,
+	eventVerbosity string,
+	killSwitch KillSwitch,
+	defaultMetrics DefaultMetrics,
 ) *Controller {
 	logger.Debug("Creating event broadcaster")
 	flaggerscheme.AddToScheme(scheme.Scheme)
@@ -80,29 +131,52 @@ func NewController(
 		},
 	}
 
-	observer := CanaryObserver{
-		metricsServer: metricServer,
+	knativeDeployer := KnativeDeployer{
+		CanaryDeployer: deployer,
 	}
 
+	observer := NewCanaryObserver(metricServer, metricsQueryConcurrency, metricsClient)
+	observer.federated = metricsProvider == "thanos"
+	observer.metricsQL = metricsProvider == "victoriametrics"
+	observer.remoteReadURL = metricsRemoteReadURL
+	observer.retention = metricsRetention
+	observer.queryTimeout = metricsQueryTimeout
+	observer.queryRetries = metricsQueryRetries
+	observer.queryBackoff = metricsQueryBackoff
+	observer.istioTelemetry = istioTelemetry
+
 	recorder := NewCanaryRecorder(true)
 
 	ctrl := &Controller{
-		kubeClient:    kubeClient,
-		istioClient:   istioClient,
-		flaggerClient: flaggerClient,
-		flaggerLister: flaggerInformer.Lister(),
-		flaggerSynced: flaggerInformer.Informer().HasSynced,
-		workqueue:     workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerAgentName),
-		eventRecorder: eventRecorder,
-		logger:        logger,
-		canaries:      new(sync.Map),
-		jobs:          map[string]CanaryJob{},
-		flaggerWindow: flaggerWindow,
-		deployer:      deployer,
-		observer:      observer,
-		recorder:      recorder,
-		notifier:      notifier,
-		meshProvider:  meshProvider,
+		kubeClient:       kubeClient,
+		istioClient:      istioClient,
+		flaggerClient:    flaggerClient,
+		flaggerLister:    flaggerInformer.Lister(),
+		flaggerSynced:    flaggerInformer.Informer().HasSynced,
+		workqueue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerAgentName),
+		eventRecorder:    eventRecorder,
+		logger:           logger,
+		canaries:         new(sync.Map),
+		analysis:         new(sync.Map),
+		serviceTelemetry: new(sync.Map),
+		jobs:             map[string]CanaryJob{},
+		flaggerWindow:    flaggerWindow,
+		deployer:         deployer,
+		knativeDeployer:  knativeDeployer,
+		observer:         observer,
+		logsObserver:     NewLogsObserver(logsServer),
+		sloObserver:      NewSLOObserver(sloServer),
+		silencer:         NewSilencer(alertmanagerURL),
+		recorder:         recorder,
+		notifier:         notifier,
+		meshProvider:     meshProvider,
+		remoteClusters:   remoteClusters,
+		metricsStaleness: metricsStaleness,
+		metricsCache:     NewMetricsCache(metricsCacheTTL),
+		webhookClient:    webhookClient,
+		eventVerbosity:   eventVerbosity,
+		killSwitch:       killSwitch,
+		defaultMetrics:   defaultMetrics,
 	}
 
 	flaggerInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -120,6 +194,28 @@ func NewController(
 			if diff := cmp.Diff(newRoll.Spec, oldRoll.Spec); diff != "" {
 				ctrl.logger.Debugf("Diff detected %s.%s %s", oldRoll.Name, oldRoll.Namespace, diff)
 				ctrl.enqueue(new)
+				return
+			}
+
+			// enqueue the deletion so that the teardown hooks get called
+			if newRoll.DeletionTimestamp != nil && oldRoll.DeletionTimestamp == nil {
+				ctrl.enqueue(new)
+				return
+			}
+
+			// enqueue interval overrides so that the job ticker gets reset
+			oldInterval := oldRoll.Annotations[flaggerv1.AnalysisIntervalAnnotation]
+			newInterval := newRoll.Annotations[flaggerv1.AnalysisIntervalAnnotation]
+			if oldInterval != newInterval {
+				ctrl.logger.Debugf("Analysis interval override changed %s.%s %s", newRoll.Name, newRoll.Namespace, newInterval)
+				ctrl.enqueue(new)
+			}
+
+			// enqueue the adaptive interval changes so that the job ticker gets reset
+			if oldRoll.Status.AnalysisInterval != newRoll.Status.AnalysisInterval {
+				ctrl.logger.Debugf("Adaptive analysis interval changed %s.%s %s",
+					newRoll.Name, newRoll.Namespace, newRoll.Status.AnalysisInterval)
+				ctrl.enqueue(new)
 			}
 		},
 		DeleteFunc: func(old interface{}) {
@@ -209,6 +305,20 @@ func (c *Controller) syncHandler(key string) error {
 		return nil
 	}
 
+	// run the teardown hooks of a deleted canary and stop its analysis
+	if cd.DeletionTimestamp != nil {
+		c.canaries.Delete(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace))
+		if err := c.finalize(cd); err != nil {
+			c.workqueue.AddRateLimited(key)
+			return err
+		}
+		return nil
+	}
+
+	if err := c.syncFinalizer(cd); err != nil {
+		return err
+	}
+
 	c.canaries.Store(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace), cd)
 
 	//if cd.Spec.TargetRef.Kind == "Deployment" {
@@ -244,56 +354,145 @@ func checkCustomResourceType(obj interface{}, logger *zap.SugaredLogger) (flagge
 	return *roll, true
 }
 
+// getDeployer returns the deployer for the canary target kind
+func (c *Controller) getDeployer(cd *flaggerv1.Canary) Deployer {
+	if cd.IsKnativeService() {
+		return &c.knativeDeployer
+	}
+	return &c.deployer
+}
+
 func (c *Controller) recordEventInfof(r *flaggerv1.Canary, template string, args ...interface{}) {
 	c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Infof(template, args...)
-	c.eventRecorder.Event(r, corev1.EventTypeNormal, "Synced", fmt.Sprintf(template, args...))
+	if c.eventVerbosity == EventVerbosityWarning {
+		return
+	}
+	c.recordEvent(r, corev1.EventTypeNormal, fmt.Sprintf(template, args...))
 }
 
 func (c *Controller) recordEventErrorf(r *flaggerv1.Canary, template string, args ...interface{}) {
 	c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Errorf(template, args...)
-	c.eventRecorder.Event(r, corev1.EventTypeWarning, "Synced", fmt.Sprintf(template, args...))
+	c.recordEvent(r, corev1.EventTypeWarning, fmt.Sprintf(template, args...))
 }
 
 func (c *Controller) recordEventWarningf(r *flaggerv1.Canary, template string, args ...interface{}) {
 	c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Infof(template, args...)
-	c.eventRecorder.Event(r, corev1.EventTypeWarning, "Synced", fmt.Sprintf(template, args...))
+	c.recordEvent(r, corev1.EventTypeWarning, fmt.Sprintf(template, args...))
 }
 
-func (c *Controller) sendNotification(cd *flaggerv1.Canary, message string, metadata bool, warn bool) {
+// recordEvent records the event annotated with the rollout ID of the analysis run
+func (c *Controller) recordEvent(r *flaggerv1.Canary, eventType string, message string) {
+	if r.Status.RolloutID == "" {
+		c.eventRecorder.Event(r, eventType, "Synced", message)
+		return
+	}
+	annotations := map[string]string{flaggerv1.RolloutIDAnnotation: r.Status.RolloutID}
+	c.eventRecorder.AnnotatedEventf(r, annotations, eventType, "Synced", "%s", message)
+}
+
+// recordAdvanceEvent records the advancement event annotated with the weights and iteration
+func (c *Controller) recordAdvanceEvent(r *flaggerv1.Canary, advance flaggerv1.CanaryAdvance) {
+	annotations := map[string]string{
+		flaggerv1.CanaryWeightAnnotation: strconv.Itoa(advance.CanaryWeight),
+		flaggerv1.TargetWeightAnnotation: strconv.Itoa(advance.TargetWeight),
+		flaggerv1.StepWeightAnnotation:   strconv.Itoa(advance.StepWeight),
+		flaggerv1.IterationAnnotation:    strconv.Itoa(advance.Iteration),
+	}
+	if r.Status.RolloutID != "" {
+		annotations[flaggerv1.RolloutIDAnnotation] = r.Status.RolloutID
+	}
+	c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).Info(advance.Message)
+	if c.eventVerbosity == EventVerbosityWarning {
+		return
+	}
+	c.eventRecorder.AnnotatedEventf(r, annotations, corev1.EventTypeNormal, "Synced", "%s", advance.Message)
+}
+
+func (c *Controller) sendNotification(cd *flaggerv1.Canary, message string, metadata bool, severity notifier.Severity) {
 	if c.notifier == nil {
 		return
 	}
 
-	var fields []notifier.SlackField
+	var fields []notifier.Field
 
 	if metadata {
 		fields = append(fields,
-			notifier.SlackField{
-				Title: "Target",
+			notifier.Field{
+				Name:  "Target",
 				Value: fmt.Sprintf("%s/%s.%s", cd.Spec.TargetRef.Kind, cd.Spec.TargetRef.Name, cd.Namespace),
 			},
-			notifier.SlackField{
-				Title: "Traffic routing",
+			notifier.Field{
+				Name: "Traffic routing",
 				Value: fmt.Sprintf("Weight step: %v max: %v",
 					cd.Spec.CanaryAnalysis.StepWeight,
 					cd.Spec.CanaryAnalysis.MaxWeight),
 			},
-			notifier.SlackField{
-				Title: "Failed checks threshold",
+			notifier.Field{
+				Name:  "Failed checks threshold",
 				Value: fmt.Sprintf("%v", cd.Spec.CanaryAnalysis.Threshold),
 			},
-			notifier.SlackField{
-				Title: "Progress deadline",
+			notifier.Field{
+				Name:  "Progress deadline",
 				Value: fmt.Sprintf("%vs", cd.GetProgressDeadlineSeconds()),
 			},
 		)
 	}
-	err := c.notifier.Post(cd.Name, cd.Namespace, message, fields, warn)
+	if cd.Status.RolloutID != "" {
+		fields = append(fields,
+			notifier.Field{
+				Name:  "Rollout ID",
+				Value: cd.Status.RolloutID,
+			},
+		)
+	}
+	// attach the metric values of the last analysis
+	if result, ok := c.analysis.Load(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)); ok {
+		if summary := result.(analysisResult).Summary(); summary != "" {
+			fields = append(fields,
+				notifier.Field{
+					Name:  "Last analysis",
+					Value: summary,
+				},
+			)
+		}
+	}
+
+	// attach the canary release version to the rollback notifications
+	if severity == notifier.SeverityError {
+		if release := c.canaryRelease(cd); release != "" {
+			fields = append(fields,
+				notifier.Field{
+					Name:  notifier.ReleaseField,
+					Value: release,
+				},
+			)
+		}
+	}
+
+	err := c.notifier.Post(cd.Name, cd.Namespace, message, fields, severity)
 	if err != nil {
 		c.logger.Error(err)
 	}
 }
 
+// canaryRelease returns the Knative revision or the container images of the canary deployment
+func (c *Controller) canaryRelease(cd *flaggerv1.Canary) string {
+	if cd.IsKnativeService() {
+		return cd.Status.LastAppliedSpec
+	}
+
+	dep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(cd.Spec.TargetRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+
+	var images []string
+	for _, container := range dep.Spec.Template.Spec.Containers {
+		images = append(images, container.Image)
+	}
+	return strings.Join(images, ",")
+}
+
 func int32p(i int32) *int32 {
 	return &i
 }
@@ -82,19 +82,26 @@ func SetupMocks(abtest bool) Mocks {
 	flaggerInformer := flaggerInformerFactory.Flagger().V1alpha3().Canaries()
 
 	ctrl := &Controller{
-		kubeClient:    kubeClient,
-		istioClient:   flaggerClient,
-		flaggerClient: flaggerClient,
-		flaggerLister: flaggerInformer.Lister(),
-		flaggerSynced: flaggerInformer.Informer().HasSynced,
-		workqueue:     workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerAgentName),
-		eventRecorder: &record.FakeRecorder{},
-		logger:        logger,
-		canaries:      new(sync.Map),
-		flaggerWindow: time.Second,
-		deployer:      deployer,
-		observer:      observer,
-		recorder:      NewCanaryRecorder(false),
+		kubeClient:       kubeClient,
+		istioClient:      flaggerClient,
+		flaggerClient:    flaggerClient,
+		flaggerLister:    flaggerInformer.Lister(),
+		flaggerSynced:    flaggerInformer.Informer().HasSynced,
+		workqueue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerAgentName),
+		eventRecorder:    &record.FakeRecorder{},
+		logger:           logger,
+		canaries:         new(sync.Map),
+		analysis:         new(sync.Map),
+		serviceTelemetry: new(sync.Map),
+		flaggerWindow:    time.Second,
+		deployer:         deployer,
+		knativeDeployer: KnativeDeployer{
+			CanaryDeployer: deployer,
+		},
+		observer:     observer,
+		logsObserver: NewLogsObserver("fake"),
+		sloObserver:  NewSLOObserver("fake"),
+		recorder:     NewCanaryRecorder(false),
 	}
 	ctrl.flaggerSynced = alwaysReady
 
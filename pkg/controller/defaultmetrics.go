@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultMetrics holds the success rate and request duration checks injected
+// in the analysis of the canaries that don't declare any metric
+type DefaultMetrics struct {
+	// telemetry of the services without the telemetry annotation, empty disables the defaults
+	telemetry string
+	// minimum request success rate percentage
+	successRate float64
+	// maximum request duration P99 in milliseconds
+	requestDuration float64
+	interval        string
+}
+
+// NewDefaultMetrics creates the default metrics of the telemetry (istio or envoy),
+// an empty telemetry injects the metrics only in the canaries whose service is annotated with its telemetry
+func NewDefaultMetrics(telemetry string, successRate float64, requestDuration float64, interval string) (DefaultMetrics, error) {
+	dm := DefaultMetrics{
+		telemetry:       telemetry,
+		successRate:     successRate,
+		requestDuration: requestDuration,
+		interval:        interval,
+	}
+	if telemetry != "" && telemetry != "istio" && telemetry != "envoy" {
+		return dm, fmt.Errorf("invalid default metrics telemetry %s, expected istio or envoy", telemetry)
+	}
+	if successRate < 0 || successRate > 100 {
+		return dm, fmt.Errorf("invalid default success rate %v, expected a percentage (0-100)", successRate)
+	}
+	if requestDuration < 0 {
+		return dm, fmt.Errorf("invalid default request duration %v, expected a positive value", requestDuration)
+	}
+	if _, err := time.ParseDuration(interval); err != nil {
+		return dm, fmt.Errorf("invalid default metrics interval %s %v", interval, err)
+	}
+	return dm, nil
+}
+
+// metricsFor returns the default metrics of the telemetry declared by the target service annotation,
+// the canary annotation overrides the service one, either overrides the cluster-wide telemetry and none disables the defaults
+func (dm DefaultMetrics) metricsFor(cd *flaggerv1.Canary, serviceTelemetry string) []flaggerv1.CanaryMetric {
+	telemetry := dm.telemetry
+	if serviceTelemetry != "" {
+		telemetry = serviceTelemetry
+	}
+	if value, ok := cd.Annotations[flaggerv1.TelemetryAnnotation]; ok {
+		telemetry = value
+	}
+
+	interval := dm.interval
+	if interval == "" {
+		interval = flaggerv1.MetricInterval
+	}
+
+	var metrics []flaggerv1.CanaryMetric
+	switch telemetry {
+	case "istio":
+		if dm.successRate > 0 {
+			metrics = append(metrics, flaggerv1.CanaryMetric{
+				Name:      "istio_requests_total",
+				Interval:  interval,
+				Threshold: dm.successRate,
+			})
+		}
+		if dm.requestDuration > 0 {
+			metrics = append(metrics, flaggerv1.CanaryMetric{
+				Name:      "istio_request_duration_seconds_bucket",
+				Interval:  interval,
+				Threshold: dm.requestDuration,
+			})
+		}
+	case "envoy":
+		// the request duration isn't a built-in metric of the envoy telemetry
+		if dm.successRate > 0 {
+			metrics = append(metrics, flaggerv1.CanaryMetric{
+				Name:      "envoy_cluster_upstream_rq",
+				Interval:  interval,
+				Threshold: dm.successRate,
+			})
+		}
+	}
+	return metrics
+}
+
+// analysisMetrics returns the metrics of the current stage or the analysis,
+// the default metrics are used if the canary declares none
+func (c *Controller) analysisMetrics(cd *flaggerv1.Canary) []flaggerv1.CanaryMetric {
+	// the built-in metrics don't match the pods of the Knative revisions
+	if metrics := cd.GetMetrics(); len(metrics) > 0 || cd.IsKnativeService() {
+		return metrics
+	}
+
+	var telemetry string
+	if value, ok := c.serviceTelemetry.Load(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)); ok {
+		telemetry = value.(string)
+	}
+	return c.defaultMetrics.metricsFor(cd, telemetry)
+}
+
+// resolveServiceTelemetry stores the telemetry annotation of the target service used by the default metrics,
+// an invalid annotation is reported and the cluster-wide telemetry is used instead
+func (c *Controller) resolveServiceTelemetry(cd *flaggerv1.Canary) {
+	key := fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)
+	c.serviceTelemetry.Delete(key)
+	if _, ok := cd.Annotations[flaggerv1.TelemetryAnnotation]; ok || len(cd.GetMetrics()) > 0 || cd.IsKnativeService() {
+		return
+	}
+
+	// the target service may not exist yet, the cluster-wide telemetry applies
+	svc, err := c.kubeClient.CoreV1().Services(cd.Namespace).Get(cd.Spec.TargetRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+	value := svc.Annotations[flaggerv1.TelemetryAnnotation]
+	if !validTelemetry(value) {
+		c.recordEventWarningf(cd, "Service %s.%s annotation %s must be istio, envoy or none, using the cluster-wide telemetry",
+			svc.Name, svc.Namespace, flaggerv1.TelemetryAnnotation)
+		return
+	}
+	if value != "" {
+		c.serviceTelemetry.Store(key, value)
+	}
+}
+
+// validTelemetry returns true if the telemetry annotation value is empty or known
+func validTelemetry(value string) bool {
+	switch value {
+	case "", "istio", "envoy", "none":
+		return true
+	}
+	return false
+}
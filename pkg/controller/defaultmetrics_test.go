@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestController_DefaultMetrics(t *testing.T) {
+	if _, err := NewDefaultMetrics("linkerd", 99, 500, "1m"); err == nil {
+		t.Errorf("Got no error wanted invalid telemetry")
+	}
+
+	mocks := SetupMocks(false)
+	dm, err := NewDefaultMetrics("istio", 99, 500, "30s")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.defaultMetrics = dm
+
+	// the declared metrics take precedence
+	if metrics := mocks.ctrl.analysisMetrics(mocks.canary); len(metrics) != len(mocks.canary.Spec.CanaryAnalysis.Metrics) {
+		t.Errorf("Got %v metrics wanted the %v declared metrics", len(metrics), len(mocks.canary.Spec.CanaryAnalysis.Metrics))
+	}
+
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.CanaryAnalysis.Metrics = nil
+	metrics := mocks.ctrl.analysisMetrics(cd)
+	if len(metrics) != 2 || metrics[0].Name != "istio_requests_total" || metrics[0].Threshold != 99 ||
+		metrics[1].Name != "istio_request_duration_seconds_bucket" || metrics[1].Threshold != 500 {
+		t.Errorf("Got metrics %+v wanted the istio success rate and request duration", metrics)
+	}
+	if metrics[0].Interval != "30s" {
+		t.Errorf("Got interval %s wanted %s", metrics[0].Interval, "30s")
+	}
+
+	// the target service annotation overrides the cluster-wide telemetry
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cd.Spec.TargetRef.Name,
+			Namespace:   cd.Namespace,
+			Annotations: map[string]string{v1alpha3.TelemetryAnnotation: "envoy"},
+		},
+	}
+	if _, err := mocks.kubeClient.CoreV1().Services(cd.Namespace).Create(svc); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.resolveServiceTelemetry(cd)
+	metrics = mocks.ctrl.analysisMetrics(cd)
+	if len(metrics) != 1 || metrics[0].Name != "envoy_cluster_upstream_rq" {
+		t.Errorf("Got metrics %+v wanted the envoy success rate", metrics)
+	}
+
+	// the service is read once per interval
+	fakeClient := mocks.kubeClient.(*fake.Clientset)
+	fakeClient.ClearActions()
+	mocks.ctrl.analysisMetrics(cd)
+	mocks.ctrl.analysisMetrics(cd)
+	if actions := fakeClient.Actions(); len(actions) != 0 {
+		t.Errorf("Got %v API calls wanted none", len(actions))
+	}
+
+	// an invalid service annotation falls back to the cluster-wide telemetry
+	svc.Annotations[v1alpha3.TelemetryAnnotation] = "linkerd"
+	if _, err := mocks.kubeClient.CoreV1().Services(cd.Namespace).Update(svc); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.resolveServiceTelemetry(cd)
+	metrics = mocks.ctrl.analysisMetrics(cd)
+	if len(metrics) != 2 || metrics[0].Name != "istio_requests_total" {
+		t.Errorf("Got metrics %+v wanted the istio success rate and request duration", metrics)
+	}
+	svc.Annotations[v1alpha3.TelemetryAnnotation] = "envoy"
+	if _, err := mocks.kubeClient.CoreV1().Services(cd.Namespace).Update(svc); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.resolveServiceTelemetry(cd)
+
+	// the canary annotation overrides the service telemetry
+	cd.Annotations = map[string]string{v1alpha3.TelemetryAnnotation: "istio"}
+	metrics = mocks.ctrl.analysisMetrics(cd)
+	if len(metrics) != 2 || metrics[0].Name != "istio_requests_total" {
+		t.Errorf("Got metrics %+v wanted the istio success rate and request duration", metrics)
+	}
+
+	cd.Annotations[v1alpha3.TelemetryAnnotation] = "none"
+	if metrics := mocks.ctrl.analysisMetrics(cd); len(metrics) != 0 {
+		t.Errorf("Got metrics %+v wanted none", metrics)
+	}
+
+	cd.Annotations[v1alpha3.TelemetryAnnotation] = "linkerd"
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted invalid telemetry annotation")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
@@ -20,9 +22,37 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 )
 
+// Deployer is managing the primary and canary workloads of a canary target kind
+type Deployer interface {
+	Sync(cd *flaggerv1.Canary) error
+	AdoptPrimary(cd *flaggerv1.Canary) (bool, error)
+	IsPrimaryReady(cd *flaggerv1.Canary) (bool, error)
+	IsCanaryReady(cd *flaggerv1.Canary) (bool, error)
+	ShouldAdvance(cd *flaggerv1.Canary) (bool, error)
+	HasTargetChanged(cd *flaggerv1.Canary) (bool, error)
+	GetTargetDrift(cd *flaggerv1.Canary) ([]string, error)
+	Promote(cd *flaggerv1.Canary) error
+	Scale(cd *flaggerv1.Canary, replicas int32) error
+	SyncStatus(cd *flaggerv1.Canary, status flaggerv1.CanaryStatus) error
+	SetStatusFailedChecks(cd *flaggerv1.Canary, val int) error
+	SetStatusHaltedIntervals(cd *flaggerv1.Canary, val int) error
+	SetStatusConsecutivePasses(cd *flaggerv1.Canary, val int) error
+	SetStatusStepRequests(cd *flaggerv1.Canary, val int) error
+	SetStatusWeight(cd *flaggerv1.Canary, val int) error
+	SetStatusIterations(cd *flaggerv1.Canary, val int) error
+	SetStatusPhase(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase) error
+	SetStatusWarmupStartTime(cd *flaggerv1.Canary, val metav1.Time) error
+	SetStatusWindowSuspendTime(cd *flaggerv1.Canary, val metav1.Time) error
+	SetStatusVerificationStartTime(cd *flaggerv1.Canary, val metav1.Time) error
+	SetStatusPrimaryWarmupStartTime(cd *flaggerv1.Canary, val metav1.Time) error
+	SetStatusApprovals(cd *flaggerv1.Canary, val map[string]string) error
+	RestorePrimary(cd *flaggerv1.Canary) (bool, error)
+}
+
 // CanaryDeployer is managing the operations for Kubernetes deployment kind
 type CanaryDeployer struct {
 	kubeClient    kubernetes.Interface
@@ -61,12 +91,26 @@ func (c *CanaryDeployer) Promote(cd *flaggerv1.Canary) error {
 		return err
 	}
 
+	// the promotion verification restores the snapshot when the promoted primary fails
+	if cd.Spec.CanaryAnalysis.RollbackPrimary || cd.Spec.CanaryAnalysis.PromotionVerification != nil {
+		if err := c.snapshotPrimary(cd, primary); err != nil {
+			return err
+		}
+	}
+
 	primaryCopy := primary.DeepCopy()
 	primaryCopy.Spec.ProgressDeadlineSeconds = canary.Spec.ProgressDeadlineSeconds
 	primaryCopy.Spec.MinReadySeconds = canary.Spec.MinReadySeconds
 	primaryCopy.Spec.RevisionHistoryLimit = canary.Spec.RevisionHistoryLimit
 	primaryCopy.Spec.Strategy = canary.Spec.Strategy
 
+	// without an autoscaler the primary runs at the scale the canary was validated at,
+	// a primary held at zero replicas until the first promotion is scaled up in any case
+	heldBack := primary.Spec.Replicas != nil && *primary.Spec.Replicas == 0
+	if (cd.Spec.AutoscalerRef == nil || heldBack) && canary.Spec.Replicas != nil && *canary.Spec.Replicas > 0 {
+		primaryCopy.Spec.Replicas = int32p(*canary.Spec.Replicas)
+	}
+
 	// update spec with primary secrets and config maps
 	primaryCopy.Spec.Template.Spec = c.configTracker.ApplyPrimaryConfigs(canary.Spec.Template.Spec, configRefs)
 
@@ -88,6 +132,81 @@ func (c *CanaryDeployer) Promote(cd *flaggerv1.Canary) error {
 	return nil
 }
 
+// snapshotPrimary stores the primary pod template in the <primary>-snapshot config map,
+// the snapshot is replaced on every promotion
+func (c *CanaryDeployer) snapshotPrimary(cd *flaggerv1.Canary, primary *appsv1.Deployment) error {
+	templateJson, err := json.Marshal(primary.Spec.Template)
+	if err != nil {
+		return fmt.Errorf("deployment %s.%s template marshal error %v", primary.Name, primary.Namespace, err)
+	}
+
+	snapshot := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-snapshot", primary.Name),
+			Namespace: cd.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+					Group:   flaggerv1.SchemeGroupVersion.Group,
+					Version: flaggerv1.SchemeGroupVersion.Version,
+					Kind:    flaggerv1.CanaryKind,
+				}),
+			},
+		},
+		Data: map[string]string{
+			"template": string(templateJson),
+		},
+	}
+
+	_, err = c.kubeClient.CoreV1().ConfigMaps(cd.Namespace).Update(snapshot)
+	if errors.IsNotFound(err) {
+		_, err = c.kubeClient.CoreV1().ConfigMaps(cd.Namespace).Create(snapshot)
+	}
+	if err != nil {
+		return fmt.Errorf("config map %s.%s snapshot error %v", snapshot.Name, cd.Namespace, err)
+	}
+	return nil
+}
+
+// RestorePrimary applies the pod template snapshotted before the last promotion to the primary
+// and deletes the snapshot, it returns false if there is no snapshot to restore
+func (c *CanaryDeployer) RestorePrimary(cd *flaggerv1.Canary) (bool, error) {
+	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	snapshotName := fmt.Sprintf("%s-snapshot", primaryName)
+
+	snapshot, err := c.kubeClient.CoreV1().ConfigMaps(cd.Namespace).Get(snapshotName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("config map %s.%s query error %v", snapshotName, cd.Namespace, err)
+	}
+
+	template := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal([]byte(snapshot.Data["template"]), &template); err != nil {
+		return false, fmt.Errorf("config map %s.%s unmarshal error %v", snapshotName, cd.Namespace, err)
+	}
+
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(primaryName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, fmt.Errorf("deployment %s.%s not found", primaryName, cd.Namespace)
+		}
+		return false, fmt.Errorf("deployment %s.%s query error %v", primaryName, cd.Namespace, err)
+	}
+
+	primaryCopy := primary.DeepCopy()
+	primaryCopy.Spec.Template = template
+	if _, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(primaryCopy); err != nil {
+		return false, fmt.Errorf("restoring deployment %s.%s template spec failed: %v", primaryName, cd.Namespace, err)
+	}
+
+	// the snapshot is restored once, a second failure keeps the restored primary
+	if err := c.kubeClient.CoreV1().ConfigMaps(cd.Namespace).Delete(snapshotName, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return true, fmt.Errorf("config map %s.%s delete error %v", snapshotName, cd.Namespace, err)
+	}
+	return true, nil
+}
+
 // IsPrimaryReady checks the primary deployment status and returns an error if
 // the deployment is in the middle of a rolling update or if the pods are unhealthy
 // it will return a non retriable error if the rolling update is stuck
@@ -136,6 +255,21 @@ func (c *CanaryDeployer) IsCanaryReady(cd *flaggerv1.Canary) (bool, error) {
 		}
 	}
 
+	// the analysis compares the canary to the baseline, both must be ready
+	if cd.Spec.CanaryAnalysis.Baseline {
+		baselineName := fmt.Sprintf("%s-baseline", targetName)
+		baseline, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(baselineName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return true, fmt.Errorf("deployment %s.%s not found", baselineName, cd.Namespace)
+			}
+			return true, fmt.Errorf("deployment %s.%s query error %v", baselineName, cd.Namespace, err)
+		}
+		if _, err := c.isDeploymentReady(baseline, cd.GetProgressDeadlineSeconds()); err != nil {
+			return true, fmt.Errorf("Halt advancement %s.%s %s", baselineName, cd.Namespace, err.Error())
+		}
+	}
+
 	return true, nil
 }
 
@@ -155,22 +289,97 @@ func (c *CanaryDeployer) IsNewSpec(cd *flaggerv1.Canary) (bool, error) {
 	}
 
 	newSpec := &canary.Spec.Template.Spec
+	oldSpec, err := lastAppliedSpec(cd)
+	if err != nil {
+		return false, err
+	}
+
+	if diff := cmp.Diff(*newSpec, *oldSpec, cmpopts.IgnoreUnexported(resource.Quantity{})); diff != "" {
+		//fmt.Println(diff)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// GetTargetDrift returns the pod spec fields and the config maps and secrets
+// of the canary deployment that changed since the last sync
+func (c *CanaryDeployer) GetTargetDrift(cd *flaggerv1.Canary) ([]string, error) {
+	var drift []string
+	if cd.Status.LastAppliedSpec != "" {
+		targetName := cd.Spec.TargetRef.Name
+		canary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(targetName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil, fmt.Errorf("deployment %s.%s not found", targetName, cd.Namespace)
+			}
+			return nil, fmt.Errorf("deployment %s.%s query error %v", targetName, cd.Namespace, err)
+		}
+		oldSpec, err := lastAppliedSpec(cd)
+		if err != nil {
+			return nil, err
+		}
+		drift = specDrift(canary.Spec.Template.Spec, *oldSpec)
+	}
+
+	configDrift, err := c.configTracker.GetConfigDrift(cd)
+	if err != nil {
+		return nil, err
+	}
+	return append(drift, configDrift...), nil
+}
+
+// lastAppliedSpec decodes the pod spec stored in the canary status on the last sync
+func lastAppliedSpec(cd *flaggerv1.Canary) (*corev1.PodSpec, error) {
 	oldSpecJson, err := base64.StdEncoding.DecodeString(cd.Status.LastAppliedSpec)
 	if err != nil {
-		return false, fmt.Errorf("%s.%s decode error %v", cd.Name, cd.Namespace, err)
+		return nil, fmt.Errorf("%s.%s decode error %v", cd.Name, cd.Namespace, err)
 	}
 	oldSpec := &corev1.PodSpec{}
 	err = json.Unmarshal(oldSpecJson, oldSpec)
 	if err != nil {
-		return false, fmt.Errorf("%s.%s unmarshal error %v", cd.Name, cd.Namespace, err)
+		return nil, fmt.Errorf("%s.%s unmarshal error %v", cd.Name, cd.Namespace, err)
 	}
+	return oldSpec, nil
+}
 
-	if diff := cmp.Diff(*newSpec, *oldSpec, cmpopts.IgnoreUnexported(resource.Quantity{})); diff != "" {
-		//fmt.Println(diff)
-		return true, nil
+// specDrift returns the pod spec fields that differ between two specs,
+// the container fields are reported per container e.g. containers[podinfo].image
+func specDrift(newSpec corev1.PodSpec, oldSpec corev1.PodSpec) []string {
+	var drift []string
+	oldContainers := make(map[string]corev1.Container)
+	for _, container := range oldSpec.Containers {
+		oldContainers[container.Name] = container
+	}
+	for _, container := range newSpec.Containers {
+		old, ok := oldContainers[container.Name]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("containers[%s] added", container.Name))
+			continue
+		}
+		delete(oldContainers, container.Name)
+		drift = append(drift, fieldsDrift(fmt.Sprintf("containers[%s].", container.Name), container, old)...)
+	}
+	for name := range oldContainers {
+		drift = append(drift, fmt.Sprintf("containers[%s] removed", name))
 	}
 
-	return false, nil
+	newSpec.Containers, oldSpec.Containers = nil, nil
+	return append(drift, fieldsDrift("", newSpec, oldSpec)...)
+}
+
+// fieldsDrift returns the JSON names of the struct fields that differ between two objects of the same type
+func fieldsDrift(prefix string, newObj interface{}, oldObj interface{}) []string {
+	var drift []string
+	newValue, oldValue := reflect.ValueOf(newObj), reflect.ValueOf(oldObj)
+	for i := 0; i < newValue.NumField(); i++ {
+		if cmp.Equal(newValue.Field(i).Interface(), oldValue.Field(i).Interface(), cmpopts.IgnoreUnexported(resource.Quantity{})) {
+			continue
+		}
+		name := strings.Split(newValue.Type().Field(i).Tag.Get("json"), ",")[0]
+		drift = append(drift, prefix+name)
+	}
+	return drift
 }
 
 // ShouldAdvance determines if the canary analysis can proceed
@@ -196,10 +405,23 @@ func (c *CanaryDeployer) ShouldAdvance(cd *flaggerv1.Canary) (bool, error) {
 
 }
 
-// SetStatusFailedChecks updates the canary failed checks counter
+// HasTargetChanged returns true if the canary deployment pod spec
+// or the tracked config maps and secrets have changed
+func (c *CanaryDeployer) HasTargetChanged(cd *flaggerv1.Canary) (bool, error) {
+	if diff, err := c.IsNewSpec(cd); diff || err != nil {
+		return diff, err
+	}
+	return c.configTracker.HasConfigChanged(cd)
+}
+
+// SetStatusFailedChecks updates the canary failed checks counter,
+// increments the halted intervals counter and resets the consecutive passes counter
 func (c *CanaryDeployer) SetStatusFailedChecks(cd *flaggerv1.Canary, val int) error {
 	cdCopy := cd.DeepCopy()
 	cdCopy.Status.FailedChecks = val
+	cdCopy.Status.HaltedIntervals = cdCopy.Status.HaltedIntervals + 1
+	cdCopy.Status.ConsecutivePasses = 0
+	cdCopy.Status.Progress = cdCopy.GetProgress()
 	cdCopy.Status.LastTransitionTime = metav1.Now()
 
 	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
@@ -209,10 +431,111 @@ func (c *CanaryDeployer) SetStatusFailedChecks(cd *flaggerv1.Canary, val int) er
 	return nil
 }
 
-// SetStatusWeight updates the canary status weight value
+// SetStatusHaltedIntervals updates the canary halted intervals counter
+func (c *CanaryDeployer) SetStatusHaltedIntervals(cd *flaggerv1.Canary, val int) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.HaltedIntervals = val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusConsecutivePasses updates the canary consecutive passes counter
+func (c *CanaryDeployer) SetStatusConsecutivePasses(cd *flaggerv1.Canary, val int) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.ConsecutivePasses = val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusStepRequests updates the number of requests served at the current step
+func (c *CanaryDeployer) SetStatusStepRequests(cd *flaggerv1.Canary, val int) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.StepRequests = val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusWarmupStartTime updates the canary warmup start time
+func (c *CanaryDeployer) SetStatusWarmupStartTime(cd *flaggerv1.Canary, val metav1.Time) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.WarmupStartTime = val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusWindowSuspendTime updates the time the analysis was suspended outside the allowed window
+func (c *CanaryDeployer) SetStatusWindowSuspendTime(cd *flaggerv1.Canary, val metav1.Time) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.WindowSuspendTime = val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusPrimaryWarmupStartTime updates the time the warmup of the promoted primary started
+func (c *CanaryDeployer) SetStatusPrimaryWarmupStartTime(cd *flaggerv1.Canary, val metav1.Time) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.PrimaryWarmupStartTime = val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusVerificationStartTime updates the time the verification of the promoted primary started
+func (c *CanaryDeployer) SetStatusVerificationStartTime(cd *flaggerv1.Canary, val metav1.Time) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.VerificationStartTime = val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusApprovals updates the approval IDs registered with the approval webhooks
+func (c *CanaryDeployer) SetStatusApprovals(cd *flaggerv1.Canary, val map[string]string) error {
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.Approvals = &val
+
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+// SetStatusWeight updates the canary status weight value and starts a new step
 func (c *CanaryDeployer) SetStatusWeight(cd *flaggerv1.Canary, val int) error {
 	cdCopy := cd.DeepCopy()
 	cdCopy.Status.CanaryWeight = val
+	cdCopy.Status.StepStartTime = metav1.Now()
+	cdCopy.Status.StepRequests = 0
+	cdCopy.Status.HaltedIntervals = 0
+	cdCopy.Status.ConsecutivePasses = 0
+	cdCopy.Status.Progress = cdCopy.GetProgress()
 	cdCopy.Status.LastTransitionTime = metav1.Now()
 
 	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
@@ -222,10 +545,15 @@ func (c *CanaryDeployer) SetStatusWeight(cd *flaggerv1.Canary, val int) error {
 	return nil
 }
 
-// SetStatusIterations updates the canary status iterations value
+// SetStatusIterations updates the canary status iterations value and starts a new step
 func (c *CanaryDeployer) SetStatusIterations(cd *flaggerv1.Canary, val int) error {
 	cdCopy := cd.DeepCopy()
 	cdCopy.Status.Iterations = val
+	cdCopy.Status.StepStartTime = metav1.Now()
+	cdCopy.Status.StepRequests = 0
+	cdCopy.Status.HaltedIntervals = 0
+	cdCopy.Status.ConsecutivePasses = 0
+	cdCopy.Status.Progress = cdCopy.GetProgress()
 	cdCopy.Status.LastTransitionTime = metav1.Now()
 
 	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
@@ -239,6 +567,8 @@ func (c *CanaryDeployer) SetStatusIterations(cd *flaggerv1.Canary, val int) erro
 func (c *CanaryDeployer) IncrementStatusIterations(cd *flaggerv1.Canary) error {
 	cdCopy := cd.DeepCopy()
 	cdCopy.Status.Iterations = cdCopy.Status.Iterations + 1
+	cdCopy.Status.HaltedIntervals = 0
+	cdCopy.Status.Progress = cdCopy.GetProgress()
 	cdCopy.Status.LastTransitionTime = metav1.Now()
 
 	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
@@ -252,12 +582,33 @@ func (c *CanaryDeployer) IncrementStatusIterations(cd *flaggerv1.Canary) error {
 func (c *CanaryDeployer) SetStatusPhase(cd *flaggerv1.Canary, phase flaggerv1.CanaryPhase) error {
 	cdCopy := cd.DeepCopy()
 	cdCopy.Status.Phase = phase
+	cdCopy.Status.HaltedIntervals = 0
 	cdCopy.Status.LastTransitionTime = metav1.Now()
 
 	if phase != flaggerv1.CanaryProgressing {
 		cdCopy.Status.CanaryWeight = 0
 		cdCopy.Status.Iterations = 0
+		cdCopy.Status.Stage = 0
+		cdCopy.Status.AnalysedIntervals = 0
+		cdCopy.Status.Approvals = nil
+		cdCopy.Status.ApprovalTimes = nil
+		cdCopy.Status.SilenceID = ""
+		cdCopy.Status.RouteMutations = 0
+		cdCopy.Status.Rollbacks = 0
+		cdCopy.Status.MetricWindows = nil
+		cdCopy.Status.IntervalWindows = nil
+		cdCopy.Status.AnalysisInterval = ""
+		cdCopy.Status.ExcludedRegions = nil
+		cdCopy.Status.FailedCheckReason = ""
+		cdCopy.Status.PrimaryWarmupStartTime = metav1.Time{}
+		cdCopy.Status.VerificationStartTime = metav1.Time{}
+		cdCopy.Status.NoValuesStartTime = metav1.Time{}
 	}
+	// the first revision has been promoted
+	if phase == flaggerv1.CanarySucceeded {
+		cdCopy.Status.InitialAnalysis = false
+	}
+	cdCopy.Status.Progress = cdCopy.GetProgress()
 
 	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
 	if err != nil {
@@ -281,7 +632,7 @@ func (c *CanaryDeployer) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.Canar
 		return fmt.Errorf("deployment %s.%s marshal error %v", cd.Spec.TargetRef.Name, cd.Namespace, err)
 	}
 
-	configs, err := c.configTracker.GetConfigRefs(cd)
+	configs, configKeys, err := c.configTracker.GetConfigRefs(cd)
 	if err != nil {
 		return fmt.Errorf("configs query error %v", err)
 	}
@@ -291,9 +642,36 @@ func (c *CanaryDeployer) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.Canar
 	cdCopy.Status.CanaryWeight = status.CanaryWeight
 	cdCopy.Status.FailedChecks = status.FailedChecks
 	cdCopy.Status.Iterations = status.Iterations
+	cdCopy.Status.Stage = status.Stage
 	cdCopy.Status.LastAppliedSpec = base64.StdEncoding.EncodeToString(specJson)
 	cdCopy.Status.LastTransitionTime = metav1.Now()
+	cdCopy.Status.AnalysisStartTime = status.AnalysisStartTime
+	cdCopy.Status.HaltedIntervals = status.HaltedIntervals
+	cdCopy.Status.AnalysedIntervals = status.AnalysedIntervals
+	cdCopy.Status.ConsecutivePasses = status.ConsecutivePasses
+	cdCopy.Status.StepStartTime = status.StepStartTime
+	cdCopy.Status.StepRequests = status.StepRequests
+	cdCopy.Status.WarmupStartTime = status.WarmupStartTime
+	cdCopy.Status.ReadinessGateFailureTime = status.ReadinessGateFailureTime
+	cdCopy.Status.NoValuesStartTime = status.NoValuesStartTime
+	cdCopy.Status.PrimaryWarmupStartTime = status.PrimaryWarmupStartTime
+	cdCopy.Status.VerificationStartTime = status.VerificationStartTime
+	cdCopy.Status.SilenceID = status.SilenceID
+	cdCopy.Status.RolloutID = status.RolloutID
+	cdCopy.Status.InitialAnalysis = status.InitialAnalysis
+	cdCopy.Status.RouteMutations = status.RouteMutations
+	cdCopy.Status.Rollbacks = status.Rollbacks
+	cdCopy.Status.Approvals = status.Approvals
+	cdCopy.Status.ApprovalTimes = status.ApprovalTimes
+	cdCopy.Status.MetricWindows = status.MetricWindows
+	cdCopy.Status.IntervalWindows = status.IntervalWindows
+	cdCopy.Status.AnalysisInterval = status.AnalysisInterval
+	cdCopy.Status.ExcludedRegions = status.ExcludedRegions
+	cdCopy.Status.FailedCheckReason = status.FailedCheckReason
+	cdCopy.Status.RollbackReason = status.RollbackReason
+	cdCopy.Status.Progress = cdCopy.GetProgress()
 	cdCopy.Status.TrackedConfigs = configs
+	cdCopy.Status.TrackedConfigKeys = configKeys
 
 	cd, err = c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
 	if err != nil {
@@ -302,23 +680,115 @@ func (c *CanaryDeployer) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.Canar
 	return nil
 }
 
-// Scale sets the canary deployment replicas
+// Scale sets the canary deployment replicas,
+// the baseline deployment is scaled along with the canary if the baseline analysis is enabled
 func (c *CanaryDeployer) Scale(cd *flaggerv1.Canary, replicas int32) error {
+	if err := c.scaleBaseline(cd, replicas); err != nil {
+		return err
+	}
+
 	targetName := cd.Spec.TargetRef.Name
-	dep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(targetName, metav1.GetOptions{})
+	var updateErr error
+	err := retryOnTransientError(func() error {
+		updateErr = nil
+		dep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(targetName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		depCopy := dep.DeepCopy()
+		depCopy.Spec.Replicas = int32p(replicas)
+
+		_, updateErr = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(depCopy)
+		return updateErr
+	})
 	if err != nil {
+		if updateErr != nil {
+			return fmt.Errorf("scaling %s.%s to %v failed: %v", targetName, cd.Namespace, replicas, err)
+		}
 		if errors.IsNotFound(err) {
 			return fmt.Errorf("deployment %s.%s not found", targetName, cd.Namespace)
 		}
 		return fmt.Errorf("deployment %s.%s query error %v", targetName, cd.Namespace, err)
 	}
+	return nil
+}
+
+// scaleBaseline creates or updates the baseline deployment from the primary spec
+// with the given replicas, a missing baseline is not created to be scaled to zero
+func (c *CanaryDeployer) scaleBaseline(cd *flaggerv1.Canary, replicas int32) error {
+	baselineName := fmt.Sprintf("%s-baseline", cd.Spec.TargetRef.Name)
+	baseline, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(baselineName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("deployment %s.%s query error %v", baselineName, cd.Namespace, err)
+	}
+	exists := err == nil
 
-	depCopy := dep.DeepCopy()
-	depCopy.Spec.Replicas = int32p(replicas)
+	if !cd.Spec.CanaryAnalysis.Baseline || replicas == 0 {
+		// scale down a baseline left over by a previous analysis
+		if !exists || baseline.Spec.Replicas == nil || *baseline.Spec.Replicas == 0 {
+			return nil
+		}
+		baselineCopy := baseline.DeepCopy()
+		baselineCopy.Spec.Replicas = int32p(0)
+		if _, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(baselineCopy); err != nil {
+			return fmt.Errorf("scaling %s.%s to 0 failed: %v", baselineName, cd.Namespace, err)
+		}
+		return nil
+	}
 
-	_, err = c.kubeClient.AppsV1().Deployments(dep.Namespace).Update(depCopy)
+	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(primaryName, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("scaling %s.%s to %v failed: %v", depCopy.GetName(), depCopy.Namespace, replicas, err)
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("deployment %s.%s not found", primaryName, cd.Namespace)
+		}
+		return fmt.Errorf("deployment %s.%s query error %v", primaryName, cd.Namespace, err)
+	}
+
+	// the baseline runs the primary version in fresh pods selected only by the baseline service
+	template := primary.Spec.Template.DeepCopy()
+	template.Labels = makePrimaryLabels(template.Labels, baselineName)
+
+	if !exists {
+		baseline = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      baselineName,
+				Labels:    primary.Labels,
+				Namespace: cd.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				ProgressDeadlineSeconds: primary.Spec.ProgressDeadlineSeconds,
+				MinReadySeconds:         primary.Spec.MinReadySeconds,
+				Replicas:                int32p(replicas),
+				Strategy:                primary.Spec.Strategy,
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app": baselineName,
+					},
+				},
+				Template: *template,
+			},
+		}
+		if _, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Create(baseline); err != nil {
+			return fmt.Errorf("creating deployment %s.%s failed: %v", baselineName, cd.Namespace, err)
+		}
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Infof("Deployment %s.%s created", baselineName, cd.Namespace)
+		return nil
+	}
+
+	baselineCopy := baseline.DeepCopy()
+	baselineCopy.Spec.Replicas = int32p(replicas)
+	baselineCopy.Spec.Template = *template
+	if _, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(baselineCopy); err != nil {
+		return fmt.Errorf("updating deployment %s.%s failed: %v", baselineName, cd.Namespace, err)
 	}
 	return nil
 }
@@ -327,11 +797,15 @@ func (c *CanaryDeployer) Scale(cd *flaggerv1.Canary, replicas int32) error {
 // and scales to zero the canary deployment
 func (c *CanaryDeployer) Sync(cd *flaggerv1.Canary) error {
 	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
-	if err := c.createPrimaryDeployment(cd); err != nil {
+	err := retryOnTransientError(func() error {
+		return c.createPrimaryDeployment(cd)
+	})
+	if err != nil {
 		return fmt.Errorf("creating deployment %s.%s failed: %v", primaryName, cd.Namespace, err)
 	}
 
-	if cd.Status.Phase == "" {
+	// the target keeps serving the first revision while its analysis runs
+	if cd.Status.Phase == "" && cd.Spec.CanaryAnalysis.InitializationMode != flaggerv1.InitializationAnalyze {
 		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Infof("Scaling down %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
 		if err := c.Scale(cd, 0); err != nil {
 			return err
@@ -339,13 +813,93 @@ func (c *CanaryDeployer) Sync(cd *flaggerv1.Canary) error {
 	}
 
 	if cd.Spec.AutoscalerRef != nil && cd.Spec.AutoscalerRef.Kind == "HorizontalPodAutoscaler" {
-		if err := c.createPrimaryHpa(cd); err != nil {
+		err := retryOnTransientError(func() error {
+			return c.createPrimaryHpa(cd)
+		})
+		if err != nil {
 			return fmt.Errorf("creating hpa %s.%s failed: %v", primaryName, cd.Namespace, err)
 		}
 	}
 	return nil
 }
 
+// AdoptPrimary takes ownership of a primary deployment that was created
+// outside of Flagger, it returns true if the deployment has been adopted
+func (c *CanaryDeployer) AdoptPrimary(cd *flaggerv1.Canary) (bool, error) {
+	targetName := cd.Spec.TargetRef.Name
+	primaryName := fmt.Sprintf("%s-primary", targetName)
+
+	primaryDep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(primaryName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("deployment %s.%s query error %v", primaryName, cd.Namespace, err)
+	}
+
+	if owner := metav1.GetControllerOf(primaryDep); owner != nil {
+		if owner.Kind == flaggerv1.CanaryKind && owner.Name == cd.Name {
+			return false, nil
+		}
+		return false, fmt.Errorf("deployment %s.%s is controlled by %s %s, remove the owner reference to adopt it",
+			primaryName, cd.Namespace, owner.Kind, owner.Name)
+	}
+
+	canaryDep, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, fmt.Errorf("deployment %s.%s not found", targetName, cd.Namespace)
+		}
+		return false, fmt.Errorf("deployment %s.%s query error %v", targetName, cd.Namespace, err)
+	}
+
+	if err := validatePrimary(canaryDep, primaryDep); err != nil {
+		return false, fmt.Errorf("adopting deployment %s.%s failed: %v", primaryName, cd.Namespace, err)
+	}
+
+	err = retryOnTransientError(func() error {
+		primary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(primaryName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		primaryCopy := primary.DeepCopy()
+		primaryCopy.OwnerReferences = append(primaryCopy.OwnerReferences,
+			*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+				Group:   flaggerv1.SchemeGroupVersion.Group,
+				Version: flaggerv1.SchemeGroupVersion.Version,
+				Kind:    flaggerv1.CanaryKind,
+			}))
+		_, err = c.kubeClient.AppsV1().Deployments(cd.Namespace).Update(primaryCopy)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("adopting deployment %s.%s failed: %v", primaryName, cd.Namespace, err)
+	}
+
+	return true, nil
+}
+
+// validatePrimary checks that an existing primary deployment
+// selects its own pods and runs the same containers as the target
+func validatePrimary(canaryDep *appsv1.Deployment, primaryDep *appsv1.Deployment) error {
+	if primaryDep.Spec.Selector == nil || primaryDep.Spec.Selector.MatchLabels["app"] != primaryDep.Name {
+		return fmt.Errorf("spec.selector.matchLabels must contain selector 'app: %s'", primaryDep.Name)
+	}
+
+	canaryContainers := canaryDep.Spec.Template.Spec.Containers
+	primaryContainers := primaryDep.Spec.Template.Spec.Containers
+	if len(canaryContainers) != len(primaryContainers) {
+		return fmt.Errorf("containers count %v does not match the target %v", len(primaryContainers), len(canaryContainers))
+	}
+	for i := range canaryContainers {
+		if canaryContainers[i].Name != primaryContainers[i].Name {
+			return fmt.Errorf("container %s does not match the target container %s",
+				primaryContainers[i].Name, canaryContainers[i].Name)
+		}
+	}
+	return nil
+}
+
 func (c *CanaryDeployer) createPrimaryDeployment(cd *flaggerv1.Canary) error {
 	targetName := cd.Spec.TargetRef.Name
 	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
@@ -382,6 +936,10 @@ func (c *CanaryDeployer) createPrimaryDeployment(cd *flaggerv1.Canary) error {
 		if canaryDep.Spec.Replicas != nil && *canaryDep.Spec.Replicas > 0 {
 			replicas = *canaryDep.Spec.Replicas
 		}
+		// the primary is scaled up when the first revision is promoted
+		if cd.Spec.CanaryAnalysis.InitializationMode == flaggerv1.InitializationAnalyze {
+			replicas = 0
+		}
 
 		// create primary deployment
 		primaryDep = &appsv1.Deployment{
@@ -552,6 +1110,47 @@ func (c *CanaryDeployer) makeAnnotations(annotations map[string]string) (map[str
 	return res, nil
 }
 
+// transientRetry is the backoff used when retrying transient Kubernetes API errors,
+// the total wait time is kept well below the analysis interval
+var transientRetry = wait.Backoff{
+	Steps:    4,
+	Duration: 200 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// isTransientError returns true if the Kubernetes API error is caused by
+// a conflict, a timeout or by the API server being temporarily unavailable
+func isTransientError(err error) bool {
+	return errors.IsConflict(err) ||
+		errors.IsServerTimeout(err) ||
+		errors.IsTimeout(err) ||
+		errors.IsTooManyRequests(err) ||
+		errors.IsServiceUnavailable(err) ||
+		errors.IsInternalError(err)
+}
+
+// retryOnTransientError runs the function until it succeeds, it returns a non transient error
+// or the retry steps are exhausted, in which case the last error is returned
+func retryOnTransientError(fn func() error) error {
+	var lastErr error
+	err := wait.ExponentialBackoff(transientRetry, func() (bool, error) {
+		lastErr = fn()
+		switch {
+		case lastErr == nil:
+			return true, nil
+		case isTransientError(lastErr):
+			return false, nil
+		default:
+			return false, lastErr
+		}
+	})
+	if err == wait.ErrWaitTimeout {
+		return lastErr
+	}
+	return err
+}
+
 func makePrimaryLabels(labels map[string]string, primaryName string) map[string]string {
 	idKey := "app"
 	res := make(map[string]string)
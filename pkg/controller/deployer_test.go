@@ -2,9 +2,16 @@ package controller
 
 import (
 	"testing"
+	"time"
 
 	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	hpav1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestCanaryDeployer_Sync(t *testing.T) {
@@ -161,6 +168,107 @@ func TestCanaryDeployer_Promote(t *testing.T) {
 	}
 }
 
+func TestCanaryDeployer_RestorePrimary(t *testing.T) {
+	mocks := SetupMocks(false)
+	mocks.canary.Spec.CanaryAnalysis.RollbackPrimary = true
+	err := mocks.deployer.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	restored, err := mocks.deployer.RestorePrimary(mocks.canary)
+	if err != nil || restored {
+		t.Fatalf("Got restored %v error %v wanted no snapshot", restored, err)
+	}
+
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = mocks.deployer.Promote(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	restored, err = mocks.deployer.RestorePrimary(mocks.canary)
+	if err != nil || !restored {
+		t.Fatalf("Got restored %v error %v wanted the snapshot restored", restored, err)
+	}
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	primaryImage := depPrimary.Spec.Template.Spec.Containers[0].Image
+	sourceImage := newTestDeployment().Spec.Template.Spec.Containers[0].Image
+	if primaryImage != sourceImage {
+		t.Errorf("Got image %s wanted %s", primaryImage, sourceImage)
+	}
+
+	if _, err := mocks.kubeClient.CoreV1().ConfigMaps("default").Get("podinfo-primary-snapshot", metav1.GetOptions{}); err == nil {
+		t.Errorf("Got snapshot wanted it deleted after the restore")
+	}
+}
+
+func TestCanaryDeployer_PromoteReplicas(t *testing.T) {
+	mocks := SetupMocks(false)
+	mocks.canary.Spec.AutoscalerRef = nil
+	err := mocks.deployer.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dep2 := newTestDeploymentV2()
+	dep2.Spec.Replicas = int32p(3)
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = mocks.deployer.Promote(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if replicas := *depPrimary.Spec.Replicas; replicas != 3 {
+		t.Errorf("Got primary replicas %v wanted %v", replicas, 3)
+	}
+
+	// the autoscaler manages the primary replicas
+	mocks.canary.Spec.AutoscalerRef = &hpav1.CrossVersionObjectReference{
+		Name:       "podinfo",
+		Kind:       "HorizontalPodAutoscaler",
+		APIVersion: "autoscaling/v2beta1",
+	}
+	dep2.Spec.Replicas = int32p(5)
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = mocks.deployer.Promote(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	depPrimary, err = mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if replicas := *depPrimary.Spec.Replicas; replicas != 3 {
+		t.Errorf("Got primary replicas %v wanted %v", replicas, 3)
+	}
+}
+
 func TestCanaryDeployer_IsReady(t *testing.T) {
 	mocks := SetupMocks(false)
 	err := mocks.deployer.Sync(mocks.canary)
@@ -262,6 +370,107 @@ func TestCanaryDeployer_SyncStatus(t *testing.T) {
 	}
 }
 
+func TestCanaryDeployer_SyncStatusBaselineAnchor(t *testing.T) {
+	mocks := SetupMocks(false)
+	err := mocks.deployer.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	start := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	status := v1alpha3.CanaryStatus{
+		Phase:             v1alpha3.CanaryProgressing,
+		AnalysisStartTime: start,
+	}
+	err = mocks.deployer.SyncStatus(mocks.canary, status)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	res, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// no anchor evaluates the baseline at query time
+	if anchor, err := res.GetBaselineAnchor(); err != nil || anchor != nil {
+		t.Errorf("Got anchor %v %v wanted nil", anchor, err)
+	}
+
+	// the start anchor pins the baseline to the analysis start time
+	res.Spec.CanaryAnalysis.BaselineAnchor = v1alpha3.BaselineAnchorStart
+	anchor, err := res.GetBaselineAnchor()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if anchor == nil || !anchor.Equal(start.Time) {
+		t.Errorf("Got anchor %v wanted %v", anchor, start.Time)
+	}
+
+	res.Spec.CanaryAnalysis.BaselineAnchor = "2019-01-02T15:04:05Z"
+	anchor, err = res.GetBaselineAnchor()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if expected := time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC); anchor == nil || !anchor.Equal(expected) {
+		t.Errorf("Got anchor %v wanted %v", anchor, expected)
+	}
+
+	res.Spec.CanaryAnalysis.BaselineAnchor = "yesterday"
+	if _, err := res.GetBaselineAnchor(); err == nil {
+		t.Errorf("Got no error wanted invalid baseline anchor")
+	}
+}
+
+func TestCanaryDeployer_GetTargetDrift(t *testing.T) {
+	mocks := SetupMocks(false)
+	err := mocks.deployer.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = mocks.deployer.SyncStatus(mocks.canary, v1alpha3.CanaryStatus{Phase: v1alpha3.CanaryProgressing})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	configMap2 := NewTestConfigMapV2()
+	_, err = mocks.kubeClient.CoreV1().ConfigMaps("default").Update(configMap2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	drift, err := mocks.deployer.GetTargetDrift(c)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, expected := range []string{
+		"containers[podinfo].image",
+		"containers[podinfo].envFrom",
+		"configmap/podinfo-config-env keys color,output changed",
+	} {
+		found := false
+		for _, d := range drift {
+			if d == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Got drift %v wanted %s", drift, expected)
+		}
+	}
+}
+
 func TestCanaryDeployer_Scale(t *testing.T) {
 	mocks := SetupMocks(false)
 	err := mocks.deployer.Sync(mocks.canary)
@@ -281,3 +490,154 @@ func TestCanaryDeployer_Scale(t *testing.T) {
 	}
 
 }
+
+func TestCanaryDeployer_ScaleBaseline(t *testing.T) {
+	mocks := SetupMocks(false)
+	mocks.canary.Spec.CanaryAnalysis.Baseline = true
+	err := mocks.deployer.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the primary runs the previous version during the analysis
+	dep2 := newTestDeploymentV2()
+	if _, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := mocks.deployer.Scale(mocks.canary, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	baseline, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-baseline", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *baseline.Spec.Replicas != 1 {
+		t.Errorf("Got baseline replicas %v wanted %v", *baseline.Spec.Replicas, 1)
+	}
+	if baseline.Spec.Template.Labels["app"] != "podinfo-baseline" || baseline.Spec.Selector.MatchLabels["app"] != "podinfo-baseline" {
+		t.Errorf("Got labels %v wanted app podinfo-baseline", baseline.Spec.Template.Labels)
+	}
+	if image := baseline.Spec.Template.Spec.Containers[0].Image; image != "quay.io/stefanprodan/podinfo:1.2.0" {
+		t.Errorf("Got baseline image %s wanted the primary image %s", image, "quay.io/stefanprodan/podinfo:1.2.0")
+	}
+
+	if err := mocks.deployer.Scale(mocks.canary, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+	baseline, err = mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-baseline", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *baseline.Spec.Replicas != 0 {
+		t.Errorf("Got baseline replicas %v wanted %v", *baseline.Spec.Replicas, 0)
+	}
+}
+
+func TestCanaryDeployer_SyncTransientError(t *testing.T) {
+	mocks := SetupMocks(false)
+
+	// fail the first deployment update with a conflict
+	conflicts := 0
+	mocks.kubeClient.(*fake.Clientset).PrependReactor("update", "deployments",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if conflicts > 0 {
+				return false, nil, nil
+			}
+			conflicts++
+			return true, nil, errors.NewConflict(schema.GroupResource{Resource: "deployments"}, "podinfo", nil)
+		})
+
+	err := mocks.deployer.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if conflicts != 1 {
+		t.Errorf("Got conflicts %v wanted %v", conflicts, 1)
+	}
+
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if *dep.Spec.Replicas != 0 {
+		t.Errorf("Got replicas %v wanted %v", *dep.Spec.Replicas, 0)
+	}
+}
+
+func TestCanaryDeployer_SyncPermanentError(t *testing.T) {
+	mocks := SetupMocks(false)
+
+	updates := 0
+	mocks.kubeClient.(*fake.Clientset).PrependReactor("update", "deployments",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updates++
+			return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "deployments"}, "podinfo", nil)
+		})
+
+	err := mocks.deployer.Sync(mocks.canary)
+	if err == nil {
+		t.Fatal("Got no error wanted forbidden")
+	}
+
+	if updates != 1 {
+		t.Errorf("Got updates %v wanted %v", updates, 1)
+	}
+}
+
+func TestCanaryDeployer_AdoptPrimary(t *testing.T) {
+	mocks := SetupMocks(false)
+
+	primary := newTestDeployment()
+	primary.Name = "podinfo-primary"
+	primary.Spec.Selector.MatchLabels["app"] = "podinfo-primary"
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Create(primary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	adopted, err := mocks.deployer.AdoptPrimary(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !adopted {
+		t.Errorf("Got adopted %v wanted %v", adopted, true)
+	}
+
+	depPrimary, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	owner := metav1.GetControllerOf(depPrimary)
+	if owner == nil || owner.Name != mocks.canary.Name {
+		t.Errorf("Got owner %v wanted %s", owner, mocks.canary.Name)
+	}
+
+	// already adopted
+	adopted, err = mocks.deployer.AdoptPrimary(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if adopted {
+		t.Errorf("Got adopted %v wanted %v", adopted, false)
+	}
+}
+
+func TestCanaryDeployer_AdoptPrimaryInvalidSelector(t *testing.T) {
+	mocks := SetupMocks(false)
+
+	primary := newTestDeployment()
+	primary.Name = "podinfo-primary"
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Create(primary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err = mocks.deployer.AdoptPrimary(mocks.canary)
+	if err == nil {
+		t.Errorf("Expected error for primary selector app: podinfo")
+	}
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DryRunResult holds the evaluation of the canary metrics against the live data
+type DryRunResult struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// true if the analysis would pass with the current values
+	Passed bool `json:"passed"`
+	// weighted score of the metrics, set only if the canary has a score threshold
+	Score   *float64       `json:"score,omitempty"`
+	Metrics []DryRunMetric `json:"metrics"`
+}
+
+// DryRunMetric holds the current value of a metric query and whether it would pass the threshold
+type DryRunMetric struct {
+	Name      string   `json:"name"`
+	Value     *float64 `json:"value,omitempty"`
+	Threshold float64  `json:"threshold"`
+	Passed    bool     `json:"passed"`
+	Skipped   bool     `json:"skipped,omitempty"`
+	Warning   bool     `json:"warning,omitempty"`
+	Message   string   `json:"message,omitempty"`
+}
+
+// DryRun runs the metric checks of the canary once without calling the webhooks,
+// recording events or updating the canary status
+func (c *Controller) DryRun(name string, namespace string) (DryRunResult, error) {
+	cd, err := c.flaggerClient.FlaggerV1alpha3().Canaries(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return DryRunResult{}, err
+	}
+
+	// the evaluation of the analysis without the webhooks, the events and the metrics cache updates
+	evaluation := c.evaluateMetrics(cd, false)
+	result := DryRunResult{
+		Name:      cd.Name,
+		Namespace: cd.Namespace,
+		Passed:    evaluation.passed && !evaluation.abort,
+		Score:     evaluation.score,
+	}
+	for _, check := range evaluation.checks {
+		result.Metrics = append(result.Metrics, DryRunMetric{
+			Name:      check.name,
+			Value:     check.value,
+			Threshold: check.threshold,
+			Passed:    check.passed,
+			Skipped:   check.skipped,
+			Warning:   check.warning,
+			Message:   check.message,
+		})
+	}
+	return result, nil
+}
+
+// DryRunHandler serves the dry run of the canary set with the name and namespace query parameters
+func (c *Controller) DryRunHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		namespace := r.URL.Query().Get("namespace")
+		if name == "" || namespace == "" {
+			http.Error(w, "name and namespace query parameters are required", http.StatusBadRequest)
+			return
+		}
+
+		result, err := c.DryRun(name, namespace)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.IsNotFound(err) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf("canary %s.%s dry run failed %v", name, namespace, err), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			c.logger.With("canary", fmt.Sprintf("%s.%s", name, namespace)).
+				Errorf("Dry run response encoding failed %v", err)
+		}
+	})
+}
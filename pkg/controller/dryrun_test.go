@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestController_DryRun(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// the fake metrics server returns 100
+	cd.Spec.CanaryAnalysis.Metrics = append(cd.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:      "errors",
+		Query:     "sum(rate(errors[1m]))",
+		Threshold: 50,
+	})
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	req := httptest.NewRequest("GET", "/dry-run?name=podinfo&namespace=default", nil)
+	rec := httptest.NewRecorder()
+	mocks.ctrl.DryRunHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status %v wanted %v", rec.Code, http.StatusOK)
+	}
+
+	var result DryRunResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err.Error())
+	}
+	if result.Passed {
+		t.Errorf("Got passed %v wanted %v", result.Passed, false)
+	}
+	if len(result.Metrics) != 3 {
+		t.Fatalf("Got %v metrics wanted %v", len(result.Metrics), 3)
+	}
+	if !result.Metrics[0].Passed || result.Metrics[0].Value == nil || *result.Metrics[0].Value != 100 {
+		t.Errorf("Got metric %+v wanted istio_requests_total passed with value 100", result.Metrics[0])
+	}
+	if result.Metrics[2].Passed {
+		t.Errorf("Got metric %+v wanted errors failed", result.Metrics[2])
+	}
+
+	// the dry run leaves the canary status untouched
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.FailedChecks != cd.Status.FailedChecks || c.Status.Phase != cd.Status.Phase {
+		t.Errorf("Got status %+v wanted %+v", c.Status, cd.Status)
+	}
+
+	req = httptest.NewRequest("GET", "/dry-run?name=missing&namespace=default", nil)
+	rec = httptest.NewRecorder()
+	mocks.ctrl.DryRunHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Got status %v wanted %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestController_DryRunNoValues(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer ts.Close()
+	mocks.ctrl.observer = CanaryObserver{metricsServer: ts.URL}
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.NoValuesPolicy = v1alpha3.NoValuesPass
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the pass policy skips the metrics without values
+	result, err := mocks.ctrl.DryRun("podinfo", "default")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !result.Passed {
+		t.Errorf("Got passed %v wanted %v", result.Passed, true)
+	}
+	for _, metric := range result.Metrics {
+		if !metric.Skipped {
+			t.Errorf("Got metric %+v wanted skipped", metric)
+		}
+	}
+
+	// the fail policy fails the analysis once the no values timeout has elapsed
+	cd.Spec.CanaryAnalysis.NoValuesPolicy = v1alpha3.NoValuesFail
+	cd.Spec.CanaryAnalysis.NoValuesTimeout = "5m"
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	status := cd.Status.DeepCopy()
+	status.NoValuesStartTime = metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	if err := mocks.deployer.SyncStatus(cd, *status); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	result, err = mocks.ctrl.DryRun("podinfo", "default")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result.Passed {
+		t.Errorf("Got passed %v wanted %v", result.Passed, false)
+	}
+}
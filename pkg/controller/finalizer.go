@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// syncFinalizer adds the teardown finalizer to the canaries with teardown hooks
+// and removes it once the hooks are removed from the spec
+func (c *Controller) syncFinalizer(cd *flaggerv1.Canary) error {
+	hooks := len(teardownHooks(cd)) > 0
+	if hooks == hasFinalizer(cd) {
+		return nil
+	}
+	return c.updateFinalizer(cd, hooks)
+}
+
+// finalize calls the teardown hooks of a deleted canary and removes the finalizer
+// once all hooks succeeded or the progress deadline elapsed since the deletion,
+// an error is returned if the hooks must be retried
+func (c *Controller) finalize(cd *flaggerv1.Canary) error {
+	if !hasFinalizer(cd) {
+		return nil
+	}
+
+	var failed int
+	for _, webhook := range teardownHooks(cd) {
+		if _, err := c.callWebhook(cd, webhook); err != nil {
+			c.recordEventWarningf(cd, "Teardown hook %s failed %v", webhook.Name, err)
+			failed++
+			continue
+		}
+		c.recordEventInfof(cd, "Teardown hook %s passed", webhook.Name)
+	}
+
+	if failed > 0 {
+		deadline := time.Duration(cd.GetProgressDeadlineSeconds()) * time.Second
+		if time.Since(cd.DeletionTimestamp.Time) < deadline {
+			return fmt.Errorf("canary %s.%s %v teardown hooks failed", cd.Name, cd.Namespace, failed)
+		}
+		c.recordEventWarningf(cd, "Teardown hooks failed for more than %vs, removing finalizer of %s.%s",
+			cd.GetProgressDeadlineSeconds(), cd.Name, cd.Namespace)
+	}
+
+	return c.updateFinalizer(cd, false)
+}
+
+// updateFinalizer adds or removes the teardown finalizer
+func (c *Controller) updateFinalizer(cd *flaggerv1.Canary, add bool) error {
+	err := retryOnTransientError(func() error {
+		canary, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).Get(cd.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		cdCopy := canary.DeepCopy()
+		var finalizers []string
+		for _, f := range cdCopy.Finalizers {
+			if f != flaggerv1.TeardownFinalizer {
+				finalizers = append(finalizers, f)
+			}
+		}
+		if add {
+			finalizers = append(finalizers, flaggerv1.TeardownFinalizer)
+		}
+		cdCopy.Finalizers = finalizers
+
+		_, err = c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).Update(cdCopy)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("canary %s.%s finalizer update error %v", cd.Name, cd.Namespace, err)
+	}
+	return nil
+}
+
+func teardownHooks(cd *flaggerv1.Canary) []flaggerv1.CanaryWebhook {
+	var hooks []flaggerv1.CanaryWebhook
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type == flaggerv1.TeardownHook {
+			hooks = append(hooks, webhook)
+		}
+	}
+	return hooks
+}
+
+func hasFinalizer(cd *flaggerv1.Canary) bool {
+	for _, f := range cd.Finalizers {
+		if f == flaggerv1.TeardownFinalizer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestController_Finalize(t *testing.T) {
+	var calls int
+	healthy := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if !healthy {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Webhooks = append(cd.Spec.CanaryAnalysis.Webhooks, v1alpha3.CanaryWebhook{
+		Name: "deregister",
+		Type: v1alpha3.TeardownHook,
+		URL:  ts.URL,
+	})
+	if cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the finalizer is added to the canaries with teardown hooks
+	if err := mocks.ctrl.syncFinalizer(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !hasFinalizer(cd) {
+		t.Fatalf("Got finalizers %v wanted %s", cd.Finalizers, v1alpha3.TeardownFinalizer)
+	}
+
+	// a failed hook blocks the deletion
+	now := metav1.Now()
+	cd.DeletionTimestamp = &now
+	if err := mocks.ctrl.finalize(cd); err == nil {
+		t.Errorf("Got no error wanted teardown hook error")
+	}
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !hasFinalizer(c) {
+		t.Errorf("Got finalizers %v wanted %s", c.Finalizers, v1alpha3.TeardownFinalizer)
+	}
+
+	// the finalizer is removed once the hooks succeed
+	healthy = true
+	if err := mocks.ctrl.finalize(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if hasFinalizer(c) {
+		t.Errorf("Got finalizers %v wanted none", c.Finalizers)
+	}
+	if calls != 2 {
+		t.Errorf("Got %v teardown hook calls wanted %v", calls, 2)
+	}
+}
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type graphQLRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// checkGraphQL compares the value found at the path of the GraphQL response to the threshold
+func (c *Controller) checkGraphQL(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	gql := metric.GraphQL
+	headers, err := c.metricHeaders(r, metric, gql.SecretName)
+	if err != nil {
+		check.queryError = true
+		check.message = err.Error()
+		return check
+	}
+
+	variables := map[string]string{
+		"name":      r.Spec.TargetRef.Name,
+		"namespace": r.Namespace,
+		"interval":  metric.Interval,
+	}
+	val, err := queryGraphQL(gql.Address, gql.Query, gql.Path, variables, headers)
+	if err != nil {
+		if strings.Contains(err.Error(), "no values found") {
+			return c.metricQueryFailure(r, metric, err)
+		}
+		check.queryError = true
+		check.message = fmt.Sprintf("GraphQL endpoint %s query failed: %v", gql.Address, err)
+		return check
+	}
+
+	check.value = &val
+	if val > metric.Threshold {
+		check.message = fmt.Sprintf("Halt %s.%s advancement %s %.2f > %v",
+			r.Name, r.Namespace, metric.Name, val, metric.Threshold)
+		return degradeCheck(metric, check, val-metric.Threshold)
+	}
+
+	check = warnCheck(r, metric, check, val > metric.WarnThreshold)
+	check.passed = true
+	return check
+}
+
+// metricHeaders returns the request headers stored in the metric secret
+func (c *Controller) metricHeaders(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, secretName string) (map[string]string, error) {
+	headers := make(map[string]string)
+	if secretName == "" {
+		return headers, nil
+	}
+
+	secret, err := c.kubeClient.CoreV1().Secrets(r.Namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("metric %s secret %s.%s query error %v", metric.Name, secretName, r.Namespace, err)
+	}
+	for key, value := range secret.Data {
+		headers[key] = string(value)
+	}
+	return headers, nil
+}
+
+// queryGraphQL posts the query to the GraphQL endpoint and returns the value found at the path of the response data
+func queryGraphQL(address string, query string, path string, variables map[string]string, headers map[string]string) (float64, error) {
+	body, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", address, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading body: %s", err.Error())
+	}
+
+	if 400 <= r.StatusCode {
+		return 0, fmt.Errorf("error response: %s", string(b))
+	}
+
+	var res graphQLResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %s, '%s'", err.Error(), string(b))
+	}
+	if len(res.Errors) > 0 {
+		return 0, fmt.Errorf("error response: %s", res.Errors[0].Message)
+	}
+
+	return graphQLValue(res.Data, path)
+}
+
+// graphQLValue walks the dot separated path of the response data, the list items are selected by index,
+// a missing or null value is reported as no values found
+func graphQLValue(data interface{}, path string) (float64, error) {
+	value := data
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				value = nil
+			} else {
+				value = v[i]
+			}
+		default:
+			value = nil
+		}
+		if value == nil {
+			return 0, fmt.Errorf("no values found at path %s", path)
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value at path %s is not a number: %s", path, v)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("value at path %s is not a number: %v", path, v)
+	}
+}
+
+// validateGraphQL checks that the GraphQL endpoint, query and path are set
+func validateGraphQL(metric flaggerv1.CanaryMetric) error {
+	if metric.Query != "" || metric.BurnRate != nil || metric.ThresholdPercent != 0 || metric.Logs != nil ||
+		metric.Ratio != nil || metric.Resource != nil || metric.MinRequests > 0 ||
+		len(metric.Selector) > 0 || len(metric.Labels) > 0 || len(metric.SuccessCodes) > 0 || len(metric.TotalCodes) > 0 {
+		return fmt.Errorf("metric %s graphql can't be used with the Prometheus query settings", metric.Name)
+	}
+	gql := metric.GraphQL
+	if u, err := url.Parse(gql.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("metric %s graphql address %s must be an http or https URL", metric.Name, gql.Address)
+	}
+	if gql.Query == "" {
+		return fmt.Errorf("metric %s graphql query is required", metric.Name)
+	}
+	for _, key := range strings.Split(gql.Path, ".") {
+		if key == "" {
+			return fmt.Errorf("metric %s graphql path %s is not valid", metric.Name, gql.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestController_CheckGraphQL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Variables["name"] != "podinfo" || req.Variables["namespace"] != "default" {
+			w.Write([]byte(`{"data":{"service":null}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"service":{"errorRate":2.5,"regions":[{"errorRate":"0.5"}]}}}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "analytics", Namespace: "default"},
+		Data:       map[string][]byte{"Authorization": []byte("Bearer token")},
+	}
+	if _, err := mocks.kubeClient.CoreV1().Secrets("default").Create(secret); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	metric := v1alpha3.CanaryMetric{
+		Name:      "error-rate",
+		Interval:  "1m",
+		Threshold: 1,
+		GraphQL: &v1alpha3.CanaryGraphQL{
+			Address:    ts.URL,
+			Query:      `query($name: String!) { service(name: $name) { errorRate } }`,
+			Path:       "service.errorRate",
+			SecretName: "analytics",
+		},
+	}
+
+	check := mocks.ctrl.checkMetric(mocks.canary, metric)
+	if check.passed || check.value == nil || *check.value != 2.5 {
+		t.Errorf("Got check %+v wanted failed with value 2.5", check)
+	}
+
+	metric.GraphQL.Path = "service.regions.0.errorRate"
+	check = mocks.ctrl.checkMetric(mocks.canary, metric)
+	if !check.passed || check.value == nil || *check.value != 0.5 {
+		t.Errorf("Got check %+v wanted passed with value 0.5", check)
+	}
+
+	metric.GraphQL.Path = "service.latency"
+	check = mocks.ctrl.checkMetric(mocks.canary, metric)
+	if !check.noValues {
+		t.Errorf("Got no values %v wanted %v", check.noValues, true)
+	}
+
+	metric.GraphQL.SecretName = ""
+	check = mocks.ctrl.checkMetric(mocks.canary, metric)
+	if !check.queryError {
+		t.Errorf("Got query error %v wanted %v", check.queryError, true)
+	}
+}
+
+func TestController_ValidateGraphQL(t *testing.T) {
+	metric := v1alpha3.CanaryMetric{
+		Name:      "error-rate",
+		Threshold: 1,
+		GraphQL: &v1alpha3.CanaryGraphQL{
+			Address: "http://analytics.internal/graphql",
+			Query:   "{ service { errorRate } }",
+			Path:    "service.errorRate",
+		},
+	}
+	if err := validateMetric(metric); err != nil {
+		t.Errorf("Got error %v wanted none", err)
+	}
+
+	metric.GraphQL.Path = "service..errorRate"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted invalid path")
+	}
+
+	metric.GraphQL.Path = "service.errorRate"
+	metric.Query = "sum(rate(errors[1m]))"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted graphql with a Prometheus query")
+	}
+}
@@ -9,6 +9,7 @@ type CanaryJob struct {
 	SkipTests        bool
 	function         func(name string, namespace string, skipTests bool)
 	done             chan bool
+	reset            chan time.Duration
 	ticker           *time.Ticker
 	analysisInterval time.Duration
 }
@@ -18,23 +19,46 @@ func (j CanaryJob) Start() {
 	go func() {
 		// run the infra bootstrap on job creation
 		j.function(j.Name, j.Namespace, j.SkipTests)
-		for {
-			select {
-			case <-j.ticker.C:
-				j.function(j.Name, j.Namespace, j.SkipTests)
-			case <-j.done:
-				return
-			}
-		}
+		j.run()
 	}()
 }
 
+// run calls the job function on every tick, the ticker is swapped in the same goroutine
+// so that a run is never started while the previous one is in progress
+func (j CanaryJob) run() {
+	ticker := j.ticker
+	for {
+		select {
+		case <-ticker.C:
+			j.function(j.Name, j.Namespace, j.SkipTests)
+		case interval := <-j.reset:
+			ticker.Stop()
+			ticker = time.NewTicker(interval)
+		case <-j.done:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
 // Stop closes the job channel and stops the ticker
 func (j CanaryJob) Stop() {
 	close(j.done)
 	j.ticker.Stop()
 }
 
+// ResetInterval schedules a running job with a new ticker without waiting for
+// the current run to finish, the next run happens after the new interval has elapsed
+func (j *CanaryJob) ResetInterval(interval time.Duration) {
+	// only the last interval matters, drop the reset the job hasn't picked up yet
+	select {
+	case <-j.reset:
+	default:
+	}
+	j.reset <- interval
+	j.analysisInterval = interval
+}
+
 func (j CanaryJob) GetCanaryAnalysisInterval() time.Duration {
 	return j.analysisInterval
 }
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCanaryJob_ResetInterval(t *testing.T) {
+	var runs int32
+	job := CanaryJob{
+		Name:      "podinfo",
+		Namespace: "default",
+		function: func(name string, namespace string, skipTests bool) {
+			atomic.AddInt32(&runs, 1)
+		},
+		done:             make(chan bool),
+		reset:            make(chan time.Duration, 1),
+		ticker:           time.NewTicker(time.Hour),
+		analysisInterval: time.Hour,
+	}
+	job.Start()
+	defer func() { job.Stop() }()
+
+	job.ResetInterval(10 * time.Millisecond)
+	if job.GetCanaryAnalysisInterval() != 10*time.Millisecond {
+		t.Errorf("Got interval %v wanted %v", job.GetCanaryAnalysisInterval(), 10*time.Millisecond)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&runs) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&runs) < 3 {
+		t.Errorf("Got %v runs wanted at least %v", atomic.LoadInt32(&runs), 3)
+	}
+}
+
+func TestCanaryJob_ResetIntervalNoOverlap(t *testing.T) {
+	var runs, running, overlaps int32
+	release := make(chan bool)
+	job := CanaryJob{
+		Name:      "podinfo",
+		Namespace: "default",
+		function: func(name string, namespace string, skipTests bool) {
+			if atomic.AddInt32(&running, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			// block the bootstrap run until the intervals have been reset
+			if atomic.AddInt32(&runs, 1) == 1 {
+				<-release
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		},
+		done:             make(chan bool),
+		reset:            make(chan time.Duration, 1),
+		ticker:           time.NewTicker(time.Millisecond),
+		analysisInterval: time.Millisecond,
+	}
+	job.Start()
+	defer func() { job.Stop() }()
+
+	for _, interval := range []time.Duration{2 * time.Millisecond, time.Millisecond, 3 * time.Millisecond} {
+		job.ResetInterval(interval)
+	}
+	// let the new ticker fire while the bootstrap run is in progress
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&runs) < 5 && time.Now().Before(deadline) {
+		job.ResetInterval(time.Millisecond)
+		time.Sleep(time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&runs) < 5 {
+		t.Errorf("Got %v runs wanted at least %v", atomic.LoadInt32(&runs), 5)
+	}
+	if n := atomic.LoadInt32(&overlaps); n != 0 {
+		t.Errorf("Got %v concurrent runs wanted none", n)
+	}
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KillSwitch pauses the advancement of all canaries while its ConfigMap is engaged,
+// the switch is engaged if the ConfigMap exists and its engaged key is set to true
+type KillSwitch struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+	// canaries held since the switch was engaged, used to record a single event per canary
+	held *sync.Map
+}
+
+// NewKillSwitch creates a kill switch for the ConfigMap reference (<namespace>/<name>),
+// an empty reference disables the kill switch
+func NewKillSwitch(kubeClient kubernetes.Interface, configMap string) (KillSwitch, error) {
+	ks := KillSwitch{
+		kubeClient: kubeClient,
+		held:       new(sync.Map),
+	}
+	if configMap == "" {
+		return ks, nil
+	}
+
+	parts := strings.Split(configMap, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ks, fmt.Errorf("invalid kill switch ConfigMap %s, expected <namespace>/<name>", configMap)
+	}
+	ks.namespace, ks.name = parts[0], parts[1]
+	return ks, nil
+}
+
+// Enabled returns true if the kill switch ConfigMap is set
+func (k *KillSwitch) Enabled() bool {
+	return k.name != ""
+}
+
+// IsEngaged returns true and the reason set in the ConfigMap if the kill switch is engaged
+func (k *KillSwitch) IsEngaged() (bool, string, error) {
+	cm, err := k.kubeClient.CoreV1().ConfigMaps(k.namespace).Get(k.name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, "", nil
+		}
+		return false, "", fmt.Errorf("kill switch ConfigMap %s.%s query error %v", k.name, k.namespace, err)
+	}
+	if cm.Data["engaged"] != "true" {
+		return false, "", nil
+	}
+	return true, cm.Data["reason"], nil
+}
+
+// Hold marks the canary as held and returns true if it wasn't held before
+func (k *KillSwitch) Hold(key string) bool {
+	_, held := k.held.LoadOrStore(key, true)
+	return !held
+}
+
+// Release removes the canary from the held canaries and returns true if it was held
+func (k *KillSwitch) Release(key string) bool {
+	if _, held := k.held.Load(key); !held {
+		return false
+	}
+	k.held.Delete(key)
+	return true
+}
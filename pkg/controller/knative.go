@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	knative "github.com/weaveworks/flagger/pkg/apis/knative/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KnativeDeployer is managing the operations for Knative Service kind,
+// the primary is the revision pinned in the Route traffic split and
+// the canary is the latest revision of the service
+type KnativeDeployer struct {
+	CanaryDeployer
+}
+
+// Sync pins the service traffic to the latest ready revision on initialization
+func (c *KnativeDeployer) Sync(cd *flaggerv1.Canary) error {
+	ksvc, err := c.getService(cd)
+	if err != nil {
+		return err
+	}
+
+	if primary, _ := ksvc.GetTrafficTarget(knative.PrimaryTrafficTag); primary != nil && primary.RevisionName != "" {
+		return nil
+	}
+
+	if ksvc.Status.LatestReadyRevisionName == "" {
+		return fmt.Errorf("service %s.%s has no ready revision, retrying", ksvc.Name, ksvc.Namespace)
+	}
+
+	ksvcCopy := ksvc.DeepCopy()
+	ksvcCopy.Spec.Traffic = knative.NewCanaryTraffic(ksvc.Status.LatestReadyRevisionName, 100, 0)
+	_, err = c.flaggerClient.ServingV1().Services(cd.Namespace).Update(ksvcCopy)
+	if err != nil {
+		return fmt.Errorf("service %s.%s update error %v", ksvc.Name, ksvc.Namespace, err)
+	}
+
+	c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+		Infof("Service %s.%s traffic pinned to revision %s", ksvc.Name, ksvc.Namespace, ksvc.Status.LatestReadyRevisionName)
+	return nil
+}
+
+// AdoptPrimary is a no-op, the primary revision is managed by Knative
+func (c *KnativeDeployer) AdoptPrimary(cd *flaggerv1.Canary) (bool, error) {
+	return false, nil
+}
+
+// IsPrimaryReady checks that the service traffic is pinned to a primary revision
+func (c *KnativeDeployer) IsPrimaryReady(cd *flaggerv1.Canary) (bool, error) {
+	ksvc, err := c.getService(cd)
+	if err != nil {
+		return true, err
+	}
+
+	if primary, _ := ksvc.GetTrafficTarget(knative.PrimaryTrafficTag); primary == nil || primary.RevisionName == "" {
+		return true, fmt.Errorf("Halt advancement service %s.%s has no primary revision", ksvc.Name, ksvc.Namespace)
+	}
+	return true, nil
+}
+
+// IsCanaryReady checks that the latest created revision is ready,
+// it will return a non retriable error if the revision failed
+func (c *KnativeDeployer) IsCanaryReady(cd *flaggerv1.Canary) (bool, error) {
+	ksvc, err := c.getService(cd)
+	if err != nil {
+		return true, err
+	}
+
+	for _, condition := range ksvc.Status.Conditions {
+		if condition.Type == "ConfigurationsReady" && condition.Status == "False" {
+			return false, fmt.Errorf("revision %s failed: %s", ksvc.Status.LatestCreatedRevisionName, condition.Message)
+		}
+	}
+
+	if ksvc.Status.LatestReadyRevisionName != ksvc.Status.LatestCreatedRevisionName {
+		return true, fmt.Errorf("Halt advancement %s.%s waiting for revision %s to be ready",
+			ksvc.Name, ksvc.Namespace, ksvc.Status.LatestCreatedRevisionName)
+	}
+	return true, nil
+}
+
+// ShouldAdvance determines if the canary analysis can proceed
+func (c *KnativeDeployer) ShouldAdvance(cd *flaggerv1.Canary) (bool, error) {
+	if cd.Status.LastAppliedSpec == "" || cd.Status.Phase == flaggerv1.CanaryProgressing {
+		return true, nil
+	}
+	return c.HasTargetChanged(cd)
+}
+
+// HasTargetChanged returns true if a new revision has been created since the last sync
+func (c *KnativeDeployer) HasTargetChanged(cd *flaggerv1.Canary) (bool, error) {
+	ksvc, err := c.getService(cd)
+	if err != nil {
+		return false, err
+	}
+	return ksvc.Status.LatestCreatedRevisionName != cd.Status.LastAppliedSpec, nil
+}
+
+// GetTargetDrift returns the revision created since the last sync
+func (c *KnativeDeployer) GetTargetDrift(cd *flaggerv1.Canary) ([]string, error) {
+	ksvc, err := c.getService(cd)
+	if err != nil {
+		return nil, err
+	}
+	if ksvc.Status.LatestCreatedRevisionName == cd.Status.LastAppliedSpec {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("revision %s", ksvc.Status.LatestCreatedRevisionName)}, nil
+}
+
+// Promote pins the primary traffic to the latest ready revision
+func (c *KnativeDeployer) Promote(cd *flaggerv1.Canary) error {
+	ksvc, err := c.getService(cd)
+	if err != nil {
+		return err
+	}
+
+	revision := ksvc.Status.LatestReadyRevisionName
+	if revision == "" {
+		return fmt.Errorf("service %s.%s has no ready revision", ksvc.Name, ksvc.Namespace)
+	}
+
+	ksvcCopy := ksvc.DeepCopy()
+	_, primaryIndex := ksvcCopy.GetTrafficTarget(knative.PrimaryTrafficTag)
+	if primaryIndex < 0 {
+		ksvcCopy.Spec.Traffic = knative.NewCanaryTraffic(revision, 100, 0)
+	} else {
+		ksvcCopy.Spec.Traffic[primaryIndex].RevisionName = revision
+	}
+
+	_, err = c.flaggerClient.ServingV1().Services(cd.Namespace).Update(ksvcCopy)
+	if err != nil {
+		return fmt.Errorf("promoting revision %s of service %s.%s failed: %v", revision, ksvc.Name, ksvc.Namespace, err)
+	}
+	return nil
+}
+
+// RestorePrimary is not supported, the primary traffic is pinned to a revision that Knative keeps
+func (c *KnativeDeployer) RestorePrimary(cd *flaggerv1.Canary) (bool, error) {
+	return false, nil
+}
+
+// Scale is a no-op, the Knative autoscaler scales revisions based on traffic
+func (c *KnativeDeployer) Scale(cd *flaggerv1.Canary, replicas int32) error {
+	return nil
+}
+
+// SyncStatus records the latest created revision as the last applied spec and updates the canary status
+func (c *KnativeDeployer) SyncStatus(cd *flaggerv1.Canary, status flaggerv1.CanaryStatus) error {
+	ksvc, err := c.getService(cd)
+	if err != nil {
+		return err
+	}
+
+	cdCopy := cd.DeepCopy()
+	cdCopy.Status.Phase = status.Phase
+	cdCopy.Status.CanaryWeight = status.CanaryWeight
+	cdCopy.Status.FailedChecks = status.FailedChecks
+	cdCopy.Status.Iterations = status.Iterations
+	cdCopy.Status.Stage = status.Stage
+	cdCopy.Status.LastAppliedSpec = ksvc.Status.LatestCreatedRevisionName
+	cdCopy.Status.LastTransitionTime = metav1.Now()
+	cdCopy.Status.AnalysisStartTime = status.AnalysisStartTime
+	cdCopy.Status.HaltedIntervals = status.HaltedIntervals
+	cdCopy.Status.AnalysedIntervals = status.AnalysedIntervals
+	cdCopy.Status.ConsecutivePasses = status.ConsecutivePasses
+	cdCopy.Status.StepStartTime = status.StepStartTime
+	cdCopy.Status.StepRequests = status.StepRequests
+	cdCopy.Status.WarmupStartTime = status.WarmupStartTime
+	cdCopy.Status.ReadinessGateFailureTime = status.ReadinessGateFailureTime
+	cdCopy.Status.NoValuesStartTime = status.NoValuesStartTime
+	cdCopy.Status.PrimaryWarmupStartTime = status.PrimaryWarmupStartTime
+	cdCopy.Status.VerificationStartTime = status.VerificationStartTime
+	cdCopy.Status.SilenceID = status.SilenceID
+	cdCopy.Status.RolloutID = status.RolloutID
+	cdCopy.Status.InitialAnalysis = status.InitialAnalysis
+	cdCopy.Status.RouteMutations = status.RouteMutations
+	cdCopy.Status.Rollbacks = status.Rollbacks
+	cdCopy.Status.Approvals = status.Approvals
+	cdCopy.Status.ApprovalTimes = status.ApprovalTimes
+	cdCopy.Status.MetricWindows = status.MetricWindows
+	cdCopy.Status.IntervalWindows = status.IntervalWindows
+	cdCopy.Status.AnalysisInterval = status.AnalysisInterval
+	cdCopy.Status.ExcludedRegions = status.ExcludedRegions
+	cdCopy.Status.FailedCheckReason = status.FailedCheckReason
+	cdCopy.Status.RollbackReason = status.RollbackReason
+	cdCopy.Status.Progress = cdCopy.GetProgress()
+
+	_, err = c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).UpdateStatus(cdCopy)
+	if err != nil {
+		return fmt.Errorf("canary %s.%s status update error %v", cdCopy.Name, cdCopy.Namespace, err)
+	}
+	return nil
+}
+
+func (c *KnativeDeployer) getService(cd *flaggerv1.Canary) (*knative.Service, error) {
+	targetName := cd.Spec.TargetRef.Name
+	ksvc, err := c.flaggerClient.ServingV1().Services(cd.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("service %s.%s not found", targetName, cd.Namespace)
+		}
+		return nil, fmt.Errorf("service %s.%s query error %v", targetName, cd.Namespace, err)
+	}
+	return ksvc, nil
+}
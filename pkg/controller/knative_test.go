@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	knative "github.com/weaveworks/flagger/pkg/apis/knative/v1"
+	hpav1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestKnativeDeployer_SyncPromote(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd := newTestCanaryKnative()
+	ksvc := &knative.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "podinfo-knative"},
+		Status: knative.ServiceStatus{
+			LatestReadyRevisionName:   "podinfo-knative-00001",
+			LatestCreatedRevisionName: "podinfo-knative-00001",
+		},
+	}
+	if _, err := mocks.flaggerClient.ServingV1().Services("default").Create(ksvc); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Create(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deployer := mocks.ctrl.getDeployer(cd)
+	if err := deployer.Sync(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ksvc, err := mocks.flaggerClient.ServingV1().Services("default").Get("podinfo-knative", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	primary, _ := ksvc.GetTrafficTarget(knative.PrimaryTrafficTag)
+	if primary == nil || primary.RevisionName != "podinfo-knative-00001" {
+		t.Fatalf("Got primary traffic target %+v wanted revision %s", primary, "podinfo-knative-00001")
+	}
+
+	// new revision
+	ksvc.Status.LatestReadyRevisionName = "podinfo-knative-00002"
+	ksvc.Status.LatestCreatedRevisionName = "podinfo-knative-00002"
+	if _, err := mocks.flaggerClient.ServingV1().Services("default").Update(ksvc); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cd.Status.LastAppliedSpec = "podinfo-knative-00001"
+	changed, err := deployer.HasTargetChanged(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !changed {
+		t.Errorf("Got target changed %v wanted %v", changed, true)
+	}
+
+	if err := deployer.Promote(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ksvc, err = mocks.flaggerClient.ServingV1().Services("default").Get("podinfo-knative", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	primary, _ = ksvc.GetTrafficTarget(knative.PrimaryTrafficTag)
+	if primary.RevisionName != "podinfo-knative-00002" {
+		t.Errorf("Got primary revision %s wanted %s", primary.RevisionName, "podinfo-knative-00002")
+	}
+}
+
+func TestKnative_ValidateMetrics(t *testing.T) {
+	cd := newTestCanaryKnative()
+	cd.Spec.CanaryAnalysis.Metrics = []v1alpha3.CanaryMetric{
+		{Name: "success rate", Threshold: 99, Query: "sum(rate(revision_app_request_count[1m]))"},
+	}
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the built-in queries select the primary and canary workloads
+	cd.Spec.CanaryAnalysis.Metrics = []v1alpha3.CanaryMetric{
+		{Name: "istio_requests_total", Threshold: 99, Interval: "1m"},
+	}
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted built-in metric not supported for Knative services")
+	}
+
+	cd.Spec.CanaryAnalysis.Metrics = []v1alpha3.CanaryMetric{
+		{Name: "success rate", ThresholdPercent: 95, Query: "sum(rate(revision_app_request_count[1m]))"},
+	}
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted baseline not supported for Knative services")
+	}
+
+	// the default metrics are not injected
+	mocks := SetupMocks(false)
+	dm, err := NewDefaultMetrics("istio", 99, 500, "1m")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.defaultMetrics = dm
+	cd.Spec.CanaryAnalysis.Metrics = nil
+	if metrics := mocks.ctrl.analysisMetrics(cd); len(metrics) != 0 {
+		t.Errorf("Got metrics %+v wanted none", metrics)
+	}
+}
+
+func newTestCanaryKnative() *v1alpha3.Canary {
+	return &v1alpha3.Canary{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1alpha3.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo-knative",
+		},
+		Spec: v1alpha3.CanarySpec{
+			TargetRef: hpav1.CrossVersionObjectReference{
+				Name:       "podinfo-knative",
+				APIVersion: "serving.knative.dev/v1",
+				Kind:       "Service",
+			},
+			CanaryAnalysis: v1alpha3.CanaryAnalysis{
+				Threshold:  10,
+				StepWeight: 10,
+				MaxWeight:  50,
+			},
+		},
+	}
+}
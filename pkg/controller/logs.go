@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// LogsObserver is used to count the log lines matching an error pattern with the Loki query API
+type LogsObserver struct {
+	logsServer string
+}
+
+// NewLogsObserver creates an observer for the Loki server address
+func NewLogsObserver(logsServer string) LogsObserver {
+	return LogsObserver{
+		logsServer: logsServer,
+	}
+}
+
+// GetLogCount returns the number of log lines of the selected streams
+// matching the pattern during the interval, zero is returned if no line matched
+func (l *LogsObserver) GetLogCount(selector string, pattern string, interval string) (float64, error) {
+	if l.logsServer == "fake" {
+		return 0, nil
+	}
+	if l.logsServer == "" {
+		return 0, fmt.Errorf("logs server not configured")
+	}
+
+	lokiURL, err := url.Parse(l.logsServer)
+	if err != nil {
+		return 0, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("./loki/api/v1/query?query=%s", logCountQuery(selector, pattern, interval)))
+	if err != nil {
+		return 0, err
+	}
+
+	u = lokiURL.ResolveReference(u)
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading body: %s", err.Error())
+	}
+
+	if 400 <= r.StatusCode {
+		return 0, fmt.Errorf("error response: %s", string(b))
+	}
+
+	var values vectorQueryResponse
+	err = json.Unmarshal(b, &values)
+	if err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %s, '%s'", err.Error(), string(b))
+	}
+
+	var count float64
+	for _, v := range values.Data.Result {
+		if s, ok := v.Value[1].(string); ok {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return 0, err
+			}
+			count = f
+		}
+	}
+	return count, nil
+}
+
+func logCountQuery(selector string, pattern string, interval string) string {
+	return url.QueryEscape(`sum(count_over_time(` + selector + ` |~ ` + strconv.Quote(pattern) + ` [` + interval + `]))`)
+}
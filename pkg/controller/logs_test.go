@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogsObserver_GetLogCount(t *testing.T) {
+	var path, query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"3"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := NewLogsObserver(ts.URL)
+
+	val, err := observer.GetLogCount(`{namespace="default",app="podinfo"}`, "(?i)error|panic", "1m")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 3 {
+		t.Errorf("Got %v wanted %v", val, 3)
+	}
+	if path != "/loki/api/v1/query" {
+		t.Errorf("Got path %s wanted %s", path, "/loki/api/v1/query")
+	}
+	expected := `sum(count_over_time({namespace="default",app="podinfo"} |~ "(?i)error|panic" [1m]))`
+	if query != expected {
+		t.Errorf("Got query %s wanted %s", query, expected)
+	}
+}
+
+func TestLogsObserver_GetLogCountNoMatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json := `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := NewLogsObserver(ts.URL)
+
+	val, err := observer.GetLogCount(`{app="podinfo"}`, "error", "1m")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if val != 0 {
+		t.Errorf("Got %v wanted %v", val, 0)
+	}
+}
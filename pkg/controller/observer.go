@@ -7,14 +7,76 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 // CanaryObserver is used to query the Istio Prometheus db
 type CanaryObserver struct {
 	metricsServer string
+	// limits the number of in-flight queries, unlimited if nil
+	queryLimit chan struct{}
+	// deadline of a metrics server query, defaults to 5s
+	queryTimeout time.Duration
+	// number of times a query is retried on a transient error, zero disables the retries
+	queryRetries int
+	// wait before the first retry, doubled on every retry, defaults to 500ms
+	queryBackoff time.Duration
+	// client used to query the metrics server, e.g. for signed requests, defaults to http.DefaultClient
+	client *http.Client
+	// query a Thanos endpoint that aggregates the metrics of multiple clusters,
+	// the replicas are deduplicated and partial responses are rejected
+	federated bool
+	// query a VictoriaMetrics endpoint, the custom queries can use the MetricsQL extensions
+	// and are sent escaped with their whitespace preserved
+	metricsQL bool
+	// Istio telemetry version, v2 exposes the request duration histogram in milliseconds
+	istioTelemetry string
+	// remote-read endpoint queried for the long-range windows older than the retention
+	remoteReadURL string
+	// local retention of the metrics server, windows within the retention use the query API
+	retention time.Duration
+	// @ modifier support of the metrics server, checked on the first anchored baseline query
+	atModifier int32
+}
+
+// @ modifier support states of the metrics server
+const (
+	atModifierUnknown int32 = iota
+	atModifierSupported
+	atModifierUnsupported
+)
+
+// NewCanaryObserver creates an observer that runs at most
+// the specified number of concurrent queries (zero means unlimited)
+func NewCanaryObserver(metricsServer string, concurrency int, client *http.Client) CanaryObserver {
+	observer := CanaryObserver{
+		metricsServer: metricsServer,
+		client:        client,
+	}
+	if concurrency > 0 {
+		observer.queryLimit = make(chan struct{}, concurrency)
+	}
+	return observer
+}
+
+// timeout returns the metrics server query deadline
+func (c *CanaryObserver) timeout() time.Duration {
+	if c.queryTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return c.queryTimeout
+}
+
+func (c *CanaryObserver) httpClient() *http.Client {
+	if c.client == nil {
+		return http.DefaultClient
+	}
+	return c.client
 }
 
 type vectorQueryResponse struct {
@@ -29,49 +91,92 @@ type vectorQueryResponse struct {
 	}
 }
 
+// queryMetric runs the query, the transient errors are retried with an exponential backoff
 func (c *CanaryObserver) queryMetric(query string) (*vectorQueryResponse, error) {
+	backoff := c.queryBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	for attempt := 0; ; attempt++ {
+		values, transient, err := c.queryMetricOnce(query)
+		if err == nil || !transient || attempt >= c.queryRetries {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%v after %d retries", err, attempt)
+			}
+			return values, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// queryMetricOnce runs the query and reports whether the error is transient,
+// the network errors, the timeouts and the 5xx and 429 responses are retried
+// while the rejected queries and the malformed responses are not
+func (c *CanaryObserver) queryMetricOnce(query string) (*vectorQueryResponse, bool, error) {
 	promURL, err := url.Parse(c.metricsServer)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	u, err := url.Parse(fmt.Sprintf("./api/v1/query?query=%s", query))
+	// the metrics server aborts the evaluation of the queries running past the deadline
+	timeout := c.timeout()
+	rawQuery := fmt.Sprintf("./api/v1/query?query=%s&timeout=%s", query,
+		strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	if c.federated {
+		// an analysis over the metrics of some of the clusters is not meaningful
+		rawQuery += "&dedup=true&partial_response=false"
+	}
+	u, err := url.Parse(rawQuery)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	u = promURL.ResolveReference(u)
 
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	if c.queryLimit != nil {
+		c.queryLimit <- struct{}{}
+		defer func() { <-c.queryLimit }()
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
 	defer cancel()
 
-	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	r, err := c.httpClient().Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, true, fmt.Errorf("query timed out after %v", timeout)
+		}
+		return nil, true, err
 	}
 	defer r.Body.Close()
 
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading body: %s", err.Error())
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, true, fmt.Errorf("query timed out after %v", timeout)
+		}
+		return nil, true, fmt.Errorf("error reading body: %s", err.Error())
 	}
 
 	if 400 <= r.StatusCode {
-		return nil, fmt.Errorf("error response: %s", string(b))
+		transient := r.StatusCode >= 500 || r.StatusCode == http.StatusTooManyRequests
+		return nil, transient, fmt.Errorf("error response: %s", string(b))
 	}
 
 	var values vectorQueryResponse
 	err = json.Unmarshal(b, &values)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling result: %s, '%s'", err.Error(), string(b))
+		return nil, false, fmt.Errorf("error unmarshaling result: %s, '%s'", err.Error(), string(b))
 	}
 
-	return &values, nil
+	return &values, false, nil
 }
 
 // GetScalar runs the promql query and returns the first value found
@@ -80,8 +185,18 @@ func (c *CanaryObserver) GetScalar(query string) (float64, error) {
 		return 100, nil
 	}
 
-	query = strings.Replace(query, "\n", "", -1)
-	query = strings.Replace(query, " ", "", -1)
+	if c.metricsQL {
+		// MetricsQL keyword operators e.g. `default` and `if` are separated by whitespace
+		// and the comments end with a new line
+		query = strings.TrimSpace(query)
+		if err := validateMetricsQL(query); err != nil {
+			return 0, err
+		}
+		query = url.QueryEscape(query)
+	} else {
+		query = strings.Replace(query, "\n", "", -1)
+		query = strings.Replace(query, " ", "", -1)
+	}
 
 	var value *float64
 	result, err := c.queryMetric(query)
@@ -106,91 +221,196 @@ func (c *CanaryObserver) GetScalar(query string) (float64, error) {
 	return *value, nil
 }
 
-func (c *CanaryObserver) GetEnvoySuccessRate(name string, namespace string, metric string, interval string) (float64, error) {
+func (c *CanaryObserver) GetEnvoySuccessRate(name string, namespace string, metric string, interval string, opts QueryOptions) (float64, error) {
 	if c.metricsServer == "fake" {
 		return 100, nil
 	}
 
-	var rate *float64
-	querySt := url.QueryEscape(`sum(rate(` +
-		metric + `{kubernetes_namespace="` +
-		namespace + `",app="` +
-		name + `",envoy_response_code!~"5.*"}[1m])) / sum(rate(` +
-		metric + `{kubernetes_namespace="` +
-		namespace + `",app="` +
-		name + `"}[` +
-		interval + `])) * 100 `)
-	result, err := c.queryMetric(querySt)
+	return c.queryValue(envoySuccessRateQuery(name, namespace, metric, interval, opts, nil), metric)
+}
+
+// GetDeploymentCounter returns the requests success rate using istio_requests_total metric
+func (c *CanaryObserver) GetDeploymentCounter(name string, namespace string, metric string, interval string, opts QueryOptions) (float64, error) {
+	if c.metricsServer == "fake" {
+		return 100, nil
+	}
+
+	return c.queryValue(istioSuccessRateQuery(name, namespace, metric, interval, opts, nil), metric)
+}
+
+// GetRequestTotal returns the number of requests counted by a success rate metric during the interval
+func (c *CanaryObserver) GetRequestTotal(name string, namespace string, metric string, interval string, opts QueryOptions) (float64, error) {
+	if c.metricsServer == "fake" {
+		return 100, nil
+	}
+
+	value, err := c.queryValue(requestTotalQuery(name, namespace, metric, interval, opts), metric)
+	if err != nil && strings.Contains(err.Error(), "no values found") {
+		return 0, nil
+	}
+	return value, err
+}
+
+// GetBurnRate returns the error budget burn rate of a success rate metric over the given window,
+// the burn rate is the error ratio divided by the error budget of the objective
+func (c *CanaryObserver) GetBurnRate(name string, namespace string, metric string, window string, objective float64, opts QueryOptions) (float64, error) {
+	if c.metricsServer == "fake" {
+		return 0, nil
+	}
+
+	return c.queryValue(burnRateQuery(name, namespace, metric, window, objective, opts), metric)
+}
+
+// GetDeploymentHistogram returns the 99P requests delay using istio_request_duration_seconds metrics
+// or istio_request_duration_milliseconds with telemetry v2, both classic and native histograms are supported
+func (c *CanaryObserver) GetDeploymentHistogram(name string, namespace string, metric string, interval string, opts QueryOptions) (time.Duration, error) {
+	if c.metricsServer == "fake" {
+		return 1, nil
+	}
+
+	opts, unit := c.istioDuration(opts)
+	rate, err := c.queryValue(istioLatencyQuery(name, namespace, metric, interval, opts, nil), metric)
 	if err != nil {
 		return 0, err
 	}
+	return time.Duration(rate * float64(unit)), nil
+}
 
-	for _, v := range result.Data.Result {
-		metricValue := v.Value[1]
-		switch metricValue.(type) {
-		case string:
-			f, err := strconv.ParseFloat(metricValue.(string), 64)
-			if err != nil {
-				return 0, err
-			}
-			rate = &f
-		}
-	}
-	if rate == nil {
-		return 0, fmt.Errorf("no values found for metric %s", metric)
+// GetSaturation returns the highest CPU or memory usage of the workload pods
+// as a percentage of the container limits or requests
+func (c *CanaryObserver) GetSaturation(name string, namespace string, resource string, reference string, container string, interval string) (float64, error) {
+	if c.metricsServer == "fake" {
+		return 0, nil
 	}
-	return *rate, nil
+
+	return c.queryValue(saturationQuery(name, namespace, resource, reference, container, interval), resource)
 }
 
-// GetDeploymentCounter returns the requests success rate using istio_requests_total metric
-func (c *CanaryObserver) GetDeploymentCounter(name string, namespace string, metric string, interval string) (float64, error) {
+// GetRequestCount returns the number of requests served by the workload during the given interval,
+// the App Mesh Envoy metrics are used for the appmesh provider and the Istio metrics otherwise
+func (c *CanaryObserver) GetRequestCount(name string, namespace string, provider string, interval time.Duration) (float64, error) {
 	if c.metricsServer == "fake" {
 		return 100, nil
 	}
 
-	var rate *float64
-	querySt := url.QueryEscape(`sum(rate(` +
-		metric + `{reporter="destination",destination_workload_namespace=~"` +
-		namespace + `",destination_workload=~"` +
-		name + `",response_code!~"5.*"}[1m])) / sum(rate(` +
-		metric + `{reporter="destination",destination_workload_namespace=~"` +
-		namespace + `",destination_workload=~"` +
-		name + `"}[` +
-		interval + `])) * 100 `)
-	result, err := c.queryMetric(querySt)
+	return c.queryValue(requestCountQuery(name, namespace, provider, interval), "requests")
+}
+
+// GetSampleAge returns the age of the latest request metric sample of the workload,
+// no samples means there is no lag to report and zero is returned
+func (c *CanaryObserver) GetSampleAge(name string, namespace string, provider string) (time.Duration, error) {
+	if c.metricsServer == "fake" {
+		return 0, nil
+	}
+
+	age, err := c.queryValue(sampleAgeQuery(name, namespace, provider), "requests")
 	if err != nil {
+		if strings.Contains(err.Error(), "no values found") {
+			return 0, nil
+		}
 		return 0, err
 	}
+	return time.Duration(age * float64(time.Second)), nil
+}
 
-	for _, v := range result.Data.Result {
-		metricValue := v.Value[1]
-		switch metricValue.(type) {
-		case string:
-			f, err := strconv.ParseFloat(metricValue.(string), 64)
-			if err != nil {
-				return 0, err
-			}
-			rate = &f
+// HasHistogram returns true if the workload exposes classic histogram buckets
+// or native histogram samples for the given metric
+func (c *CanaryObserver) HasHistogram(name string, namespace string, metric string) (bool, error) {
+	if c.metricsServer == "fake" {
+		return true, nil
+	}
+
+	opts, _ := c.istioDuration(QueryOptions{})
+	_, err := c.queryValue(istioHistogramSeriesQuery(name, namespace, opts.metricName(metric)), metric)
+	if err != nil {
+		if strings.Contains(err.Error(), "no values found") {
+			return false, nil
 		}
+		return false, err
 	}
-	if rate == nil {
-		return 0, fmt.Errorf("no values found for metric %s", metric)
+	return true, nil
+}
+
+// GetBaseline returns the value of a built-in metric for the baseline workload (primary or baseline deployment),
+// if an anchor is specified the query is evaluated at the anchor time using the PromQL @ modifier,
+// the request duration is returned in milliseconds
+func (c *CanaryObserver) GetBaseline(workload string, namespace string, metric string, interval string, opts QueryOptions, anchor *time.Time) (float64, error) {
+	if c.metricsServer == "fake" {
+		return 100, nil
+	}
+
+	if anchor != nil {
+		if err := c.checkAtModifier(); err != nil {
+			return 0, err
+		}
+	}
+
+	switch metric {
+	case "envoy_cluster_upstream_rq":
+		return c.queryValue(envoySuccessRateQuery(workload, namespace, metric, interval, opts, anchor), metric)
+	case "istio_requests_total":
+		return c.queryValue(istioSuccessRateQuery(workload, namespace, metric, interval, opts, anchor), metric)
+	case "istio_request_duration_seconds_bucket":
+		opts, unit := c.istioDuration(opts)
+		rate, err := c.queryValue(istioLatencyQuery(workload, namespace, metric, interval, opts, anchor), metric)
+		if err != nil {
+			return 0, err
+		}
+		return rate * float64(unit/time.Millisecond), nil
+	default:
+		return 0, fmt.Errorf("baseline not supported for metric %s", metric)
 	}
-	return *rate, nil
 }
 
-// GetDeploymentHistogram returns the 99P requests delay using istio_request_duration_seconds_bucket metrics
-func (c *CanaryObserver) GetDeploymentHistogram(name string, namespace string, metric string, interval string) (time.Duration, error) {
+// istioDuration returns the query options of the Istio request duration histogram and the unit of its buckets,
+// with telemetry v2 the istio_request_duration_milliseconds histogram is queried unless the schema sets a metric name
+func (c *CanaryObserver) istioDuration(opts QueryOptions) (QueryOptions, time.Duration) {
+	if c.istioTelemetry != "v2" || opts.MetricName != "" {
+		return opts, time.Second
+	}
+	opts.MetricName = "istio_request_duration_milliseconds_bucket"
+	return opts, time.Millisecond
+}
+
+// GetLongRangeBaseline returns the average of a recorded series for the workload over the window,
+// the window is read from the remote-read endpoint if it exceeds the local retention
+func (c *CanaryObserver) GetLongRangeBaseline(workload string, namespace string, series string, window string, opts QueryOptions) (float64, error) {
 	if c.metricsServer == "fake" {
-		return 1, nil
+		return 100, nil
+	}
+
+	duration, err := time.ParseDuration(window)
+	if err != nil {
+		return 0, err
+	}
+
+	labels := opts.seriesLabels(workload, namespace)
+	if c.remoteReadURL == "" || duration <= c.retention {
+		query := fmt.Sprintf("avg_over_time(%s{%s}[%s])", series, labelMatchers(labels), window)
+		return c.queryValue(url.QueryEscape(query), series)
+	}
+
+	labels["__name__"] = series
+	end := time.Now()
+	values, err := c.remoteRead(labels, end.Add(-duration), end)
+	if err != nil {
+		return 0, err
 	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("no values found for metric %s", series)
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values)), nil
+}
+
+// queryValue runs the escaped promql query and returns the last value found
+func (c *CanaryObserver) queryValue(query string, metric string) (float64, error) {
 	var rate *float64
-	querySt := url.QueryEscape(`histogram_quantile(0.99, sum(rate(` +
-		metric + `{reporter="destination",destination_workload=~"` +
-		name + `", destination_workload_namespace=~"` +
-		namespace + `"}[` +
-		interval + `])) by (le))`)
-	result, err := c.queryMetric(querySt)
+	result, err := c.queryMetric(query)
 	if err != nil {
 		return 0, err
 	}
@@ -209,13 +429,358 @@ func (c *CanaryObserver) GetDeploymentHistogram(name string, namespace string, m
 	if rate == nil {
 		return 0, fmt.Errorf("no values found for metric %s", metric)
 	}
-	ms := time.Duration(int64(*rate*1000)) * time.Millisecond
-	return ms, nil
+	return *rate, nil
+}
+
+// checkAtModifier returns an error if the metrics server rejects the @ modifier,
+// it's enabled by default from Prometheus v2.33 and with the promql-at-modifier feature from v2.25,
+// the support is detected with a probe query and cached for the lifetime of the observer
+func (c *CanaryObserver) checkAtModifier() error {
+	unsupported := fmt.Errorf("baseline anchor requires the PromQL @ modifier, " +
+		"enabled by default from Prometheus v2.33 or with --enable-feature=promql-at-modifier from v2.25")
+	switch atomic.LoadInt32(&c.atModifier) {
+	case atModifierSupported:
+		return nil
+	case atModifierUnsupported:
+		return unsupported
+	}
+
+	_, err := c.queryMetric(url.QueryEscape("up @ 0"))
+	if err == nil {
+		atomic.StoreInt32(&c.atModifier, atModifierSupported)
+		return nil
+	}
+	// the servers without the @ modifier reject the query with a parse error,
+	// the other errors are not cached and the support is checked again on the next query
+	if strings.Contains(err.Error(), "bad_data") {
+		atomic.StoreInt32(&c.atModifier, atModifierUnsupported)
+		return unsupported
+	}
+	return err
+}
+
+// QueryOptions customizes the built-in metric queries
+type QueryOptions struct {
+	// response codes counted by the success rate queries
+	Codes StatusCodes
+	// label matchers used instead of the workload labels, e.g. for Pushgateway series
+	Selector map[string]string
+	// label matchers added to the workload labels or to the selector
+	Labels map[string]string
+	// label names used instead of the built-in workload, namespace and response code labels,
+	// e.g. for the metrics exported through an OpenTelemetry collector
+	MetricName     string
+	WorkloadLabel  string
+	NamespaceLabel string
+	CodeLabel      string
+	// evaluate the range selectors this long ago with the PromQL offset modifier
+	Offset string
+	// the series with these values of the exclude label are left out, e.g. the unhealthy regions
+	ExcludeLabel  string
+	ExcludeValues []string
+	// the requests with a path matching these regular expressions are left out, e.g. the health checks
+	PathLabel     string
+	ExcludedPaths []string
+	// aggregation of the per pod values (avg, min, max or worst-pod), empty for a fleet-wide query
+	Aggregation string
+	PodLabel    string
+}
+
+// StatusCodes holds the response code classes (e.g. 2xx) or codes
+// counted by the success rate queries
+type StatusCodes struct {
+	Success []string
+	Total   []string
+}
+
+var statusCodeRegexp = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+// Validate checks that the codes are either classes between 1xx and 5xx or codes between 100 and 599
+func (s StatusCodes) Validate() error {
+	for _, code := range append(append([]string{}, s.Success...), s.Total...) {
+		if !statusCodeRegexp.MatchString(code) {
+			return fmt.Errorf("invalid response code %s, must be a class between 1xx and 5xx or a code between 100 and 599", code)
+		}
+	}
+	return nil
+}
+
+// successMatcher returns the label matcher of the successful requests,
+// defaults to all the non 5xx codes
+func (s StatusCodes) successMatcher(label string) string {
+	if len(s.Success) == 0 {
+		return label + `!~"5.*"`
+	}
+	return label + `=~"` + codeRegexp(s.Success) + `"`
+}
+
+// totalMatcher returns the label matcher of the total requests,
+// defaults to all codes
+func (s StatusCodes) totalMatcher(label string) string {
+	if len(s.Total) == 0 {
+		return ""
+	}
+	return `,` + label + `=~"` + codeRegexp(s.Total) + `"`
+}
+
+func codeRegexp(codes []string) string {
+	var patterns []string
+	for _, code := range codes {
+		patterns = append(patterns, strings.Replace(code, "xx", "..", 1))
+	}
+	return strings.Join(patterns, "|")
+}
+
+// atModifier returns the PromQL @ modifier for the given time
+func atModifier(at *time.Time) string {
+	if at == nil {
+		return ""
+	}
+	return fmt.Sprintf(" @ %d", at.Unix())
+}
+
+// modifiers returns the PromQL offset and @ modifiers of the range selectors
+func (o QueryOptions) modifiers(at *time.Time) string {
+	if o.Offset == "" {
+		return atModifier(at)
+	}
+	return " offset " + o.Offset + atModifier(at)
+}
+
+// groupBy returns the grouping clause of the sum aggregations,
+// the pod label is added when the values are aggregated per pod
+func (o QueryOptions) groupBy(labels ...string) string {
+	if o.Aggregation != "" {
+		pod := o.PodLabel
+		if pod == "" {
+			pod = "pod"
+		}
+		labels = append(labels, pod)
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return " by (" + strings.Join(labels, ", ") + ")"
+}
+
+// aggregate applies the aggregation to the per pod values of the query,
+// the worst pod has the lowest value if higher values are better and the highest otherwise
+func (o QueryOptions) aggregate(query string, higherIsBetter bool) string {
+	switch o.Aggregation {
+	case "":
+		return query
+	case "worst-pod":
+		if higherIsBetter {
+			return `min(` + query + `)`
+		}
+		return `max(` + query + `)`
+	default:
+		return o.Aggregation + `(` + query + `)`
+	}
+}
+
+func envoySuccessRateQuery(name string, namespace string, metric string, interval string, opts QueryOptions, at *time.Time) string {
+	matchers := envoyMatchers(name, namespace, opts)
+	return url.QueryEscape(opts.aggregate(`sum(rate(`+
+		opts.metricName(metric)+`{`+matchers+`,`+opts.Codes.successMatcher(opts.codeLabel("envoy_response_code"))+`}[1m]`+opts.modifiers(at)+`))`+opts.groupBy()+` / sum(rate(`+
+		opts.metricName(metric)+`{`+matchers+opts.Codes.totalMatcher(opts.codeLabel("envoy_response_code"))+`}[`+
+		interval+`]`+opts.modifiers(at)+`))`+opts.groupBy()+` * 100 `, true))
+}
+
+func istioSuccessRateQuery(name string, namespace string, metric string, interval string, opts QueryOptions, at *time.Time) string {
+	matchers := istioMatchers(name, namespace, opts)
+	return url.QueryEscape(opts.aggregate(`sum(rate(`+
+		opts.metricName(metric)+`{`+matchers+`,`+opts.Codes.successMatcher(opts.codeLabel("response_code"))+`}[1m]`+opts.modifiers(at)+`))`+opts.groupBy()+` / sum(rate(`+
+		opts.metricName(metric)+`{`+matchers+opts.Codes.totalMatcher(opts.codeLabel("response_code"))+`}[`+
+		interval+`]`+opts.modifiers(at)+`))`+opts.groupBy()+` * 100 `, true))
+}
+
+func burnRateQuery(name string, namespace string, metric string, window string, objective float64, opts QueryOptions) string {
+	matchers := istioMatchers(name, namespace, opts)
+	label := opts.codeLabel("response_code")
+	if metric == "envoy_cluster_upstream_rq" {
+		matchers = envoyMatchers(name, namespace, opts)
+		label = opts.codeLabel("envoy_response_code")
+	}
+	budget := `(1 - ` + strconv.FormatFloat(objective, 'g', -1, 64) + ` / 100)`
+	return url.QueryEscape(`(1 - sum(rate(` +
+		opts.metricName(metric) + `{` + matchers + `,` + opts.Codes.successMatcher(label) + `}[` + window + `]` + opts.modifiers(nil) + `)) / sum(rate(` +
+		opts.metricName(metric) + `{` + matchers + opts.Codes.totalMatcher(label) + `}[` + window + `]` + opts.modifiers(nil) + `))) / ` + budget)
+}
+
+func requestTotalQuery(name string, namespace string, metric string, interval string, opts QueryOptions) string {
+	matchers := istioMatchers(name, namespace, opts) + opts.Codes.totalMatcher(opts.codeLabel("response_code"))
+	if metric == "envoy_cluster_upstream_rq" {
+		matchers = envoyMatchers(name, namespace, opts) + opts.Codes.totalMatcher(opts.codeLabel("envoy_response_code"))
+	}
+	return url.QueryEscape(`sum(increase(` + opts.metricName(metric) + `{` + matchers + `}[` + interval + `]` + opts.modifiers(nil) + `))`)
+}
+
+func requestCountQuery(name string, namespace string, provider string, interval time.Duration) string {
+	seconds := int(interval.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	if provider == "appmesh" {
+		return url.QueryEscape(fmt.Sprintf(`sum(increase(envoy_cluster_upstream_rq{kubernetes_namespace="%s",app="%s"}[%ds]))`,
+			namespace, name, seconds))
+	}
+	return url.QueryEscape(fmt.Sprintf(`sum(increase(istio_requests_total{%s}[%ds]))`,
+		istioMatchers(name, namespace, QueryOptions{}), seconds))
+}
+
+func sampleAgeQuery(name string, namespace string, provider string) string {
+	if provider == "appmesh" {
+		return url.QueryEscape(fmt.Sprintf(`time() - max(timestamp(envoy_cluster_upstream_rq{kubernetes_namespace="%s",app="%s"}))`,
+			namespace, name))
+	}
+	return url.QueryEscape(fmt.Sprintf(`time() - max(timestamp(istio_requests_total{%s}))`,
+		istioMatchers(name, namespace, QueryOptions{})))
+}
+
+// saturationQuery divides the cAdvisor usage of the pods by the kube-state-metrics limits or requests,
+// the pod name pattern excludes the primary pods (<name>-primary-<hash>-<id>)
+func saturationQuery(name string, namespace string, resource string, reference string, container string, interval string) string {
+	if reference == "" {
+		reference = "limits"
+	}
+	pods := `namespace="` + namespace + `",pod=~"` + name + `-[a-z0-9]+-[a-z0-9]+"`
+	containers := `container!="",container!="POD"`
+	if container != "" {
+		containers = `container="` + container + `"`
+	}
+	usage := `rate(container_cpu_usage_seconds_total{` + pods + `,` + containers + `}[` + interval + `])`
+	if resource == "memory" {
+		usage = `container_memory_working_set_bytes{` + pods + `,` + containers + `}`
+	}
+	return url.QueryEscape(`max(sum by (pod) (` + usage + `) / sum by (pod) (kube_pod_container_resource_` +
+		reference + `{` + pods + `,` + containers + `,resource="` + resource + `"})) * 100`)
+}
+
+// istioLatencyQuery returns the P99 latency computed from either classic
+// histograms (_bucket series with le labels) or Prometheus native histograms
+func istioLatencyQuery(name string, namespace string, metric string, interval string, opts QueryOptions, at *time.Time) string {
+	base := strings.TrimSuffix(opts.metricName(metric), "_bucket")
+	selector := `{` + istioMatchers(name, namespace, opts) + `}`
+	return url.QueryEscape(opts.aggregate(`histogram_quantile(0.99, sum(rate(`+
+		base+`_bucket`+selector+`[`+
+		interval+`]`+opts.modifiers(at)+`))`+opts.groupBy("le")+`) or histogram_quantile(0.99, sum(rate(`+
+		base+selector+`[`+
+		interval+`]`+opts.modifiers(at)+`))`+opts.groupBy()+`)`, false))
+}
+
+// istioHistogramSeriesQuery counts the classic or native histogram series of a workload
+func istioHistogramSeriesQuery(name string, namespace string, metric string) string {
+	base := strings.TrimSuffix(metric, "_bucket")
+	selector := `{` + istioMatchers(name, namespace, QueryOptions{}) + `}`
+	return url.QueryEscape(`count(` + base + `_bucket` + selector + `) or count(` + base + selector + `)`)
+}
+
+// istioMatchers returns the label matchers of the Istio workload metrics
+// or the custom selector if one is specified
+func istioMatchers(name string, namespace string, opts QueryOptions) string {
+	matchers := `reporter="destination",destination_workload_namespace=~"` +
+		namespace + `",destination_workload=~"` +
+		name + `"`
+	return opts.matchers(opts.workloadMatchers(matchers, name, namespace))
+}
+
+// envoyMatchers returns the label matchers of the App Mesh Envoy metrics
+// or the custom selector if one is specified
+func envoyMatchers(name string, namespace string, opts QueryOptions) string {
+	matchers := `kubernetes_namespace="` +
+		namespace + `",app="` +
+		name + `"`
+	return opts.matchers(opts.workloadMatchers(matchers, name, namespace))
+}
+
+// workloadMatchers returns the built-in workload matchers or the matchers of the custom label names
+func (o QueryOptions) workloadMatchers(builtin string, name string, namespace string) string {
+	if o.WorkloadLabel == "" {
+		return builtin
+	}
+	return o.NamespaceLabel + `=~"` + namespace + `",` + o.WorkloadLabel + `=~"` + name + `"`
+}
+
+// seriesLabels returns the equality labels of the workload recorded series,
+// the schema labels replace the Istio workload labels
+func (o QueryOptions) seriesLabels(name string, namespace string) map[string]string {
+	labels := map[string]string{
+		"destination_workload":           name,
+		"destination_workload_namespace": namespace,
+	}
+	if o.WorkloadLabel != "" {
+		labels = map[string]string{
+			o.WorkloadLabel:  name,
+			o.NamespaceLabel: namespace,
+		}
+	}
+	for k, v := range o.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// metricName returns the queried metric name, defaults to the built-in metric
+func (o QueryOptions) metricName(builtin string) string {
+	if o.MetricName == "" {
+		return builtin
+	}
+	return o.MetricName
+}
+
+// codeLabel returns the response code label name, defaults to the built-in label
+func (o QueryOptions) codeLabel(builtin string) string {
+	if o.CodeLabel == "" {
+		return builtin
+	}
+	return o.CodeLabel
+}
+
+// matchers replaces the workload matchers with the selector and appends the extra labels
+// and the exclusion matchers
+func (o QueryOptions) matchers(workload string) string {
+	if len(o.Selector) > 0 {
+		workload = labelMatchers(o.Selector)
+	}
+	if len(o.Labels) > 0 {
+		workload = workload + "," + labelMatchers(o.Labels)
+	}
+	if o.ExcludeLabel != "" && len(o.ExcludeValues) > 0 {
+		var values []string
+		for _, v := range o.ExcludeValues {
+			values = append(values, regexp.QuoteMeta(v))
+		}
+		workload = workload + "," + fmt.Sprintf(`%s!~%q`, o.ExcludeLabel, strings.Join(values, "|"))
+	}
+	if o.PathLabel != "" && len(o.ExcludedPaths) > 0 {
+		workload = workload + "," + fmt.Sprintf(`%s!~%q`, o.PathLabel, strings.Join(o.ExcludedPaths, "|"))
+	}
+	return workload
+}
+
+// labelMatchers returns the equality matchers of the labels sorted by name
+func labelMatchers(labels map[string]string) string {
+	var names []string
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matchers []string
+	for _, name := range names {
+		matchers = append(matchers, fmt.Sprintf(`%s=%q`, name, labels[name]))
+	}
+	return strings.Join(matchers, ",")
 }
 
 // CheckMetricsServer call Prometheus status endpoint and returns an error if
 // the API is unreachable
-func CheckMetricsServer(address string) (bool, error) {
+func CheckMetricsServer(address string, client *http.Client) (bool, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
 	promURL, err := url.Parse(address)
 	if err != nil {
 		return false, err
@@ -236,7 +801,7 @@ func CheckMetricsServer(address string) (bool, error) {
 	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
 	defer cancel()
 
-	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	r, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return false, err
 	}
@@ -253,3 +818,73 @@ func CheckMetricsServer(address string) (bool, error) {
 
 	return true, nil
 }
+
+// VictoriaMetricsURL returns the Prometheus API prefix of a VictoriaMetrics endpoint,
+// the queries of a cluster tenant (accountID or accountID:projectID) are served by vmselect
+// under /select/<tenant>/prometheus/
+func VictoriaMetricsURL(address string, tenant string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	if tenant != "" {
+		if !victoriaMetricsTenantRegexp.MatchString(tenant) {
+			return "", fmt.Errorf("invalid VictoriaMetrics tenant %s, expected accountID or accountID:projectID", tenant)
+		}
+		u.Path = fmt.Sprintf("%s/select/%s/prometheus", strings.TrimSuffix(u.Path, "/"), tenant)
+	}
+	// the query API is resolved relative to the prefix
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path = u.Path + "/"
+	}
+	return u.String(), nil
+}
+
+var victoriaMetricsTenantRegexp = regexp.MustCompile(`^[0-9]+(:[0-9]+)?$`)
+
+// validateMetricsQL checks that the brackets and the string literals of a MetricsQL query are balanced,
+// the quotes and backticks are supported as string delimiters and the # comments are skipped
+func validateMetricsQL(query string) error {
+	if query == "" {
+		return fmt.Errorf("metricsql: empty query")
+	}
+
+	pairs := map[rune]rune{')': '(', '}': '{', ']': '['}
+	var stack []rune
+	var quote rune
+	comment, escaped := false, false
+	for _, r := range query {
+		switch {
+		case comment:
+			if r == '\n' {
+				comment = false
+			}
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if r == '\\' && quote != '`' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '#':
+			comment = true
+		case r == '(' || r == '{' || r == '[':
+			stack = append(stack, r)
+		case r == ')' || r == '}' || r == ']':
+			if len(stack) == 0 || stack[len(stack)-1] != pairs[r] {
+				return fmt.Errorf("metricsql: unexpected %q in query %s", r, query)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("metricsql: unterminated string in query %s", query)
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("metricsql: unclosed %q in query %s", stack[len(stack)-1], query)
+	}
+	return nil
+}
@@ -3,6 +3,9 @@ package controller
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -18,7 +21,7 @@ func TestCanaryObserver_GetDeploymentCounter(t *testing.T) {
 		metricsServer: ts.URL,
 	}
 
-	val, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m")
+	val, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -40,7 +43,7 @@ func TestCanaryObserver_GetDeploymentHistogram(t *testing.T) {
 		metricsServer: ts.URL,
 	}
 
-	val, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m")
+	val, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m", QueryOptions{})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -57,7 +60,7 @@ func TestCheckMetricsServer(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	ok, err := CheckMetricsServer(ts.URL)
+	ok, err := CheckMetricsServer(ts.URL, nil)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -73,7 +76,7 @@ func TestCheckMetricsServer_Offline(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	ok, err := CheckMetricsServer(ts.URL)
+	ok, err := CheckMetricsServer(ts.URL, nil)
 	if err == nil {
 		t.Errorf("Got no error wanted %v", http.StatusBadGateway)
 	}
@@ -82,3 +85,717 @@ func TestCheckMetricsServer_Offline(t *testing.T) {
 		t.Errorf("Got %v wanted %v", ok, false)
 	}
 }
+
+func TestCanaryObserver_GetBaseline(t *testing.T) {
+	var probes int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if query == "up @ 0" {
+			probes++
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+			return
+		}
+		if !strings.Contains(query, `destination_workload=~"podinfo-primary"`) {
+			t.Errorf("Got query %s wanted primary workload", query)
+		}
+		if !strings.Contains(query, "[1m] @ 1545905245") {
+			t.Errorf("Got query %s wanted @ modifier", query)
+		}
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"99"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	anchor := time.Unix(1545905245, 0)
+	val, err := observer.GetBaseline("podinfo-primary", "default", "istio_requests_total", "1m", QueryOptions{}, &anchor)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 99 {
+		t.Errorf("Got %v wanted %v", val, 99)
+	}
+
+	// the @ modifier support is cached
+	if _, err := observer.GetBaseline("podinfo-primary", "default", "istio_requests_total", "1m", QueryOptions{}, &anchor); err != nil {
+		t.Fatal(err.Error())
+	}
+	if probes != 1 {
+		t.Errorf("Got %v probe queries wanted %v", probes, 1)
+	}
+}
+
+func TestCanaryObserver_GetBaselineUnsupported(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"1:4: parse error: @ modifier is disabled"}`))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	anchor := time.Unix(1545905245, 0)
+	_, err := observer.GetBaseline("podinfo-primary", "default", "istio_requests_total", "1m", QueryOptions{}, &anchor)
+	if err == nil || !strings.Contains(err.Error(), "@ modifier") {
+		t.Errorf("Got error %v wanted @ modifier error", err)
+	}
+}
+
+func TestCanaryObserver_QueryLimit(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"100"]}]}}`
+		w.Write([]byte(json))
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	observer := NewCanaryObserver(ts.URL, 2, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{}); err != nil {
+				t.Error(err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > 2 {
+		t.Errorf("Got %v concurrent queries wanted at most %v", maxInFlight, 2)
+	}
+}
+
+func TestCanaryObserver_GetDeploymentHistogramNative(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if !strings.Contains(query, "by (le)) or histogram_quantile(0.99, sum(rate(istio_request_duration_seconds{") {
+			t.Errorf("Got query %s without native histogram fallback", query)
+		}
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"0.0125"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	val, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m", QueryOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 12500*time.Microsecond {
+		t.Errorf("Got %v wanted %v", val, 12500*time.Microsecond)
+	}
+}
+
+func TestCanaryObserver_HasHistogram(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json := `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	ok, err := observer.HasHistogram("podinfo-primary", "default", "istio_request_duration_seconds_bucket")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if ok {
+		t.Errorf("Got histogram found %v wanted %v", ok, false)
+	}
+}
+
+func TestCanaryObserver_IstioTelemetryV2(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"200"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer:  ts.URL,
+		istioTelemetry: "v2",
+	}
+
+	val, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m", QueryOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if val != 200*time.Millisecond {
+		t.Errorf("Got %v wanted %v", val, 200*time.Millisecond)
+	}
+	if !strings.Contains(query, "istio_request_duration_milliseconds_bucket{") {
+		t.Errorf("Got query %s wanted the milliseconds histogram", query)
+	}
+
+	baseline, err := observer.GetBaseline("podinfo-primary", "default", "istio_request_duration_seconds_bucket", "1m", QueryOptions{}, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if baseline != 200 {
+		t.Errorf("Got baseline %v wanted %v", baseline, 200)
+	}
+
+	if _, err := observer.HasHistogram("podinfo-primary", "default", "istio_request_duration_seconds_bucket"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(query, "count(istio_request_duration_milliseconds_bucket{") {
+		t.Errorf("Got query %s wanted the milliseconds histogram", query)
+	}
+
+	opts := QueryOptions{MetricName: "http_request_duration_seconds_bucket"}
+	if _, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(query, "http_request_duration_seconds_bucket{") {
+		t.Errorf("Got query %s wanted the schema metric", query)
+	}
+}
+
+func TestCanaryObserver_GetDeploymentCounterStatusCodes(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	codes := StatusCodes{Success: []string{"2xx", "4xx"}, Total: []string{"2xx", "4xx", "503"}}
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{Codes: codes}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(query, `response_code=~"2..|4.."`) {
+		t.Errorf("Got query %s wanted success codes matcher", query)
+	}
+	if !strings.Contains(query, `response_code=~"2..|4..|503"`) {
+		t.Errorf("Got query %s wanted total codes matcher", query)
+	}
+}
+
+func TestCanaryObserver_GetDeploymentCounterOffset(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "5m", QueryOptions{Offset: "2m"}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(query, `[1m] offset 2m)`) || !strings.Contains(query, `[5m] offset 2m)`) {
+		t.Errorf("Got query %s wanted the offset modifier on every range selector", query)
+	}
+}
+
+func TestStatusCodes_Validate(t *testing.T) {
+	if err := (StatusCodes{Success: []string{"2xx", "404"}, Total: []string{"1xx", "599"}}).Validate(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, code := range []string{"6xx", "2x", "099", "600", "xxx"} {
+		if err := (StatusCodes{Success: []string{code}}).Validate(); err == nil {
+			t.Errorf("Got no error for code %s", code)
+		}
+	}
+}
+
+func TestCanaryObserver_GetRequestCount(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"1520"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	val, err := observer.GetRequestCount("podinfo", "default", "istio", 90*time.Second)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 1520 {
+		t.Errorf("Got %v wanted %v", val, 1520)
+	}
+	if !strings.Contains(query, "increase(istio_requests_total") || !strings.Contains(query, "[90s]") {
+		t.Errorf("Got query %s wanted istio_requests_total increase over 90s", query)
+	}
+}
+
+func TestCanaryObserver_GetDeploymentCounterSelector(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	opts := QueryOptions{Selector: map[string]string{"job": "batch", "exported_namespace": "default"}}
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(query, `istio_requests_total{exported_namespace="default",job="batch",response_code!~"5.*"}`) {
+		t.Errorf("Got query %s wanted custom selector", query)
+	}
+	if strings.Contains(query, "destination_workload") {
+		t.Errorf("Got query %s wanted no workload labels", query)
+	}
+}
+
+func TestCanaryObserver_GetDeploymentHistogramLabels(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"0.2"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	opts := QueryOptions{Labels: map[string]string{"destination_port": "9898"}}
+	if _, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(query, `destination_workload=~"podinfo",destination_port="9898"}`) {
+		t.Errorf("Got query %s wanted workload labels and extra labels", query)
+	}
+}
+
+func TestCanaryObserver_ExcludeLabel(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	opts := QueryOptions{ExcludeLabel: "region", ExcludeValues: []string{"eu-west-1", "us.east"}}
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(query, `destination_workload=~"podinfo",region!~"eu-west-1|us\\.east"`) {
+		t.Errorf("Got query %s wanted the excluded regions matcher", query)
+	}
+}
+
+func TestCanaryObserver_ExcludedPaths(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	opts := QueryOptions{PathLabel: "request_path", ExcludedPaths: []string{"/healthz", `/metrics\..*`}}
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(query, `destination_workload=~"podinfo",request_path!~"/healthz|/metrics\\..*"`) {
+		t.Errorf("Got query %s wanted the excluded paths matcher", query)
+	}
+
+	if _, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(query, `request_path!~"/healthz|/metrics\\..*"`) {
+		t.Errorf("Got query %s wanted the excluded paths matcher", query)
+	}
+}
+
+func TestCanaryObserver_QueryTimeout(t *testing.T) {
+	timeouts := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeouts <- r.URL.Query().Get("timeout")
+		time.Sleep(200 * time.Millisecond)
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+		queryTimeout:  50 * time.Millisecond,
+	}
+
+	_, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{})
+	if err == nil || !strings.Contains(err.Error(), "query timed out") {
+		t.Errorf("Got error %v wanted query timed out", err)
+	}
+	if timeout := <-timeouts; timeout != "0.05" {
+		t.Errorf("Got timeout param %s wanted %s", timeout, "0.05")
+	}
+}
+
+func TestCanaryObserver_QueryRetries(t *testing.T) {
+	var calls int
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"status":"error","error":"backend unavailable"}`))
+			return
+		}
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+		queryRetries:  2,
+		queryBackoff:  time.Millisecond,
+	}
+
+	// a transient error is retried
+	val, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if val != 100 || calls != 2 {
+		t.Errorf("Got value %v after %v calls wanted %v after %v calls", val, calls, 100, 2)
+	}
+
+	// a rejected query is not retried
+	calls = 0
+	status = http.StatusBadRequest
+	_, err = observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{})
+	if err == nil || calls != 1 {
+		t.Errorf("Got error %v after %v calls wanted the bad request without retries", err, calls)
+	}
+}
+
+func TestCanaryObserver_Federated(t *testing.T) {
+	var params url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.Query()
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+		federated:     true,
+	}
+
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if params.Get("dedup") != "true" || params.Get("partial_response") != "false" {
+		t.Errorf("Got params %v wanted dedup=true and partial_response=false", params)
+	}
+	if !strings.Contains(params.Get("query"), "istio_requests_total") {
+		t.Errorf("Got query %s wanted the istio_requests_total query", params.Get("query"))
+	}
+}
+
+func TestCanaryObserver_MetricsQL(t *testing.T) {
+	var params url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.Query()
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"2"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+		metricsQL:     true,
+	}
+
+	query := `sum(rate(http_errors_total{app="podinfo"}[1m])) default 0`
+	val, err := observer.GetScalar(query)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if val != 2 {
+		t.Errorf("Got %v wanted %v", val, 2)
+	}
+	if params.Get("query") != query {
+		t.Errorf("Got query %s wanted %s", params.Get("query"), query)
+	}
+
+	if _, err := observer.GetScalar(`sum(rate(http_errors_total{app="podinfo"}[1m])`); err == nil {
+		t.Errorf("Got no error wanted unbalanced brackets")
+	}
+}
+
+func TestVictoriaMetricsURL(t *testing.T) {
+	u, err := VictoriaMetricsURL("http://vmselect:8481", "0:1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if u != "http://vmselect:8481/select/0:1/prometheus/" {
+		t.Errorf("Got URL %s wanted %s", u, "http://vmselect:8481/select/0:1/prometheus/")
+	}
+
+	u, err = VictoriaMetricsURL("http://victoria-metrics:8428", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if u != "http://victoria-metrics:8428/" {
+		t.Errorf("Got URL %s wanted %s", u, "http://victoria-metrics:8428/")
+	}
+
+	if _, err := VictoriaMetricsURL("http://vmselect:8481", "tenant"); err == nil {
+		t.Errorf("Got no error wanted invalid tenant")
+	}
+}
+
+func TestCanaryObserver_GetSampleAge(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"90.5"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	age, err := observer.GetSampleAge("podinfo", "default", "istio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if age != 90500*time.Millisecond {
+		t.Errorf("Got %v wanted %v", age, 90500*time.Millisecond)
+	}
+
+	if !strings.HasPrefix(query, `time() - max(timestamp(istio_requests_total{`) {
+		t.Errorf("Got query %s wanted the age of the latest istio_requests_total sample", query)
+	}
+}
+
+func TestCanaryObserver_GetRequestTotal(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	val, err := observer.GetRequestTotal("podinfo", "default", "envoy_cluster_upstream_rq", "1m", QueryOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 0 {
+		t.Errorf("Got %v wanted %v for an empty result", val, 0)
+	}
+
+	if !strings.HasPrefix(query, `sum(increase(envoy_cluster_upstream_rq{`) || !strings.HasSuffix(query, `}[1m]))`) {
+		t.Errorf("Got query %s wanted the requests increase over 1m", query)
+	}
+}
+
+func TestCanaryObserver_GetBurnRate(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"14.4"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	val, err := observer.GetBurnRate("podinfo", "default", "istio_requests_total", "1h", 99.9, QueryOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 14.4 {
+		t.Errorf("Got %v wanted %v", val, 14.4)
+	}
+
+	if !strings.Contains(query, `[1h]))) / (1 - 99.9 / 100)`) {
+		t.Errorf("Got query %s wanted the error ratio over 1h divided by the error budget", query)
+	}
+}
+
+func TestCanaryObserver_GetDeploymentCounterSchema(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"100"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	opts := QueryOptions{
+		MetricName:     "http_server_request_duration_seconds_count",
+		WorkloadLabel:  "k8s_deployment_name",
+		NamespaceLabel: "k8s_namespace_name",
+		CodeLabel:      "http_response_status_code",
+	}
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := `http_server_request_duration_seconds_count{k8s_namespace_name=~"default",k8s_deployment_name=~"podinfo",http_response_status_code!~"5.*"}`
+	if !strings.Contains(query, want) {
+		t.Errorf("Got query %s wanted %s", query, want)
+	}
+	if strings.Contains(query, "istio_requests_total") || strings.Contains(query, "destination_workload") {
+		t.Errorf("Got query %s wanted no built-in names", query)
+	}
+}
+
+func TestCanaryObserver_GetSaturation(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"85.5"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	val, err := observer.GetSaturation("podinfo", "default", "memory", "", "podinfod", "1m")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 85.5 {
+		t.Errorf("Got %v wanted %v", val, 85.5)
+	}
+
+	want := `kube_pod_container_resource_limits{namespace="default",pod=~"podinfo-[a-z0-9]+-[a-z0-9]+",container="podinfod",resource="memory"}`
+	if !strings.Contains(query, want) || !strings.Contains(query, "container_memory_working_set_bytes") {
+		t.Errorf("Got query %s wanted the working set divided by %s", query, want)
+	}
+}
+
+func TestCanaryObserver_Aggregation(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"0.5"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+	}
+
+	// the worst pod has the lowest success rate
+	opts := QueryOptions{Aggregation: "worst-pod"}
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.HasPrefix(query, "min(sum(rate(") || strings.Count(query, ")) by (pod)") != 2 {
+		t.Errorf("Got query %s wanted the lowest per pod success rate", query)
+	}
+
+	// the worst pod has the highest latency
+	opts = QueryOptions{Aggregation: "worst-pod", PodLabel: "kubernetes_pod_name"}
+	if _, err := observer.GetDeploymentHistogram("podinfo", "default", "istio_request_duration_seconds_bucket", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.HasPrefix(query, "max(histogram_quantile(") || !strings.Contains(query, "by (le, kubernetes_pod_name)") {
+		t.Errorf("Got query %s wanted the highest per pod latency", query)
+	}
+
+	opts = QueryOptions{Aggregation: "avg"}
+	if _, err := observer.GetEnvoySuccessRate("podinfo", "default", "envoy_cluster_upstream_rq", "1m", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.HasPrefix(query, "avg(sum(rate(") {
+		t.Errorf("Got query %s wanted the average per pod success rate", query)
+	}
+
+	// the fleet-wide query is unchanged
+	if _, err := observer.GetDeploymentCounter("podinfo", "default", "istio_requests_total", "1m", QueryOptions{}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if strings.Contains(query, "by (") {
+		t.Errorf("Got query %s wanted a fleet-wide query", query)
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkPodEvents returns the first rollback reason found in the events and the container terminations
+// of the canary pods since the analysis started, an empty string means no rollback reason was found
+func (c *Controller) checkPodEvents(cd *flaggerv1.Canary) (string, error) {
+	reasons := cd.Spec.CanaryAnalysis.RollbackEvents
+	if len(reasons) == 0 {
+		return "", nil
+	}
+
+	targetName := cd.Spec.TargetRef.Name
+	canary, err := c.kubeClient.AppsV1().Deployments(cd.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("deployment %s.%s query error %v", targetName, cd.Namespace, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(canary.Spec.Selector)
+	if err != nil {
+		return "", fmt.Errorf("deployment %s.%s selector error %v", targetName, cd.Namespace, err)
+	}
+	pods, err := c.kubeClient.CoreV1().Pods(cd.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", fmt.Errorf("pods %s.%s query error %v", targetName, cd.Namespace, err)
+	}
+
+	since := cd.Status.AnalysisStartTime
+	names := make(map[string]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		names[pod.Name] = true
+		// the OOMKilled and the other termination reasons are not reported as events
+		for _, status := range pod.Status.ContainerStatuses {
+			for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+				if terminated == nil || !hasRollbackReason(reasons, terminated.Reason) || terminated.FinishedAt.Before(&since) {
+					continue
+				}
+				return fmt.Sprintf("container %s of pod %s terminated %s", status.Name, pod.Name, terminated.Reason), nil
+			}
+		}
+	}
+
+	events, err := c.kubeClient.CoreV1().Events(cd.Namespace).List(metav1.ListOptions{FieldSelector: "involvedObject.kind=Pod"})
+	if err != nil {
+		return "", fmt.Errorf("events %s query error %v", cd.Namespace, err)
+	}
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind != "Pod" || !names[event.InvolvedObject.Name] || !hasRollbackReason(reasons, event.Reason) {
+			continue
+		}
+		seen := event.LastTimestamp
+		if seen.IsZero() {
+			seen = metav1.NewTime(event.EventTime.Time)
+		}
+		if seen.Before(&since) {
+			continue
+		}
+		return fmt.Sprintf("pod %s event %s %s", event.InvolvedObject.Name, event.Reason, event.Message), nil
+	}
+	return "", nil
+}
+
+// hasRollbackReason returns true if the reason is one of the rollback reasons, the comparison ignores case
+func hasRollbackReason(reasons []string, reason string) bool {
+	for _, r := range reasons {
+		if strings.EqualFold(r, reason) {
+			return true
+		}
+	}
+	return false
+}
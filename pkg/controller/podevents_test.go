@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestScheduler_RollbackEvents(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.RollbackEvents = []string{"FailedMount", "OOMKilled"}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, name := range []string{"podinfo-7d8f9", "backend-5c6d7"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "podinfo"},
+			},
+		}
+		if name == "backend-5c6d7" {
+			pod.Labels["app"] = "backend"
+		}
+		if _, err := mocks.kubeClient.CoreV1().Pods("default").Create(pod); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// the events of the other pods are ignored
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "backend-5c6d7.1", Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "backend-5c6d7", Namespace: "default"},
+		Reason:         "FailedMount",
+		LastTimestamp:  metav1.Now(),
+	}
+	if _, err := mocks.kubeClient.CoreV1().Events("default").Create(event); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryProgressing {
+		t.Fatalf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryProgressing)
+	}
+
+	event = &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "podinfo-7d8f9.1", Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "podinfo-7d8f9", Namespace: "default"},
+		Reason:         "FailedMount",
+		Message:        "secret not found",
+		LastTimestamp:  metav1.Now(),
+	}
+	if _, err := mocks.kubeClient.CoreV1().Events("default").Create(event); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+	if c.Status.RollbackReason != v1alpha3.RollbackKubernetesEvent {
+		t.Errorf("Got rollback reason %s wanted %s", c.Status.RollbackReason, v1alpha3.RollbackKubernetesEvent)
+	}
+}
+
+func TestController_CheckPodEventsTermination(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.CanaryAnalysis.RollbackEvents = []string{"oomkilled"}
+	cd.Status.AnalysisStartTime = metav1.Now()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podinfo-7d8f9",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "podinfo"},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "podinfo",
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", FinishedAt: metav1.Now()},
+					},
+				},
+			},
+		},
+	}
+	if _, err := mocks.kubeClient.CoreV1().Pods("default").Create(pod); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	event, err := mocks.ctrl.checkPodEvents(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if event != "container podinfo of pod podinfo-7d8f9 terminated OOMKilled" {
+		t.Errorf("Got event %q wanted the OOMKilled termination", event)
+	}
+}
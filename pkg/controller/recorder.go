@@ -10,10 +10,15 @@ import (
 
 // CanaryRecorder records the canary analysis as Prometheus metrics
 type CanaryRecorder struct {
-	duration *prometheus.HistogramVec
-	total    *prometheus.GaugeVec
-	status   *prometheus.GaugeVec
-	weight   *prometheus.GaugeVec
+	duration        *prometheus.HistogramVec
+	total           *prometheus.GaugeVec
+	status          *prometheus.GaugeVec
+	weight          *prometheus.GaugeVec
+	progress        *prometheus.GaugeVec
+	analysis        *prometheus.GaugeVec
+	webhookDuration *prometheus.HistogramVec
+	webhookTotal    *prometheus.CounterVec
+	rollbackTotal   *prometheus.CounterVec
 }
 
 // NewCanaryRecorder creates a new recorder and registers the Prometheus metrics
@@ -31,7 +36,7 @@ func NewCanaryRecorder(register bool) CanaryRecorder {
 		Help:      "Total number of canary object",
 	}, []string{"namespace"})
 
-	// 0 - running, 1 - successful, 2 - failed
+	// 0 - running, 1 - successful, 2 - failed, 3 - cancelled
 	status := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Subsystem: controllerAgentName,
 		Name:      "canary_status",
@@ -44,18 +49,61 @@ func NewCanaryRecorder(register bool) CanaryRecorder {
 		Help:      "The virtual service destination weight current value",
 	}, []string{"workload", "namespace"})
 
+	progress := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: controllerAgentName,
+		Name:      "canary_progress",
+		Help:      "The canary analysis completion percentage",
+	}, []string{"name", "namespace"})
+
+	analysis := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: controllerAgentName,
+		Name:      "canary_metric_analysis",
+		Help:      "The value of the canary metrics evaluated in the last analysis",
+	}, []string{"name", "namespace", "metric"})
+
+	webhookDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: controllerAgentName,
+		Name:      "webhook_duration_seconds",
+		Help:      "Seconds spent calling the canary analysis webhooks.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"name", "namespace", "webhook"})
+
+	// status is either success or failure
+	webhookTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: controllerAgentName,
+		Name:      "webhook_total",
+		Help:      "Total number of canary analysis webhook calls",
+	}, []string{"name", "namespace", "webhook", "status"})
+
+	// reason is one of the rollback reasons e.g. MetricThreshold or ProgressDeadline
+	rollbackTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: controllerAgentName,
+		Name:      "canary_rollback_total",
+		Help:      "Total number of canary rollbacks",
+	}, []string{"name", "namespace", "reason"})
+
 	if register {
 		prometheus.MustRegister(duration)
 		prometheus.MustRegister(total)
 		prometheus.MustRegister(status)
 		prometheus.MustRegister(weight)
+		prometheus.MustRegister(progress)
+		prometheus.MustRegister(analysis)
+		prometheus.MustRegister(webhookDuration)
+		prometheus.MustRegister(webhookTotal)
+		prometheus.MustRegister(rollbackTotal)
 	}
 
 	return CanaryRecorder{
-		duration: duration,
-		total:    total,
-		status:   status,
-		weight:   weight,
+		duration:        duration,
+		total:           total,
+		status:          status,
+		weight:          weight,
+		progress:        progress,
+		analysis:        analysis,
+		webhookDuration: webhookDuration,
+		webhookTotal:    webhookTotal,
+		rollbackTotal:   rollbackTotal,
 	}
 }
 
@@ -77,6 +125,8 @@ func (cr *CanaryRecorder) SetStatus(cd *flaggerv1.Canary) {
 		status = 0
 	case flaggerv1.CanaryFailed:
 		status = 2
+	case flaggerv1.CanaryCancelled:
+		status = 3
 	default:
 		status = 1
 	}
@@ -88,3 +138,33 @@ func (cr *CanaryRecorder) SetWeight(cd *flaggerv1.Canary, primary int, canary in
 	cr.weight.WithLabelValues(fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name), cd.Namespace).Set(float64(primary))
 	cr.weight.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace).Set(float64(canary))
 }
+
+// SetProgress sets the canary analysis completion percentage
+func (cr *CanaryRecorder) SetProgress(cd *flaggerv1.Canary) {
+	cr.progress.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace).Set(float64(cd.GetProgress()))
+}
+
+// SetAnalysis sets the last evaluated value of each canary metric
+func (cr *CanaryRecorder) SetAnalysis(cd *flaggerv1.Canary, checks []metricCheck) {
+	for _, check := range checks {
+		if check.value != nil {
+			cr.analysis.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace, check.name).Set(*check.value)
+		}
+	}
+}
+
+// SetWebhook records the duration and the result of a webhook call,
+// the webhook name is used as label to keep the cardinality bounded
+func (cr *CanaryRecorder) SetWebhook(cd *flaggerv1.Canary, webhook string, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	cr.webhookDuration.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace, webhook).Observe(duration.Seconds())
+	cr.webhookTotal.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace, webhook, status).Inc()
+}
+
+// IncRollback increments the rollbacks counter of the canary for the rollback reason
+func (cr *CanaryRecorder) IncRollback(cd *flaggerv1.Canary, reason flaggerv1.RollbackReason) {
+	cr.rollbackTotal.WithLabelValues(cd.Spec.TargetRef.Name, cd.Namespace, string(reason)).Inc()
+}
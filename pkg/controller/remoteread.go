@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// The remote-read API exchanges snappy compressed protobuf messages,
+// the few fields used by Flagger are encoded and decoded below to avoid
+// depending on the Prometheus packages and their gRPC dependencies:
+//
+//	message ReadRequest { repeated Query queries = 1; }
+//	message Query { int64 start_timestamp_ms = 1; int64 end_timestamp_ms = 2; repeated LabelMatcher matchers = 3; }
+//	message LabelMatcher { Type type = 1; string name = 2; string value = 3; }
+//	message ReadResponse { repeated QueryResult results = 1; }
+//	message QueryResult { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+
+// remoteRead returns the values of the samples matching the labels between start and end
+func (c *CanaryObserver) remoteRead(labels map[string]string, start time.Time, end time.Time) ([]float64, error) {
+	body := snappy.Encode(nil, encodeReadRequest(labels, start, end))
+
+	req, err := http.NewRequest("POST", c.remoteReadURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+
+	if c.queryLimit != nil {
+		c.queryLimit <- struct{}{}
+		defer func() { <-c.queryLimit }()
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 30*time.Second)
+	defer cancel()
+
+	r, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %s", err.Error())
+	}
+
+	if 400 <= r.StatusCode {
+		return nil, fmt.Errorf("error response: %s", string(b))
+	}
+
+	data, err := snappy.Decode(nil, b)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing response: %v", err)
+	}
+
+	values, err := decodeReadResponse(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+	return values, nil
+}
+
+// encodeReadRequest encodes a read request with a single query of equality matchers
+func encodeReadRequest(labels map[string]string, start time.Time, end time.Time) []byte {
+	var names []string
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var query protoBuffer
+	query.varintField(1, uint64(start.UnixNano()/int64(time.Millisecond)))
+	query.varintField(2, uint64(end.UnixNano()/int64(time.Millisecond)))
+	for _, name := range names {
+		var matcher protoBuffer
+		// the equal matcher type is the default value and is omitted
+		matcher.bytesField(2, []byte(name))
+		matcher.bytesField(3, []byte(labels[name]))
+		query.bytesField(3, matcher)
+	}
+
+	var request protoBuffer
+	request.bytesField(1, query)
+	return request
+}
+
+// decodeReadResponse returns the values of the samples of all the series in the response
+func decodeReadResponse(b []byte) ([]float64, error) {
+	var values []float64
+	err := protoFields(b, func(field int, results []byte) error {
+		if field != 1 {
+			return nil
+		}
+		return protoFields(results, func(field int, series []byte) error {
+			if field != 1 {
+				return nil
+			}
+			return protoFields(series, func(field int, sample []byte) error {
+				if field != 2 {
+					return nil
+				}
+				return protoFields(sample, func(field int, value []byte) error {
+					if field == 1 {
+						values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(value)))
+					}
+					return nil
+				})
+			})
+		})
+	})
+	return values, err
+}
+
+type protoBuffer []byte
+
+func (p *protoBuffer) varint(v uint64) {
+	*p = append(*p, encodeVarint(v)...)
+}
+
+func (p *protoBuffer) varintField(field int, v uint64) {
+	p.varint(uint64(field)<<3 | 0)
+	p.varint(v)
+}
+
+func (p *protoBuffer) bytesField(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	*p = append(*p, b...)
+}
+
+// protoFields calls fn with the number and the payload of every field of the message,
+// the payload of varint fields is the encoded varint and the payload of fixed fields is little endian
+func protoFields(b []byte, fn func(field int, payload []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("invalid field key")
+		}
+		b = b[n:]
+
+		var size int
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("invalid varint")
+			}
+			size = n
+		case 1:
+			size = 8
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errors.New("invalid length")
+			}
+			b = b[n:]
+			size = int(l)
+		case 5:
+			size = 4
+		default:
+			return fmt.Errorf("unsupported wire type %v", key&7)
+		}
+
+		if len(b) < size {
+			return errors.New("unexpected end of message")
+		}
+		if err := fn(int(key>>3), b[:size]); err != nil {
+			return err
+		}
+		b = b[size:]
+	}
+	return nil
+}
+
+func encodeVarint(v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, v)]
+}
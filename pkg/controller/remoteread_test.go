@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+func TestCanaryObserver_GetLongRangeBaselineRemoteRead(t *testing.T) {
+	matchers := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/read" || r.Header.Get("Content-Encoding") != "snappy" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		req, err := snappy.Decode(nil, b)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// collect the matchers of the first query
+		protoFields(req, func(field int, query []byte) error {
+			return protoFields(query, func(field int, matcher []byte) error {
+				if field != 3 {
+					return nil
+				}
+				var name, value string
+				protoFields(matcher, func(field int, v []byte) error {
+					switch field {
+					case 2:
+						name = string(v)
+					case 3:
+						value = string(v)
+					}
+					return nil
+				})
+				matchers[name] = value
+				return nil
+			})
+		})
+
+		var series protoBuffer
+		for i, v := range []float64{98, 99, 100} {
+			var sample protoBuffer
+			value := make([]byte, 8)
+			binary.LittleEndian.PutUint64(value, math.Float64bits(v))
+			sample.varint(1<<3 | 1)
+			sample = append(sample, value...)
+			sample.varintField(2, uint64(i))
+			series.bytesField(2, sample)
+		}
+		var result protoBuffer
+		result.bytesField(1, series)
+		var res protoBuffer
+		res.bytesField(1, result)
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(snappy.Encode(nil, res))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: "http://prometheus:9090",
+		remoteReadURL: ts.URL + "/api/v1/read",
+		retention:     24 * time.Hour,
+	}
+
+	val, err := observer.GetLongRangeBaseline("podinfo-primary", "default", "workload:istio_requests:success_rate", "720h", QueryOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 99 {
+		t.Errorf("Got %v wanted %v", val, 99)
+	}
+
+	expected := map[string]string{
+		"__name__":                       "workload:istio_requests:success_rate",
+		"destination_workload":           "podinfo-primary",
+		"destination_workload_namespace": "default",
+	}
+	for k, v := range expected {
+		if matchers[k] != v {
+			t.Errorf("Got matcher %s=%s wanted %s", k, matchers[k], v)
+		}
+	}
+}
+
+func TestCanaryObserver_GetLongRangeBaselineWithinRetention(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		json := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.458,"97"]}]}}`
+		w.Write([]byte(json))
+	}))
+	defer ts.Close()
+
+	observer := CanaryObserver{
+		metricsServer: ts.URL,
+		remoteReadURL: ts.URL + "/api/v1/read",
+		retention:     360 * time.Hour,
+	}
+
+	val, err := observer.GetLongRangeBaseline("podinfo-primary", "default", "workload:istio_requests:success_rate", "168h", QueryOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if val != 97 {
+		t.Errorf("Got %v wanted %v", val, 97)
+	}
+
+	if !strings.HasPrefix(query, "avg_over_time(workload:istio_requests:success_rate{") || !strings.HasSuffix(query, "[168h])") {
+		t.Errorf("Got query %s wanted the query API average", query)
+	}
+}
@@ -1,12 +1,19 @@
 package controller
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/weaveworks/flagger/pkg/router"
+	"math"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	"github.com/weaveworks/flagger/pkg/notifier"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -25,17 +32,20 @@ func (c *Controller) scheduleCanaries() {
 		current[name] = fmt.Sprintf("%s.%s", canary.Spec.TargetRef.Name, canary.Namespace)
 
 		job, exists := c.jobs[name]
-		// schedule new job for exsiting job with different analysisInterval or non-existing job
-		if (exists && job.GetCanaryAnalysisInterval() != canary.GetAnalysisInterval()) || !exists {
-			if exists {
-				job.Stop()
-			}
+		// reset the ticker of an existing job with a different analysisInterval
+		if exists && job.GetCanaryAnalysisInterval() != canary.GetAnalysisInterval() {
+			job.ResetInterval(canary.GetAnalysisInterval())
+			c.jobs[name] = job
+		}
 
+		// schedule new job for non-existing job
+		if !exists {
 			newJob := CanaryJob{
 				Name:             canary.Name,
 				Namespace:        canary.Namespace,
 				function:         c.advanceCanary,
 				done:             make(chan bool),
+				reset:            make(chan time.Duration, 1),
 				ticker:           time.NewTicker(canary.GetAnalysisInterval()),
 				analysisInterval: canary.GetAnalysisInterval(),
 			}
@@ -59,6 +69,7 @@ func (c *Controller) scheduleCanaries() {
 		if _, exists := current[job]; !exists {
 			c.jobs[job].Stop()
 			delete(c.jobs, job)
+			c.serviceTelemetry.Delete(job)
 		}
 	}
 
@@ -87,31 +98,83 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 		return
 	}
 
+	// hold the canary at its current weight without advancing or rolling back
+	if hold := c.checkKillSwitch(cd); hold {
+		return
+	}
+
 	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	deployer := c.getDeployer(cd)
 
-	// create primary deployment and hpa if needed
-	if err := c.deployer.Sync(cd); err != nil {
+	if err := validateAnalysis(cd); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	// refuse to start the analysis of canaries that depend on each other
+	if err := c.validateDependencies(cd); err != nil {
 		c.recordEventWarningf(cd, "%v", err)
 		return
 	}
+	// the baseline traffic is routed by the Istio router, the default mesh provider
+	if cd.Spec.CanaryAnalysis.Baseline && c.meshProvider != "" && c.meshProvider != "istio" {
+		c.recordEventWarningf(cd, "Canary %s.%s baseline is supported only by the istio mesh provider", cd.Name, cd.Namespace)
+		return
+	}
 
-	// init routers
-	routerFactory := router.NewFactory(c.kubeClient, c.flaggerClient, c.logger, c.istioClient)
-	meshRouter := routerFactory.MeshRouter(c.meshProvider)
+	// the stage match conditions are routed by the Istio router
+	if hasStageMatch(cd) && c.meshProvider != "" && c.meshProvider != "istio" {
+		c.recordEventWarningf(cd, "Canary %s.%s stage match conditions are supported only by the istio mesh provider", cd.Name, cd.Namespace)
+		return
+	}
+
+	// take ownership of a primary deployment created outside of Flagger
+	if cd.Status.Phase == "" {
+		adopted, err := deployer.AdoptPrimary(cd)
+		if err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+		if adopted {
+			c.recordEventInfof(cd, "Adopted existing deployment %s.%s", primaryName, cd.Namespace)
+		}
+	}
 
-	// create ClusterIP services and virtual service if needed
-	if err := routerFactory.KubernetesRouter().Sync(cd); err != nil {
+	// create primary deployment and hpa if needed
+	if err := deployer.Sync(cd); err != nil {
 		c.recordEventWarningf(cd, "%v", err)
 		return
 	}
 
+	// init routers
+	routerFactory := router.NewFactory(c.kubeClient, c.flaggerClient, c.logger, c.istioClient, c.remoteClusters...)
+	meshRouter := routerFactory.MeshRouter(c.meshProvider)
+	if cd.IsKnativeService() {
+		// Knative routes the traffic between revisions without ClusterIP services
+		meshRouter = routerFactory.KnativeRouter()
+	} else {
+		// create ClusterIP services and virtual service if needed
+		if err := routerFactory.KubernetesRouter().Sync(cd); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+	}
+
 	// create or update virtual service
 	if err := meshRouter.Sync(cd); err != nil {
 		c.recordEventWarningf(cd, "%v", err)
 		return
 	}
 
-	shouldAdvance, err := c.deployer.ShouldAdvance(cd)
+	// read the telemetry of the target service once for all the metric evaluations of this interval
+	c.resolveServiceTelemetry(cd)
+
+	// cancel the analysis if the canary has been suspended
+	if cd.Spec.Suspend {
+		c.cancelCanary(cd, deployer, meshRouter)
+		return
+	}
+
+	shouldAdvance, err := deployer.ShouldAdvance(cd)
 	if err != nil {
 		c.recordEventWarningf(cd, "%v", err)
 		return
@@ -121,6 +184,22 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 		return
 	}
 
+	// wait for the dependencies to finish their rollout before starting or advancing the analysis
+	if cd.Status.Phase != "" {
+		if dependency, ok := c.dependencyInProgress(cd); ok {
+			c.recordEventInfof(cd, "Waiting for %s.%s dependency %s rollout to finish",
+				cd.Name, cd.Namespace, dependency)
+			return
+		}
+	}
+
+	// suspend the analysis outside the daily allowed window, the initialization isn't held
+	if cd.Status.Phase != "" {
+		if suspended := c.checkAllowedWindow(cd, deployer); suspended {
+			return
+		}
+	}
+
 	// set max weight default value to 100%
 	maxWeight := 100
 	if cd.Spec.CanaryAnalysis.MaxWeight > 0 {
@@ -129,7 +208,7 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 
 	// check primary deployment status
 	if !skipLivenessChecks {
-		if _, err := c.deployer.IsPrimaryReady(cd); err != nil {
+		if _, err := deployer.IsPrimaryReady(cd); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
@@ -146,31 +225,44 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 	c.recorder.SetWeight(cd, primaryWeight, canaryWeight)
 
 	// check if canary analysis should start (canary revision has changes) or continue
-	if ok := c.checkCanaryStatus(cd, shouldAdvance); !ok {
+	if ok := c.checkCanaryStatus(cd, deployer, shouldAdvance); !ok {
 		return
 	}
 
 	// check if canary revision changed during analysis
-	if restart := c.hasCanaryRevisionChanged(cd); restart {
-		c.recordEventInfof(cd, "New revision detected! Restarting analysis for %s.%s",
-			cd.Spec.TargetRef.Name, cd.Namespace)
+	if restart := c.hasCanaryRevisionChanged(cd, deployer); restart {
+		c.recordEventInfof(cd, "New revision detected! Restarting analysis for %s.%s%s",
+			cd.Spec.TargetRef.Name, cd.Namespace, c.targetDriftSummary(cd, deployer))
+		if c.metricsCache != nil {
+			c.metricsCache.Invalidate(cd)
+		}
 
-		// route all traffic back to primary
+		// route all traffic back to primary, the canary keeps the traffic until the first revision is promoted
 		primaryWeight = 100
 		canaryWeight = 0
-		if err := meshRouter.SetRoutes(cd, primaryWeight, canaryWeight); err != nil {
+		if cd.Status.InitialAnalysis {
+			primaryWeight, canaryWeight = 0, 100
+		}
+		if err := c.setRoutes(cd, meshRouter, primaryWeight, canaryWeight); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
 
-		// reset status
+		// reset status, the retry budget counters are kept for the whole rollout
+		// and a new revision is not counted as a rollback
 		status := flaggerv1.CanaryStatus{
-			Phase:        flaggerv1.CanaryProgressing,
-			CanaryWeight: 0,
-			FailedChecks: 0,
-			Iterations:   0,
+			Phase:             flaggerv1.CanaryProgressing,
+			CanaryWeight:      0,
+			FailedChecks:      0,
+			Iterations:        0,
+			AnalysisStartTime: v1.Now(),
+			SilenceID:         c.startSilence(cd),
+			RolloutID:         newRolloutID(),
+			InitialAnalysis:   cd.Status.InitialAnalysis,
+			RouteMutations:    cd.Status.RouteMutations,
+			Rollbacks:         cd.Status.Rollbacks,
 		}
-		if err := c.deployer.SyncStatus(cd, status); err != nil {
+		if err := deployer.SyncStatus(cd, status); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
@@ -181,110 +273,303 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 		c.recorder.SetDuration(cd, time.Since(begin))
 	}()
 
+	// analyse the promoted primary, the canary is scaled to zero during the verification
+	if !cd.Status.VerificationStartTime.IsZero() {
+		c.verifyPromotion(cd, deployer)
+		return
+	}
+
 	// check canary deployment status
 	var retriable = true
 	if !skipLivenessChecks {
-		retriable, err = c.deployer.IsCanaryReady(cd)
+		retriable, err = deployer.IsCanaryReady(cd)
+		if err != nil && retriable {
+			c.recordEventWarningf(cd, "%v", err)
+			if cd.Status.Phase == flaggerv1.CanaryProgressing {
+				if err := deployer.SetStatusHaltedIntervals(cd, cd.Status.HaltedIntervals+1); err != nil {
+					c.recordEventWarningf(cd, "%v", err)
+					return
+				}
+				c.checkStalled(cd, cd.Status.HaltedIntervals+1)
+			}
+			return
+		}
+	}
+
+	// check the app readiness once the canary deployment is available
+	if retriable && cd.Status.Phase == flaggerv1.CanaryProgressing {
+		retriable, err = c.checkReadinessGates(cd)
 		if err != nil && retriable {
 			c.recordEventWarningf(cd, "%v", err)
+			// the readiness gate failure time is persisted with the halted intervals counter
+			if err := deployer.SetStatusHaltedIntervals(cd, cd.Status.HaltedIntervals+1); err != nil {
+				c.recordEventWarningf(cd, "%v", err)
+				return
+			}
+			c.checkStalled(cd, cd.Status.HaltedIntervals+1)
 			return
 		}
 	}
 
 	// check if analysis should be skipped
-	if skip := c.shouldSkipAnalysis(cd, meshRouter, primaryWeight, canaryWeight); skip {
+	if skip := c.shouldSkipAnalysis(cd, deployer, meshRouter, primaryWeight, canaryWeight); skip {
 		return
 	}
 
 	// check if the number of failed checks reached the threshold
-	if cd.Status.Phase == flaggerv1.CanaryProgressing &&
-		(!retriable || cd.Status.FailedChecks >= cd.Spec.CanaryAnalysis.Threshold) {
+	thresholdReached := failedChecksThresholdReached(cd)
+
+	// check if the route mutations or the rollbacks exceeded the retry budget
+	budgetExceeded := retryBudgetExceeded(cd)
 
-		if cd.Status.FailedChecks >= cd.Spec.CanaryAnalysis.Threshold {
+	// poll the approval webhooks, a rejected approval rolls back the canary
+	var rejectedBy string
+	if cd.Status.Phase == flaggerv1.CanaryProgressing && retriable && !thresholdReached && !budgetExceeded {
+		var hold bool
+		if hold, rejectedBy = c.checkApprovals(cd, deployer); hold {
+			return
+		}
+	}
+
+	// scan the canary pods for the events and the container terminations that roll back the canary
+	var podEvent string
+	if cd.Status.Phase == flaggerv1.CanaryProgressing && retriable && !thresholdReached && !budgetExceeded && rejectedBy == "" {
+		if podEvent, err = c.checkPodEvents(cd); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+		}
+	}
+
+	if cd.Status.Phase == flaggerv1.CanaryProgressing && (!retriable || thresholdReached || budgetExceeded ||
+		rejectedBy != "" || podEvent != "") {
+
+		var reason string
+		var rollbackReason flaggerv1.RollbackReason
+		if rejectedBy != "" {
+			reason = fmt.Sprintf("Approval %s rejected", rejectedBy)
+			rollbackReason = flaggerv1.RollbackExternalAbort
+			c.recordEventWarningf(cd, "Rolling back %s.%s approval %s rejected",
+				cd.Name, cd.Namespace, rejectedBy)
+			c.sendNotification(cd, reason, false, notifier.SeverityError)
+		}
+
+		if podEvent != "" {
+			reason = fmt.Sprintf("Kubernetes event detected, %s", podEvent)
+			rollbackReason = flaggerv1.RollbackKubernetesEvent
+			c.recordEventWarningf(cd, "Rolling back %s.%s %s", cd.Name, cd.Namespace, podEvent)
+			c.sendNotification(cd, reason, false, notifier.SeverityError)
+		}
+
+		if thresholdReached {
+			reason = fmt.Sprintf("Failed checks threshold reached %v", failedChecksSummary(cd))
+			rollbackReason = cd.Status.FailedCheckReason
+			if rollbackReason == "" {
+				rollbackReason = flaggerv1.RollbackMetricThreshold
+			}
 			c.recordEventWarningf(cd, "Rolling back %s.%s failed checks threshold reached %v",
-				cd.Name, cd.Namespace, cd.Status.FailedChecks)
-			c.sendNotification(cd, fmt.Sprintf("Failed checks threshold reached %v", cd.Status.FailedChecks),
-				false, true)
+				cd.Name, cd.Namespace, failedChecksSummary(cd))
+			c.sendNotification(cd, reason, false, notifier.SeverityError)
+		}
+
+		if budgetExceeded {
+			reason = fmt.Sprintf("Retry budget exceeded %v", retryBudgetSummary(cd))
+			rollbackReason = flaggerv1.RollbackRetryBudgetExceeded
+			c.recordEventWarningf(cd, "Rolling back %s.%s retry budget exceeded %v",
+				cd.Name, cd.Namespace, retryBudgetSummary(cd))
+			c.sendNotification(cd, reason, false, notifier.SeverityError)
 		}
 
 		if !retriable {
+			reason = fmt.Sprintf("Progress deadline exceeded %v", err)
+			rollbackReason = flaggerv1.RollbackProgressDeadline
 			c.recordEventWarningf(cd, "Rolling back %s.%s progress deadline exceeded %v",
 				cd.Name, cd.Namespace, err)
-			c.sendNotification(cd, fmt.Sprintf("Progress deadline exceeded %v", err),
-				false, true)
+			c.sendNotification(cd, reason, false, notifier.SeverityError)
 		}
+		// read the failed revision before the canary status is reset
+		revision := c.canaryRelease(cd)
 
-		// route all traffic back to primary
+		// route all traffic back to primary, the canary keeps the traffic
+		// and its replicas while the primary has never been promoted
 		primaryWeight = 100
 		canaryWeight = 0
+		if cd.Status.InitialAnalysis {
+			primaryWeight, canaryWeight = 0, 100
+		}
 		if err := meshRouter.SetRoutes(cd, primaryWeight, canaryWeight); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
 
 		c.recorder.SetWeight(cd, primaryWeight, canaryWeight)
-		c.recordEventWarningf(cd, "Canary failed! Scaling down %s.%s",
-			cd.Name, cd.Namespace)
+		if cd.Status.InitialAnalysis {
+			c.recordEventWarningf(cd, "Canary failed! %s.%s keeps the traffic until a revision is promoted",
+				cd.Spec.TargetRef.Name, cd.Namespace)
+		} else {
+			c.recordEventWarningf(cd, "Canary failed! Scaling down %s.%s",
+				cd.Name, cd.Namespace)
 
-		// shutdown canary
-		if err := c.deployer.Scale(cd, 0); err != nil {
-			c.recordEventWarningf(cd, "%v", err)
-			return
+			// shutdown canary or keep it warm for the next revision
+			if err := deployer.Scale(cd, cd.Spec.CanaryAnalysis.PostRollbackReplicas); err != nil {
+				c.recordEventWarningf(cd, "%v", err)
+				return
+			}
 		}
 
-		// mark canary as failed
-		if err := c.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryFailed, CanaryWeight: 0}); err != nil {
+		// roll the primary back to the template it ran before the last promotion
+		if cd.Spec.CanaryAnalysis.RollbackPrimary && !cd.Status.InitialAnalysis {
+			restored, err := deployer.RestorePrimary(cd)
+			if err != nil {
+				c.recordEventWarningf(cd, "%v", err)
+			} else if restored {
+				c.recordEventWarningf(cd, "Primary %s-primary.%s rolled back to the template of the previous promotion",
+					cd.Spec.TargetRef.Name, cd.Namespace)
+			}
+		}
+
+		// expire the analysis silence and mark canary as failed
+		c.endSilence(cd)
+		// the next revision is analysed as the first one while the primary has never been promoted
+		status := flaggerv1.CanaryStatus{
+			Phase:           flaggerv1.CanaryFailed,
+			CanaryWeight:    0,
+			RollbackReason:  rollbackReason,
+			InitialAnalysis: cd.Status.InitialAnalysis,
+		}
+		if err := deployer.SyncStatus(cd, status); err != nil {
 			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
 			return
 		}
 
 		c.recorder.SetStatus(cd)
+		c.recorder.IncRollback(cd, rollbackReason)
+
+		// keep a durable record of the rollback on the canary object
+		if err := c.annotateRollback(cd, reason, revision); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+		}
+		return
+	}
+
+	// wait for the canary to warm up before evaluating the metrics
+	if warming := c.warmupCanary(cd, deployer, meshRouter, canaryWeight); warming {
 		return
 	}
 
 	// check if the canary success rate is above the threshold
 	// skip check if no traffic is routed to canary
 	if canaryWeight == 0 {
+		if ok := c.runPreRolloutHooks(cd, deployer); !ok {
+			return
+		}
 		c.recordEventInfof(cd, "Starting canary analysis for %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
 	} else {
-		if ok := c.analyseCanary(cd); !ok {
-			if err := c.deployer.SetStatusFailedChecks(cd, cd.Status.FailedChecks+1); err != nil {
+		result := c.analyseCanary(cd)
+		if result.waiting {
+			return
+		}
+		c.recordAnalysis(cd, result)
+		// the analysed intervals counter is persisted by the next status update
+		cd.Status.AnalysedIntervals++
+		c.adaptInterval(cd, result.checks)
+		if !result.passed {
+			// step the canary weight down instead of counting a failed check
+			if result.degraded {
+				if ok := c.stepDownCanary(cd, deployer, meshRouter, canaryWeight); ok {
+					return
+				}
+			}
+			// the failed check reason is persisted with the failed checks
+			cd.Status.FailedCheckReason = result.reason
+			failedChecks := cd.Status.FailedChecks + 1
+			if result.abort {
+				failedChecks = abortFailedChecks(cd)
+			}
+			if err := deployer.SetStatusFailedChecks(cd, failedChecks); err != nil {
+				c.recordEventWarningf(cd, "%v", err)
+				return
+			}
+			c.checkStalled(cd, cd.Status.HaltedIntervals+1)
+			return
+		}
+
+		// forgive the failed checks of a recovered canary, the counter is persisted by the next status update
+		if decay := cd.Spec.CanaryAnalysis.FailedChecksDecay; decay > 0 && cd.Status.FailedChecks > 0 {
+			cd.Status.FailedChecks = decayFailedChecks(cd.Status.FailedChecks, decay)
+			c.recordEventInfof(cd, "Decay %s.%s failed checks to %v", cd.Name, cd.Namespace, cd.Status.FailedChecks)
+		}
+
+		// hold the current weight while a metric is above its warning threshold
+		if result.warning {
+			c.holdOnWarning(cd, deployer, result)
+			return
+		}
+
+		// hold the advancement until the analysis passed for the required number of consecutive intervals
+		if required := cd.Spec.CanaryAnalysis.ConsecutivePasses; cd.Status.ConsecutivePasses+1 < required {
+			if err := deployer.SetStatusConsecutivePasses(cd, cd.Status.ConsecutivePasses+1); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
+			c.recordEventInfof(cd, "Hold %s.%s advancement consecutive passes %v/%v",
+				cd.Name, cd.Namespace, cd.Status.ConsecutivePasses+1, required)
+			return
+		}
+
+		// hold the advancement until the canary served the minimum number of requests at the current step
+		if hold := c.holdForRequests(cd, deployer); hold {
+			return
+		}
+
+		// hold the advancement until the canary spent the step hold duration at the current weight
+		if hold := c.holdForStep(cd); hold {
 			return
 		}
 	}
 
-	// canary fix routing: A/B testing
-	if len(cd.Spec.CanaryAnalysis.Match) > 0 {
+	// canary stages: progressive cohorts
+	if len(cd.Spec.CanaryAnalysis.Stages) > 0 && !cd.Status.InitialAnalysis {
+		c.advanceStages(cd, deployer, meshRouter)
+		return
+	}
+
+	// canary fix routing: A/B testing or the analysis of the first revision
+	if len(cd.Spec.CanaryAnalysis.Match) > 0 || cd.Status.InitialAnalysis {
+		iterations := analysisIterations(cd, maxWeight)
 		// route traffic to canary and increment iterations
-		if cd.Spec.CanaryAnalysis.Iterations > cd.Status.Iterations {
-			if err := meshRouter.SetRoutes(cd, 0, 100); err != nil {
+		if iterations > cd.Status.Iterations {
+			canaryWeight := matchedCanaryWeight(cd)
+			if err := c.setRoutes(cd, meshRouter, 100-canaryWeight, canaryWeight); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
-			c.recorder.SetWeight(cd, 0, 100)
-
-			if err := c.deployer.SetStatusIterations(cd, cd.Status.Iterations+1); err != nil {
+			c.recorder.SetWeight(cd, 100-canaryWeight, canaryWeight)
+
+			advance := flaggerv1.CanaryAdvance{
+				Time:         v1.Now(),
+				CanaryWeight: canaryWeight,
+				TargetWeight: canaryWeight,
+				StepWeight:   cd.Spec.CanaryAnalysis.MatchStepWeight,
+				Iteration:    cd.Status.Iterations + 1,
+				Message: fmt.Sprintf("Advance %s.%s canary iteration %v/%v",
+					cd.Name, cd.Namespace, cd.Status.Iterations+1, iterations),
+			}
+			appendHistory(cd, advance)
+			if err := deployer.SetStatusIterations(cd, cd.Status.Iterations+1); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
-			c.recordEventInfof(cd, "Advance %s.%s canary iteration %v/%v",
-				cd.Name, cd.Namespace, cd.Status.Iterations+1, cd.Spec.CanaryAnalysis.Iterations)
+			c.recordAdvanceEvent(cd, advance)
 			return
 		}
 
 		// promote canary - max iterations reached
-		if cd.Spec.CanaryAnalysis.Iterations == cd.Status.Iterations {
-			c.recordEventInfof(cd, "Copying %s.%s template spec to %s.%s",
-				cd.Spec.TargetRef.Name, cd.Namespace, primaryName, cd.Namespace)
-			if err := c.deployer.Promote(cd); err != nil {
+		if iterations == cd.Status.Iterations {
+			if err := c.promoteCanary(cd, deployer); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
 			// increment iterations
-			if err := c.deployer.SetStatusIterations(cd, cd.Status.Iterations+1); err != nil {
+			if err := deployer.SetStatusIterations(cd, cd.Status.Iterations+1); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
@@ -292,7 +577,17 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 		}
 
 		// shutdown canary
-		if cd.Spec.CanaryAnalysis.Iterations < cd.Status.Iterations {
+		if iterations < cd.Status.Iterations {
+			// wait for the promotion webhooks to confirm the promotion
+			if ok := c.checkPromotionHooks(cd); !ok {
+				return
+			}
+
+			// wait for the promoted primary to warm up
+			if ok := c.checkPrimaryWarmup(cd, deployer); !ok {
+				return
+			}
+
 			// route all traffic to the primary
 			if err := meshRouter.SetRoutes(cd, 100, 0); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
@@ -302,19 +597,25 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 			c.recordEventInfof(cd, "Promotion completed! Scaling down %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
 
 			// canary scale to zero
-			if err := c.deployer.Scale(cd, 0); err != nil {
+			if err := deployer.Scale(cd, 0); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
 
-			// update status phase
-			if err := c.deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
+			// hold the success until the promoted primary passed the verification
+			if verifying := c.startVerification(cd, deployer); verifying {
+				return
+			}
+
+			// expire the analysis silence and update status phase
+			c.endSilence(cd)
+			if err := deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
 			c.recorder.SetStatus(cd)
 			c.sendNotification(cd, "Canary analysis completed successfully, promotion finished.",
-				false, false)
+				false, notifier.SeverityInfo)
 			return
 		}
 
@@ -323,39 +624,58 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 
 	// canary incremental traffic weight
 	if canaryWeight < maxWeight {
-		primaryWeight -= cd.Spec.CanaryAnalysis.StepWeight
-		if primaryWeight < 0 {
-			primaryWeight = 0
-		}
-		canaryWeight += cd.Spec.CanaryAnalysis.StepWeight
-		if primaryWeight > 100 {
-			primaryWeight = 100
+		stepWeight := cd.Spec.CanaryAnalysis.StepWeight
+		if start := cd.Spec.CanaryAnalysis.StartWeight; canaryWeight == 0 && start > 0 {
+			// begin the rollout at the start weight instead of the first step
+			primaryWeight, canaryWeight = 100-start, start
+		} else {
+			stepWeight = c.adaptiveStepWeight(cd)
+			primaryWeight, canaryWeight = nextWeights(canaryWeight, stepWeight,
+				maxWeight, cd.Spec.CanaryAnalysis.WeightGranularity)
 		}
 
-		if err := meshRouter.SetRoutes(cd, primaryWeight, canaryWeight); err != nil {
+		if err := c.setRoutes(cd, meshRouter, primaryWeight, canaryWeight); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
 
+		advance := flaggerv1.CanaryAdvance{
+			Time:         v1.Now(),
+			CanaryWeight: canaryWeight,
+			TargetWeight: maxWeight,
+			StepWeight:   stepWeight,
+			Iteration:    weightStep(canaryWeight, cd.Spec.CanaryAnalysis.StepWeight),
+			Message:      fmt.Sprintf("Advance %s.%s canary weight %v", cd.Name, cd.Namespace, canaryWeight),
+		}
+		appendHistory(cd, advance)
+
 		// update weight status
-		if err := c.deployer.SetStatusWeight(cd, canaryWeight); err != nil {
+		if err := deployer.SetStatusWeight(cd, canaryWeight); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
 
 		c.recorder.SetWeight(cd, primaryWeight, canaryWeight)
-		c.recordEventInfof(cd, "Advance %s.%s canary weight %v", cd.Name, cd.Namespace, canaryWeight)
+		c.recordAdvanceEvent(cd, advance)
 
 		// promote canary
-		if canaryWeight == maxWeight {
-			c.recordEventInfof(cd, "Copying %s.%s template spec to %s.%s",
-				cd.Spec.TargetRef.Name, cd.Namespace, primaryName, cd.Namespace)
-			if err := c.deployer.Promote(cd); err != nil {
+		if canaryWeight >= maxWeight {
+			if err := c.promoteCanary(cd, deployer); err != nil {
 				c.recordEventWarningf(cd, "%v", err)
 				return
 			}
 		}
 	} else {
+		// wait for the promotion webhooks to confirm the promotion
+		if ok := c.checkPromotionHooks(cd); !ok {
+			return
+		}
+
+		// wait for the promoted primary to warm up
+		if ok := c.checkPrimaryWarmup(cd, deployer); !ok {
+			return
+		}
+
 		// route all traffic back to primary
 		primaryWeight = 100
 		canaryWeight = 0
@@ -368,23 +688,124 @@ func (c *Controller) advanceCanary(name string, namespace string, skipLivenessCh
 		c.recordEventInfof(cd, "Promotion completed! Scaling down %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
 
 		// shutdown canary
-		if err := c.deployer.Scale(cd, 0); err != nil {
+		if err := deployer.Scale(cd, 0); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
 
-		// update status phase
-		if err := c.deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
+		// hold the success until the promoted primary passed the verification
+		if verifying := c.startVerification(cd, deployer); verifying {
+			return
+		}
+
+		// expire the analysis silence and update status phase
+		c.endSilence(cd)
+		if err := deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
 			c.recordEventWarningf(cd, "%v", err)
 			return
 		}
 		c.recorder.SetStatus(cd)
 		c.sendNotification(cd, "Canary analysis completed successfully, promotion finished.",
-			false, false)
+			false, notifier.SeverityInfo)
+	}
+}
+
+// advanceStages routes the matched traffic of the current stage to the canary and increments the iterations,
+// the canary advances to the next stage once the stage iterations are done and is promoted after the last stage
+func (c *Controller) advanceStages(cd *flaggerv1.Canary, deployer Deployer, meshRouter router.Interface) {
+	stages := cd.Spec.CanaryAnalysis.Stages
+	stage := cd.GetStage()
+	last := cd.Status.Stage >= len(stages)-1
+
+	// move on to the next cohort, the stage index is persisted with the iterations
+	if !last && cd.Status.Iterations >= stage.Iterations {
+		cd.Status.Stage++
+		cd.Status.Iterations = 0
+		stage = cd.GetStage()
+		c.recordEventInfof(cd, "Advance %s.%s canary to stage %s %v/%v",
+			cd.Name, cd.Namespace, stage.Name, cd.Status.Stage+1, len(stages))
+	}
+
+	// route traffic to canary and increment iterations
+	if stage.Iterations > cd.Status.Iterations {
+		canaryWeight := stageCanaryWeight(stage)
+		if err := c.setRoutes(cd, meshRouter, 100-canaryWeight, canaryWeight); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+		c.recorder.SetWeight(cd, 100-canaryWeight, canaryWeight)
+
+		advance := flaggerv1.CanaryAdvance{
+			Time:         v1.Now(),
+			CanaryWeight: canaryWeight,
+			TargetWeight: canaryWeight,
+			Iteration:    cd.Status.Iterations + 1,
+			Message: fmt.Sprintf("Advance %s.%s canary stage %s iteration %v/%v",
+				cd.Name, cd.Namespace, stage.Name, cd.Status.Iterations+1, stage.Iterations),
+		}
+		appendHistory(cd, advance)
+		if err := deployer.SetStatusIterations(cd, cd.Status.Iterations+1); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+		c.recordAdvanceEvent(cd, advance)
+		return
+	}
+
+	// promote canary - the last stage passed
+	if stage.Iterations == cd.Status.Iterations {
+		if err := c.promoteCanary(cd, deployer); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+		// increment iterations
+		if err := deployer.SetStatusIterations(cd, cd.Status.Iterations+1); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+		}
+		return
+	}
+
+	// wait for the promotion webhooks to confirm the promotion
+	if ok := c.checkPromotionHooks(cd); !ok {
+		return
+	}
+
+	// wait for the promoted primary to warm up
+	if ok := c.checkPrimaryWarmup(cd, deployer); !ok {
+		return
+	}
+
+	// shutdown canary
+	if err := meshRouter.SetRoutes(cd, 100, 0); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	c.recorder.SetWeight(cd, 100, 0)
+	c.recordEventInfof(cd, "Promotion completed! Scaling down %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
+
+	// canary scale to zero
+	if err := deployer.Scale(cd, 0); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+
+	// hold the success until the promoted primary passed the verification
+	if verifying := c.startVerification(cd, deployer); verifying {
+		return
 	}
+
+	// expire the analysis silence and update status phase
+	c.endSilence(cd)
+	if err := deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	c.recorder.SetStatus(cd)
+	c.sendNotification(cd, "Canary analysis completed successfully, promotion finished.",
+		false, notifier.SeverityInfo)
 }
 
-func (c *Controller) shouldSkipAnalysis(cd *flaggerv1.Canary, meshRouter router.Interface, primaryWeight int, canaryWeight int) bool {
+func (c *Controller) shouldSkipAnalysis(cd *flaggerv1.Canary, deployer Deployer, meshRouter router.Interface, primaryWeight int, canaryWeight int) bool {
 	if !cd.Spec.SkipAnalysis {
 		return false
 	}
@@ -399,21 +820,25 @@ func (c *Controller) shouldSkipAnalysis(cd *flaggerv1.Canary, meshRouter router.
 	c.recorder.SetWeight(cd, primaryWeight, canaryWeight)
 
 	// copy spec and configs from canary to primary
-	c.recordEventInfof(cd, "Copying %s.%s template spec to %s-primary.%s",
-		cd.Spec.TargetRef.Name, cd.Namespace, cd.Spec.TargetRef.Name, cd.Namespace)
-	if err := c.deployer.Promote(cd); err != nil {
+	if err := c.promoteCanary(cd, deployer); err != nil {
 		c.recordEventWarningf(cd, "%v", err)
 		return false
 	}
 
+	// hold the scale down until the promotion webhooks confirm the promotion
+	if ok := c.checkPromotionHooks(cd); !ok {
+		return true
+	}
+
 	// shutdown canary
-	if err := c.deployer.Scale(cd, 0); err != nil {
+	if err := deployer.Scale(cd, 0); err != nil {
 		c.recordEventWarningf(cd, "%v", err)
 		return false
 	}
 
-	// update status phase
-	if err := c.deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
+	// expire the analysis silence and update status phase
+	c.endSilence(cd)
+	if err := deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
 		c.recordEventWarningf(cd, "%v", err)
 		return false
 	}
@@ -423,144 +848,2670 @@ func (c *Controller) shouldSkipAnalysis(cd *flaggerv1.Canary, meshRouter router.
 	c.recordEventInfof(cd, "Promotion completed! Canary analysis was skipped for %s.%s",
 		cd.Spec.TargetRef.Name, cd.Namespace)
 	c.sendNotification(cd, "Canary analysis was skipped, promotion finished.",
-		false, false)
+		false, notifier.SeverityInfo)
 
 	return true
 }
 
-func (c *Controller) checkCanaryStatus(cd *flaggerv1.Canary, shouldAdvance bool) bool {
-	c.recorder.SetStatus(cd)
-	if cd.Status.Phase == flaggerv1.CanaryProgressing {
-		return true
+// promoteCanary copies the canary spec and configs to the primary,
+// the copy is left to the promotion webhooks if the canary has any
+func (c *Controller) promoteCanary(cd *flaggerv1.Canary, deployer Deployer) error {
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type == flaggerv1.PromotionHook {
+			c.recordEventInfof(cd, "Promoting %s.%s with the promotion webhooks", cd.Name, cd.Namespace)
+			return nil
+		}
 	}
 
-	if cd.Status.Phase == "" {
-		if err := c.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryInitialized}); err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
-			return false
+	c.recordEventInfof(cd, "Copying %s.%s template spec to %s-primary.%s",
+		cd.Spec.TargetRef.Name, cd.Namespace, cd.Spec.TargetRef.Name, cd.Namespace)
+	return deployer.Promote(cd)
+}
+
+// checkPromotionHooks calls the promotion webhooks and returns true once every webhook
+// confirmed the promotion, the webhooks are called again on every interval until then
+func (c *Controller) checkPromotionHooks(cd *flaggerv1.Canary) bool {
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type != flaggerv1.PromotionHook {
+			continue
 		}
-		c.recorder.SetStatus(cd)
-		c.recordEventInfof(cd, "Initialization done! %s.%s", cd.Name, cd.Namespace)
-		c.sendNotification(cd, "New deployment detected, initialization completed.",
-			true, false)
-		return false
-	}
 
-	if shouldAdvance {
-		c.recordEventInfof(cd, "New revision detected! Scaling up %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
-		c.sendNotification(cd, "New revision detected, starting canary analysis.",
-			true, false)
-		if err := c.deployer.Scale(cd, 1); err != nil {
-			c.recordEventErrorf(cd, "%v", err)
+		begin := time.Now()
+		var promotion flaggerv1.CanaryPromotion
+		client, err := c.webhookTLSClient(cd, webhook)
+		if err == nil {
+			promotion, err = CallPromotion(cd.Name, cd.Namespace, cd.Status.RolloutID, webhook, client)
+		}
+		c.recorder.SetWebhook(cd, webhook.Name, time.Since(begin), err)
+		if err != nil {
+			c.recordEventWarningf(cd, "Halt %s.%s promotion %s failed %v",
+				cd.Name, cd.Namespace, webhook.Name, err)
 			return false
 		}
-		if err := c.deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryProgressing}); err != nil {
-			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
+
+		if promotion.Status == flaggerv1.PromotionPending {
+			msg := fmt.Sprintf("Hold %s.%s scale down waiting for promotion %s", cd.Name, cd.Namespace, webhook.Name)
+			if promotion.Message != "" {
+				msg = msg + ", " + promotion.Message
+			}
+			c.recordEventInfof(cd, "%s", msg)
 			return false
 		}
-		c.recorder.SetStatus(cd)
-		return false
 	}
-	return false
+	return true
 }
 
-func (c *Controller) hasCanaryRevisionChanged(cd *flaggerv1.Canary) bool {
-	if cd.Status.Phase == flaggerv1.CanaryProgressing {
-		if diff, _ := c.deployer.IsNewSpec(cd); diff {
-			return true
-		}
-		if diff, _ := c.deployer.configTracker.HasConfigChanged(cd); diff {
-			return true
+// checkPrimaryWarmup returns true once the promoted primary has been ready for the warmup duration
+// and the primary-warmup webhooks passed, or once the warmup timed out
+func (c *Controller) checkPrimaryWarmup(cd *flaggerv1.Canary, deployer Deployer) bool {
+	var hooks []flaggerv1.CanaryWebhook
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type == flaggerv1.PrimaryWarmupHook {
+			hooks = append(hooks, webhook)
 		}
 	}
-	return false
-}
+	if cd.Spec.CanaryAnalysis.PrimaryWarmup == nil && len(hooks) == 0 {
+		return true
+	}
 
-func (c *Controller) analyseCanary(r *flaggerv1.Canary) bool {
-	// run external checks
-	for _, webhook := range r.Spec.CanaryAnalysis.Webhooks {
-		err := CallWebhook(r.Name, r.Namespace, webhook)
-		if err != nil {
-			c.recordEventWarningf(r, "Halt %s.%s advancement external check %s failed %v",
-				r.Name, r.Namespace, webhook.Name, err)
+	// the liveness checks may be skipped, the warmup starts only once the primary rollout finished
+	if _, err := deployer.IsPrimaryReady(cd); err != nil {
+		c.recordEventInfof(cd, "Hold %s.%s traffic switch waiting for the primary %v", cd.Name, cd.Namespace, err)
+		return false
+	}
+
+	start := cd.Status.PrimaryWarmupStartTime
+	if start.IsZero() {
+		if err := deployer.SetStatusPrimaryWarmupStartTime(cd, v1.Now()); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
 			return false
 		}
+		c.recordEventInfof(cd, "Starting %s-primary.%s warmup", cd.Spec.TargetRef.Name, cd.Namespace)
+		return false
 	}
 
-	// run metrics checks
-	for _, metric := range r.Spec.CanaryAnalysis.Metrics {
-		if metric.Interval == "" {
-			metric.Interval = r.GetMetricInterval()
-		}
+	elapsed := time.Since(start.Time)
+	if timeout := cd.GetPrimaryWarmupTimeout(); timeout > 0 && elapsed >= timeout {
+		c.recordEventWarningf(cd, "Primary %s-primary.%s warmup timed out after %v, routing all traffic to the primary",
+			cd.Spec.TargetRef.Name, cd.Namespace, timeout)
+		return true
+	}
 
-		if metric.Name == "envoy_cluster_upstream_rq" {
-			val, err := c.observer.GetEnvoySuccessRate(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval)
-			if err != nil {
-				if strings.Contains(err.Error(), "no values found") {
-					c.recordEventWarningf(r, "Halt advancement no values found for metric %s probably %s.%s is not receiving traffic",
-						metric.Name, r.Spec.TargetRef.Name, r.Namespace)
-				} else {
-					c.recordEventErrorf(r, "Metrics server %s query failed: %v", c.observer.metricsServer, err)
-				}
-				return false
-			}
-			if float64(metric.Threshold) > val {
-				c.recordEventWarningf(r, "Halt %s.%s advancement success rate %.2f%% < %v%%",
-					r.Name, r.Namespace, val, metric.Threshold)
-				return false
-			}
-		}
+	if remaining := cd.GetPrimaryWarmupDuration() - elapsed; remaining > 0 {
+		c.recordEventInfof(cd, "Hold %s.%s traffic switch warming up the primary %v remaining",
+			cd.Name, cd.Namespace, remaining.Round(time.Second))
+		return false
+	}
 
-		if metric.Name == "istio_requests_total" {
-			val, err := c.observer.GetDeploymentCounter(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval)
-			if err != nil {
-				if strings.Contains(err.Error(), "no values found") {
-					c.recordEventWarningf(r, "Halt advancement no values found for metric %s probably %s.%s is not receiving traffic",
-						metric.Name, r.Spec.TargetRef.Name, r.Namespace)
-				} else {
-					c.recordEventErrorf(r, "Metrics server %s query failed: %v", c.observer.metricsServer, err)
+	for _, webhook := range hooks {
+		if _, err := c.callWebhook(cd, webhook); err != nil {
+			c.recordEventInfof(cd, "Hold %s.%s traffic switch primary warmup %s failed %v",
+				cd.Name, cd.Namespace, webhook.Name, err)
+			return false
+		}
+	}
+	return true
+}
+
+// startVerification records the start of the promoted primary verification,
+// it returns false if the canary has no promotion verification
+func (c *Controller) startVerification(cd *flaggerv1.Canary, deployer Deployer) bool {
+	duration := cd.GetVerificationDuration()
+	if duration == 0 {
+		return false
+	}
+
+	if err := deployer.SetStatusVerificationStartTime(cd, v1.Now()); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return true
+	}
+	c.recordEventInfof(cd, "Promotion completed! Verifying %s-primary.%s for %v",
+		cd.Spec.TargetRef.Name, cd.Namespace, duration)
+	return true
+}
+
+// verifyPromotion checks the metrics of the promoted primary until the verification duration has elapsed,
+// a failed check restores the primary template of the previous promotion and fails the canary
+func (c *Controller) verifyPromotion(cd *flaggerv1.Canary, deployer Deployer) {
+	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+
+	// query the metrics of the primary workload instead of the canary
+	primary := cd.DeepCopy()
+	primary.Spec.TargetRef.Name = primaryName
+	for _, metric := range verificationMetrics(c.analysisMetrics(cd)) {
+		check := c.checkMetric(primary, metric)
+		if check.passed {
+			continue
+		}
+		// a metrics server failure holds the verification instead of rolling back a healthy primary
+		if check.queryError {
+			c.recordEventErrorf(cd, "%s", check.message)
+			return
+		}
+
+		c.recordEventWarningf(cd, "Rolling back %s.%s promotion verification failed %s",
+			cd.Name, cd.Namespace, check.String())
+		c.sendNotification(cd, fmt.Sprintf("Promotion verification failed %s", check.String()),
+			false, notifier.SeverityError)
+		c.failVerification(cd, deployer)
+		return
+	}
+
+	remaining := cd.GetVerificationDuration() - time.Since(cd.Status.VerificationStartTime.Time)
+	if remaining > 0 {
+		c.recordEventInfof(cd, "Verifying %s.%s promotion, succeeds in %v",
+			primaryName, cd.Namespace, remaining.Round(time.Second))
+		return
+	}
+
+	// expire the analysis silence and update status phase
+	c.endSilence(cd)
+	if err := deployer.SetStatusPhase(cd, flaggerv1.CanarySucceeded); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	c.recorder.SetStatus(cd)
+	c.recordEventInfof(cd, "Promotion verified! %s.%s passed the verification", primaryName, cd.Namespace)
+	c.sendNotification(cd, "Canary analysis completed successfully, promotion verified.",
+		false, notifier.SeverityInfo)
+}
+
+// failVerification restores the primary template of the previous promotion and marks the canary as failed
+func (c *Controller) failVerification(cd *flaggerv1.Canary, deployer Deployer) {
+	revision := c.canaryRelease(cd)
+
+	restored, err := deployer.RestorePrimary(cd)
+	if err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+	} else if restored {
+		c.recordEventWarningf(cd, "Primary %s-primary.%s rolled back to the template of the previous promotion",
+			cd.Spec.TargetRef.Name, cd.Namespace)
+	}
+
+	// expire the analysis silence and mark canary as failed
+	c.endSilence(cd)
+	reason := flaggerv1.RollbackVerificationFailure
+	status := flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryFailed, CanaryWeight: 0, RollbackReason: reason}
+	if err := deployer.SyncStatus(cd, status); err != nil {
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
+		return
+	}
+
+	c.recorder.SetStatus(cd)
+	c.recorder.IncRollback(cd, reason)
+	if err := c.annotateRollback(cd, "Promotion verification failed", revision); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+	}
+}
+
+// verificationMetrics returns the analysis metrics evaluated against the promoted primary,
+// the metrics comparing the canary to the primary or the baseline are left out
+func verificationMetrics(metrics []flaggerv1.CanaryMetric) []flaggerv1.CanaryMetric {
+	var verified []flaggerv1.CanaryMetric
+	for _, metric := range metrics {
+		if metric.ThresholdPercent > 0 || metric.LatencyDelta != nil {
+			continue
+		}
+		verified = append(verified, metric)
+	}
+	return verified
+}
+
+// cancelCanary routes all traffic to the primary and scales the canary to zero,
+// the cancellation is not counted as a failed analysis and during the analysis
+// of the first revision the canary keeps the traffic as the primary has no replicas
+func (c *Controller) cancelCanary(cd *flaggerv1.Canary, deployer Deployer, meshRouter router.Interface) {
+	if cd.Status.Phase != flaggerv1.CanaryProgressing {
+		return
+	}
+
+	// the primary has no replicas until the first revision is promoted, the canary keeps serving
+	if cd.Status.InitialAnalysis {
+		if err := meshRouter.SetRoutes(cd, 0, 100); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return
+		}
+		c.recorder.SetWeight(cd, 0, 100)
+
+		status := flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryCancelled, InitialAnalysis: true}
+		if err := deployer.SyncStatus(cd, status); err != nil {
+			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
+			return
+		}
+		c.recorder.SetStatus(cd)
+		c.recordEventInfof(cd, "Canary %s.%s suspended, analysis cancelled and %s.%s kept serving the traffic",
+			cd.Name, cd.Namespace, cd.Spec.TargetRef.Name, cd.Namespace)
+		c.sendNotification(cd, "Canary suspended, analysis cancelled.",
+			false, notifier.SeverityInfo)
+		return
+	}
+
+	if err := meshRouter.SetRoutes(cd, 100, 0); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	c.recorder.SetWeight(cd, 100, 0)
+
+	if err := deployer.Scale(cd, 0); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+
+	if err := deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryCancelled}); err != nil {
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
+		return
+	}
+	c.recorder.SetStatus(cd)
+	c.recordEventInfof(cd, "Canary %s.%s suspended, analysis cancelled and %s.%s scaled down",
+		cd.Name, cd.Namespace, cd.Spec.TargetRef.Name, cd.Namespace)
+	c.sendNotification(cd, "Canary suspended, analysis cancelled.",
+		false, notifier.SeverityInfo)
+}
+
+// warmupCanary routes the warmup weight to the canary and returns true
+// until the warmup duration has elapsed since the canary became ready
+func (c *Controller) warmupCanary(cd *flaggerv1.Canary, deployer Deployer, meshRouter router.Interface, canaryWeight int) bool {
+	duration := cd.GetWarmupDuration()
+	if duration == 0 || cd.Status.Phase != flaggerv1.CanaryProgressing {
+		return false
+	}
+
+	start := cd.Status.WarmupStartTime
+	if start.IsZero() {
+		start = v1.Now()
+		if err := deployer.SetStatusWarmupStartTime(cd, start); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return true
+		}
+	}
+
+	remaining := duration - time.Since(start.Time)
+	if remaining <= 0 {
+		return false
+	}
+
+	weight := cd.Spec.CanaryAnalysis.WarmupWeight
+	if canaryWeight != weight {
+		if err := c.setRoutes(cd, meshRouter, 100-weight, weight); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+			return true
+		}
+		c.recorder.SetWeight(cd, 100-weight, weight)
+	}
+
+	c.recordEventInfof(cd, "Warming up %s.%s canary weight %v, analysis starts in %v",
+		cd.Name, cd.Namespace, weight, remaining.Round(time.Second))
+	return true
+}
+
+func (c *Controller) checkCanaryStatus(cd *flaggerv1.Canary, deployer Deployer, shouldAdvance bool) bool {
+	c.recorder.SetStatus(cd)
+	c.recorder.SetProgress(cd)
+	if cd.Status.Phase == flaggerv1.CanaryProgressing {
+		return true
+	}
+
+	// the first revision goes through the analysis instead of waiting for the next one,
+	// the primary is held at zero replicas until the first revision is promoted
+	if cd.Status.Phase == "" && cd.Spec.CanaryAnalysis.InitializationMode == flaggerv1.InitializationAnalyze {
+		c.recordEventInfof(cd, "Initialization done! Starting the analysis of the first revision of %s.%s",
+			cd.Spec.TargetRef.Name, cd.Namespace)
+		cd = cd.DeepCopy()
+		cd.Status.InitialAnalysis = true
+		shouldAdvance = true
+	} else if cd.Status.Phase == "" {
+		if err := deployer.SyncStatus(cd, flaggerv1.CanaryStatus{Phase: flaggerv1.CanaryInitialized}); err != nil {
+			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
+			return false
+		}
+		c.recorder.SetStatus(cd)
+		c.recordEventInfof(cd, "Initialization done! %s.%s", cd.Name, cd.Namespace)
+		c.sendNotification(cd, "New deployment detected, initialization completed.",
+			true, notifier.SeverityInfo)
+		return false
+	}
+
+	if shouldAdvance {
+		// tag the events and notifications of the new analysis run with its ID
+		cd = cd.DeepCopy()
+		cd.Status.RolloutID = newRolloutID()
+		c.analysis.Delete(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace))
+		if c.metricsCache != nil {
+			c.metricsCache.Invalidate(cd)
+		}
+		c.recordEventInfof(cd, "New revision detected! Scaling up %s.%s", cd.Spec.TargetRef.Name, cd.Namespace)
+		c.sendNotification(cd, "New revision detected, starting canary analysis.",
+			true, notifier.SeverityInfo)
+		c.checkHistogramMetrics(cd)
+		c.checkDNSPropagation(cd)
+		if err := deployer.Scale(cd, 1); err != nil {
+			c.recordEventErrorf(cd, "%v", err)
+			return false
+		}
+		status := flaggerv1.CanaryStatus{
+			Phase:             flaggerv1.CanaryProgressing,
+			AnalysisStartTime: v1.Now(),
+			SilenceID:         c.startSilence(cd),
+			RolloutID:         cd.Status.RolloutID,
+			InitialAnalysis:   cd.Status.InitialAnalysis,
+		}
+		if err := deployer.SyncStatus(cd, status); err != nil {
+			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
+			return false
+		}
+		c.recorder.SetStatus(cd)
+		return false
+	}
+	return false
+}
+
+// newRolloutID returns a random ID used to correlate the events,
+// notifications and webhook calls of an analysis run
+func newRolloutID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// checkDNSPropagation warns that the weighted DNS records shift the traffic with a delay,
+// the resolvers keep routing to the previous records until the TTL expires
+func (c *Controller) checkDNSPropagation(cd *flaggerv1.Canary) {
+	dns := cd.Spec.Service.DNS
+	if dns == nil || !strings.Contains(c.meshProvider, "externaldns") {
+		return
+	}
+	if ttl := time.Duration(dns.TTL) * time.Second; ttl >= cd.GetAnalysisInterval() {
+		c.recordEventWarningf(cd, "DNS record %s TTL %v is longer than the analysis interval, "+
+			"the metrics of a step can be evaluated before the traffic shift propagates", dns.Name, ttl)
+		return
+	}
+	c.recordEventWarningf(cd, "DNS record %s traffic shifts take up to the TTL of %vs to propagate", dns.Name, dns.TTL)
+}
+
+// targetDriftSummary lists what changed in the canary target since the analysis started,
+// the summary is empty if the drift can't be determined
+func (c *Controller) targetDriftSummary(cd *flaggerv1.Canary, deployer Deployer) string {
+	drift, err := deployer.GetTargetDrift(cd)
+	if err != nil {
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).
+			Errorf("Drift detection failed %v", err)
+		return ""
+	}
+	if len(drift) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" changed: %s", strings.Join(drift, ", "))
+}
+
+func (c *Controller) hasCanaryRevisionChanged(cd *flaggerv1.Canary, deployer Deployer) bool {
+	if cd.Status.Phase == flaggerv1.CanaryProgressing {
+		if diff, _ := deployer.HasTargetChanged(cd); diff {
+			return true
+		}
+	}
+	return false
+}
+
+// analyseCanary runs the webhooks and the metric checks,
+// the result holds the evaluation of every metric even if the analysis failed
+func (c *Controller) analyseCanary(r *flaggerv1.Canary) analysisResult {
+	// run external checks, the grouped webhooks are evaluated with the quorum of their group
+	for _, webhook := range r.Spec.CanaryAnalysis.Webhooks {
+		if !isRolloutHook(webhook) || webhook.Group != "" {
+			continue
+		}
+		running, err := c.callWebhook(r, webhook)
+		if err != nil {
+			c.recordEventWarningf(r, "Halt %s.%s advancement external check %s failed %v",
+				r.Name, r.Namespace, webhook.Name, err)
+			return analysisResult{reason: flaggerv1.RollbackWebhookFailure}
+		}
+		if !running {
+			c.recordEventInfof(r, "Hold %s.%s advancement waiting for load test %s traffic",
+				r.Name, r.Namespace, webhook.Name)
+			return analysisResult{waiting: true}
+		}
+	}
+
+	if err := c.checkWebhookGroups(r); err != nil {
+		c.recordEventWarningf(r, "Halt %s.%s advancement %v", r.Name, r.Namespace, err)
+		return analysisResult{reason: flaggerv1.RollbackWebhookFailure}
+	}
+
+	// leave the metrics of the unhealthy regions out of the analysis
+	if ok := c.checkRegionHealth(r); !ok {
+		return analysisResult{waiting: true}
+	}
+
+	// hold the advancement instead of evaluating metrics lagging behind
+	if stale := c.checkStaleness(r); stale {
+		return analysisResult{waiting: true}
+	}
+
+	result := c.evaluateMetrics(r, true)
+	c.recordMetricsEvents(r, result)
+	return result
+}
+
+// evaluateMetrics runs the metric checks and applies the no values policy, the scoring and the thresholds
+// without recording events, the smoothed values and the no values start time are set on the canary status
+// and persisted by the next status update, the metrics cache is updated only if cache is true
+func (c *Controller) evaluateMetrics(r *flaggerv1.Canary, cache bool) analysisResult {
+	// run metrics checks concurrently, the observer limits the number of in-flight queries
+	metrics := c.analysisMetrics(r)
+	checks := make([]metricCheck, len(metrics))
+	var wg sync.WaitGroup
+	for i, metric := range metrics {
+		wg.Add(1)
+		go func(i int, metric flaggerv1.CanaryMetric) {
+			defer wg.Done()
+			if c.metricsCache != nil {
+				if check, ok := c.metricsCache.Get(r, metric); ok {
+					checks[i] = check
+					return
 				}
-				return false
 			}
-			if float64(metric.Threshold) > val {
-				c.recordEventWarningf(r, "Halt %s.%s advancement success rate %.2f%% < %v%%",
-					r.Name, r.Namespace, val, metric.Threshold)
-				return false
+			checks[i] = c.checkMetric(r, metric)
+			if c.metricsCache != nil && cache {
+				c.metricsCache.Set(r, metric, checks[i])
 			}
+		}(i, metric)
+	}
+	wg.Wait()
+
+	// average the values of the smoothed metrics over the last intervals before thresholding
+	for i, metric := range metrics {
+		if metric.EvaluationWindows > 1 {
+			checks[i] = smoothCheck(r, metric, checks[i])
 		}
+	}
 
-		if metric.Name == "istio_request_duration_seconds_bucket" {
-			val, err := c.observer.GetDeploymentHistogram(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval)
-			if err != nil {
-				c.recordEventErrorf(r, "Metrics server %s query failed: %v", c.observer.metricsServer, err)
-				return false
+	// apply the no values policy, the default halts the advancement like a failed metric
+	switch r.Spec.CanaryAnalysis.NoValuesPolicy {
+	case flaggerv1.NoValuesPass:
+		for i := range checks {
+			if checks[i].noValues {
+				checks[i].passed, checks[i].skipped, checks[i].noValues = true, true, false
+				checks[i].noValuesSkipped = true
 			}
-			t := time.Duration(metric.Threshold) * time.Millisecond
-			if val > t {
-				c.recordEventWarningf(r, "Halt %s.%s advancement request duration %v > %v",
-					r.Name, r.Namespace, val, t)
-				return false
+		}
+	case flaggerv1.NoValuesFail:
+		noValues := false
+		for _, check := range checks {
+			if check.noValues {
+				noValues = true
 			}
 		}
+		// the checks without values halt the advancement until the timeout
+		if !noValues {
+			r.Status.NoValuesStartTime = v1.Time{}
+		} else if r.Status.NoValuesStartTime.IsZero() {
+			r.Status.NoValuesStartTime = v1.Now()
+		} else if timeout := r.GetNoValuesTimeout(); time.Since(r.Status.NoValuesStartTime.Time) > timeout {
+			return analysisResult{checks: checks, abort: true, reason: flaggerv1.RollbackNoTraffic}
+		}
+	}
 
-		if metric.Query != "" {
-			val, err := c.observer.GetScalar(metric.Query)
-			if err != nil {
-				if strings.Contains(err.Error(), "no values found") {
-					c.recordEventWarningf(r, "Halt advancement no values found for metric %s probably %s.%s is not receiving traffic",
-						metric.Name, r.Spec.TargetRef.Name, r.Namespace)
-				} else {
-					c.recordEventErrorf(r, "Metrics server %s query failed: %v", c.observer.metricsServer, err)
+	result := analysisResult{passed: true, checks: checks, reason: flaggerv1.RollbackMetricThreshold}
+
+	// weighted scoring tolerates failed metrics if the score is above the threshold
+	if threshold := r.Spec.CanaryAnalysis.ScoreThreshold; threshold > 0 {
+		score := analysisScore(metrics, checks)
+		result.score = &score
+		result.passed = score >= threshold
+		return result
+	}
+
+	// report the first failed check in the order the metrics are defined
+	for i, check := range checks {
+		if check.warning {
+			result.warning = true
+		}
+		if !check.passed {
+			if result.passed {
+				if check.noValues {
+					result.reason = flaggerv1.RollbackNoTraffic
 				}
-				return false
-			}
-			if val > float64(metric.Threshold) {
-				c.recordEventWarningf(r, "Halt %s.%s advancement %s %.2f > %v",
-					r.Name, r.Namespace, metric.Name, val, metric.Threshold)
-				return false
+				result.failed = &checks[i]
+				result.passed = false
+				result.degraded = true
 			}
+			result.degraded = result.degraded && check.degraded
+		}
+	}
+
+	return result
+}
+
+// recordMetricsEvents records the skipped, the aborted and the failed checks of the metrics evaluation
+func (c *Controller) recordMetricsEvents(r *flaggerv1.Canary, result analysisResult) {
+	for _, check := range result.checks {
+		if check.noValuesSkipped {
+			c.recordEventInfof(r, "Skip %s.%s metric %s, no values found", r.Name, r.Namespace, check.name)
+		}
+	}
+
+	if result.abort {
+		c.recordEventWarningf(r, "Rolling back %s.%s no values found for more than %v",
+			r.Name, r.Namespace, r.GetNoValuesTimeout())
+		return
+	}
+
+	// report the observed and the required sample counts of the metrics skipped on low traffic
+	for _, check := range result.checks {
+		if check.skipped && check.samples != nil {
+			c.recordEventInfof(r, "Skip %s.%s metric %s, %.0f samples observed, %v required",
+				r.Name, r.Namespace, check.name, *check.samples, check.minSamples)
+		}
+	}
+
+	if result.score != nil && !result.passed {
+		c.recordEventWarningf(r, "Halt %s.%s advancement analysis score %.2f < %v %s",
+			r.Name, r.Namespace, *result.score, r.Spec.CanaryAnalysis.ScoreThreshold, result.Summary())
+	}
+
+	if check := result.failed; check != nil {
+		if check.queryError {
+			c.recordEventErrorf(r, "%s", check.message)
+		} else {
+			c.recordEventWarningf(r, "%s", check.message)
+		}
+	}
+}
+
+// checkKillSwitch returns true if the kill switch is engaged,
+// the hold and the release are recorded once per canary
+func (c *Controller) checkKillSwitch(cd *flaggerv1.Canary) bool {
+	if !c.killSwitch.Enabled() {
+		return false
+	}
+
+	engaged, reason, err := c.killSwitch.IsEngaged()
+	if err != nil {
+		// an unreachable API server fails the advancement anyway
+		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Errorf("%v", err)
+		return false
+	}
+
+	key := fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)
+	if !engaged {
+		if released := c.killSwitch.Release(key); released {
+			c.recordEventInfof(cd, "Resume %s.%s advancement, kill switch released", cd.Name, cd.Namespace)
 		}
+		return false
 	}
 
+	if held := c.killSwitch.Hold(key); held {
+		message := fmt.Sprintf("Halt %s.%s advancement, kill switch engaged", cd.Name, cd.Namespace)
+		if reason != "" {
+			message = fmt.Sprintf("%s: %s", message, reason)
+		}
+		c.recordEventWarningf(cd, "%s", message)
+	}
 	return true
 }
+
+// checkAllowedWindow returns true if the canary is outside its daily allowed window,
+// on resume the step and warmup start times are shifted by the suspension
+// so that the step holds and the warmup don't count the suspended time
+func (c *Controller) checkAllowedWindow(cd *flaggerv1.Canary, deployer Deployer) bool {
+	open := true
+	if window := cd.Spec.CanaryAnalysis.AllowedWindow; window != nil {
+		var err error
+		if open, err = windowContains(window, time.Now()); err != nil {
+			c.recordEventWarningf(cd, "Canary %s.%s allowed window %v", cd.Name, cd.Namespace, err)
+			return true
+		}
+	}
+	suspended := !cd.Status.WindowSuspendTime.IsZero()
+
+	if !open {
+		if !suspended {
+			cd.Status.WindowSuspendTime = v1.Now()
+			if err := deployer.SetStatusWindowSuspendTime(cd, cd.Status.WindowSuspendTime); err != nil {
+				c.recordEventWarningf(cd, "%v", err)
+				return true
+			}
+			c.recordEventInfof(cd, "Suspend %s.%s analysis outside the allowed window %s",
+				cd.Name, cd.Namespace, windowDescription(cd.Spec.CanaryAnalysis.AllowedWindow))
+		}
+		return true
+	}
+
+	if !suspended {
+		return false
+	}
+
+	// the shifted times are persisted with the suspend time reset
+	suspension := time.Since(cd.Status.WindowSuspendTime.Time)
+	if cd.Status.Phase == flaggerv1.CanaryProgressing {
+		start := cd.Status.StepStartTime
+		if start.IsZero() {
+			start = cd.Status.AnalysisStartTime
+		}
+		if !start.IsZero() {
+			cd.Status.StepStartTime = v1.NewTime(start.Add(suspension))
+		}
+		if !cd.Status.WarmupStartTime.IsZero() {
+			cd.Status.WarmupStartTime = v1.NewTime(cd.Status.WarmupStartTime.Add(suspension))
+		}
+	}
+	cd.Status.WindowSuspendTime = v1.Time{}
+	if err := deployer.SetStatusWindowSuspendTime(cd, cd.Status.WindowSuspendTime); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return true
+	}
+	c.recordEventInfof(cd, "Resume %s.%s analysis, allowed window opened after %v",
+		cd.Name, cd.Namespace, suspension.Round(time.Second))
+	return false
+}
+
+// checkRegionHealth calls the region-health webhooks and stores the unhealthy regions in the canary status,
+// it returns false if a webhook failed since the metrics of an unhealthy region could skew the analysis
+func (c *Controller) checkRegionHealth(r *flaggerv1.Canary) bool {
+	if r.Spec.CanaryAnalysis.RegionLabel == "" {
+		return true
+	}
+
+	unhealthy := make(map[string]bool)
+	for _, webhook := range r.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type != flaggerv1.RegionHealthHook {
+			continue
+		}
+
+		begin := time.Now()
+		var regions []string
+		client, err := c.webhookTLSClient(r, webhook)
+		if err == nil {
+			regions, err = CallRegionHealth(r.Name, r.Namespace, r.Status.RolloutID, webhook, client)
+		}
+		c.recorder.SetWebhook(r, webhook.Name, time.Since(begin), err)
+		if err != nil {
+			c.recordEventWarningf(r, "Hold %s.%s advancement region health %s failed %v",
+				r.Name, r.Namespace, webhook.Name, err)
+			return false
+		}
+		for _, region := range regions {
+			unhealthy[region] = true
+		}
+	}
+
+	var excluded []string
+	for region := range unhealthy {
+		excluded = append(excluded, region)
+	}
+	sort.Strings(excluded)
+
+	if strings.Join(excluded, ",") != strings.Join(r.Status.ExcludedRegions, ",") {
+		if len(excluded) > 0 {
+			c.recordEventWarningf(r, "Exclude regions %s from the %s.%s analysis",
+				strings.Join(excluded, ", "), r.Name, r.Namespace)
+		} else {
+			c.recordEventInfof(r, "Include all regions in the %s.%s analysis", r.Name, r.Namespace)
+		}
+	}
+	// the excluded regions are persisted by the next status update
+	r.Status.ExcludedRegions = excluded
+	return true
+}
+
+// checkStaleness returns true if the latest request metric sample of the canary
+// is older than the canary or the controller staleness tolerance
+func (c *Controller) checkStaleness(r *flaggerv1.Canary) bool {
+	tolerance := r.GetMetricsStaleness()
+	if tolerance == 0 {
+		tolerance = c.metricsStaleness
+	}
+	if tolerance == 0 || len(c.analysisMetrics(r)) == 0 {
+		return false
+	}
+
+	age, err := c.observer.GetSampleAge(r.Spec.TargetRef.Name, r.Namespace, c.meshProvider)
+	if err != nil {
+		// the metric checks report the metrics server errors
+		c.logger.With("canary", fmt.Sprintf("%s.%s", r.Name, r.Namespace)).
+			Debugf("Metrics freshness query failed: %v", err)
+		return false
+	}
+
+	if age > tolerance {
+		c.recordEventWarningf(r, "Hold %s.%s advancement metrics are stale, the latest sample is %v old (tolerance %v)",
+			r.Name, r.Namespace, age.Round(time.Second), tolerance)
+		return true
+	}
+	return false
+}
+
+// checkApprovals registers the approvals with the approval webhooks and polls their decisions,
+// it returns true if the advancement should be held and the name of the webhook that rejected the canary
+func (c *Controller) checkApprovals(cd *flaggerv1.Canary, deployer Deployer) (bool, string) {
+	approvals := make(map[string]string)
+	if cd.Status.Approvals != nil {
+		for k, v := range *cd.Status.Approvals {
+			approvals[k] = v
+		}
+	}
+	times := make(map[string]v1.Time)
+	if cd.Status.ApprovalTimes != nil {
+		for k, v := range *cd.Status.ApprovalTimes {
+			times[k] = v
+		}
+	}
+
+	hold, changed := false, false
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type != flaggerv1.ApprovalHook {
+			continue
+		}
+
+		client, err := c.webhookTLSClient(cd, webhook)
+		if err != nil {
+			c.recordEventWarningf(cd, "Halt %s.%s advancement approval %s failed %v",
+				cd.Name, cd.Namespace, webhook.Name, err)
+			hold = true
+			continue
+		}
+
+		id, ok := approvals[webhook.Name]
+		// an expired approval is replaced by a new one that must be approved again
+		if ok && approvalExpired(webhook, times[webhook.Name]) {
+			c.recordEventInfof(cd, "Approval %s for %s.%s expired after %s",
+				webhook.Name, cd.Name, cd.Namespace, webhook.ApprovalExpiry)
+			delete(times, webhook.Name)
+			ok = false
+		}
+		if !ok {
+			id, err := CreateApproval(cd.Name, cd.Namespace, cd.Status.RolloutID, webhook, client)
+			if err != nil {
+				c.recordEventWarningf(cd, "Halt %s.%s advancement approval %s registration failed %v",
+					cd.Name, cd.Namespace, webhook.Name, err)
+				hold = true
+				continue
+			}
+			c.recordEventInfof(cd, "Approval %s registered for %s.%s with ID %s",
+				webhook.Name, cd.Name, cd.Namespace, id)
+			approvals[webhook.Name] = id
+			hold, changed = true, true
+			continue
+		}
+
+		status, err := GetApproval(webhook, id, client)
+		if err != nil {
+			c.recordEventWarningf(cd, "Halt %s.%s advancement approval %s query failed %v",
+				cd.Name, cd.Namespace, webhook.Name, err)
+			hold = true
+			continue
+		}
+
+		switch status {
+		case flaggerv1.ApprovalRejected:
+			// the rollback resets the registered approvals
+			return false, webhook.Name
+		case flaggerv1.ApprovalPending:
+			c.recordEventInfof(cd, "Hold %s.%s advancement waiting for approval %s",
+				cd.Name, cd.Namespace, webhook.Name)
+			hold = true
+		case flaggerv1.ApprovalApproved:
+			// the expiry starts when the approval is first seen approved
+			if _, ok := times[webhook.Name]; !ok && webhook.ApprovalExpiry != "" {
+				times[webhook.Name] = v1.Now()
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		cd.Status.Approvals = &approvals
+		cd.Status.ApprovalTimes = &times
+		if err := deployer.SetStatusApprovals(cd, approvals); err != nil {
+			c.recordEventWarningf(cd, "%v", err)
+		}
+	}
+
+	return hold, ""
+}
+
+// approvalExpired returns true if the approval was granted longer than the webhook approval expiry ago
+func approvalExpired(webhook flaggerv1.CanaryWebhook, approvedAt v1.Time) bool {
+	if webhook.ApprovalExpiry == "" || approvedAt.IsZero() {
+		return false
+	}
+	expiry, err := time.ParseDuration(webhook.ApprovalExpiry)
+	if err != nil {
+		return false
+	}
+	return time.Since(approvedAt.Time) > expiry
+}
+
+// runPreRolloutHooks calls the pre-rollout webhooks before routing traffic to the canary,
+// it returns false if a webhook failed or the load test traffic is not flowing yet
+func (c *Controller) runPreRolloutHooks(cd *flaggerv1.Canary, deployer Deployer) bool {
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type != flaggerv1.PreRolloutHook {
+			continue
+		}
+		running, err := c.callWebhook(cd, webhook)
+		if err != nil {
+			c.recordEventWarningf(cd, "Halt %s.%s advancement pre-rollout check %s failed %v",
+				cd.Name, cd.Namespace, webhook.Name, err)
+			cd.Status.FailedCheckReason = flaggerv1.RollbackWebhookFailure
+			if err := deployer.SetStatusFailedChecks(cd, cd.Status.FailedChecks+1); err != nil {
+				c.recordEventWarningf(cd, "%v", err)
+			}
+			return false
+		}
+		if !running {
+			c.recordEventInfof(cd, "Hold %s.%s advancement waiting for load test %s traffic",
+				cd.Name, cd.Namespace, webhook.Name)
+			return false
+		}
+	}
+	return true
+}
+
+// checkReadinessGates calls the readiness gate webhooks and returns a non retriable error
+// if a gate has been failing for longer than the progress deadline
+func (c *Controller) checkReadinessGates(cd *flaggerv1.Canary) (bool, error) {
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type != flaggerv1.ReadinessGateHook {
+			continue
+		}
+		if _, err := c.callWebhook(cd, webhook); err != nil {
+			// the failure time is persisted by the next status update
+			if cd.Status.ReadinessGateFailureTime.IsZero() {
+				cd.Status.ReadinessGateFailureTime = v1.Now()
+			}
+			deadline := time.Duration(cd.GetProgressDeadlineSeconds()) * time.Second
+			if time.Since(cd.Status.ReadinessGateFailureTime.Time) > deadline {
+				return false, fmt.Errorf("readiness gate %s failed for more than %vs %v",
+					webhook.Name, cd.GetProgressDeadlineSeconds(), err)
+			}
+			return true, fmt.Errorf("Halt advancement %s.%s readiness gate %s failed %v",
+				cd.Name, cd.Namespace, webhook.Name, err)
+		}
+	}
+	cd.Status.ReadinessGateFailureTime = v1.Time{}
+	return true, nil
+}
+
+// analysisScore returns the weighted percentage of passing metrics
+func analysisScore(metrics []flaggerv1.CanaryMetric, checks []metricCheck) float64 {
+	var total, passed float64
+	for i, check := range checks {
+		// skipped metrics don't count towards the score
+		if check.skipped {
+			continue
+		}
+		weight := metrics[i].Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		if check.passed {
+			passed += weight
+		}
+	}
+	if total == 0 {
+		return 100
+	}
+	return passed / total * 100
+}
+
+// recordAnalysis exposes the metric values of the last analysis as Prometheus metrics
+// and keeps them for the notifications sent until the next analysis
+func (c *Controller) recordAnalysis(cd *flaggerv1.Canary, result analysisResult) {
+	c.recorder.SetAnalysis(cd, result.checks)
+	c.analysis.Store(fmt.Sprintf("%s.%s", cd.Name, cd.Namespace), result)
+	if result.passed && len(result.checks) > 0 {
+		c.recordEventInfof(cd, "Analysis passed for %s.%s %s", cd.Name, cd.Namespace, result.Summary())
+	}
+}
+
+// checkStalled sends a notification once the canary advancement
+// has been halted for the configured number of consecutive intervals
+func (c *Controller) checkStalled(cd *flaggerv1.Canary, haltedIntervals int) {
+	threshold := cd.Spec.CanaryAnalysis.StalledThreshold
+	if threshold < 1 || haltedIntervals != threshold {
+		return
+	}
+
+	c.recordEventWarningf(cd, "Canary %s.%s stalled, advancement halted for %v consecutive intervals",
+		cd.Name, cd.Namespace, haltedIntervals)
+	c.sendNotification(cd, fmt.Sprintf("Canary analysis stalled, advancement halted for %v consecutive intervals", haltedIntervals),
+		true, notifier.SeverityWarn)
+}
+
+// nextWeights increments the canary weight by one step without going over the max weight,
+// the canary weight is rounded to the granularity if specified and the weights always sum to 100
+func nextWeights(canaryWeight int, stepWeight int, maxWeight int, granularity int) (int, int) {
+	weight := canaryWeight + stepWeight
+	if granularity > 1 {
+		rounded := (weight + granularity/2) / granularity * granularity
+		if rounded <= canaryWeight {
+			rounded = (canaryWeight/granularity + 1) * granularity
+		}
+		weight = rounded
+	}
+	if weight > maxWeight {
+		weight = maxWeight
+	}
+	if weight > 100 {
+		weight = 100
+	}
+	if weight < 0 {
+		weight = 0
+	}
+	return 100 - weight, weight
+}
+
+// adaptiveStepWeight returns the step weight scaled by the remaining error budget if the adaptive step is set,
+// the minimum step is used if the error budget query fails
+func (c *Controller) adaptiveStepWeight(cd *flaggerv1.Canary) int {
+	as := cd.Spec.CanaryAnalysis.AdaptiveStep
+	if as == nil {
+		return cd.Spec.CanaryAnalysis.StepWeight
+	}
+
+	remaining, err := c.observer.GetScalar(as.Query)
+	if err != nil {
+		c.recordEventWarningf(cd, "Metrics server %s error budget query failed: %v, advancing %s.%s by the minimum step %v",
+			c.observer.metricsServer, err, cd.Name, cd.Namespace, as.MinStepWeight)
+		return as.MinStepWeight
+	}
+
+	step := scaleStepWeight(as.MinStepWeight, as.MaxStepWeight, remaining)
+	c.recordEventInfof(cd, "Error budget %.2f%% remaining, advancing %s.%s by %v",
+		remaining, cd.Name, cd.Namespace, step)
+	return step
+}
+
+// scaleStepWeight interpolates the step weight between the minimum and the maximum step
+// proportionally to the remaining error budget percentage
+func scaleStepWeight(minStep int, maxStep int, remaining float64) int {
+	remaining = math.Max(0, math.Min(100, remaining))
+	return minStep + int(math.Round(float64(maxStep-minStep)*remaining/100))
+}
+
+// holdForRequests returns true if the canary served less than the minimum
+// number of requests since it advanced to the current weight or iteration
+func (c *Controller) holdForRequests(cd *flaggerv1.Canary, deployer Deployer) bool {
+	minRequests := cd.Spec.CanaryAnalysis.MinRequests
+	if minRequests <= 0 {
+		return false
+	}
+
+	start := cd.Status.StepStartTime
+	if start.IsZero() {
+		start = cd.Status.AnalysisStartTime
+	}
+
+	val, err := c.observer.GetRequestCount(cd.Spec.TargetRef.Name, cd.Namespace, c.meshProvider, time.Since(start.Time))
+	if err != nil {
+		c.recordEventWarningf(cd, "Metrics server %s query failed: %v", c.observer.metricsServer, err)
+		return true
+	}
+
+	requests := int(val)
+	if requests >= minRequests {
+		return false
+	}
+
+	if err := deployer.SetStatusStepRequests(cd, requests); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return true
+	}
+	c.recordEventInfof(cd, "Hold %s.%s advancement requests %v/%v",
+		cd.Name, cd.Namespace, requests, minRequests)
+	return true
+}
+
+// holdForStep returns true if the canary advanced to the current weight
+// more recently than the duration of the step hold matching the weight
+func (c *Controller) holdForStep(cd *flaggerv1.Canary) bool {
+	hold := stepHold(cd, cd.Status.CanaryWeight)
+	if hold == nil || len(cd.Spec.CanaryAnalysis.Match) > 0 || len(cd.Spec.CanaryAnalysis.Stages) > 0 {
+		return false
+	}
+
+	duration, err := time.ParseDuration(hold.Duration)
+	if err != nil {
+		return false
+	}
+
+	start := cd.Status.StepStartTime
+	if start.IsZero() {
+		start = cd.Status.AnalysisStartTime
+	}
+
+	elapsed := time.Since(start.Time)
+	if elapsed >= duration {
+		return false
+	}
+
+	c.recordEventInfof(cd, "Hold %s.%s advancement at weight %v for %v",
+		cd.Name, cd.Namespace, cd.Status.CanaryWeight, (duration - elapsed).Round(time.Second))
+	return true
+}
+
+// stepHold returns the hold with the highest weight less than or equal to the canary weight
+func stepHold(cd *flaggerv1.Canary, canaryWeight int) *flaggerv1.CanaryStepHold {
+	var hold *flaggerv1.CanaryStepHold
+	for i, h := range cd.Spec.CanaryAnalysis.StepHolds {
+		if h.Weight <= canaryWeight && (hold == nil || h.Weight > hold.Weight) {
+			hold = &cd.Spec.CanaryAnalysis.StepHolds[i]
+		}
+	}
+	return hold
+}
+
+// holdOnWarning halts the advancement without counting a failed check,
+// the warning is sent once when the canary starts being held
+func (c *Controller) holdOnWarning(cd *flaggerv1.Canary, deployer Deployer, result analysisResult) {
+	var message string
+	for _, check := range result.checks {
+		if check.warning {
+			message = check.message
+			break
+		}
+	}
+
+	c.recordEventWarningf(cd, "%s", message)
+	if cd.Status.HaltedIntervals == 0 {
+		c.sendNotification(cd, message, false, notifier.SeverityWarn)
+	}
+	if err := deployer.SetStatusHaltedIntervals(cd, cd.Status.HaltedIntervals+1); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return
+	}
+	c.checkStalled(cd, cd.Status.HaltedIntervals+1)
+}
+
+// startSilence creates the Alertmanager silence of the canary analysis and returns its ID,
+// the silence of a previous analysis is expired first
+func (c *Controller) startSilence(cd *flaggerv1.Canary) string {
+	if cd.Spec.CanaryAnalysis.Silence == nil || !c.silencer.Enabled() {
+		return ""
+	}
+	c.endSilence(cd)
+
+	matchers := map[string]string{
+		"namespace": cd.Namespace,
+		"service":   cd.Spec.TargetRef.Name,
+	}
+	for k, v := range cd.Spec.CanaryAnalysis.Silence.Matchers {
+		matchers[k] = v
+	}
+
+	id, err := c.silencer.CreateSilence(matchers, cd.GetSilenceDuration(),
+		fmt.Sprintf("Canary analysis of %s.%s", cd.Name, cd.Namespace))
+	if err != nil {
+		c.recordEventWarningf(cd, "Alertmanager %v", err)
+		return ""
+	}
+	c.recordEventInfof(cd, "Alertmanager silence %s created for %s.%s", id, cd.Name, cd.Namespace)
+	return id
+}
+
+// endSilence expires the Alertmanager silence of the canary analysis,
+// the silence ID is removed from the status by the terminal phase update
+func (c *Controller) endSilence(cd *flaggerv1.Canary) {
+	if cd.Status.SilenceID == "" || !c.silencer.Enabled() {
+		return
+	}
+	if err := c.silencer.DeleteSilence(cd.Status.SilenceID); err != nil {
+		c.recordEventWarningf(cd, "Alertmanager %v", err)
+	}
+}
+
+// stepDownCanary reduces the canary weight by one step and returns true if the weight has been reduced,
+// the canary is not stepped down below the first step and A/B testing or staged canaries are never stepped down
+func (c *Controller) stepDownCanary(cd *flaggerv1.Canary, deployer Deployer, meshRouter router.Interface, canaryWeight int) bool {
+	stepWeight := cd.Spec.CanaryAnalysis.StepWeight
+	if len(cd.Spec.CanaryAnalysis.Match) > 0 || len(cd.Spec.CanaryAnalysis.Stages) > 0 ||
+		stepWeight <= 0 || canaryWeight-stepWeight < stepWeight {
+		return false
+	}
+
+	maxWeight := 100
+	if cd.Spec.CanaryAnalysis.MaxWeight > 0 {
+		maxWeight = cd.Spec.CanaryAnalysis.MaxWeight
+	}
+
+	canaryWeight -= stepWeight
+	primaryWeight := 100 - canaryWeight
+	if err := c.setRoutes(cd, meshRouter, primaryWeight, canaryWeight); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return true
+	}
+	// the rollbacks counter is persisted with the canary weight
+	cd.Status.Rollbacks++
+
+	advance := flaggerv1.CanaryAdvance{
+		Time:         v1.Now(),
+		CanaryWeight: canaryWeight,
+		TargetWeight: maxWeight,
+		StepWeight:   stepWeight,
+		Iteration:    weightStep(canaryWeight, stepWeight),
+		Message:      fmt.Sprintf("Step down %s.%s canary weight %v", cd.Name, cd.Namespace, canaryWeight),
+	}
+	appendHistory(cd, advance)
+
+	if err := deployer.SetStatusWeight(cd, canaryWeight); err != nil {
+		c.recordEventWarningf(cd, "%v", err)
+		return true
+	}
+
+	c.recorder.SetWeight(cd, primaryWeight, canaryWeight)
+	c.recordEventWarningf(cd, "%s", advance.Message)
+	return true
+}
+
+// setRoutes updates the traffic weights and counts the route mutation against the retry budget,
+// the route mutations counter is persisted by the next status update
+func (c *Controller) setRoutes(cd *flaggerv1.Canary, meshRouter router.Interface, primaryWeight int, canaryWeight int) error {
+	if err := meshRouter.SetRoutes(cd, primaryWeight, canaryWeight); err != nil {
+		return err
+	}
+	cd.Status.RouteMutations++
+	return nil
+}
+
+// annotateRollback records the rollback reason, time and failed revision as canary annotations,
+// the canary is fetched again since its status has been updated during the rollback
+func (c *Controller) annotateRollback(cd *flaggerv1.Canary, reason string, revision string) error {
+	err := retryOnTransientError(func() error {
+		canary, err := c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).Get(cd.Name, v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		cdCopy := canary.DeepCopy()
+		if cdCopy.Annotations == nil {
+			cdCopy.Annotations = make(map[string]string)
+		}
+		cdCopy.Annotations[flaggerv1.RollbackReasonAnnotation] = reason
+		cdCopy.Annotations[flaggerv1.RollbackTimestampAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		cdCopy.Annotations[flaggerv1.RollbackRevisionAnnotation] = revision
+
+		_, err = c.flaggerClient.FlaggerV1alpha3().Canaries(cd.Namespace).Update(cdCopy)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("canary %s.%s rollback annotations update error %v", cd.Name, cd.Namespace, err)
+	}
+	return nil
+}
+
+// validateAnalysis checks that the absolute and the percentage failed checks thresholds are not both set
+// and that the metrics label matchers and schemas are valid
+func validateAnalysis(cd *flaggerv1.Canary) error {
+	metrics := cd.Spec.CanaryAnalysis.Metrics
+	for _, stage := range cd.Spec.CanaryAnalysis.Stages {
+		metrics = append(metrics, stage.Metrics...)
+	}
+	for _, metric := range metrics {
+		if err := validateLabels(metric.Labels); err != nil {
+			return fmt.Errorf("canary %s.%s metric %s labels %v", cd.Name, cd.Namespace, metric.Name, err)
+		}
+		if err := validateSchema(metric); err != nil {
+			return fmt.Errorf("canary %s.%s %v", cd.Name, cd.Namespace, err)
+		}
+		if err := validateAggregation(metric); err != nil {
+			return fmt.Errorf("canary %s.%s %v", cd.Name, cd.Namespace, err)
+		}
+		if err := validateKnativeMetric(cd, metric); err != nil {
+			return fmt.Errorf("canary %s.%s %v", cd.Name, cd.Namespace, err)
+		}
+	}
+	if cd.Spec.CanaryAnalysis.ThresholdPercent < 0 || cd.Spec.CanaryAnalysis.ThresholdPercent > 100 {
+		return fmt.Errorf("canary %s.%s thresholdPercent must be between 0 and 100", cd.Name, cd.Namespace)
+	}
+	if cd.Spec.CanaryAnalysis.ThresholdPercent > 0 && cd.Spec.CanaryAnalysis.Threshold > 0 {
+		return fmt.Errorf("canary %s.%s threshold and thresholdPercent are mutually exclusive", cd.Name, cd.Namespace)
+	}
+	if err := validateWeights(cd); err != nil {
+		return err
+	}
+	if start := cd.Spec.CanaryAnalysis.StartWeight; start != 0 {
+		maxWeight := 100
+		if cd.Spec.CanaryAnalysis.MaxWeight > 0 {
+			maxWeight = cd.Spec.CanaryAnalysis.MaxWeight
+		}
+		if start < 0 || start >= maxWeight {
+			return fmt.Errorf("canary %s.%s startWeight must be between 0 and maxWeight", cd.Name, cd.Namespace)
+		}
+		if granularity := cd.Spec.CanaryAnalysis.WeightGranularity; granularity > 1 && start%granularity != 0 {
+			return fmt.Errorf("canary %s.%s startWeight must be a multiple of weightGranularity", cd.Name, cd.Namespace)
+		}
+	}
+	if err := validateStages(cd); err != nil {
+		return err
+	}
+	if err := validateStepHolds(cd); err != nil {
+		return err
+	}
+	if as := cd.Spec.CanaryAnalysis.AdaptiveStep; as != nil {
+		if as.Query == "" {
+			return fmt.Errorf("canary %s.%s adaptiveStep query is required", cd.Name, cd.Namespace)
+		}
+		if as.MinStepWeight < 1 || as.MaxStepWeight < as.MinStepWeight || as.MaxStepWeight > 100 {
+			return fmt.Errorf("canary %s.%s adaptiveStep weights must be between 1 and 100 and minStepWeight <= maxStepWeight",
+				cd.Name, cd.Namespace)
+		}
+		if len(cd.Spec.CanaryAnalysis.Match) > 0 || len(cd.Spec.CanaryAnalysis.Stages) > 0 {
+			return fmt.Errorf("canary %s.%s adaptiveStep can't be used with match or stages", cd.Name, cd.Namespace)
+		}
+	}
+	if len(cd.Spec.CanaryAnalysis.RollbackEvents) > 0 && cd.IsKnativeService() {
+		return fmt.Errorf("canary %s.%s rollbackEvents is not supported for Knative services", cd.Name, cd.Namespace)
+	}
+	if ai := cd.Spec.CanaryAnalysis.AdaptiveInterval; ai != nil {
+		minInterval, minErr := time.ParseDuration(ai.MinInterval)
+		maxInterval, maxErr := time.ParseDuration(ai.MaxInterval)
+		if minErr != nil || maxErr != nil || minInterval <= 0 || maxInterval < minInterval {
+			return fmt.Errorf("canary %s.%s adaptiveInterval intervals must be positive durations and minInterval <= maxInterval",
+				cd.Name, cd.Namespace)
+		}
+		if ai.MaxVariation <= 0 || ai.Samples < 0 || ai.Samples == 1 {
+			return fmt.Errorf("canary %s.%s adaptiveInterval maxVariation must be positive and samples at least 2",
+				cd.Name, cd.Namespace)
+		}
+	}
+	if cd.Spec.CanaryAnalysis.FailedChecksDecay < 0 {
+		return fmt.Errorf("canary %s.%s failedChecksDecay must be positive", cd.Name, cd.Namespace)
+	}
+	if label := cd.Spec.CanaryAnalysis.RegionLabel; label != "" && !labelNameRegexp.MatchString(label) {
+		return fmt.Errorf("canary %s.%s regionLabel %s is not a valid label name", cd.Name, cd.Namespace, label)
+	}
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Type == flaggerv1.RegionHealthHook && cd.Spec.CanaryAnalysis.RegionLabel == "" {
+			return fmt.Errorf("canary %s.%s region-health webhook %s requires a regionLabel", cd.Name, cd.Namespace, webhook.Name)
+		}
+		if webhook.Group != "" && (!isRolloutHook(webhook) || webhook.WaitForTraffic) {
+			return fmt.Errorf("canary %s.%s webhook %s group is supported only for the rollout webhooks without waitForTraffic",
+				cd.Name, cd.Namespace, webhook.Name)
+		}
+		if webhook.ApprovalExpiry != "" {
+			if webhook.Type != flaggerv1.ApprovalHook {
+				return fmt.Errorf("canary %s.%s webhook %s approvalExpiry requires the approval type",
+					cd.Name, cd.Namespace, webhook.Name)
+			}
+			if d, err := time.ParseDuration(webhook.ApprovalExpiry); err != nil || d <= 0 {
+				return fmt.Errorf("canary %s.%s webhook %s approvalExpiry %s must be a positive duration",
+					cd.Name, cd.Namespace, webhook.Name, webhook.ApprovalExpiry)
+			}
+		}
+	}
+	if err := validateWebhookGroups(cd); err != nil {
+		return err
+	}
+	if window := cd.Spec.CanaryAnalysis.AllowedWindow; window != nil {
+		if err := validateWindow(window); err != nil {
+			return fmt.Errorf("canary %s.%s allowedWindow %v", cd.Name, cd.Namespace, err)
+		}
+	}
+	if verification := cd.Spec.CanaryAnalysis.PromotionVerification; verification != nil {
+		if cd.IsKnativeService() {
+			return fmt.Errorf("canary %s.%s promotionVerification is not supported for Knative services", cd.Name, cd.Namespace)
+		}
+		if d, err := time.ParseDuration(verification.Duration); err != nil || d <= 0 {
+			return fmt.Errorf("canary %s.%s promotionVerification duration %s must be a positive duration",
+				cd.Name, cd.Namespace, verification.Duration)
+		}
+	}
+	if cd.Spec.CanaryAnalysis.PostRollbackReplicas < 0 {
+		return fmt.Errorf("canary %s.%s postRollbackReplicas must be positive", cd.Name, cd.Namespace)
+	}
+	if warmup := cd.Spec.CanaryAnalysis.PrimaryWarmup; warmup != nil {
+		for _, value := range []string{warmup.Duration, warmup.Timeout} {
+			if d, err := time.ParseDuration(value); value != "" && (err != nil || d <= 0) {
+				return fmt.Errorf("canary %s.%s primaryWarmup %s must be a positive duration", cd.Name, cd.Namespace, value)
+			}
+		}
+		if timeout := cd.GetPrimaryWarmupTimeout(); timeout > 0 && timeout <= cd.GetPrimaryWarmupDuration() {
+			return fmt.Errorf("canary %s.%s primaryWarmup timeout must be greater than the duration", cd.Name, cd.Namespace)
+		}
+	}
+	switch cd.Spec.CanaryAnalysis.NoValuesPolicy {
+	case "", flaggerv1.NoValuesHalt, flaggerv1.NoValuesPass, flaggerv1.NoValuesFail:
+	default:
+		return fmt.Errorf("canary %s.%s noValuesPolicy %s must be halt, pass or fail",
+			cd.Name, cd.Namespace, cd.Spec.CanaryAnalysis.NoValuesPolicy)
+	}
+	if !validTelemetry(cd.Annotations[flaggerv1.TelemetryAnnotation]) {
+		return fmt.Errorf("canary %s.%s annotation %s must be istio, envoy or none",
+			cd.Name, cd.Namespace, flaggerv1.TelemetryAnnotation)
+	}
+	switch cd.Spec.CanaryAnalysis.InitializationMode {
+	case "", flaggerv1.InitializationPromote, flaggerv1.InitializationAnalyze:
+	default:
+		return fmt.Errorf("canary %s.%s initializationMode %s must be promote or analyze",
+			cd.Name, cd.Namespace, cd.Spec.CanaryAnalysis.InitializationMode)
+	}
+	if cd.Spec.CanaryAnalysis.InitializationMode == flaggerv1.InitializationAnalyze && cd.IsKnativeService() {
+		return fmt.Errorf("canary %s.%s initializationMode analyze is not supported for Knative services", cd.Name, cd.Namespace)
+	}
+	if budget := cd.Spec.CanaryAnalysis.RetryBudget; budget != nil && (budget.RouteMutations < 0 || budget.Rollbacks < 0) {
+		return fmt.Errorf("canary %s.%s retryBudget values must be positive", cd.Name, cd.Namespace)
+	}
+	if cd.Spec.CanaryAnalysis.Baseline {
+		if cd.IsKnativeService() {
+			return fmt.Errorf("canary %s.%s baseline is not supported for Knative services", cd.Name, cd.Namespace)
+		}
+		if len(cd.Spec.CanaryAnalysis.Match) > 0 || len(cd.Spec.CanaryAnalysis.WeightedMatch) > 0 ||
+			len(cd.Spec.CanaryAnalysis.Stages) > 0 {
+			return fmt.Errorf("canary %s.%s baseline is not supported with match conditions", cd.Name, cd.Namespace)
+		}
+	}
+	return nil
+}
+
+// validateWebhookGroups checks that every grouped webhook belongs to a declared group
+// and that the quorum of each group can be reached
+func validateWebhookGroups(cd *flaggerv1.Canary) error {
+	members := make(map[string]int)
+	for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+		if webhook.Group != "" {
+			members[webhook.Group]++
+		}
+	}
+	declared := make(map[string]bool)
+	for _, group := range cd.Spec.CanaryAnalysis.WebhookGroups {
+		if group.Name == "" || declared[group.Name] {
+			return fmt.Errorf("canary %s.%s webhook group names must be unique and not empty", cd.Name, cd.Namespace)
+		}
+		declared[group.Name] = true
+		if group.Quorum < 1 || group.Quorum > members[group.Name] {
+			return fmt.Errorf("canary %s.%s webhook group %s quorum %v must be between 1 and the %v webhooks of the group",
+				cd.Name, cd.Namespace, group.Name, group.Quorum, members[group.Name])
+		}
+	}
+	for group := range members {
+		if !declared[group] {
+			return fmt.Errorf("canary %s.%s webhook group %s is not declared in webhookGroups", cd.Name, cd.Namespace, group)
+		}
+	}
+	return nil
+}
+
+// validateWeights checks that the weight ramp reaches the max weight and the canary gets promoted,
+// a step weight of zero or a max weight above 100 would hold the canary at the same weight
+func validateWeights(cd *flaggerv1.Canary) error {
+	analysis := cd.Spec.CanaryAnalysis
+	if cd.Spec.SkipAnalysis || len(analysis.Match) > 0 || len(analysis.Stages) > 0 {
+		return nil
+	}
+	if analysis.MaxWeight < 0 || analysis.MaxWeight > 100 {
+		return fmt.Errorf("canary %s.%s maxWeight %v must be between 0 and 100",
+			cd.Name, cd.Namespace, analysis.MaxWeight)
+	}
+	// the adaptive step replaces the step weight
+	if analysis.AdaptiveStep == nil && (analysis.StepWeight < 1 || analysis.StepWeight > 100) {
+		return fmt.Errorf("canary %s.%s stepWeight %v must be between 1 and 100",
+			cd.Name, cd.Namespace, analysis.StepWeight)
+	}
+	return nil
+}
+
+// validateStepHolds checks that the step holds are used with progressive traffic shifting
+// and that every hold has a valid weight and duration
+func validateStepHolds(cd *flaggerv1.Canary) error {
+	holds := cd.Spec.CanaryAnalysis.StepHolds
+	if len(holds) == 0 {
+		return nil
+	}
+	if len(cd.Spec.CanaryAnalysis.Match) > 0 || len(cd.Spec.CanaryAnalysis.Stages) > 0 {
+		return fmt.Errorf("canary %s.%s stepHolds can't be used with match or stages", cd.Name, cd.Namespace)
+	}
+	weights := make(map[int]bool)
+	for _, hold := range holds {
+		if hold.Weight < 1 || hold.Weight > 100 || weights[hold.Weight] {
+			return fmt.Errorf("canary %s.%s stepHolds weights must be unique and between 1 and 100", cd.Name, cd.Namespace)
+		}
+		weights[hold.Weight] = true
+		if d, err := time.ParseDuration(hold.Duration); err != nil || d <= 0 {
+			return fmt.Errorf("canary %s.%s stepHold %v duration %s must be a positive duration",
+				cd.Name, cd.Namespace, hold.Weight, hold.Duration)
+		}
+	}
+	return nil
+}
+
+// validateStages checks that the stages replace the A/B testing conditions
+// and that every stage has a name, a number of iterations and a valid weight,
+// the weights of the stages without match conditions must increase up to the max weight
+func validateStages(cd *flaggerv1.Canary) error {
+	stages := cd.Spec.CanaryAnalysis.Stages
+	if len(stages) == 0 {
+		return nil
+	}
+	if len(cd.Spec.CanaryAnalysis.Match) > 0 || len(cd.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		return fmt.Errorf("canary %s.%s stages can't be used with match or weightedMatch", cd.Name, cd.Namespace)
+	}
+	if cd.IsKnativeService() {
+		return fmt.Errorf("canary %s.%s stages are not supported for Knative services", cd.Name, cd.Namespace)
+	}
+	names := make(map[string]bool)
+	for _, stage := range stages {
+		if stage.Name == "" || names[stage.Name] {
+			return fmt.Errorf("canary %s.%s stage names must be unique and non-empty", cd.Name, cd.Namespace)
+		}
+		names[stage.Name] = true
+		if stage.Iterations < 1 {
+			return fmt.Errorf("canary %s.%s stage %s iterations must be greater than zero", cd.Name, cd.Namespace, stage.Name)
+		}
+		if stage.Weight < 0 || stage.Weight > 100 {
+			return fmt.Errorf("canary %s.%s stage %s weight must be between 0 and 100", cd.Name, cd.Namespace, stage.Name)
+		}
+	}
+
+	maxWeight := 100
+	if cd.Spec.CanaryAnalysis.MaxWeight > 0 {
+		maxWeight = cd.Spec.CanaryAnalysis.MaxWeight
+	}
+	// the stages without match conditions shift all the traffic
+	lastWeight := 0
+	for _, stage := range stages {
+		if len(stage.Match) > 0 {
+			continue
+		}
+		weight := stage.Weight
+		if weight == 0 {
+			weight = 100
+		}
+		if weight <= lastWeight || weight > maxWeight {
+			return fmt.Errorf("canary %s.%s stage %s weight %v must be greater than %v and at most maxWeight %v",
+				cd.Name, cd.Namespace, stage.Name, weight, lastWeight, maxWeight)
+		}
+		lastWeight = weight
+	}
+	if lastWeight > 0 && lastWeight != maxWeight {
+		return fmt.Errorf("canary %s.%s last stage weight %v must be equal to maxWeight %v",
+			cd.Name, cd.Namespace, lastWeight, maxWeight)
+	}
+	return nil
+}
+
+// dependencyKey returns the canaries map key of a dependency,
+// the dependency namespace defaults to the namespace of the dependent canary
+func dependencyKey(cd *flaggerv1.Canary, dependency flaggerv1.CanaryDependency) string {
+	namespace := dependency.Namespace
+	if namespace == "" {
+		namespace = cd.Namespace
+	}
+	return fmt.Sprintf("%s.%s", dependency.Name, namespace)
+}
+
+// validateDependencies walks the dependsOn graph of the canary and returns an error
+// if one of the dependencies leads back to the canary
+func (c *Controller) validateDependencies(cd *flaggerv1.Canary) error {
+	start := fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)
+	visited := make(map[string]bool)
+
+	var visit func(canary *flaggerv1.Canary, path []string) error
+	visit = func(canary *flaggerv1.Canary, path []string) error {
+		for _, dependency := range canary.Spec.DependsOn {
+			key := dependencyKey(canary, dependency)
+			if key == start {
+				return fmt.Errorf("canary %s dependsOn cycle detected %s", start, strings.Join(append(path, key), " -> "))
+			}
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			// unknown canaries are not part of the graph
+			value, ok := c.canaries.Load(key)
+			if !ok {
+				continue
+			}
+			if err := visit(value.(*flaggerv1.Canary), append(path, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return visit(cd, []string{start})
+}
+
+// dependencyInProgress returns the first dependency of the canary that is being rolled out
+func (c *Controller) dependencyInProgress(cd *flaggerv1.Canary) (string, bool) {
+	for _, dependency := range cd.Spec.DependsOn {
+		key := dependencyKey(cd, dependency)
+		value, ok := c.canaries.Load(key)
+		if !ok {
+			continue
+		}
+		if value.(*flaggerv1.Canary).Status.Phase == flaggerv1.CanaryProgressing {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// failedChecksThresholdReached returns true if the failed checks reached the absolute threshold
+// or if the percentage of failed intervals is above the percentage threshold
+func failedChecksThresholdReached(cd *flaggerv1.Canary) bool {
+	if percent := cd.Spec.CanaryAnalysis.ThresholdPercent; percent > 0 {
+		if cd.Status.AnalysedIntervals == 0 {
+			return false
+		}
+		return float64(cd.Status.FailedChecks)*100/float64(cd.Status.AnalysedIntervals) > percent
+	}
+	return cd.Status.FailedChecks >= cd.Spec.CanaryAnalysis.Threshold
+}
+
+// abortFailedChecks returns the failed checks count that reaches the threshold,
+// the canary is rolled back on the next interval
+func abortFailedChecks(cd *flaggerv1.Canary) int {
+	failedChecks := cd.Status.FailedChecks + 1
+	if cd.Spec.CanaryAnalysis.ThresholdPercent > 0 {
+		// every analysed interval counts as failed
+		if cd.Status.AnalysedIntervals > failedChecks {
+			failedChecks = cd.Status.AnalysedIntervals
+		}
+	} else if cd.Spec.CanaryAnalysis.Threshold > failedChecks {
+		failedChecks = cd.Spec.CanaryAnalysis.Threshold
+	}
+	return failedChecks
+}
+
+// decayFailedChecks decrements the failed checks counter without going below zero
+func decayFailedChecks(failedChecks int, decay int) int {
+	if failedChecks <= decay {
+		return 0
+	}
+	return failedChecks - decay
+}
+
+// retryBudgetExceeded returns true if the route mutations or the rollbacks
+// made during the rollout are above the retry budget
+func retryBudgetExceeded(cd *flaggerv1.Canary) bool {
+	budget := cd.Spec.CanaryAnalysis.RetryBudget
+	if budget == nil {
+		return false
+	}
+	if budget.RouteMutations > 0 && cd.Status.RouteMutations > budget.RouteMutations {
+		return true
+	}
+	return budget.Rollbacks > 0 && cd.Status.Rollbacks > budget.Rollbacks
+}
+
+func retryBudgetSummary(cd *flaggerv1.Canary) string {
+	return fmt.Sprintf("%v route mutations %v rollbacks", cd.Status.RouteMutations, cd.Status.Rollbacks)
+}
+
+func failedChecksSummary(cd *flaggerv1.Canary) string {
+	if cd.Spec.CanaryAnalysis.ThresholdPercent > 0 {
+		return fmt.Sprintf("%v/%v intervals", cd.Status.FailedChecks, cd.Status.AnalysedIntervals)
+	}
+	return fmt.Sprintf("%v", cd.Status.FailedChecks)
+}
+
+// weightStep returns the number of steps needed to reach the canary weight
+func weightStep(canaryWeight int, stepWeight int) int {
+	if stepWeight <= 0 {
+		return 0
+	}
+	return (canaryWeight + stepWeight - 1) / stepWeight
+}
+
+// statusHistoryLimit is the maximum number of advancements kept in the canary status
+const statusHistoryLimit = 10
+
+// appendHistory adds the advancement to the status history,
+// the history is persisted by the next status update
+func appendHistory(cd *flaggerv1.Canary, advance flaggerv1.CanaryAdvance) {
+	history := append(cd.Status.History, advance)
+	if len(history) > statusHistoryLimit {
+		history = history[len(history)-statusHistoryLimit:]
+	}
+	cd.Status.History = history
+}
+
+// stageCanaryWeight returns the percentage of the stage matched traffic routed to the canary
+func stageCanaryWeight(stage *flaggerv1.CanaryStage) int {
+	if stage.Weight <= 0 {
+		return 100
+	}
+	return stage.Weight
+}
+
+// hasStageMatch returns true if a stage routes the canary traffic based on match conditions
+func hasStageMatch(cd *flaggerv1.Canary) bool {
+	for _, stage := range cd.Spec.CanaryAnalysis.Stages {
+		if len(stage.Match) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchedCanaryWeight returns the percentage of the matched traffic that
+// should be routed to the canary for the current iteration
+func matchedCanaryWeight(cd *flaggerv1.Canary) int {
+	if cd.Status.InitialAnalysis {
+		return 100
+	}
+	step := cd.Spec.CanaryAnalysis.MatchStepWeight
+	if step <= 0 {
+		return 100
+	}
+	weight := step * (cd.Status.Iterations + 1)
+	if weight > 100 {
+		return 100
+	}
+	return weight
+}
+
+// analysisIterations returns the number of iterations of the fixed routing analysis,
+// without iterations the first revision is analysed for as many intervals as it takes to reach the max weight
+func analysisIterations(cd *flaggerv1.Canary, maxWeight int) int {
+	iterations := cd.Spec.CanaryAnalysis.Iterations
+	if iterations > 0 || !cd.Status.InitialAnalysis {
+		return iterations
+	}
+	step := cd.Spec.CanaryAnalysis.StepWeight
+	if step <= 0 {
+		return 1
+	}
+	return (maxWeight + step - 1) / step
+}
+
+// metricCheck holds the result of a metric evaluation,
+// the value is not set if the query failed or if the metric was skipped,
+// the samples are set if the metric has a minimum sample count
+type metricCheck struct {
+	name       string
+	value      *float64
+	threshold  float64
+	passed     bool
+	skipped    bool
+	degraded   bool
+	warning    bool
+	queryError bool
+	timedOut   bool
+	noValues   bool
+	// set if the no values policy passed the check
+	noValuesSkipped bool
+	message         string
+	samples         *float64
+	minSamples      float64
+}
+
+// String returns the metric value and threshold in a human readable format
+func (m metricCheck) String() string {
+	if m.skipped {
+		return fmt.Sprintf("%s skipped (%s)", m.name, m.message)
+	}
+	if m.value == nil {
+		return fmt.Sprintf("%s n/a (threshold %v)", m.name, m.threshold)
+	}
+	return fmt.Sprintf("%s %.2f (threshold %v)", m.name, *m.value, m.threshold)
+}
+
+// analysisResult holds the evaluation of all metrics of an analysis interval,
+// the score is set only if weighted scoring is enabled
+// waiting is set if the metrics were not evaluated because the load test is not running,
+// degraded is set if all the failed checks are within their degraded band,
+// warning is set if a passed check crossed its warning threshold
+// and reason is the cause of a failed analysis
+type analysisResult struct {
+	passed   bool
+	waiting  bool
+	degraded bool
+	warning  bool
+	// fail the canary without waiting for the failed checks threshold
+	abort  bool
+	score  *float64
+	checks []metricCheck
+	// first failed check in the order the metrics are defined
+	failed *metricCheck
+	reason flaggerv1.RollbackReason
+}
+
+// Summary returns the evaluated metrics in a human readable format
+func (r analysisResult) Summary() string {
+	values := make([]string, 0, len(r.checks)+1)
+	if r.score != nil {
+		values = append(values, fmt.Sprintf("score %.2f", *r.score))
+	}
+	for _, check := range r.checks {
+		values = append(values, check.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+// checkMetric queries the metrics server and compares the result with the metric threshold
+func (c *Controller) checkMetric(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric) metricCheck {
+	if metric.Interval == "" {
+		metric.Interval = r.GetMetricInterval()
+	}
+
+	check := metricCheck{name: metric.Name, threshold: metric.Threshold}
+
+	if err := validateMetric(metric); err != nil {
+		check.queryError = true
+		check.message = err.Error()
+		return check
+	}
+
+	if metric.ThresholdPercent > 0 {
+		threshold, err := c.baselineThreshold(r, metric)
+		if err != nil {
+			check.queryError = true
+			check.message = fmt.Sprintf("Metrics server %s baseline query failed: %v", c.observer.metricsServer, err)
+			return check
+		}
+		metric.Threshold = threshold
+		check.threshold = threshold
+	}
+
+	if metric.BurnRate != nil {
+		return c.checkBurnRate(r, metric, check)
+	}
+
+	if metric.LatencyDelta != nil {
+		return c.checkLatencyDelta(r, metric, check)
+	}
+
+	if metric.Resource != nil {
+		return c.checkResource(r, metric, check)
+	}
+
+	if metric.MinRequests > 0 {
+		total, err := c.observer.GetRequestTotal(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval, canaryQueryOptions(r, metric))
+		if err != nil {
+			return c.metricQueryFailure(r, metric, err)
+		}
+		check.samples, check.minSamples = &total, metric.MinRequests
+		if total < metric.MinRequests {
+			check.passed = true
+			check.skipped = true
+			check.message = fmt.Sprintf("%.0f requests < %v", total, metric.MinRequests)
+			return check
+		}
+	}
+
+	if metric.Logs != nil {
+		return c.checkLogs(r, metric, check)
+	}
+
+	if metric.GraphQL != nil {
+		return c.checkGraphQL(r, metric, check)
+	}
+
+	if metric.SLO != nil {
+		return c.checkSLO(r, metric, check)
+	}
+
+	if metric.Ratio != nil {
+		return c.checkRatio(r, metric, check)
+	}
+
+	if metric.Name == "envoy_cluster_upstream_rq" {
+		val, err := c.observer.GetEnvoySuccessRate(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval, canaryQueryOptions(r, metric))
+		if err != nil {
+			return c.metricQueryFailure(r, metric, err)
+		}
+		check.value = &val
+		if float64(metric.Threshold) > val {
+			check.message = fmt.Sprintf("Halt %s.%s advancement success rate %.2f%% < %v%%",
+				r.Name, r.Namespace, val, metric.Threshold)
+			return degradeCheck(metric, check, metric.Threshold-val)
+		}
+		check = warnCheck(r, metric, check, val < metric.WarnThreshold)
+	}
+
+	if metric.Name == "istio_requests_total" {
+		val, err := c.observer.GetDeploymentCounter(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval, canaryQueryOptions(r, metric))
+		if err != nil {
+			return c.metricQueryFailure(r, metric, err)
+		}
+		check.value = &val
+		if float64(metric.Threshold) > val {
+			check.message = fmt.Sprintf("Halt %s.%s advancement success rate %.2f%% < %v%%",
+				r.Name, r.Namespace, val, metric.Threshold)
+			return degradeCheck(metric, check, metric.Threshold-val)
+		}
+		check = warnCheck(r, metric, check, val < metric.WarnThreshold)
+	}
+
+	if metric.Name == "istio_request_duration_seconds_bucket" {
+		val, err := c.observer.GetDeploymentHistogram(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval, canaryQueryOptions(r, metric))
+		if err != nil {
+			return c.metricQueryFailure(r, metric, err)
+		}
+		ms := float64(val) / float64(time.Millisecond)
+		check.value = &ms
+		t := time.Duration(metric.Threshold * float64(time.Millisecond))
+		if val > t {
+			check.message = fmt.Sprintf("Halt %s.%s advancement request duration %v > %v",
+				r.Name, r.Namespace, val, t)
+			return degradeCheck(metric, check, ms-metric.Threshold)
+		}
+		check = warnCheck(r, metric, check, ms > metric.WarnThreshold)
+	}
+
+	if metric.Query != "" {
+		val, err := c.observer.GetScalar(metric.Query)
+		if err != nil {
+			return c.metricQueryFailure(r, metric, err)
+		}
+		check.value = &val
+		if val > float64(metric.Threshold) {
+			check.message = fmt.Sprintf("Halt %s.%s advancement %s %.2f > %v",
+				r.Name, r.Namespace, metric.Name, val, metric.Threshold)
+			return degradeCheck(metric, check, val-metric.Threshold)
+		}
+		check = warnCheck(r, metric, check, val > metric.WarnThreshold)
+	}
+
+	check.passed = true
+	return check
+}
+
+// smoothCheck records the check value in the canary status and evaluates the threshold
+// against the weighted moving average of the values recorded over the metric evaluation windows
+func smoothCheck(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	if check.value == nil || check.skipped || check.queryError {
+		return check
+	}
+
+	values := appendMetricWindow(r, metric.Name, *check.value, metric.EvaluationWindows)
+	val := weightedAverage(values)
+
+	smoothed := metricCheck{name: check.name, threshold: check.threshold, value: &val}
+	if higherIsBetter(metric) {
+		if val < check.threshold {
+			smoothed.message = fmt.Sprintf("Halt %s.%s advancement %s %.2f averaged over %v intervals < %v",
+				r.Name, r.Namespace, metric.Name, val, len(values), check.threshold)
+			return degradeCheck(metric, smoothed, check.threshold-val)
+		}
+		smoothed = warnCheck(r, metric, smoothed, val < metric.WarnThreshold)
+	} else {
+		if val > check.threshold {
+			smoothed.message = fmt.Sprintf("Halt %s.%s advancement %s %.2f averaged over %v intervals > %v",
+				r.Name, r.Namespace, metric.Name, val, len(values), check.threshold)
+			return degradeCheck(metric, smoothed, val-check.threshold)
+		}
+		smoothed = warnCheck(r, metric, smoothed, val > metric.WarnThreshold)
+	}
+
+	smoothed.passed = true
+	return smoothed
+}
+
+// appendMetricWindow adds the value to the metric recent values in the canary status,
+// keeps only the last windows values and returns them oldest first
+func appendMetricWindow(r *flaggerv1.Canary, name string, value float64, windows int) []float64 {
+	var values []float64
+	r.Status.MetricWindows, values = appendWindow(r.Status.MetricWindows, name, value, windows)
+	return values
+}
+
+// appendWindow appends the value to the window of the metric and drops the oldest values,
+// it returns the updated windows and the values of the metric window
+func appendWindow(windows []flaggerv1.CanaryMetricWindow, name string, value float64, size int) ([]flaggerv1.CanaryMetricWindow, []float64) {
+	for i, w := range windows {
+		if w.Name != name {
+			continue
+		}
+		values := append(w.Values, value)
+		if len(values) > size {
+			values = values[len(values)-size:]
+		}
+		windows[i].Values = values
+		return windows, values
+	}
+	windows = append(windows, flaggerv1.CanaryMetricWindow{
+		Name:   name,
+		Values: []float64{value},
+	})
+	return windows, []float64{value}
+}
+
+// adaptInterval doubles the analysis interval when the recent metric values vary above the maximum variation
+// and halves it when they are stable, the values and the interval are persisted by the next status update
+func (c *Controller) adaptInterval(cd *flaggerv1.Canary, checks []metricCheck) {
+	ai := cd.Spec.CanaryAnalysis.AdaptiveInterval
+	if ai == nil {
+		return
+	}
+	minInterval, err := time.ParseDuration(ai.MinInterval)
+	if err != nil {
+		return
+	}
+	maxInterval, err := time.ParseDuration(ai.MaxInterval)
+	if err != nil {
+		return
+	}
+	samples := ai.Samples
+	if samples == 0 {
+		samples = 5
+	}
+
+	// the highest variation of the metrics with enough values
+	variation := -1.0
+	for _, check := range checks {
+		if check.value == nil || check.skipped {
+			continue
+		}
+		var values []float64
+		cd.Status.IntervalWindows, values = appendWindow(cd.Status.IntervalWindows, check.name, *check.value, samples)
+		if len(values) < samples {
+			continue
+		}
+		if v := coefficientOfVariation(values); v > variation {
+			variation = v
+		}
+	}
+	if variation < 0 {
+		return
+	}
+
+	current := cd.GetAnalysisInterval()
+	interval := current
+	if variation > ai.MaxVariation {
+		interval = current * 2
+	} else if variation < ai.MaxVariation/2 {
+		interval = current / 2
+	}
+	if interval < minInterval {
+		interval = minInterval
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	if interval == current {
+		return
+	}
+
+	cd.Status.AnalysisInterval = interval.String()
+	c.recordEventInfof(cd, "Analysis interval of %s.%s changed from %v to %v, metrics variation %.2f%%",
+		cd.Name, cd.Namespace, current, interval, variation)
+}
+
+// coefficientOfVariation returns the standard deviation of the values as a percentage of their mean,
+// zero is returned if the mean is zero
+func coefficientOfVariation(values []float64) float64 {
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+	return math.Sqrt(variance) / math.Abs(mean) * 100
+}
+
+// weightedAverage returns the linearly weighted moving average of the values,
+// the latest value has the highest weight
+func weightedAverage(values []float64) float64 {
+	var sum, weights float64
+	for i, v := range values {
+		w := float64(i + 1)
+		sum += v * w
+		weights += w
+	}
+	if weights == 0 {
+		return 0
+	}
+	return sum / weights
+}
+
+// degradeCheck marks a failed check as degraded if the distance
+// between the value and the threshold is within the metric degraded band
+func degradeCheck(metric flaggerv1.CanaryMetric, check metricCheck, distance float64) metricCheck {
+	if metric.DegradedBand > 0 && distance <= metric.DegradedBand {
+		check.degraded = true
+		check.message = fmt.Sprintf("%s (degraded)", check.message)
+	}
+	return check
+}
+
+// warnCheck marks the check with a warning if the metric has a warning threshold and the value crossed it
+func warnCheck(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck, crossed bool) metricCheck {
+	if metric.WarnThreshold != 0 && crossed && !check.warning {
+		check.warning = true
+		check.message = fmt.Sprintf("Hold %s.%s advancement %s %.2f crossed the warning threshold %v",
+			r.Name, r.Namespace, metric.Name, *check.value, metric.WarnThreshold)
+	}
+	return check
+}
+
+// checkBurnRate queries the error budget burn rate over the long and short windows,
+// the check fails only if the burn rate is above the threshold over both windows
+func (c *Controller) checkBurnRate(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	br := metric.BurnRate
+	long, err := c.observer.GetBurnRate(r.Spec.TargetRef.Name, r.Namespace, metric.Name, br.LongWindow, br.Objective, canaryQueryOptions(r, metric))
+	if err != nil {
+		return c.metricQueryFailure(r, metric, err)
+	}
+	short, err := c.observer.GetBurnRate(r.Spec.TargetRef.Name, r.Namespace, metric.Name, br.ShortWindow, br.Objective, canaryQueryOptions(r, metric))
+	if err != nil {
+		return c.metricQueryFailure(r, metric, err)
+	}
+
+	val := math.Min(long, short)
+	check.value = &val
+	if val > metric.Threshold {
+		check.message = fmt.Sprintf("Halt %s.%s advancement error budget burn rate %.2f (%s) %.2f (%s) > %v",
+			r.Name, r.Namespace, long, br.LongWindow, short, br.ShortWindow, metric.Threshold)
+		return degradeCheck(metric, check, val-metric.Threshold)
+	}
+
+	check = warnCheck(r, metric, check, val > metric.WarnThreshold)
+	check.passed = true
+	return check
+}
+
+// checkLatencyDelta compares the canary P99 latency to the primary (or baseline) one,
+// the check value is the difference in milliseconds
+func (c *Controller) checkLatencyDelta(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	opts := canaryQueryOptions(r, metric)
+	canary, err := c.observer.GetDeploymentHistogram(r.Spec.TargetRef.Name, r.Namespace, metric.Name, metric.Interval, opts)
+	if err != nil {
+		return c.metricQueryFailure(r, metric, err)
+	}
+	workload := fmt.Sprintf("%s-primary", r.Spec.TargetRef.Name)
+	if r.Spec.CanaryAnalysis.Baseline {
+		workload = fmt.Sprintf("%s-baseline", r.Spec.TargetRef.Name)
+	}
+	primary, err := c.observer.GetDeploymentHistogram(workload, r.Namespace, metric.Name, metric.Interval, opts)
+	if err != nil {
+		return c.metricQueryFailure(r, metric, err)
+	}
+
+	ld := metric.LatencyDelta
+	delta := float64(canary-primary) / float64(time.Millisecond)
+	check.value = &delta
+	if ld.MaxDelta > 0 && delta > ld.MaxDelta {
+		check.message = fmt.Sprintf("Halt %s.%s advancement request duration %v exceeds %s %v by %.2fms > %vms",
+			r.Name, r.Namespace, canary, workload, primary, delta, ld.MaxDelta)
+		return degradeCheck(metric, check, delta-ld.MaxDelta)
+	}
+	if ld.MaxDeltaPercent > 0 && primary > 0 {
+		percent := delta * 100 / (float64(primary) / float64(time.Millisecond))
+		if percent > ld.MaxDeltaPercent {
+			check.message = fmt.Sprintf("Halt %s.%s advancement request duration %v exceeds %s %v by %.2f%% > %v%%",
+				r.Name, r.Namespace, canary, workload, primary, percent, ld.MaxDeltaPercent)
+			return degradeCheck(metric, check, percent-ld.MaxDeltaPercent)
+		}
+	}
+
+	check.passed = true
+	return check
+}
+
+// checkLogs counts the canary log lines matching the error pattern during the interval
+func (c *Controller) checkLogs(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	selector := metric.Logs.Selector
+	if selector == "" {
+		selector = fmt.Sprintf(`{namespace="%s",app="%s"}`, r.Namespace, r.Spec.TargetRef.Name)
+	}
+
+	val, err := c.logsObserver.GetLogCount(selector, metric.Logs.Pattern, metric.Interval)
+	if err != nil {
+		check.queryError = true
+		check.message = fmt.Sprintf("Logs server %s query failed: %v", c.logsObserver.logsServer, err)
+		return check
+	}
+
+	check.value = &val
+	if val > metric.Threshold {
+		check.message = fmt.Sprintf("Halt %s.%s advancement %s log lines matching %s %v > %v",
+			r.Name, r.Namespace, metric.Name, metric.Logs.Pattern, val, metric.Threshold)
+		return degradeCheck(metric, check, val-metric.Threshold)
+	}
+
+	check = warnCheck(r, metric, check, val > metric.WarnThreshold)
+	check.passed = true
+	return check
+}
+
+// checkResource compares the highest CPU or memory saturation of the canary pods to the threshold
+func (c *Controller) checkResource(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	res := metric.Resource
+	val, err := c.observer.GetSaturation(r.Spec.TargetRef.Name, r.Namespace, res.Name, res.Reference, res.Container, metric.Interval)
+	if err != nil {
+		return c.metricQueryFailure(r, metric, err)
+	}
+
+	check.value = &val
+	if val > metric.Threshold {
+		check.message = fmt.Sprintf("Halt %s.%s advancement %s saturation %.2f%% > %v%%",
+			r.Name, r.Namespace, res.Name, val, metric.Threshold)
+		return degradeCheck(metric, check, val-metric.Threshold)
+	}
+
+	check = warnCheck(r, metric, check, val > metric.WarnThreshold)
+	check.passed = true
+	return check
+}
+
+// checkRatio evaluates the percentage of the numerator and denominator queries,
+// the check is skipped if the denominator is below the minimum sample size
+func (c *Controller) checkRatio(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	denominator, err := c.observer.GetScalar(metric.Ratio.Denominator)
+	if err != nil {
+		return c.metricQueryFailure(r, metric, err)
+	}
+	check.samples, check.minSamples = &denominator, metric.Ratio.MinSamples
+	if denominator <= 0 || denominator < metric.Ratio.MinSamples {
+		check.passed = true
+		check.skipped = true
+		check.message = fmt.Sprintf("%.0f samples < %v", denominator, metric.Ratio.MinSamples)
+		return check
+	}
+
+	numerator, err := c.observer.GetScalar(metric.Ratio.Numerator)
+	if err != nil {
+		return c.metricQueryFailure(r, metric, err)
+	}
+
+	val := numerator / denominator * 100
+	check.value = &val
+	if val < metric.Threshold {
+		check.message = fmt.Sprintf("Halt %s.%s advancement %s %.2f%% < %v%%",
+			r.Name, r.Namespace, metric.Name, val, metric.Threshold)
+		return degradeCheck(metric, check, metric.Threshold-val)
+	}
+
+	check = warnCheck(r, metric, check, val < metric.WarnThreshold)
+	check.passed = true
+	return check
+}
+
+// validateRatio checks that the ratio metric doesn't use the other query settings
+func validateRatio(metric flaggerv1.CanaryMetric) error {
+	if metric.Query != "" || metric.BurnRate != nil || metric.ThresholdPercent != 0 || metric.MinRequests != 0 ||
+		len(metric.Selector) > 0 || len(metric.Labels) > 0 || len(metric.SuccessCodes) > 0 || len(metric.TotalCodes) > 0 {
+		return fmt.Errorf("metric %s ratio can't be used with the other query settings", metric.Name)
+	}
+	if metric.Ratio.Numerator == "" || metric.Ratio.Denominator == "" {
+		return fmt.Errorf("metric %s ratio numerator and denominator are required", metric.Name)
+	}
+	if metric.Ratio.MinSamples < 0 {
+		return fmt.Errorf("metric %s ratio minSamples must be positive", metric.Name)
+	}
+	return nil
+}
+
+// validateResource checks that the saturation metric doesn't use the query settings
+// and that the resource and its reference are supported
+func validateResource(metric flaggerv1.CanaryMetric) error {
+	if metric.Query != "" || metric.BurnRate != nil || metric.LatencyDelta != nil || metric.Logs != nil ||
+		metric.Ratio != nil || metric.ThresholdPercent != 0 || metric.MinRequests != 0 || metric.Schema != nil ||
+		len(metric.Selector) > 0 || len(metric.Labels) > 0 || len(metric.SuccessCodes) > 0 || len(metric.TotalCodes) > 0 {
+		return fmt.Errorf("metric %s resource can't be used with the other query settings", metric.Name)
+	}
+	res := metric.Resource
+	if res.Name != "cpu" && res.Name != "memory" {
+		return fmt.Errorf("metric %s resource name must be cpu or memory", metric.Name)
+	}
+	if res.Reference != "" && res.Reference != "limits" && res.Reference != "requests" {
+		return fmt.Errorf("metric %s resource reference must be limits or requests", metric.Name)
+	}
+	if res.Container != "" && !containerNameRegexp.MatchString(res.Container) {
+		return fmt.Errorf("metric %s resource invalid container name %s", metric.Name, res.Container)
+	}
+	return nil
+}
+
+var containerNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// validateLogs checks that the log metric doesn't use the Prometheus query settings
+func validateLogs(metric flaggerv1.CanaryMetric) error {
+	if metric.Query != "" || metric.BurnRate != nil || metric.ThresholdPercent != 0 ||
+		len(metric.Selector) > 0 || len(metric.Labels) > 0 || len(metric.SuccessCodes) > 0 || len(metric.TotalCodes) > 0 {
+		return fmt.Errorf("metric %s logs can't be used with the Prometheus query settings", metric.Name)
+	}
+	if metric.Logs.Pattern == "" {
+		return fmt.Errorf("metric %s logs pattern is required", metric.Name)
+	}
+	if _, err := regexp.Compile(metric.Logs.Pattern); err != nil {
+		return fmt.Errorf("metric %s logs pattern %v", metric.Name, err)
+	}
+	return nil
+}
+
+// validateLatencyDelta checks that the latency delta is set on the request duration built-in metric
+// and that at least one of the positive max deltas is set
+func validateLatencyDelta(metric flaggerv1.CanaryMetric) error {
+	if metric.Name != "istio_request_duration_seconds_bucket" || metric.Query != "" ||
+		metric.BurnRate != nil || metric.Logs != nil || metric.Ratio != nil {
+		return fmt.Errorf("metric %s latency delta is supported only for the request duration built-in metric", metric.Name)
+	}
+	if metric.ThresholdPercent != 0 || metric.WarnThreshold != 0 {
+		return fmt.Errorf("metric %s thresholdPercent and warnThreshold are not supported with a latency delta", metric.Name)
+	}
+	ld := metric.LatencyDelta
+	if ld.MaxDelta < 0 || ld.MaxDeltaPercent < 0 {
+		return fmt.Errorf("metric %s latency delta values must be positive", metric.Name)
+	}
+	if ld.MaxDelta == 0 && ld.MaxDeltaPercent == 0 {
+		return fmt.Errorf("metric %s latency delta requires maxDelta or maxDeltaPercent", metric.Name)
+	}
+	return nil
+}
+
+// validateBurnRate checks that the burn rate objective and windows are valid
+func validateBurnRate(metric flaggerv1.CanaryMetric) error {
+	if metric.Name != "envoy_cluster_upstream_rq" && metric.Name != "istio_requests_total" || metric.Query != "" {
+		return fmt.Errorf("metric %s burn rate is supported only for the success rate built-in metrics", metric.Name)
+	}
+	if metric.ThresholdPercent != 0 {
+		return fmt.Errorf("metric %s thresholdPercent is not supported with a burn rate", metric.Name)
+	}
+	br := metric.BurnRate
+	if br.Objective <= 0 || br.Objective >= 100 {
+		return fmt.Errorf("metric %s burn rate objective must be between 0 and 100", metric.Name)
+	}
+	long, err := time.ParseDuration(br.LongWindow)
+	if err != nil {
+		return fmt.Errorf("metric %s burn rate long window %v", metric.Name, err)
+	}
+	short, err := time.ParseDuration(br.ShortWindow)
+	if err != nil {
+		return fmt.Errorf("metric %s burn rate short window %v", metric.Name, err)
+	}
+	if short >= long {
+		return fmt.Errorf("metric %s burn rate short window must be shorter than the long window", metric.Name)
+	}
+	return nil
+}
+
+// validateQueryOffset checks that the offset is set for a built-in metric
+// and that it is shorter than the metric interval
+func validateQueryOffset(metric flaggerv1.CanaryMetric) error {
+	if metric.Query != "" || metric.Logs != nil || metric.Ratio != nil || metric.Resource != nil {
+		return fmt.Errorf("metric %s queryOffset is supported only for the built-in metrics", metric.Name)
+	}
+	offset, err := time.ParseDuration(metric.QueryOffset)
+	if err != nil || offset <= 0 {
+		return fmt.Errorf("metric %s queryOffset %s must be a positive duration", metric.Name, metric.QueryOffset)
+	}
+	interval, err := time.ParseDuration(metric.Interval)
+	if err != nil || offset >= interval {
+		return fmt.Errorf("metric %s queryOffset %s must be less than the interval %s", metric.Name, metric.QueryOffset, metric.Interval)
+	}
+	return nil
+}
+
+// higherIsBetter returns true if the metric fails below the threshold,
+// e.g. the success rate and the ratio metrics
+func higherIsBetter(metric flaggerv1.CanaryMetric) bool {
+	if metric.SLO != nil {
+		return sloIndicator(metric.SLO) == flaggerv1.SLOErrorBudget
+	}
+	return metric.Ratio != nil || (metric.Name == "envoy_cluster_upstream_rq" || metric.Name == "istio_requests_total") &&
+		metric.Query == "" && metric.BurnRate == nil && metric.Logs == nil && metric.Resource == nil && metric.GraphQL == nil
+}
+
+// validateMetric checks that the metric threshold settings can be used together
+// and that the response codes and the label selector are valid
+func validateMetric(metric flaggerv1.CanaryMetric) error {
+	if metric.DegradedBand < 0 {
+		return fmt.Errorf("metric %s degradedBand must be positive", metric.Name)
+	}
+
+	if metric.QueryOffset != "" {
+		if err := validateQueryOffset(metric); err != nil {
+			return err
+		}
+	}
+
+	if metric.EvaluationWindows < 0 {
+		return fmt.Errorf("metric %s evaluationWindows must be positive", metric.Name)
+	}
+	if metric.EvaluationWindows > 1 && (metric.BurnRate != nil || metric.LatencyDelta != nil) {
+		return fmt.Errorf("metric %s evaluationWindows is not supported with a burn rate or a latency delta", metric.Name)
+	}
+
+	if metric.WarnThreshold != 0 {
+		if metric.ThresholdPercent != 0 {
+			return fmt.Errorf("metric %s warnThreshold is not supported with thresholdPercent", metric.Name)
+		}
+		if higherIsBetter(metric) && metric.WarnThreshold <= metric.Threshold {
+			return fmt.Errorf("metric %s warnThreshold must be above the threshold", metric.Name)
+		}
+		if !higherIsBetter(metric) && metric.WarnThreshold >= metric.Threshold {
+			return fmt.Errorf("metric %s warnThreshold must be below the threshold", metric.Name)
+		}
+	}
+
+	if metric.LatencyDelta != nil {
+		if err := validateLatencyDelta(metric); err != nil {
+			return err
+		}
+	}
+
+	if metric.Resource != nil {
+		return validateResource(metric)
+	}
+
+	if metric.GraphQL != nil {
+		return validateGraphQL(metric)
+	}
+
+	if metric.SLO != nil {
+		return validateSLO(metric)
+	}
+
+	if metric.Logs != nil {
+		if metric.Ratio != nil {
+			return fmt.Errorf("metric %s logs can't be used with a ratio", metric.Name)
+		}
+		return validateLogs(metric)
+	}
+
+	if metric.Ratio != nil {
+		return validateRatio(metric)
+	}
+
+	if len(metric.Selector) > 0 {
+		if metric.Query != "" {
+			return fmt.Errorf("metric %s selector is not supported for custom queries", metric.Name)
+		}
+		if metric.ThresholdPercent != 0 {
+			return fmt.Errorf("metric %s thresholdPercent is not supported with a selector", metric.Name)
+		}
+		if err := validateLabels(metric.Selector); err != nil {
+			return fmt.Errorf("metric %s selector %v", metric.Name, err)
+		}
+	}
+
+	if len(metric.Labels) > 0 {
+		if metric.Query != "" {
+			return fmt.Errorf("metric %s labels are not supported for custom queries", metric.Name)
+		}
+		if err := validateLabels(metric.Labels); err != nil {
+			return fmt.Errorf("metric %s labels %v", metric.Name, err)
+		}
+	}
+
+	if len(metric.ExcludedPaths) > 0 || metric.PathLabel != "" {
+		if err := validateExcludedPaths(metric); err != nil {
+			return err
+		}
+	}
+
+	if metric.BurnRate != nil {
+		if err := validateBurnRate(metric); err != nil {
+			return err
+		}
+	}
+
+	if metric.MinRequests != 0 {
+		if metric.Name != "envoy_cluster_upstream_rq" && metric.Name != "istio_requests_total" || metric.Query != "" {
+			return fmt.Errorf("metric %s minRequests is supported only for the success rate built-in metrics", metric.Name)
+		}
+		if metric.MinRequests < 0 {
+			return fmt.Errorf("metric %s minRequests must be positive", metric.Name)
+		}
+		if metric.BurnRate != nil {
+			return fmt.Errorf("metric %s minRequests is not supported with a burn rate", metric.Name)
+		}
+	}
+
+	codes := metricQueryOptions(metric).Codes
+	if len(codes.Success) > 0 || len(codes.Total) > 0 {
+		if metric.Name != "envoy_cluster_upstream_rq" && metric.Name != "istio_requests_total" || metric.Query != "" {
+			return fmt.Errorf("metric %s response codes are supported only for the success rate built-in metrics", metric.Name)
+		}
+		if err := codes.Validate(); err != nil {
+			return fmt.Errorf("metric %s %v", metric.Name, err)
+		}
+	}
+
+	if metric.ThresholdPercent == 0 {
+		if metric.LongRange != nil {
+			return fmt.Errorf("metric %s longRange requires thresholdPercent", metric.Name)
+		}
+		return nil
+	}
+	if metric.ThresholdPercent < 0 {
+		return fmt.Errorf("metric %s thresholdPercent must be positive", metric.Name)
+	}
+	if metric.Threshold != 0 {
+		return fmt.Errorf("metric %s threshold and thresholdPercent are mutually exclusive", metric.Name)
+	}
+	if metric.Query != "" {
+		return fmt.Errorf("metric %s thresholdPercent is not supported for custom queries", metric.Name)
+	}
+	if lr := metric.LongRange; lr != nil {
+		if !metricNameRegexp.MatchString(lr.Series) {
+			return fmt.Errorf("metric %s longRange series %s is not a valid metric name", metric.Name, lr.Series)
+		}
+		if d, err := time.ParseDuration(lr.Window); err != nil || d <= 0 {
+			return fmt.Errorf("metric %s longRange window %s must be a positive duration", metric.Name, lr.Window)
+		}
+	}
+	return nil
+}
+
+// canaryQueryOptions returns the query options of the metric without the series of the excluded regions
+func canaryQueryOptions(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric) QueryOptions {
+	opts := metricQueryOptions(metric)
+	opts.ExcludeLabel = r.Spec.CanaryAnalysis.RegionLabel
+	opts.ExcludeValues = r.Status.ExcludedRegions
+	return opts
+}
+
+func metricQueryOptions(metric flaggerv1.CanaryMetric) QueryOptions {
+	opts := QueryOptions{
+		Codes:    StatusCodes{Success: metric.SuccessCodes, Total: metric.TotalCodes},
+		Selector: metric.Selector,
+		Labels:   metric.Labels,
+		Offset:   metric.QueryOffset,
+		// the aggregation applies only to the built-in success rate and duration queries
+		Aggregation:   string(metric.Aggregation),
+		ExcludedPaths: metric.ExcludedPaths,
+		PathLabel:     metric.PathLabel,
+	}
+	if len(metric.ExcludedPaths) > 0 && metric.PathLabel == "" {
+		opts.PathLabel = "request_path"
+	}
+	if schema := metric.Schema; schema != nil {
+		opts.MetricName = schema.MetricName
+		opts.WorkloadLabel = schema.WorkloadLabel
+		opts.NamespaceLabel = schema.NamespaceLabel
+		opts.CodeLabel = schema.CodeLabel
+		opts.PodLabel = schema.PodLabel
+	}
+	return opts
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// validateSchema checks that the schema overrides a built-in metric and
+// that it contains the labels required by the built-in query
+func validateSchema(metric flaggerv1.CanaryMetric) error {
+	schema := metric.Schema
+	if schema == nil {
+		return nil
+	}
+
+	successRate := metric.Name == "envoy_cluster_upstream_rq" || metric.Name == "istio_requests_total"
+	if !successRate && metric.Name != "istio_request_duration_seconds_bucket" || metric.Query != "" ||
+		metric.Ratio != nil || metric.Logs != nil {
+		return fmt.Errorf("metric %s schema is supported only for the built-in metrics", metric.Name)
+	}
+	if schema.MetricName != "" && !metricNameRegexp.MatchString(schema.MetricName) {
+		return fmt.Errorf("metric %s schema invalid metric name %s", metric.Name, schema.MetricName)
+	}
+
+	labels := map[string]string{}
+	if len(metric.Selector) == 0 {
+		if schema.WorkloadLabel == "" || schema.NamespaceLabel == "" {
+			return fmt.Errorf("metric %s schema workloadLabel and namespaceLabel are required", metric.Name)
+		}
+		labels[schema.WorkloadLabel] = ""
+		labels[schema.NamespaceLabel] = ""
+	}
+	if successRate {
+		if schema.CodeLabel == "" {
+			return fmt.Errorf("metric %s schema codeLabel is required by the success rate metrics", metric.Name)
+		}
+		labels[schema.CodeLabel] = ""
+	}
+	if err := validateLabels(labels); err != nil {
+		return fmt.Errorf("metric %s schema %v", metric.Name, err)
+	}
+	return nil
+}
+
+// validateAggregation checks that the per pod aggregation is set on a built-in
+// success rate or request duration metric and that the function is known
+func validateAggregation(metric flaggerv1.CanaryMetric) error {
+	if metric.Aggregation == "" {
+		return nil
+	}
+	switch metric.Aggregation {
+	case flaggerv1.AggregationAvg, flaggerv1.AggregationMin, flaggerv1.AggregationMax, flaggerv1.AggregationWorstPod:
+	default:
+		return fmt.Errorf("metric %s aggregation %s must be avg, min, max or worst-pod", metric.Name, metric.Aggregation)
+	}
+	builtin := metric.Name == "envoy_cluster_upstream_rq" || metric.Name == "istio_requests_total" ||
+		metric.Name == "istio_request_duration_seconds_bucket"
+	if !builtin || metric.Query != "" || metric.Ratio != nil || metric.Logs != nil ||
+		metric.BurnRate != nil || metric.Resource != nil {
+		return fmt.Errorf("metric %s aggregation is supported only by the success rate and request duration built-in metrics", metric.Name)
+	}
+	if schema := metric.Schema; schema != nil && schema.PodLabel != "" {
+		if err := validateLabels(map[string]string{schema.PodLabel: ""}); err != nil {
+			return fmt.Errorf("metric %s schema %v", metric.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateKnativeMetric checks that the metrics of a Knative service declare their own queries,
+// the built-in metrics and the baselines select the primary and canary workloads that don't match the revision pods
+func validateKnativeMetric(cd *flaggerv1.Canary, metric flaggerv1.CanaryMetric) error {
+	if !cd.IsKnativeService() {
+		return nil
+	}
+	builtin := metric.Query == "" && metric.Ratio == nil && metric.GraphQL == nil && metric.SLO == nil && metric.Logs == nil
+	if builtin || metric.ThresholdPercent != 0 || metric.LatencyDelta != nil || metric.MinRequests != 0 {
+		return fmt.Errorf("metric %s built-in queries, thresholdPercent, latencyDelta and minRequests are not supported for Knative services",
+			metric.Name)
+	}
+	return nil
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validateExcludedPaths checks that the excluded paths are valid regular expressions
+// and that they are set on a success rate or request duration built-in metric
+func validateExcludedPaths(metric flaggerv1.CanaryMetric) error {
+	builtin := metric.Name == "envoy_cluster_upstream_rq" || metric.Name == "istio_requests_total" ||
+		metric.Name == "istio_request_duration_seconds_bucket"
+	if !builtin || metric.Query != "" || metric.Ratio != nil || metric.Logs != nil || metric.Resource != nil {
+		return fmt.Errorf("metric %s excludedPaths are supported only by the success rate and request duration built-in metrics", metric.Name)
+	}
+	if len(metric.ExcludedPaths) == 0 {
+		return fmt.Errorf("metric %s pathLabel requires excludedPaths", metric.Name)
+	}
+	if metric.PathLabel != "" && !labelNameRegexp.MatchString(metric.PathLabel) {
+		return fmt.Errorf("metric %s invalid pathLabel %s", metric.Name, metric.PathLabel)
+	}
+	for _, path := range metric.ExcludedPaths {
+		if path == "" {
+			return fmt.Errorf("metric %s excludedPaths can't contain an empty path", metric.Name)
+		}
+		if _, err := regexp.Compile(path); err != nil {
+			return fmt.Errorf("metric %s invalid excluded path %s %v", metric.Name, path, err)
+		}
+	}
+	return nil
+}
+
+// validateLabels checks that the label names are valid Prometheus label names
+func validateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid label name %s", name)
+		}
+	}
+	return nil
+}
+
+// baselineThreshold returns the metric threshold computed as a percentage
+// of the value measured for the primary or the baseline deployment,
+// the long-range baselines average a recorded series up to the current time
+func (c *Controller) baselineThreshold(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric) (float64, error) {
+	workload := fmt.Sprintf("%s-primary", r.Spec.TargetRef.Name)
+	if r.Spec.CanaryAnalysis.Baseline {
+		workload = fmt.Sprintf("%s-baseline", r.Spec.TargetRef.Name)
+	}
+	if lr := metric.LongRange; lr != nil {
+		baseline, err := c.observer.GetLongRangeBaseline(workload, r.Namespace, lr.Series, lr.Window, metricQueryOptions(metric))
+		if err != nil {
+			return 0, err
+		}
+		return baseline * metric.ThresholdPercent / 100, nil
+	}
+
+	anchor, err := r.GetBaselineAnchor()
+	if err != nil {
+		return 0, err
+	}
+	baseline, err := c.observer.GetBaseline(workload, r.Namespace, metric.Name, metric.Interval, canaryQueryOptions(r, metric), anchor)
+	if err != nil {
+		return 0, err
+	}
+	return baseline * metric.ThresholdPercent / 100, nil
+}
+
+// checkHistogramMetrics warns if the latency metrics have no histogram series,
+// the primary workload is used since the canary is not receiving traffic yet
+func (c *Controller) checkHistogramMetrics(cd *flaggerv1.Canary) {
+	primaryName := fmt.Sprintf("%s-primary", cd.Spec.TargetRef.Name)
+	for _, metric := range cd.Spec.CanaryAnalysis.Metrics {
+		if metric.Name != "istio_request_duration_seconds_bucket" || metric.Query != "" || len(metric.Selector) > 0 {
+			continue
+		}
+		ok, err := c.observer.HasHistogram(primaryName, cd.Namespace, metric.Name)
+		if err != nil {
+			c.recordEventWarningf(cd, "Metrics server %s query failed: %v", c.observer.metricsServer, err)
+			continue
+		}
+		if !ok {
+			c.recordEventWarningf(cd, "No histogram series found for metric %s and %s.%s, the latency check will halt the analysis",
+				metric.Name, primaryName, cd.Namespace)
+		}
+	}
+}
+
+// metricQueryFailure returns a failed check for a query error,
+// a query with no results means the canary is not receiving traffic
+func (c *Controller) metricQueryFailure(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, err error) metricCheck {
+	check := metricCheck{name: metric.Name, threshold: metric.Threshold}
+	if strings.Contains(err.Error(), "no values found") {
+		check.noValues = true
+		check.message = fmt.Sprintf("Halt advancement no values found for metric %s probably %s.%s is not receiving traffic",
+			metric.Name, r.Spec.TargetRef.Name, r.Namespace)
+		return check
+	}
+	// a slow query halts the advancement like a missing value unless the canary fails on timeouts
+	if strings.Contains(err.Error(), "query timed out") && !r.Spec.CanaryAnalysis.FailOnQueryTimeout {
+		check.timedOut = true
+		check.message = fmt.Sprintf("Halt advancement metric %s %v", metric.Name, err)
+		return check
+	}
+
+	check.queryError = true
+	check.message = fmt.Sprintf("Metrics server %s query failed: %v", c.observer.metricsServer, err)
+	return check
+}
@@ -1,9 +1,19 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestScheduler_Init(t *testing.T) {
@@ -62,6 +72,95 @@ func TestScheduler_Rollback(t *testing.T) {
 	if c.Status.Phase != v1alpha3.CanaryFailed {
 		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
 	}
+
+	if reason := c.Annotations[v1alpha3.RollbackReasonAnnotation]; !strings.HasPrefix(reason, "Failed checks threshold reached") {
+		t.Errorf("Got rollback reason %s wanted failed checks threshold", reason)
+	}
+	if c.Annotations[v1alpha3.RollbackTimestampAnnotation] == "" {
+		t.Errorf("Got empty rollback timestamp")
+	}
+	if revision := c.Annotations[v1alpha3.RollbackRevisionAnnotation]; revision != "quay.io/stefanprodan/podinfo:1.2.0" {
+		t.Errorf("Got rollback revision %s wanted %s", revision, "quay.io/stefanprodan/podinfo:1.2.0")
+	}
+	if c.Status.RollbackReason != v1alpha3.RollbackMetricThreshold {
+		t.Errorf("Got rollback reason %s wanted %s", c.Status.RollbackReason, v1alpha3.RollbackMetricThreshold)
+	}
+}
+
+func TestScheduler_RollbackReason(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update failed checks to max, the last check failed without values
+	err := mocks.deployer.SyncStatus(mocks.canary, v1alpha3.CanaryStatus{
+		Phase:             v1alpha3.CanaryProgressing,
+		FailedChecks:      11,
+		FailedCheckReason: v1alpha3.RollbackNoTraffic,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.RollbackReason != v1alpha3.RollbackNoTraffic {
+		t.Errorf("Got rollback reason %s wanted %s", c.Status.RollbackReason, v1alpha3.RollbackNoTraffic)
+	}
+	if c.Status.FailedCheckReason != "" {
+		t.Errorf("Got failed check reason %s wanted none after rollback", c.Status.FailedCheckReason)
+	}
+
+	check := mocks.ctrl.metricQueryFailure(c, c.Spec.CanaryAnalysis.Metrics[0], errors.New("no values found for metric istio_requests_total"))
+	if !check.noValues {
+		t.Errorf("Got no values %v wanted %v", check.noValues, true)
+	}
+}
+
+func TestScheduler_PostRollbackReplicas(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.PostRollbackReplicas = 1
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update failed checks to max
+	err = mocks.deployer.SyncStatus(cd, v1alpha3.CanaryStatus{Phase: v1alpha3.CanaryProgressing, FailedChecks: 11})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *dep.Spec.Replicas != 1 {
+		t.Errorf("Got canary replicas %v wanted %v", *dep.Spec.Replicas, 1)
+	}
 }
 
 func TestScheduler_SkipAnalysis(t *testing.T) {
@@ -157,6 +256,15 @@ func TestScheduler_NewRevisionReset(t *testing.T) {
 	if canaryWeight != 0 {
 		t.Errorf("Got canary route %v wanted %v", canaryWeight, 0)
 	}
+
+	// the restart is not counted against the retry budget
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Rollbacks != 0 {
+		t.Errorf("Got rollbacks %v wanted %v", c.Status.Rollbacks, 0)
+	}
 }
 
 func TestScheduler_Promotion(t *testing.T) {
@@ -329,3 +437,3118 @@ func TestScheduler_ABTesting(t *testing.T) {
 		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanarySucceeded)
 	}
 }
+
+func TestScheduler_ABTestingMatchStepWeight(t *testing.T) {
+	mocks := SetupMocks(true)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cd.Spec.CanaryAnalysis.MatchStepWeight = 40
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect pod spec changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	for _, expected := range []int{40, 80, 100} {
+		// advance
+		mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+		primaryWeight, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if canaryWeight != expected {
+			t.Errorf("Got canary route %v wanted %v", canaryWeight, expected)
+		}
+
+		if primaryWeight != 100-expected {
+			t.Errorf("Got primary route %v wanted %v", primaryWeight, 100-expected)
+		}
+	}
+}
+
+func TestScheduler_Stages(t *testing.T) {
+	mocks := SetupMocks(true)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cd.Spec.CanaryAnalysis.Stages = []v1alpha3.CanaryStage{
+		{Name: "internal", Match: cd.Spec.CanaryAnalysis.Match, Iterations: 1},
+		{Name: "everyone", Weight: 50, Iterations: 1},
+	}
+	cd.Spec.CanaryAnalysis.MaxWeight = 50
+	cd.Spec.CanaryAnalysis.Match = nil
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect pod spec changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	for i, expected := range []int{100, 50} {
+		// advance
+		mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+		c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if c.Status.Stage != i {
+			t.Errorf("Got stage %v wanted %v", c.Status.Stage, i)
+		}
+
+		_, canaryWeight, err := mocks.router.GetRoutes(c)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if canaryWeight != expected {
+			t.Errorf("Got canary route %v wanted %v", canaryWeight, expected)
+		}
+	}
+
+	// promote
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	primaryDep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryDep.Spec.Template.Spec.Containers[0].Image != dep2.Spec.Template.Spec.Containers[0].Image {
+		t.Errorf("Got primary image %v wanted %v",
+			primaryDep.Spec.Template.Spec.Containers[0].Image, dep2.Spec.Template.Spec.Containers[0].Image)
+	}
+
+	// shutdown canary
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanarySucceeded {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanarySucceeded)
+	}
+}
+
+func TestScheduler_ThresholdPercent(t *testing.T) {
+	mocks := SetupMocks(false)
+
+	metric := v1alpha3.CanaryMetric{
+		Name:             "istio_requests_total",
+		ThresholdPercent: 90,
+		Interval:         "1m",
+	}
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); !check.passed {
+		t.Errorf("Got failed check %s wanted passed", check.message)
+	}
+
+	metric.ThresholdPercent = 110
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); check.passed {
+		t.Errorf("Got passed check wanted failed")
+	}
+
+	metric.Threshold = 99
+	check := mocks.ctrl.checkMetric(mocks.canary, metric)
+	if check.passed || !strings.Contains(check.message, "mutually exclusive") {
+		t.Errorf("Got check %+v wanted mutually exclusive error", check)
+	}
+}
+
+func TestScheduler_NextWeights(t *testing.T) {
+	tests := []struct {
+		step        int
+		max         int
+		granularity int
+	}{
+		{step: 5, max: 50},
+		{step: 30, max: 50},
+		{step: 7, max: 100},
+		{step: 33, max: 100, granularity: 10},
+		{step: 3, max: 50, granularity: 5},
+		{step: 150, max: 120},
+	}
+
+	for _, tt := range tests {
+		canaryWeight := 0
+		for i := 0; i < 100; i++ {
+			primaryWeight, next := nextWeights(canaryWeight, tt.step, tt.max, tt.granularity)
+			if primaryWeight+next != 100 {
+				t.Errorf("Got weights %v + %v != 100 for step %v max %v", primaryWeight, next, tt.step, tt.max)
+			}
+			if primaryWeight < 0 || next < 0 {
+				t.Errorf("Got negative weights %v %v for step %v max %v", primaryWeight, next, tt.step, tt.max)
+			}
+			if next <= canaryWeight {
+				t.Errorf("Got weight %v not advancing from %v for step %v max %v", next, canaryWeight, tt.step, tt.max)
+			}
+			canaryWeight = next
+			if canaryWeight >= tt.max || canaryWeight == 100 {
+				break
+			}
+		}
+		if canaryWeight != tt.max && canaryWeight != 100 {
+			t.Errorf("Got final weight %v wanted %v for step %v", canaryWeight, tt.max, tt.step)
+		}
+	}
+
+	_, canaryWeight := nextWeights(10, 3, 50, 5)
+	if canaryWeight != 15 {
+		t.Errorf("Got canary weight %v wanted %v", canaryWeight, 15)
+	}
+}
+
+func TestScheduler_HaltedIntervals(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// advance
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// add a failing metric check
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	metrics := cd.Spec.CanaryAnalysis.Metrics
+	cd.Spec.CanaryAnalysis.StalledThreshold = 2
+	cd.Spec.CanaryAnalysis.Metrics = append(metrics, v1alpha3.CanaryMetric{
+		Name:      "errors",
+		Query:     "sum(errors)",
+		Threshold: 50,
+	})
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// halt
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.HaltedIntervals != 2 {
+		t.Errorf("Got halted intervals %v wanted %v", c.Status.HaltedIntervals, 2)
+	}
+
+	// remove the failing metric check
+	c.Spec.CanaryAnalysis.Metrics = metrics
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(c); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// advance
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.HaltedIntervals != 0 {
+		t.Errorf("Got halted intervals %v wanted %v", c.Status.HaltedIntervals, 0)
+	}
+}
+
+func TestScheduler_AnalysisResult(t *testing.T) {
+	mocks := SetupMocks(false)
+
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.CanaryAnalysis.Metrics = append(cd.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:      "errors",
+		Query:     "sum(errors)",
+		Threshold: 50,
+	})
+
+	result := mocks.ctrl.analyseCanary(cd)
+	if result.passed {
+		t.Errorf("Got analysis passed %v wanted %v", result.passed, false)
+	}
+
+	if len(result.checks) != len(cd.Spec.CanaryAnalysis.Metrics) {
+		t.Fatalf("Got %v metric checks wanted %v", len(result.checks), len(cd.Spec.CanaryAnalysis.Metrics))
+	}
+
+	for i, check := range result.checks {
+		if check.name != cd.Spec.CanaryAnalysis.Metrics[i].Name {
+			t.Errorf("Got metric %s wanted %s", check.name, cd.Spec.CanaryAnalysis.Metrics[i].Name)
+		}
+		if check.value == nil {
+			t.Errorf("Got no value for metric %s", check.name)
+		}
+	}
+
+	if !strings.Contains(result.Summary(), "errors 100.00 (threshold 50)") {
+		t.Errorf("Got summary %s", result.Summary())
+	}
+}
+
+func TestScheduler_Suspend(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// advance
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.Suspend = true
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// cancel
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	primaryWeight, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if primaryWeight != 100 {
+		t.Errorf("Got primary route %v wanted %v", primaryWeight, 100)
+	}
+
+	if canaryWeight != 0 {
+		t.Errorf("Got canary route %v wanted %v", canaryWeight, 0)
+	}
+
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if *dep.Spec.Replicas != 0 {
+		t.Errorf("Got canary replicas %v wanted %v", *dep.Spec.Replicas, 0)
+	}
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if c.Status.Phase != v1alpha3.CanaryCancelled {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryCancelled)
+	}
+}
+
+func TestScheduler_AnalysisScore(t *testing.T) {
+	mocks := SetupMocks(false)
+
+	cd := mocks.canary.DeepCopy()
+	for i := range cd.Spec.CanaryAnalysis.Metrics {
+		cd.Spec.CanaryAnalysis.Metrics[i].Weight = 4
+	}
+	cd.Spec.CanaryAnalysis.Metrics = append(cd.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:      "errors",
+		Query:     "sum(errors)",
+		Threshold: 50,
+	})
+
+	// strict mode fails on any metric
+	if result := mocks.ctrl.analyseCanary(cd); result.passed {
+		t.Errorf("Got analysis passed %v wanted %v", result.passed, false)
+	}
+
+	// 8 out of 9 is above the threshold
+	cd.Spec.CanaryAnalysis.ScoreThreshold = 80
+	result := mocks.ctrl.analyseCanary(cd)
+	if !result.passed {
+		t.Errorf("Got analysis passed %v wanted %v", result.passed, true)
+	}
+	if result.score == nil || *result.score < 88 || *result.score > 89 {
+		t.Errorf("Got score %v wanted %.2f", result.score, 800.0/9)
+	}
+
+	cd.Spec.CanaryAnalysis.ScoreThreshold = 90
+	if result := mocks.ctrl.analyseCanary(cd); result.passed {
+		t.Errorf("Got analysis passed %v wanted %v", result.passed, false)
+	}
+}
+
+func TestScheduler_Warmup(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.WarmupDuration = "1h"
+	cd.Spec.CanaryAnalysis.WarmupWeight = 5
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// warmup
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	_, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if canaryWeight != 5 {
+		t.Errorf("Got canary route %v wanted %v", canaryWeight, 5)
+	}
+
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cd.Status.WarmupStartTime.IsZero() {
+		t.Fatalf("Got empty warmup start time")
+	}
+
+	// end warmup
+	if err := mocks.deployer.SetStatusWarmupStartTime(cd, metav1.NewTime(time.Now().Add(-2*time.Hour))); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	_, canaryWeight, err = mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if canaryWeight != 5+cd.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary route %v wanted %v", canaryWeight, 5+cd.Spec.CanaryAnalysis.StepWeight)
+	}
+}
+
+func TestScheduler_AnalysisThresholdPercent(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Threshold = 0
+	cd.Spec.CanaryAnalysis.ThresholdPercent = 40
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// advance
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// add a failing metric check
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Metrics = append(cd.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:      "errors",
+		Query:     "sum(errors)",
+		Threshold: 50,
+	})
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// halt
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.AnalysedIntervals != 2 {
+		t.Errorf("Got analysed intervals %v wanted %v", c.Status.AnalysedIntervals, 2)
+	}
+	if c.Status.Phase != v1alpha3.CanaryProgressing {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryProgressing)
+	}
+
+	// rollback with 50% of the intervals failed
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+}
+
+func TestScheduler_ValidateAnalysis(t *testing.T) {
+	cd := newTestCanary()
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cd.Spec.CanaryAnalysis.ThresholdPercent = 20
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted mutually exclusive thresholds error")
+	}
+
+	cd.Spec.CanaryAnalysis.Threshold = 0
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestScheduler_ValidateAggregation(t *testing.T) {
+	cd := newTestCanary()
+	cd.Spec.CanaryAnalysis.Metrics[0].Aggregation = v1alpha3.AggregationWorstPod
+	cd.Spec.CanaryAnalysis.Metrics[1].Aggregation = v1alpha3.AggregationMax
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cd.Spec.CanaryAnalysis.Metrics[0].Aggregation = "median"
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted unknown aggregation error")
+	}
+
+	cd = newTestCanary()
+	cd.Spec.CanaryAnalysis.Metrics = append(cd.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:        "error-rate",
+		Query:       "sum(rate(errors_total[1m]))",
+		Aggregation: v1alpha3.AggregationAvg,
+	})
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted custom query aggregation error")
+	}
+}
+
+func TestScheduler_ValidateWeights(t *testing.T) {
+	cd := newTestCanary()
+	cd.Spec.CanaryAnalysis.MaxWeight = 120
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted out of range maxWeight error")
+	}
+
+	cd = newTestCanary()
+	cd.Spec.CanaryAnalysis.StepWeight = 0
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted out of range stepWeight error")
+	}
+
+	cd.Spec.CanaryAnalysis.StepWeight = -10
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted out of range stepWeight error")
+	}
+
+	// the iterations drive the A/B testing
+	cd = newTestCanaryAB()
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the stage weights must increase up to the max weight
+	cd = newTestCanary()
+	cd.Spec.CanaryAnalysis.Stages = []v1alpha3.CanaryStage{
+		{Name: "ten", Weight: 10, Iterations: 1},
+		{Name: "fifty", Weight: 50, Iterations: 1},
+	}
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cd.Spec.CanaryAnalysis.Stages = []v1alpha3.CanaryStage{
+		{Name: "fifty", Weight: 50, Iterations: 1},
+		{Name: "ten", Weight: 10, Iterations: 1},
+	}
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted decreasing stage weights error")
+	}
+
+	cd.Spec.CanaryAnalysis.Stages = []v1alpha3.CanaryStage{
+		{Name: "ten", Weight: 10, Iterations: 1},
+		{Name: "everyone", Weight: 120, Iterations: 1},
+	}
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted out of range stage weight error")
+	}
+
+	cd.Spec.CanaryAnalysis.Stages = []v1alpha3.CanaryStage{
+		{Name: "ten", Weight: 10, Iterations: 1},
+		{Name: "thirty", Weight: 30, Iterations: 1},
+	}
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted last stage weight below maxWeight error")
+	}
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.MaxWeight = 150
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the analysis doesn't start
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != 0 {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 0)
+	}
+}
+
+func TestScheduler_QueryTimeout(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd := newTestCanary()
+	metric := cd.Spec.CanaryAnalysis.Metrics[0]
+
+	check := mocks.ctrl.metricQueryFailure(cd, metric, errors.New("query timed out after 5s"))
+	if !check.timedOut || check.queryError || check.passed {
+		t.Errorf("Got timed out %v query error %v wanted a halted check", check.timedOut, check.queryError)
+	}
+
+	cd.Spec.CanaryAnalysis.FailOnQueryTimeout = true
+	check = mocks.ctrl.metricQueryFailure(cd, metric, errors.New("query timed out after 5s"))
+	if check.timedOut || !check.queryError {
+		t.Errorf("Got timed out %v query error %v wanted a query error", check.timedOut, check.queryError)
+	}
+}
+
+func TestScheduler_ConsecutivePasses(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.ConsecutivePasses = 3
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// hold for two passes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.ConsecutivePasses != 2 {
+		t.Errorf("Got consecutive passes %v wanted %v", c.Status.ConsecutivePasses, 2)
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// advance on the third pass
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.ConsecutivePasses != 0 {
+		t.Errorf("Got consecutive passes %v wanted %v", c.Status.ConsecutivePasses, 0)
+	}
+	if c.Status.CanaryWeight != 2*c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 2*c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// a failed analysis resets the counter
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c.Spec.CanaryAnalysis.Metrics = append(c.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:      "errors",
+		Query:     "sum(errors)",
+		Threshold: 50,
+	})
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(c); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.ConsecutivePasses != 0 {
+		t.Errorf("Got consecutive passes %v wanted %v", c.Status.ConsecutivePasses, 0)
+	}
+}
+
+func TestScheduler_MinRequests(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.MinRequests = 1000
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// hold, the fake observer reports 100 requests
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.StepRequests != 100 {
+		t.Errorf("Got step requests %v wanted %v", c.Status.StepRequests, 100)
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// advance once the minimum is reached
+	c.Spec.CanaryAnalysis.MinRequests = 100
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(c); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != 2*c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 2*c.Spec.CanaryAnalysis.StepWeight)
+	}
+	if c.Status.StepRequests != 0 || c.Status.StepStartTime.IsZero() {
+		t.Errorf("Got step requests %v start %v wanted a new step", c.Status.StepRequests, c.Status.StepStartTime)
+	}
+}
+
+func TestScheduler_StepHolds(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.StepHolds = []v1alpha3.CanaryStepHold{
+		{Weight: cd.Spec.CanaryAnalysis.StepWeight, Duration: "1h"},
+	}
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// hold at the first step
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// advance once the hold duration elapsed
+	c.Status.StepStartTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").UpdateStatus(c); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != 2*c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 2*c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// the hold applies to the higher weights
+	if hold := stepHold(c, 2*c.Spec.CanaryAnalysis.StepWeight); hold == nil || hold.Duration != "1h" {
+		t.Errorf("Got step hold %v wanted %v", hold, "1h")
+	}
+	if hold := stepHold(c, c.Spec.CanaryAnalysis.StepWeight-1); hold != nil {
+		t.Errorf("Got step hold %v wanted none", hold)
+	}
+}
+
+func TestScheduler_AdaptiveStep(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.AdaptiveStep = &v1alpha3.CanaryAdaptiveStep{
+		Query:         "slo:error_budget_remaining:percent",
+		MinStepWeight: 5,
+		MaxStepWeight: 30,
+	}
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis, the fake observer reports the whole error budget
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != 30 {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 30)
+	}
+
+	tests := []struct {
+		remaining float64
+		step      int
+	}{
+		{remaining: 100, step: 30},
+		{remaining: 50, step: 18},
+		{remaining: 0, step: 5},
+		{remaining: -20, step: 5},
+	}
+	for _, tt := range tests {
+		if step := scaleStepWeight(5, 30, tt.remaining); step != tt.step {
+			t.Errorf("Got step %v for %v%% remaining wanted %v", step, tt.remaining, tt.step)
+		}
+	}
+}
+
+func TestScheduler_KillSwitch(t *testing.T) {
+	mocks := SetupMocks(false)
+	ks, err := NewKillSwitch(mocks.kubeClient, "flagger-system/flagger-kill-switch")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.killSwitch = ks
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "flagger-kill-switch", Namespace: "flagger-system"},
+		Data:       map[string]string{"engaged": "true", "reason": "platform incident"},
+	}
+	if _, err := mocks.kubeClient.CoreV1().ConfigMaps("flagger-system").Create(cm); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// hold while the switch is engaged
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// resume once the switch is released
+	cm.Data["engaged"] = "false"
+	if _, err := mocks.kubeClient.CoreV1().ConfigMaps("flagger-system").Update(cm); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != 2*c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 2*c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	if _, err := NewKillSwitch(mocks.kubeClient, "flagger-kill-switch"); err == nil {
+		t.Errorf("Got no error wanted invalid ConfigMap reference")
+	}
+}
+
+func TestScheduler_ValidateMetricSelector(t *testing.T) {
+	metric := v1alpha3.CanaryMetric{
+		Name:      "istio_requests_total",
+		Threshold: 99,
+		Selector:  map[string]string{"job": "batch"},
+	}
+	if err := validateMetric(metric); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	metric.Selector = map[string]string{"exported-job": "batch"}
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted invalid label name")
+	}
+}
+
+func TestScheduler_ValidateExcludedPaths(t *testing.T) {
+	metric := v1alpha3.CanaryMetric{
+		Name:          "istio_request_duration_seconds_bucket",
+		Threshold:     500,
+		ExcludedPaths: []string{"/healthz", "/metrics.*"},
+	}
+	if err := validateMetric(metric); err != nil {
+		t.Fatal(err.Error())
+	}
+	if opts := metricQueryOptions(metric); opts.PathLabel != "request_path" {
+		t.Errorf("Got path label %s wanted %s", opts.PathLabel, "request_path")
+	}
+
+	metric.ExcludedPaths = []string{"/healthz("}
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted invalid excluded path")
+	}
+
+	metric.ExcludedPaths = []string{"/healthz"}
+	metric.PathLabel = "request-path"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted invalid path label")
+	}
+
+	metric.PathLabel = ""
+	metric.Name = "error-rate"
+	metric.Query = "sum(rate(errors[1m]))"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted excluded paths with a custom query")
+	}
+}
+
+func TestScheduler_ValidateAnalysisLabels(t *testing.T) {
+	cd := newTestCanary()
+	cd.Spec.CanaryAnalysis.Metrics[0].Labels = map[string]string{"container": "app"}
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cd.Spec.CanaryAnalysis.Metrics[0].Labels = map[string]string{"0container": "app"}
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted invalid label name")
+	}
+}
+
+func TestScheduler_ValidateBurnRate(t *testing.T) {
+	metric := v1alpha3.CanaryMetric{
+		Name:      "istio_requests_total",
+		Threshold: 14.4,
+		BurnRate: &v1alpha3.CanaryBurnRate{
+			Objective:   99.9,
+			LongWindow:  "1h",
+			ShortWindow: "5m",
+		},
+	}
+	if err := validateMetric(metric); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	metric.BurnRate.ShortWindow = "2h"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted short window longer than the long window")
+	}
+
+	metric.BurnRate.ShortWindow = "5m"
+	metric.BurnRate.Objective = 100
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted invalid objective")
+	}
+
+	metric.BurnRate.Objective = 99.9
+	metric.Name = "istio_request_duration_seconds_bucket"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted burn rate not supported")
+	}
+}
+
+func TestScheduler_ValidateQueryOffset(t *testing.T) {
+	metric := v1alpha3.CanaryMetric{
+		Name:        "istio_requests_total",
+		Interval:    "5m",
+		Threshold:   99,
+		QueryOffset: "1m",
+	}
+	if err := validateMetric(metric); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	metric.QueryOffset = "5m"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted offset longer than the interval")
+	}
+
+	metric.QueryOffset = "1m"
+	metric.Query = "sum(rate(http_requests_total[5m]))"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted offset not supported for custom queries")
+	}
+}
+
+func TestScheduler_EvaluationWindows(t *testing.T) {
+	cd := newTestCanary()
+	metric := v1alpha3.CanaryMetric{
+		Name:              "istio_requests_total",
+		Threshold:         98,
+		EvaluationWindows: 3,
+	}
+
+	var check metricCheck
+	for _, val := range []float64{100, 100, 97} {
+		v := val
+		check = smoothCheck(cd, metric, metricCheck{name: metric.Name, threshold: metric.Threshold, value: &v})
+	}
+	// (100 + 2*100 + 3*97) / 6
+	if !check.passed || *check.value != 98.5 {
+		t.Errorf("Got passed %v value %v wanted passed with value %v", check.passed, *check.value, 98.5)
+	}
+
+	v := 97.0
+	check = smoothCheck(cd, metric, metricCheck{name: metric.Name, threshold: metric.Threshold, value: &v})
+	// (100 + 2*97 + 3*97) / 6
+	if check.passed || *check.value != 97.5 {
+		t.Errorf("Got passed %v value %v wanted failed with value %v", check.passed, *check.value, 97.5)
+	}
+	if len(cd.Status.MetricWindows) != 1 || len(cd.Status.MetricWindows[0].Values) != 3 {
+		t.Errorf("Got metric windows %v wanted the last 3 values", cd.Status.MetricWindows)
+	}
+
+	metric.BurnRate = &v1alpha3.CanaryBurnRate{Objective: 99.9, LongWindow: "1h", ShortWindow: "5m"}
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted evaluation windows not supported with a burn rate")
+	}
+}
+
+func TestScheduler_BurnRate(t *testing.T) {
+	mocks := SetupMocks(false)
+	mocks.canary.Spec.CanaryAnalysis.Metrics = []v1alpha3.CanaryMetric{
+		{
+			Name:      "istio_requests_total",
+			Threshold: 14.4,
+			BurnRate: &v1alpha3.CanaryBurnRate{
+				Objective:   99.9,
+				LongWindow:  "1h",
+				ShortWindow: "5m",
+			},
+		},
+	}
+
+	check := mocks.ctrl.checkMetric(mocks.canary, mocks.canary.Spec.CanaryAnalysis.Metrics[0])
+	if !check.passed {
+		t.Errorf("Got failed check %s wanted passed", check.message)
+	}
+}
+
+func TestScheduler_MetricMinRequests(t *testing.T) {
+	mocks := SetupMocks(false)
+	metric := v1alpha3.CanaryMetric{
+		Name:        "istio_requests_total",
+		Threshold:   101,
+		MinRequests: 1000,
+	}
+
+	// the fake metrics server returns 100 requests
+	check := mocks.ctrl.checkMetric(mocks.canary, metric)
+	if !check.passed || !check.skipped {
+		t.Errorf("Got passed %v skipped %v wanted the metric skipped below the requests floor", check.passed, check.skipped)
+	}
+
+	metric.MinRequests = 10
+	check = mocks.ctrl.checkMetric(mocks.canary, metric)
+	if check.passed || check.skipped {
+		t.Errorf("Got passed %v skipped %v wanted the metric evaluated above the requests floor", check.passed, check.skipped)
+	}
+
+	metric.Name = "istio_request_duration_seconds_bucket"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted minRequests not supported error")
+	}
+}
+
+func TestScheduler_SkippedMetricEvent(t *testing.T) {
+	mocks := SetupMocks(false)
+	recorder := record.NewFakeRecorder(10)
+	mocks.ctrl.eventRecorder = recorder
+	mocks.canary.Spec.CanaryAnalysis.Webhooks = nil
+	mocks.canary.Spec.CanaryAnalysis.Metrics = []v1alpha3.CanaryMetric{
+		{
+			Name:        "istio_requests_total",
+			Threshold:   99,
+			MinRequests: 1000,
+		},
+	}
+
+	// the fake metrics server returns 100 requests
+	result := mocks.ctrl.analyseCanary(mocks.canary)
+	if !result.passed || !result.checks[0].skipped {
+		t.Fatalf("Got %s wanted the metric skipped", result.Summary())
+	}
+
+	var found bool
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, "istio_requests_total, 100 samples observed, 1000 required") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Got no event reporting the observed and required samples")
+	}
+}
+
+func TestScheduler_DegradedStepDown(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis and advance to 30%
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cd.Status.CanaryWeight != 30 {
+		t.Fatalf("Got canary weight %v wanted %v", cd.Status.CanaryWeight, 30)
+	}
+
+	// the fake metrics server returns 100 which is within the degraded band
+	cd.Spec.CanaryAnalysis.Metrics = append(cd.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:         "errors",
+		Query:        "sum(errors)",
+		Threshold:    98,
+		DegradedBand: 5,
+	})
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, weight := range []int{20, 10} {
+		mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+		c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if c.Status.CanaryWeight != weight {
+			t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, weight)
+		}
+		if c.Status.FailedChecks != 0 {
+			t.Errorf("Got failed checks %v wanted %v", c.Status.FailedChecks, 0)
+		}
+	}
+
+	// the canary is not stepped down below the first step
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != 10 {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 10)
+	}
+	if c.Status.FailedChecks != 1 {
+		t.Errorf("Got failed checks %v wanted %v", c.Status.FailedChecks, 1)
+	}
+
+	// a value outside of the band fails the check
+	check := mocks.ctrl.checkMetric(c, v1alpha3.CanaryMetric{Name: "errors", Query: "sum(errors)", Threshold: 90, DegradedBand: 5})
+	if check.passed || check.degraded {
+		t.Errorf("Got passed %v degraded %v wanted the check failed", check.passed, check.degraded)
+	}
+}
+
+func TestScheduler_WarnThreshold(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the fake metrics server returns 100 which is between the warning and the hard thresholds
+	cd.Spec.CanaryAnalysis.Metrics = append(cd.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:          "errors",
+		Query:         "sum(errors)",
+		Threshold:     150,
+		WarnThreshold: 50,
+	})
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+	if c.Status.FailedChecks != 0 || c.Status.HaltedIntervals != 1 {
+		t.Errorf("Got failed checks %v halted intervals %v wanted %v %v", c.Status.FailedChecks, c.Status.HaltedIntervals, 0, 1)
+	}
+
+	// the warning threshold must be crossed before the hard threshold
+	metric := v1alpha3.CanaryMetric{Name: "istio_requests_total", Threshold: 99, WarnThreshold: 98}
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted warnThreshold above the threshold error")
+	}
+	metric.WarnThreshold = 99.5
+	if err := validateMetric(metric); err != nil {
+		t.Errorf("Got error %v wanted a valid warning threshold", err)
+	}
+}
+
+func TestScheduler_MetricRatio(t *testing.T) {
+	samples := "1000"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		val := samples
+		if strings.Contains(r.URL.Query().Get("query"), "completed") {
+			val = "30"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"` + val + `"]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	mocks.ctrl.observer = CanaryObserver{metricsServer: ts.URL}
+	metric := v1alpha3.CanaryMetric{
+		Name:      "conversion",
+		Threshold: 5,
+		Ratio: &v1alpha3.CanaryRatio{
+			Numerator:   `sum(increase(checkouts_completed_total{app="podinfo-canary"}[5m]))`,
+			Denominator: `sum(increase(checkouts_started_total{app="podinfo-canary"}[5m]))`,
+			MinSamples:  100,
+		},
+	}
+
+	// 30 conversions out of 1000 checkouts
+	check := mocks.ctrl.checkMetric(mocks.canary, metric)
+	if check.passed || check.value == nil || *check.value != 3 {
+		t.Errorf("Got passed %v value %v wanted the 3%% conversion rate below the threshold", check.passed, check.value)
+	}
+
+	metric.Threshold = 2
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); !check.passed {
+		t.Errorf("Got %s wanted the conversion rate above the threshold", check.message)
+	}
+
+	// a low sample window doesn't halt the advancement
+	samples = "50"
+	metric.Threshold = 99
+	check = mocks.ctrl.checkMetric(mocks.canary, metric)
+	if !check.passed || !check.skipped {
+		t.Errorf("Got passed %v skipped %v wanted the metric skipped below the sample size", check.passed, check.skipped)
+	}
+
+	metric.Query = "sum(checkouts)"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted ratio can't be used with a query error")
+	}
+}
+
+func TestScheduler_LatencyDelta(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		val := "0.25"
+		if strings.Contains(r.URL.Query().Get("query"), "podinfo-primary") {
+			val = "0.2"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"` + val + `"]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	mocks.ctrl.observer = CanaryObserver{metricsServer: ts.URL}
+	metric := v1alpha3.CanaryMetric{
+		Name:         "istio_request_duration_seconds_bucket",
+		Interval:     "1m",
+		LatencyDelta: &v1alpha3.CanaryLatencyDelta{MaxDelta: 40},
+	}
+
+	// the canary is 50ms slower than the primary
+	check := mocks.ctrl.checkMetric(mocks.canary, metric)
+	if check.passed || check.value == nil || math.Abs(*check.value-50) > 0.001 {
+		t.Errorf("Got passed %v value %v wanted the 50ms delta above the max delta", check.passed, check.value)
+	}
+
+	metric.LatencyDelta = &v1alpha3.CanaryLatencyDelta{MaxDelta: 100}
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); !check.passed {
+		t.Errorf("Got %s wanted the delta below the max delta", check.message)
+	}
+
+	// the canary is 25% slower than the primary
+	metric.LatencyDelta = &v1alpha3.CanaryLatencyDelta{MaxDeltaPercent: 20}
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); check.passed {
+		t.Errorf("Got passed wanted the delta above the max delta percentage")
+	}
+
+	metric.LatencyDelta = &v1alpha3.CanaryLatencyDelta{}
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted max delta required error")
+	}
+
+	metric.Name = "istio_requests_total"
+	metric.LatencyDelta = &v1alpha3.CanaryLatencyDelta{MaxDelta: 100}
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted latency delta not supported error")
+	}
+}
+
+func TestScheduler_Resource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"92"]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	mocks.ctrl.observer = CanaryObserver{metricsServer: ts.URL}
+	metric := v1alpha3.CanaryMetric{
+		Name:      "memory-saturation",
+		Interval:  "1m",
+		Threshold: 90,
+		Resource:  &v1alpha3.CanaryResource{Name: "memory"},
+	}
+
+	check := mocks.ctrl.checkMetric(mocks.canary, metric)
+	if check.passed || !strings.Contains(check.message, "memory saturation 92.00% > 90%") {
+		t.Errorf("Got passed %v message %s wanted the saturation above the threshold", check.passed, check.message)
+	}
+
+	metric.Threshold = 95
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); !check.passed {
+		t.Errorf("Got %s wanted the saturation below the threshold", check.message)
+	}
+
+	metric.Resource = &v1alpha3.CanaryResource{Name: "disk"}
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted resource name error")
+	}
+
+	metric.Resource = &v1alpha3.CanaryResource{Name: "cpu", Reference: "requests"}
+	metric.Query = "sum(up)"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted resource with query error")
+	}
+}
+
+func TestScheduler_NoValuesPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	mocks.ctrl.observer = CanaryObserver{metricsServer: ts.URL}
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.CanaryAnalysis.Webhooks = nil
+	cd.Spec.CanaryAnalysis.Metrics = []v1alpha3.CanaryMetric{
+		{
+			Name:      "istio_requests_total",
+			Threshold: 99,
+		},
+		{
+			Name:      "istio_request_duration_seconds_bucket",
+			Threshold: 500,
+		},
+	}
+
+	// the default policy halts the advancement
+	result := mocks.ctrl.analyseCanary(cd)
+	if result.passed || result.abort || result.reason != v1alpha3.RollbackNoTraffic {
+		t.Errorf("Got passed %v abort %v reason %s wanted a failed check without traffic", result.passed, result.abort, result.reason)
+	}
+
+	cd.Spec.CanaryAnalysis.NoValuesPolicy = v1alpha3.NoValuesPass
+	result = mocks.ctrl.analyseCanary(cd)
+	if !result.passed || !result.checks[0].skipped || !result.checks[1].skipped {
+		t.Errorf("Got %s wanted the metrics skipped", result.Summary())
+	}
+
+	// the fail policy halts the advancement until the timeout
+	cd.Spec.CanaryAnalysis.NoValuesPolicy = v1alpha3.NoValuesFail
+	cd.Spec.CanaryAnalysis.NoValuesTimeout = "5m"
+	result = mocks.ctrl.analyseCanary(cd)
+	if result.passed || result.abort || result.reason != v1alpha3.RollbackNoTraffic {
+		t.Errorf("Got passed %v abort %v reason %s wanted a failed check without traffic", result.passed, result.abort, result.reason)
+	}
+	if cd.Status.NoValuesStartTime.IsZero() {
+		t.Errorf("Got no start time wanted the time the values went missing")
+	}
+	cd.Status.NoValuesStartTime = metav1.NewTime(time.Now().Add(-6 * time.Minute))
+	result = mocks.ctrl.analyseCanary(cd)
+	if result.passed || !result.abort || result.reason != v1alpha3.RollbackNoTraffic {
+		t.Errorf("Got passed %v abort %v reason %s wanted the canary aborted", result.passed, result.abort, result.reason)
+	}
+
+	cd.Spec.CanaryAnalysis.Threshold = 5
+	cd.Status.FailedChecks = 1
+	if failedChecks := abortFailedChecks(cd); failedChecks != 5 {
+		t.Errorf("Got failed checks %v wanted %v", failedChecks, 5)
+	}
+	cd.Spec.CanaryAnalysis.ThresholdPercent = 20
+	cd.Status.AnalysedIntervals = 8
+	if failedChecks := abortFailedChecks(cd); failedChecks != 8 {
+		t.Errorf("Got failed checks %v wanted %v", failedChecks, 8)
+	}
+
+	cd.Spec.CanaryAnalysis.ThresholdPercent = 0
+	if err := validateAnalysis(cd); err != nil {
+		t.Errorf("Got error %v wanted a valid noValuesPolicy", err)
+	}
+	cd.Spec.CanaryAnalysis.NoValuesPolicy = "ignore"
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted invalid noValuesPolicy")
+	}
+}
+
+func TestScheduler_MetricsStaleness(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1545905245.596,"300"]}]}}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	mocks.ctrl.observer = CanaryObserver{metricsServer: ts.URL}
+	mocks.ctrl.metricsStaleness = 10 * time.Minute
+
+	// the latest sample is 5m old
+	if result := mocks.ctrl.analyseCanary(mocks.canary); result.waiting {
+		t.Errorf("Got waiting analysis wanted the metrics evaluated within the controller tolerance")
+	}
+
+	mocks.canary.Spec.CanaryAnalysis.MetricsStaleness = "2m"
+	if result := mocks.ctrl.analyseCanary(mocks.canary); !result.waiting {
+		t.Errorf("Got %s wanted the analysis held on stale metrics", result.Summary())
+	}
+}
+
+func TestScheduler_ReadinessGate(t *testing.T) {
+	healthy := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{Name: "app-health", URL: ts.URL, Type: v1alpha3.ReadinessGateHook},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// hold on the failing readiness gate
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.ReadinessGateFailureTime.IsZero() {
+		t.Errorf("Got no readiness gate failure time wanted the failure recorded")
+	}
+	if c.Status.CanaryWeight != 0 || c.Status.HaltedIntervals != 1 {
+		t.Errorf("Got canary weight %v halted intervals %v wanted %v %v", c.Status.CanaryWeight, c.Status.HaltedIntervals, 0, 1)
+	}
+
+	// advance once the app reports healthy
+	healthy = true
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !c.Status.ReadinessGateFailureTime.IsZero() {
+		t.Errorf("Got readiness gate failure time %v wanted the failure reset", c.Status.ReadinessGateFailureTime)
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// roll back when the gate fails for longer than the progress deadline
+	healthy = false
+	c.Status.ReadinessGateFailureTime = metav1.NewTime(time.Now().Add(-time.Hour))
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").UpdateStatus(c); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary phase %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+}
+
+func TestScheduler_Silence(t *testing.T) {
+	var deleted bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = true
+			return
+		}
+		w.Write([]byte(`{"silenceID":"s-1"}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	mocks.ctrl.silencer = NewSilencer(ts.URL)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Silence = &v1alpha3.CanarySilence{Matchers: map[string]string{"severity": "page"}}
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes and silence the canary alerts
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.SilenceID != "s-1" {
+		t.Errorf("Got silence ID %s wanted %s", c.Status.SilenceID, "s-1")
+	}
+
+	// roll back and expire the silence
+	c.Status.FailedChecks = c.Spec.CanaryAnalysis.Threshold
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").UpdateStatus(c); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary phase %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+	if !deleted || c.Status.SilenceID != "" {
+		t.Errorf("Got deleted %v silence ID %s wanted the silence expired", deleted, c.Status.SilenceID)
+	}
+}
+
+func TestScheduler_Approval(t *testing.T) {
+	decision := v1alpha3.ApprovalPending
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Write([]byte(`{"id":"cr-1"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"cr-1","status":"` + string(decision) + `"}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{Name: "change-request", URL: ts.URL, Type: v1alpha3.ApprovalHook},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// register approval
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// wait for approval
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Approvals == nil || (*c.Status.Approvals)["change-request"] != "cr-1" {
+		t.Errorf("Got approvals %v wanted change-request cr-1", c.Status.Approvals)
+	}
+	if c.Status.CanaryWeight != 0 {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 0)
+	}
+
+	// approve
+	decision = v1alpha3.ApprovalApproved
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// reject
+	decision = v1alpha3.ApprovalRejected
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+	if c.Status.Approvals != nil {
+		t.Errorf("Got approvals %v wanted none after rollback", *c.Status.Approvals)
+	}
+	if c.Status.RollbackReason != v1alpha3.RollbackExternalAbort {
+		t.Errorf("Got rollback reason %s wanted %s", c.Status.RollbackReason, v1alpha3.RollbackExternalAbort)
+	}
+}
+
+func TestScheduler_AllowedWindow(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// advance
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// close the window
+	now := time.Now().UTC()
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.AllowedWindow = &v1alpha3.CanaryAllowedWindow{
+		Start: now.Add(time.Hour).Format("15:04"),
+		End:   now.Add(2 * time.Hour).Format("15:04"),
+	}
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// suspend
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.WindowSuspendTime.IsZero() {
+		t.Errorf("Got no window suspend time wanted the analysis suspended")
+	}
+	if c.Status.Phase != v1alpha3.CanaryProgressing || c.Status.CanaryWeight != 10 {
+		t.Errorf("Got phase %v weight %v wanted %v %v", c.Status.Phase, c.Status.CanaryWeight, v1alpha3.CanaryProgressing, 10)
+	}
+
+	// open the window
+	c.Spec.CanaryAnalysis.AllowedWindow = &v1alpha3.CanaryAllowedWindow{
+		Start: now.Add(-time.Hour).Format("15:04"),
+		End:   now.Add(time.Hour).Format("15:04"),
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(c)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// resume from the same weight
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !c.Status.WindowSuspendTime.IsZero() {
+		t.Errorf("Got window suspend time %v wanted the analysis resumed", c.Status.WindowSuspendTime)
+	}
+	if c.Status.CanaryWeight != 20 {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 20)
+	}
+}
+
+func TestScheduler_ApprovalExpiry(t *testing.T) {
+	decision := v1alpha3.ApprovalApproved
+	var registrations int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			registrations++
+			w.Write([]byte(fmt.Sprintf(`{"id":"cr-%v"}`, registrations)))
+			return
+		}
+		w.Write([]byte(`{"status":"` + string(decision) + `"}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{Name: "change-request", URL: ts.URL, Type: v1alpha3.ApprovalHook, ApprovalExpiry: "1h"},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// register approval
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// approved
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.ApprovalTimes == nil {
+		t.Fatalf("Got approval times %v wanted change-request approval time", c.Status.ApprovalTimes)
+	}
+	if approvedAt := (*c.Status.ApprovalTimes)["change-request"]; approvedAt.IsZero() {
+		t.Fatalf("Got approval times %v wanted change-request approval time", c.Status.ApprovalTimes)
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// expire the approval
+	(*c.Status.ApprovalTimes)["change-request"] = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").UpdateStatus(c)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	decision = v1alpha3.ApprovalPending
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if registrations != 2 || (*c.Status.Approvals)["change-request"] != "cr-2" {
+		t.Errorf("Got approvals %v wanted change-request cr-2", *c.Status.Approvals)
+	}
+	if _, ok := (*c.Status.ApprovalTimes)["change-request"]; ok {
+		t.Errorf("Got approval times %v wanted none for the new approval", *c.Status.ApprovalTimes)
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	// the new approval is pending
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+}
+
+func TestScheduler_PromotionHook(t *testing.T) {
+	status := v1alpha3.PromotionPending
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"status":"` + string(status) + `","message":"waiting for the git sync"}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{Name: "gitops", URL: ts.URL, Type: v1alpha3.PromotionHook},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	err = mocks.router.SetRoutes(mocks.canary, 60, 40)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// advance to the max weight, the promotion is left to the webhook
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	if calls != 0 {
+		t.Errorf("Got %v promotion calls wanted %v", calls, 0)
+	}
+
+	primaryDep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryDep.Spec.Template.Spec.Containers[0].Image == dep2.Spec.Template.Spec.Containers[0].Image {
+		t.Errorf("Got primary image %v wanted the canary spec not copied", primaryDep.Spec.Template.Spec.Containers[0].Image)
+	}
+
+	// wait for the promotion
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	if calls != 1 {
+		t.Errorf("Got %v promotion calls wanted %v", calls, 1)
+	}
+
+	_, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if canaryWeight != 50 {
+		t.Errorf("Got canary route %v wanted %v", canaryWeight, 50)
+	}
+
+	// promoted
+	status = v1alpha3.PromotionPromoted
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	primaryWeight, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight != 100 || canaryWeight != 0 {
+		t.Errorf("Got routes %v/%v wanted %v/%v", primaryWeight, canaryWeight, 100, 0)
+	}
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanarySucceeded {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanarySucceeded)
+	}
+}
+
+func TestScheduler_RegionHealth(t *testing.T) {
+	unhealthy := `["eu-west-1"]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"unhealthy":` + unhealthy + `}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.RegionLabel = "region"
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{Name: "region-health", URL: ts.URL, Type: v1alpha3.RegionHealthHook},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// analyse with the unhealthy region excluded
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(c.Status.ExcludedRegions) != 1 || c.Status.ExcludedRegions[0] != "eu-west-1" {
+		t.Errorf("Got excluded regions %v wanted %v", c.Status.ExcludedRegions, []string{"eu-west-1"})
+	}
+	if c.Status.CanaryWeight != 2*c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 2*c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	opts := canaryQueryOptions(c, c.Spec.CanaryAnalysis.Metrics[0])
+	if opts.ExcludeLabel != "region" || len(opts.ExcludeValues) != 1 {
+		t.Errorf("Got exclude label %s values %v wanted region eu-west-1", opts.ExcludeLabel, opts.ExcludeValues)
+	}
+
+	// the region recovered
+	unhealthy = `[]`
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(c.Status.ExcludedRegions) != 0 {
+		t.Errorf("Got excluded regions %v wanted none", c.Status.ExcludedRegions)
+	}
+}
+
+func TestScheduler_AdvanceHistory(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// advance twice
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(c.Status.History) != 2 {
+		t.Fatalf("Got %v history entries wanted %v", len(c.Status.History), 2)
+	}
+	last := c.Status.History[1]
+	if last.CanaryWeight != 2*c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", last.CanaryWeight, 2*c.Spec.CanaryAnalysis.StepWeight)
+	}
+	if last.StepWeight != c.Spec.CanaryAnalysis.StepWeight || last.TargetWeight != c.Spec.CanaryAnalysis.MaxWeight {
+		t.Errorf("Got step weight %v target weight %v wanted %v %v", last.StepWeight, last.TargetWeight,
+			c.Spec.CanaryAnalysis.StepWeight, c.Spec.CanaryAnalysis.MaxWeight)
+	}
+	if last.Iteration != 2 {
+		t.Errorf("Got iteration %v wanted %v", last.Iteration, 2)
+	}
+	if !strings.HasPrefix(last.Message, "Advance podinfo.default canary weight") {
+		t.Errorf("Got message %s wanted advance canary weight", last.Message)
+	}
+
+	// the history keeps only the last entries
+	for i := 0; i < statusHistoryLimit; i++ {
+		appendHistory(c, v1alpha3.CanaryAdvance{CanaryWeight: i})
+	}
+	if len(c.Status.History) != statusHistoryLimit {
+		t.Errorf("Got %v history entries wanted %v", len(c.Status.History), statusHistoryLimit)
+	}
+	if w := c.Status.History[statusHistoryLimit-1].CanaryWeight; w != statusHistoryLimit-1 {
+		t.Errorf("Got last canary weight %v wanted %v", w, statusHistoryLimit-1)
+	}
+}
+
+func TestScheduler_PreRolloutWaitForTraffic(t *testing.T) {
+	running := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		if running {
+			w.Write([]byte(`{"canary":"podinfo.default","running":true}`))
+			return
+		}
+		w.Write([]byte(`{"canary":"podinfo.default","running":false}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{
+			Name:           "load-test",
+			URL:            ts.URL,
+			Type:           v1alpha3.PreRolloutHook,
+			WaitForTraffic: true,
+			Metadata:       &map[string]string{"cmd": "hey -z 1m http://podinfo-canary.default:9898/"},
+		},
+	}
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// wait for the load test
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != 0 {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 0)
+	}
+	if c.Status.FailedChecks != 0 {
+		t.Errorf("Got failed checks %v wanted %v", c.Status.FailedChecks, 0)
+	}
+
+	// traffic is flowing
+	running = true
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.CanaryWeight != c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, c.Spec.CanaryAnalysis.StepWeight)
+	}
+}
+
+func TestScheduler_ValidateLogs(t *testing.T) {
+	metric := v1alpha3.CanaryMetric{
+		Name:      "error-logs",
+		Threshold: 5,
+		Logs:      &v1alpha3.CanaryLogs{Pattern: "(?i)error|panic"},
+	}
+	if err := validateMetric(metric); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	metric.Logs.Pattern = "(error"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted invalid pattern")
+	}
+
+	metric.Logs.Pattern = "error"
+	metric.Query = "sum(up)"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted logs with a custom query")
+	}
+}
+
+func TestScheduler_Progress(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// advance twice
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// weight 20 out of max weight 50
+	if c.Status.Progress != 40 {
+		t.Errorf("Got progress %v wanted %v", c.Status.Progress, 40)
+	}
+
+	// A/B testing progress is based on iterations
+	c.Spec.CanaryAnalysis.Match = newTestCanaryAB().Spec.CanaryAnalysis.Match
+	c.Spec.CanaryAnalysis.Iterations = 4
+	c.Status.Iterations = 1
+	if p := c.GetProgress(); p != 25 {
+		t.Errorf("Got progress %v wanted %v", p, 25)
+	}
+
+	c.Status.Phase = v1alpha3.CanarySucceeded
+	if p := c.GetProgress(); p != 100 {
+		t.Errorf("Got progress %v wanted %v", p, 100)
+	}
+}
+
+func TestScheduler_RetryBudget(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.RetryBudget = &v1alpha3.CanaryRetryBudget{RouteMutations: 1}
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// advance twice
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.RouteMutations != 2 {
+		t.Errorf("Got route mutations %v wanted %v", c.Status.RouteMutations, 2)
+	}
+
+	// rollback
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+	if reason := c.Annotations[v1alpha3.RollbackReasonAnnotation]; !strings.HasPrefix(reason, "Retry budget exceeded") {
+		t.Errorf("Got rollback reason %s wanted retry budget exceeded", reason)
+	}
+	if c.Status.RollbackReason != v1alpha3.RollbackRetryBudgetExceeded {
+		t.Errorf("Got rollback reason %s wanted %s", c.Status.RollbackReason, v1alpha3.RollbackRetryBudgetExceeded)
+	}
+}
+
+func TestScheduler_ValidateSchema(t *testing.T) {
+	metric := v1alpha3.CanaryMetric{
+		Name:      "istio_requests_total",
+		Threshold: 99,
+		Schema: &v1alpha3.CanaryMetricSchema{
+			MetricName:     "http_server_request_duration_seconds_count",
+			WorkloadLabel:  "k8s_deployment_name",
+			NamespaceLabel: "k8s_namespace_name",
+			CodeLabel:      "http_response_status_code",
+		},
+	}
+	if err := validateSchema(metric); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the success rate requires the response code label
+	metric.Schema.CodeLabel = ""
+	if err := validateSchema(metric); err == nil {
+		t.Errorf("Got no error wanted code label required error")
+	}
+
+	// the latency doesn't use the response code label
+	metric.Name = "istio_request_duration_seconds_bucket"
+	if err := validateSchema(metric); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	metric.Schema.WorkloadLabel = ""
+	if err := validateSchema(metric); err == nil {
+		t.Errorf("Got no error wanted workload label required error")
+	}
+
+	metric.Query = "sum(up)"
+	if err := validateSchema(metric); err == nil {
+		t.Errorf("Got no error wanted custom query error")
+	}
+}
+
+func TestScheduler_StartWeight(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.StartWeight = 25
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// first step
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	_, canaryWeight, err := mocks.router.GetRoutes(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if canaryWeight != 25 {
+		t.Errorf("Got canary weight %v wanted %v", canaryWeight, 25)
+	}
+
+	// next steps are incremented by the step weight
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	_, canaryWeight, err = mocks.router.GetRoutes(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if canaryWeight != 35 {
+		t.Errorf("Got canary weight %v wanted %v", canaryWeight, 35)
+	}
+
+	// the start weight must be below the max weight
+	cd.Spec.CanaryAnalysis.StartWeight = 50
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted start weight above max weight error")
+	}
+
+	// the start weight must be a multiple of the granularity
+	cd.Spec.CanaryAnalysis.StartWeight = 25
+	cd.Spec.CanaryAnalysis.WeightGranularity = 10
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error wanted start weight granularity error")
+	}
+}
+
+func TestScheduler_EventVerbosity(t *testing.T) {
+	mocks := SetupMocks(false)
+	recorder := record.NewFakeRecorder(10)
+	mocks.ctrl.eventRecorder = recorder
+	mocks.ctrl.eventVerbosity = EventVerbosityWarning
+
+	mocks.ctrl.recordEventInfof(mocks.canary, "Advance %s canary weight %v", "podinfo", 10)
+	mocks.ctrl.recordAdvanceEvent(mocks.canary, v1alpha3.CanaryAdvance{Message: "Advance podinfo canary weight 20"})
+	mocks.ctrl.recordEventWarningf(mocks.canary, "Halt %s advancement", "podinfo")
+
+	if len(recorder.Events) != 1 {
+		t.Fatalf("Got %v events wanted only the warning", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning") {
+		t.Errorf("Got event %s wanted the warning", event)
+	}
+
+	mocks.ctrl.eventVerbosity = EventVerbosityInfo
+	mocks.ctrl.recordEventInfof(mocks.canary, "Advance %s canary weight %v", "podinfo", 10)
+	if len(recorder.Events) != 1 {
+		t.Errorf("Got %v events wanted the info event", len(recorder.Events))
+	}
+}
+
+func TestScheduler_DependsOn(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.DependsOn = []v1alpha3.CanaryDependency{{Name: "backend"}}
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	backend := newTestCanary()
+	backend.Name = "backend"
+	backend.Status.Phase = v1alpha3.CanaryProgressing
+	mocks.ctrl.canaries.Store("backend.default", backend)
+
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	if _, err := mocks.kubeClient.AppsV1().Deployments("default").Update(dep2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// wait for the dependency rollout
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryInitialized {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryInitialized)
+	}
+
+	// a dependency leading back to the canary is a cycle
+	backend.Status.Phase = v1alpha3.CanarySucceeded
+	backend.Spec.DependsOn = []v1alpha3.CanaryDependency{{Name: "podinfo", Namespace: "default"}}
+	mocks.ctrl.canaries.Store("podinfo.default", c)
+	if err := mocks.ctrl.validateDependencies(c); err == nil {
+		t.Errorf("Got no error wanted dependsOn cycle")
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryInitialized {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryInitialized)
+	}
+
+	// start the analysis once the dependency is promoted
+	backend.Spec.DependsOn = nil
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryProgressing {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryProgressing)
+	}
+}
+
+func TestScheduler_FailedChecksDecay(t *testing.T) {
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.FailedChecksDecay = 2
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// start analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := mocks.deployer.SetStatusFailedChecks(cd, 3); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the passing intervals decay the failed checks
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.FailedChecks != 1 {
+		t.Errorf("Got failed checks %v wanted %v", c.Status.FailedChecks, 1)
+	}
+	if c.Status.CanaryWeight != 2*c.Spec.CanaryAnalysis.StepWeight {
+		t.Errorf("Got canary weight %v wanted %v", c.Status.CanaryWeight, 2*c.Spec.CanaryAnalysis.StepWeight)
+	}
+
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.FailedChecks != 0 {
+		t.Errorf("Got failed checks %v wanted %v", c.Status.FailedChecks, 0)
+	}
+}
+
+func TestScheduler_PromotionVerification(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.PromotionVerification = &v1alpha3.CanaryPromotionVerification{Duration: "1h"}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	err = mocks.router.SetRoutes(mocks.canary, 60, 40)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// advance and promote
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// route all traffic to the primary and start the verification
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// verify
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryProgressing || c.Status.VerificationStartTime.IsZero() {
+		t.Fatalf("Got phase %v verification start %v wanted the promotion under verification",
+			c.Status.Phase, c.Status.VerificationStartTime)
+	}
+	primaryWeight, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight != 100 || canaryWeight != 0 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", primaryWeight, canaryWeight, 100, 0)
+	}
+
+	// fail the verification, the fake metrics server returns 100
+	c.Spec.CanaryAnalysis.Metrics = append(c.Spec.CanaryAnalysis.Metrics, v1alpha3.CanaryMetric{
+		Name:      "errors",
+		Query:     "sum(rate(errors[1m]))",
+		Threshold: 50,
+	})
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(c)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+	if c.Status.RollbackReason != v1alpha3.RollbackVerificationFailure {
+		t.Errorf("Got rollback reason %s wanted %s", c.Status.RollbackReason, v1alpha3.RollbackVerificationFailure)
+	}
+
+	primaryDep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if image := primaryDep.Spec.Template.Spec.Containers[0].Image; image != "quay.io/stefanprodan/podinfo:1.2.0" {
+		t.Errorf("Got primary image %v wanted %v", image, "quay.io/stefanprodan/podinfo:1.2.0")
+	}
+}
+
+func TestScheduler_PrimaryWarmup(t *testing.T) {
+	warm := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !warm {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.PrimaryWarmup = &v1alpha3.CanaryPrimaryWarmup{Timeout: "1h"}
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{Name: "warmup", Type: v1alpha3.PrimaryWarmupHook, URL: ts.URL},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	err = mocks.router.SetRoutes(mocks.canary, 60, 40)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// advance and promote
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// the promoted primary rollout is not finished
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !c.Status.PrimaryWarmupStartTime.IsZero() {
+		t.Errorf("Got primary warmup start %v wanted none before the primary is ready", c.Status.PrimaryWarmupStartTime)
+	}
+
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	primary.Status.Replicas = *primary.Spec.Replicas
+	primary.Status.UpdatedReplicas = *primary.Spec.Replicas
+	primary.Status.AvailableReplicas = *primary.Spec.Replicas
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").UpdateStatus(primary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// start the warmup
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// the warmup webhook fails
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryProgressing || c.Status.PrimaryWarmupStartTime.IsZero() {
+		t.Fatalf("Got phase %v primary warmup start %v wanted the primary warming up",
+			c.Status.Phase, c.Status.PrimaryWarmupStartTime)
+	}
+	primaryWeight, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight == 100 {
+		t.Errorf("Got primary %v canary %v wanted the traffic switch held", primaryWeight, canaryWeight)
+	}
+
+	// route all traffic to the warm primary
+	warm = true
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanarySucceeded {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanarySucceeded)
+	}
+	if !c.Status.PrimaryWarmupStartTime.IsZero() {
+		t.Errorf("Got primary warmup start %v wanted the status reset", c.Status.PrimaryWarmupStartTime)
+	}
+	primaryWeight, canaryWeight, err = mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight != 100 || canaryWeight != 0 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", primaryWeight, canaryWeight, 100, 0)
+	}
+}
+
+func TestScheduler_AdaptInterval(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.CanaryAnalysis.Interval = "1m"
+	cd.Spec.CanaryAnalysis.AdaptiveInterval = &v1alpha3.CanaryAdaptiveInterval{
+		MinInterval:  "30s",
+		MaxInterval:  "3m",
+		MaxVariation: 10,
+		Samples:      3,
+	}
+	if err := validateAnalysis(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	check := func(value float64) []metricCheck {
+		return []metricCheck{{name: "istio_requests_total", value: &value, passed: true}}
+	}
+
+	// the interval is kept until enough values are collected
+	mocks.ctrl.adaptInterval(cd, check(100))
+	mocks.ctrl.adaptInterval(cd, check(50))
+	if cd.Status.AnalysisInterval != "" {
+		t.Fatalf("Got analysis interval %v wanted the fixed interval", cd.Status.AnalysisInterval)
+	}
+
+	// noisy values double the interval up to the max interval
+	mocks.ctrl.adaptInterval(cd, check(100))
+	if interval := cd.GetAnalysisInterval(); interval != 2*time.Minute {
+		t.Errorf("Got analysis interval %v wanted %v", interval, 2*time.Minute)
+	}
+	mocks.ctrl.adaptInterval(cd, check(50))
+	if interval := cd.GetAnalysisInterval(); interval != 3*time.Minute {
+		t.Errorf("Got analysis interval %v wanted %v", interval, 3*time.Minute)
+	}
+
+	// stable values halve the interval down to the min interval
+	for i := 0; i < 3; i++ {
+		mocks.ctrl.adaptInterval(cd, check(99))
+	}
+	if interval := cd.GetAnalysisInterval(); interval != 90*time.Second {
+		t.Errorf("Got analysis interval %v wanted %v", interval, 90*time.Second)
+	}
+	mocks.ctrl.adaptInterval(cd, check(99))
+	mocks.ctrl.adaptInterval(cd, check(99))
+	if interval := cd.GetAnalysisInterval(); interval != 30*time.Second {
+		t.Errorf("Got analysis interval %v wanted %v", interval, 30*time.Second)
+	}
+
+	cd.Spec.CanaryAnalysis.AdaptiveInterval.MinInterval = "5m"
+	if err := validateAnalysis(cd); err == nil {
+		t.Errorf("Got no error for a min interval above the max interval")
+	}
+}
+
+func TestScheduler_RolloutID(t *testing.T) {
+	var rolloutIDs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload v1alpha3.CanaryWebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rolloutIDs = append(rolloutIDs, payload.RolloutID)
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	// init
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cd.Status.RolloutID != "" {
+		t.Errorf("Got rollout ID %s wanted none before the analysis", cd.Status.RolloutID)
+	}
+	cd.Spec.CanaryAnalysis.Webhooks = []v1alpha3.CanaryWebhook{
+		{Name: "acceptance-test", URL: ts.URL},
+	}
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// update
+	dep2 := newTestDeploymentV2()
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// detect changes
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rolloutID := c.Status.RolloutID
+	if rolloutID == "" {
+		t.Fatalf("Got no rollout ID wanted one generated when the analysis starts")
+	}
+
+	// progressing
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	// the rollout webhooks run once the canary receives traffic
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	if len(rolloutIDs) == 0 || rolloutIDs[len(rolloutIDs)-1] != rolloutID {
+		t.Errorf("Got webhook rollout IDs %v wanted %s", rolloutIDs, rolloutID)
+	}
+
+	// restart the analysis with a new revision
+	dep3 := newTestDeploymentV2()
+	dep3.Spec.Template.Spec.Containers[0].Image = "quay.io/stefanprodan/podinfo:1.4.2"
+	_, err = mocks.kubeClient.AppsV1().Deployments("default").Update(dep3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.RolloutID == "" || c.Status.RolloutID == rolloutID {
+		t.Errorf("Got rollout ID %s wanted a new ID after the restart", c.Status.RolloutID)
+	}
+}
+
+func TestScheduler_InitializationAnalyze(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.InitializationMode = v1alpha3.InitializationAnalyze
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// init and start the analysis of the first revision
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *primary.Spec.Replicas != 0 {
+		t.Errorf("Got primary replicas %v wanted %v", *primary.Spec.Replicas, 0)
+	}
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryProgressing || !c.Status.InitialAnalysis {
+		t.Errorf("Got canary phase %v initial analysis %v wanted %v", c.Status.Phase, c.Status.InitialAnalysis, v1alpha3.CanaryProgressing)
+	}
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *dep.Spec.Replicas != 1 {
+		t.Errorf("Got canary replicas %v wanted %v", *dep.Spec.Replicas, 1)
+	}
+
+	// the canary serves all the traffic during the analysis
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	primaryWeight, canaryWeight, err := mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight != 0 || canaryWeight != 100 {
+		t.Errorf("Got weights %v/%v wanted %v/%v", primaryWeight, canaryWeight, 0, 100)
+	}
+
+	// analyse until the first revision is promoted
+	for i := 0; i < 20 && c.Status.Phase != v1alpha3.CanarySucceeded; i++ {
+		mocks.ctrl.advanceCanary("podinfo", "default", true)
+		c, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if c.Status.Phase != v1alpha3.CanarySucceeded {
+		t.Fatalf("Got canary phase %v wanted %v", c.Status.Phase, v1alpha3.CanarySucceeded)
+	}
+	if c.Status.InitialAnalysis {
+		t.Errorf("Got initial analysis after the promotion of the first revision")
+	}
+
+	primary, err = mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *primary.Spec.Replicas != 1 {
+		t.Errorf("Got primary replicas %v wanted %v", *primary.Spec.Replicas, 1)
+	}
+	primaryWeight, canaryWeight, err = mocks.router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight != 100 || canaryWeight != 0 {
+		t.Errorf("Got weights %v/%v wanted %v/%v", primaryWeight, canaryWeight, 100, 0)
+	}
+
+	c.Spec.CanaryAnalysis.InitializationMode = "skip"
+	if err := validateAnalysis(c); err == nil {
+		t.Errorf("Got no error wanted invalid initialization mode")
+	}
+}
+
+func TestScheduler_InitializationAnalyzeRollback(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.InitializationMode = v1alpha3.InitializationAnalyze
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// init and route the traffic to the first revision
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	// update failed checks to max
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	status := cd.Status.DeepCopy()
+	status.FailedChecks = 11
+	if err := mocks.deployer.SyncStatus(cd, *status); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// rollback
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryFailed {
+		t.Fatalf("Got canary phase %v wanted %v", c.Status.Phase, v1alpha3.CanaryFailed)
+	}
+	if !c.Status.InitialAnalysis {
+		t.Errorf("Got no initial analysis wanted the next revision to be analysed as the first one")
+	}
+
+	// the failed revision is never promoted and keeps serving the traffic
+	primary, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo-primary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *primary.Spec.Replicas != 0 {
+		t.Errorf("Got primary replicas %v wanted %v", *primary.Spec.Replicas, 0)
+	}
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *dep.Spec.Replicas == 0 {
+		t.Errorf("Got canary replicas %v wanted the canary to keep its replicas", *dep.Spec.Replicas)
+	}
+	primaryWeight, canaryWeight, err := mocks.router.GetRoutes(c)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight != 0 || canaryWeight != 100 {
+		t.Errorf("Got routes %v/%v wanted %v/%v", primaryWeight, canaryWeight, 0, 100)
+	}
+
+	c.Spec.TargetRef.Kind = "Service"
+	c.Spec.TargetRef.APIVersion = "serving.knative.dev/v1"
+	if err := validateAnalysis(c); err == nil {
+		t.Errorf("Got no error wanted initialization mode not supported for Knative services")
+	}
+}
+
+func TestScheduler_InitializationAnalyzeSuspend(t *testing.T) {
+	mocks := SetupMocks(false)
+	cd, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.CanaryAnalysis.InitializationMode = v1alpha3.InitializationAnalyze
+	_, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// init and route the traffic to the first revision
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	cd, err = mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cd.Spec.Suspend = true
+	if _, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Update(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// cancel
+	mocks.ctrl.advanceCanary("podinfo", "default", true)
+
+	c, err := mocks.flaggerClient.FlaggerV1alpha3().Canaries("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Status.Phase != v1alpha3.CanaryCancelled {
+		t.Errorf("Got canary state %v wanted %v", c.Status.Phase, v1alpha3.CanaryCancelled)
+	}
+	if !c.Status.InitialAnalysis {
+		t.Errorf("Got no initial analysis wanted the first revision to be analysed on resume")
+	}
+
+	primaryWeight, canaryWeight, err := mocks.router.GetRoutes(c)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if primaryWeight != 0 || canaryWeight != 100 {
+		t.Errorf("Got routes %v/%v wanted %v/%v", primaryWeight, canaryWeight, 0, 100)
+	}
+	dep, err := mocks.kubeClient.AppsV1().Deployments("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *dep.Spec.Replicas == 0 {
+		t.Errorf("Got canary replicas %v wanted the canary to keep its replicas", *dep.Spec.Replicas)
+	}
+}
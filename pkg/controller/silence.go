@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Silencer is used to create and expire silences with the Alertmanager v2 API
+type Silencer struct {
+	alertmanagerURL string
+}
+
+// NewSilencer creates a silencer for the Alertmanager address,
+// an empty address disables the silences
+func NewSilencer(alertmanagerURL string) Silencer {
+	return Silencer{
+		alertmanagerURL: strings.TrimSuffix(alertmanagerURL, "/"),
+	}
+}
+
+type silenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+}
+
+type silence struct {
+	Matchers  []silenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+}
+
+type silenceResponse struct {
+	SilenceID string `json:"silenceID"`
+}
+
+// Enabled returns true if the Alertmanager address is set
+func (s *Silencer) Enabled() bool {
+	return s.alertmanagerURL != ""
+}
+
+// CreateSilence silences the alerts matching the labels for the given duration and returns the silence ID
+func (s *Silencer) CreateSilence(matchers map[string]string, duration time.Duration, comment string) (string, error) {
+	names := make([]string, 0, len(matchers))
+	for name := range matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now().UTC()
+	payload := silence{
+		StartsAt:  now,
+		EndsAt:    now.Add(duration),
+		CreatedBy: "flagger",
+		Comment:   comment,
+	}
+	for _, name := range names {
+		payload.Matchers = append(payload.Matchers, silenceMatcher{Name: name, Value: matchers[name]})
+	}
+
+	payloadBin, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := doWebhook("POST", s.alertmanagerURL+"/api/v2/silences", bytes.NewBuffer(payloadBin), "", nil)
+	if err != nil {
+		return "", fmt.Errorf("silence creation failed %v", err)
+	}
+
+	var res silenceResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return "", fmt.Errorf("error decoding silence response: %s", err.Error())
+	}
+	if res.SilenceID == "" {
+		return "", fmt.Errorf("silence ID not found in response '%s'", string(b))
+	}
+	return res.SilenceID, nil
+}
+
+// DeleteSilence expires the silence
+func (s *Silencer) DeleteSilence(id string) error {
+	if _, err := doWebhook("DELETE", fmt.Sprintf("%s/api/v2/silence/%s", s.alertmanagerURL, id), nil, "", nil); err != nil {
+		return fmt.Errorf("silence %s deletion failed %v", id, err)
+	}
+	return nil
+}
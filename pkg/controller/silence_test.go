@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSilencer_CreateDeleteSilence(t *testing.T) {
+	var payload silence
+	var deleted string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/v2/silences":
+			b, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &payload); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"silenceID":"s-1"}`))
+		case r.Method == "DELETE":
+			deleted = r.URL.Path
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	silencer := NewSilencer(ts.URL + "/")
+	matchers := map[string]string{"service": "podinfo", "namespace": "default"}
+	id, err := silencer.CreateSilence(matchers, time.Hour, "Canary analysis of podinfo.default")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if id != "s-1" {
+		t.Errorf("Got silence ID %s wanted %s", id, "s-1")
+	}
+	if len(payload.Matchers) != 2 || payload.Matchers[0].Name != "namespace" || payload.Matchers[1].Value != "podinfo" {
+		t.Errorf("Got matchers %+v wanted namespace=default service=podinfo", payload.Matchers)
+	}
+	if d := payload.EndsAt.Sub(payload.StartsAt); d != time.Hour {
+		t.Errorf("Got silence duration %v wanted %v", d, time.Hour)
+	}
+
+	if err := silencer.DeleteSilence(id); err != nil {
+		t.Fatal(err.Error())
+	}
+	if deleted != "/api/v2/silence/s-1" {
+		t.Errorf("Got delete path %s wanted %s", deleted, "/api/v2/silence/s-1")
+	}
+}
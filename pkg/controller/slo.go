@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+)
+
+// SLOObserver is used to query the SLO status API of the SLO platform,
+// the API returns the remaining error budget percentage and the burn rate of an SLO e.g.
+//
+//	GET <server>/slos/<name>?project=<project>
+//	{"errorBudgetRemaining": 72.5, "burnRate": 0.8}
+type SLOObserver struct {
+	sloServer string
+}
+
+// NewSLOObserver creates an observer for the SLO platform address
+func NewSLOObserver(sloServer string) SLOObserver {
+	return SLOObserver{
+		sloServer: sloServer,
+	}
+}
+
+// GetSLOValue returns the indicator value of the SLO status,
+// a missing or null value is reported as no values found
+func (s *SLOObserver) GetSLOValue(slo *flaggerv1.CanarySLO, headers map[string]string) (float64, error) {
+	if s.sloServer == "fake" {
+		if sloIndicator(slo) == flaggerv1.SLOBurnRate {
+			return 0, nil
+		}
+		return 100, nil
+	}
+	if s.sloServer == "" {
+		return 0, fmt.Errorf("SLO server not configured")
+	}
+
+	sloURL, err := url.Parse(s.sloServer)
+	if err != nil {
+		return 0, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("./slos/%s?project=%s", url.PathEscape(slo.Name), url.QueryEscape(slo.Project)))
+	if err != nil {
+		return 0, err
+	}
+
+	u = sloURL.ResolveReference(u)
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading body: %s", err.Error())
+	}
+
+	if 400 <= r.StatusCode {
+		return 0, fmt.Errorf("error response: %s", string(b))
+	}
+
+	var status interface{}
+	if err := json.Unmarshal(b, &status); err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %s, '%s'", err.Error(), string(b))
+	}
+
+	field := "errorBudgetRemaining"
+	if sloIndicator(slo) == flaggerv1.SLOBurnRate {
+		field = "burnRate"
+	}
+	return graphQLValue(status, field)
+}
+
+// sloIndicator returns the SLO status value checked, defaults to the remaining error budget
+func sloIndicator(slo *flaggerv1.CanarySLO) flaggerv1.SLOIndicator {
+	if slo.Indicator == "" {
+		return flaggerv1.SLOErrorBudget
+	}
+	return slo.Indicator
+}
+
+// checkSLO compares the remaining error budget or the burn rate of the SLO to the threshold
+func (c *Controller) checkSLO(r *flaggerv1.Canary, metric flaggerv1.CanaryMetric, check metricCheck) metricCheck {
+	slo := metric.SLO
+	headers, err := c.metricHeaders(r, metric, slo.SecretName)
+	if err != nil {
+		check.queryError = true
+		check.message = err.Error()
+		return check
+	}
+
+	val, err := c.sloObserver.GetSLOValue(slo, headers)
+	if err != nil {
+		if strings.Contains(err.Error(), "no values found") {
+			return c.metricQueryFailure(r, metric, err)
+		}
+		check.queryError = true
+		check.message = fmt.Sprintf("SLO server %s query failed: %v", c.sloObserver.sloServer, err)
+		return check
+	}
+
+	check.value = &val
+	if sloIndicator(slo) == flaggerv1.SLOBurnRate {
+		if val > metric.Threshold {
+			check.message = fmt.Sprintf("Halt %s.%s advancement SLO %s burn rate %.2f > %v",
+				r.Name, r.Namespace, slo.Name, val, metric.Threshold)
+			return degradeCheck(metric, check, val-metric.Threshold)
+		}
+		check = warnCheck(r, metric, check, val > metric.WarnThreshold)
+	} else {
+		if val < metric.Threshold {
+			check.message = fmt.Sprintf("Halt %s.%s advancement SLO %s error budget %.2f%% < %v%%",
+				r.Name, r.Namespace, slo.Name, val, metric.Threshold)
+			return degradeCheck(metric, check, metric.Threshold-val)
+		}
+		check = warnCheck(r, metric, check, val < metric.WarnThreshold)
+	}
+	check.passed = true
+	return check
+}
+
+// validateSLO checks that the SLO name and indicator are set
+func validateSLO(metric flaggerv1.CanaryMetric) error {
+	if metric.Query != "" || metric.BurnRate != nil || metric.ThresholdPercent != 0 || metric.Logs != nil ||
+		metric.Ratio != nil || metric.Resource != nil || metric.GraphQL != nil || metric.MinRequests > 0 ||
+		len(metric.Selector) > 0 || len(metric.Labels) > 0 || len(metric.SuccessCodes) > 0 || len(metric.TotalCodes) > 0 {
+		return fmt.Errorf("metric %s slo can't be used with the Prometheus query settings", metric.Name)
+	}
+	if metric.SLO.Name == "" {
+		return fmt.Errorf("metric %s slo name is required", metric.Name)
+	}
+	switch metric.SLO.Indicator {
+	case "", flaggerv1.SLOErrorBudget, flaggerv1.SLOBurnRate:
+	default:
+		return fmt.Errorf("metric %s slo indicator %s must be error-budget or burn-rate", metric.Name, metric.SLO.Indicator)
+	}
+	return nil
+}
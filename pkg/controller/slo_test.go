@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+)
+
+func TestController_CheckSLO(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/slos/podinfo-availability" || r.URL.Query().Get("project") != "default" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"errorBudgetRemaining":12.5,"burnRate":null}`))
+	}))
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	mocks.ctrl.sloObserver = NewSLOObserver(ts.URL)
+	metric := v1alpha3.CanaryMetric{
+		Name:      "availability",
+		Threshold: 20,
+		SLO: &v1alpha3.CanarySLO{
+			Name:    "podinfo-availability",
+			Project: "default",
+		},
+	}
+
+	// the remaining error budget is below the minimum
+	check := mocks.ctrl.checkMetric(mocks.canary, metric)
+	if check.passed || check.value == nil || *check.value != 12.5 {
+		t.Errorf("Got check %+v wanted failed with value 12.5", check)
+	}
+
+	metric.Threshold = 10
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); !check.passed {
+		t.Errorf("Got %s wanted the error budget above the threshold", check.message)
+	}
+
+	metric.SLO.Indicator = v1alpha3.SLOBurnRate
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); !check.noValues {
+		t.Errorf("Got no values %v wanted %v", check.noValues, true)
+	}
+
+	metric.SLO.Name = "missing"
+	if check := mocks.ctrl.checkMetric(mocks.canary, metric); !check.queryError {
+		t.Errorf("Got query error %v wanted %v", check.queryError, true)
+	}
+
+	metric.SLO.Indicator = "latency"
+	if err := validateMetric(metric); err == nil {
+		t.Errorf("Got no error wanted invalid slo indicator")
+	}
+}
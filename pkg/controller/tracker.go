@@ -4,6 +4,9 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+
 	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
 	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
 	"go.uber.org/zap"
@@ -33,6 +36,8 @@ type ConfigRef struct {
 	Name     string
 	Type     ConfigRefType
 	Checksum string
+	// checksum of every data key
+	Keys map[string]string
 }
 
 // GetName returns the config ref type and name
@@ -47,6 +52,24 @@ func checksum(data interface{}) string {
 	return fmt.Sprintf("%x", hashBytes[:8])
 }
 
+// configMapKeys computes the checksum of every ConfigMap value
+func configMapKeys(data map[string]string) map[string]string {
+	keys := make(map[string]string, len(data))
+	for key, value := range data {
+		keys[key] = checksum(value)
+	}
+	return keys
+}
+
+// secretKeys computes the checksum of every Secret value
+func secretKeys(data map[string][]byte) map[string]string {
+	keys := make(map[string]string, len(data))
+	for key, value := range data {
+		keys[key] = checksum(value)
+	}
+	return keys
+}
+
 // getRefFromConfigMap transforms a Kubernetes ConfigMap into a ConfigRef
 // and computes the checksum of the ConfigMap data
 func (ct *ConfigTracker) getRefFromConfigMap(name string, namespace string) (*ConfigRef, error) {
@@ -59,6 +82,7 @@ func (ct *ConfigTracker) getRefFromConfigMap(name string, namespace string) (*Co
 		Name:     config.Name,
 		Type:     ConfigRefMap,
 		Checksum: checksum(config.Data),
+		Keys:     configMapKeys(config.Data),
 	}, nil
 }
 
@@ -83,6 +107,7 @@ func (ct *ConfigTracker) getRefFromSecret(name string, namespace string) (*Confi
 		Name:     secret.Name,
 		Type:     ConfigRefSecret,
 		Checksum: checksum(secret.Data),
+		Keys:     secretKeys(secret.Data),
 	}, nil
 }
 
@@ -183,18 +208,23 @@ func (ct *ConfigTracker) GetTargetConfigs(cd *flaggerv1.Canary) (map[string]Conf
 }
 
 // GetConfigRefs returns a map of configs and their checksum
-func (ct *ConfigTracker) GetConfigRefs(cd *flaggerv1.Canary) (*map[string]string, error) {
+// and a map of the config keys, in the type/name/key format, and their checksum
+func (ct *ConfigTracker) GetConfigRefs(cd *flaggerv1.Canary) (*map[string]string, *map[string]string, error) {
 	res := make(map[string]string)
+	keys := make(map[string]string)
 	configs, err := ct.GetTargetConfigs(cd)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, cfg := range configs {
 		res[cfg.GetName()] = cfg.Checksum
+		for key, sum := range cfg.Keys {
+			keys[fmt.Sprintf("%s/%s", cfg.GetName(), key)] = sum
+		}
 	}
 
-	return &res, nil
+	return &res, &keys, nil
 }
 
 // HasConfigChanged checks for changes in ConfigMaps and Secretes by comparing
@@ -230,6 +260,65 @@ func (ct *ConfigTracker) HasConfigChanged(cd *flaggerv1.Canary) (bool, error) {
 	return false, nil
 }
 
+// GetConfigDrift returns the ConfigMaps and Secrets that were added, removed or modified
+// since the last sync, for the modified configs the changed keys are listed
+func (ct *ConfigTracker) GetConfigDrift(cd *flaggerv1.Canary) ([]string, error) {
+	configs, err := ct.GetTargetConfigs(cd)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]string)
+	if cd.Status.TrackedConfigs != nil {
+		tracked = *cd.Status.TrackedConfigs
+	}
+
+	var drift []string
+	for name, cfg := range configs {
+		sum, ok := tracked[name]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s added", name))
+		case sum != cfg.Checksum && cd.Status.TrackedConfigKeys == nil:
+			// the keys of the configs tracked before the key checksums were recorded are unknown
+			drift = append(drift, fmt.Sprintf("%s changed", name))
+		case sum != cfg.Checksum:
+			keys := changedKeys(name, cfg.Keys, *cd.Status.TrackedConfigKeys)
+			drift = append(drift, fmt.Sprintf("%s keys %s changed", name, strings.Join(keys, ",")))
+		}
+	}
+	for name := range tracked {
+		if _, ok := configs[name]; !ok {
+			drift = append(drift, fmt.Sprintf("%s removed", name))
+		}
+	}
+
+	sort.Strings(drift)
+	return drift, nil
+}
+
+// changedKeys returns the keys of a config that were added, removed or modified
+func changedKeys(name string, keys map[string]string, trackedKeys map[string]string) []string {
+	prefix := name + "/"
+	var res []string
+	for key, sum := range keys {
+		if trackedKeys[prefix+key] != sum {
+			res = append(res, key)
+		}
+	}
+	for trackedKey := range trackedKeys {
+		if !strings.HasPrefix(trackedKey, prefix) {
+			continue
+		}
+		if key := strings.TrimPrefix(trackedKey, prefix); keys[key] == "" {
+			res = append(res, key)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
+
 // CreatePrimaryConfigs syncs the primary Kubernetes ConfigMaps and Secretes
 // with those found in the target deployment
 func (ct *ConfigTracker) CreatePrimaryConfigs(cd *flaggerv1.Canary, refs map[string]ConfigRef) error {
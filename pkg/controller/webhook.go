@@ -3,71 +3,303 @@ package controller
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // CallWebhook does a HTTP POST to an external service and
 // returns an error if the response status code is non-2xx
-func CallWebhook(name string, namespace string, w flaggerv1.CanaryWebhook) error {
+func CallWebhook(name string, namespace string, rolloutID string, w flaggerv1.CanaryWebhook, client *http.Client) error {
+	payloadBin, err := webhookPayload(name, namespace, rolloutID, w)
+	if err != nil {
+		return err
+	}
+
+	_, err = doWebhook("POST", w.URL, bytes.NewBuffer(payloadBin), w.Timeout, client)
+	return err
+}
+
+// CallLoadTest does a HTTP POST to the load tester and
+// returns the load test status found in the response
+func CallLoadTest(name string, namespace string, rolloutID string, w flaggerv1.CanaryWebhook, client *http.Client) (flaggerv1.LoadTestStatus, error) {
+	var status flaggerv1.LoadTestStatus
+	payloadBin, err := webhookPayload(name, namespace, rolloutID, w)
+	if err != nil {
+		return status, err
+	}
+
+	b, err := doWebhook("POST", w.URL, bytes.NewBuffer(payloadBin), w.Timeout, client)
+	if err != nil {
+		return status, err
+	}
+
+	if err := json.Unmarshal(b, &status); err != nil {
+		return status, fmt.Errorf("error decoding load test status: %s", err.Error())
+	}
+	return status, nil
+}
+
+// CreateApproval does a HTTP POST to an approval webhook and
+// returns the ID of the registered approval
+func CreateApproval(name string, namespace string, rolloutID string, w flaggerv1.CanaryWebhook, client *http.Client) (string, error) {
+	payloadBin, err := webhookPayload(name, namespace, rolloutID, w)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := doWebhook("POST", w.URL, bytes.NewBuffer(payloadBin), w.Timeout, client)
+	if err != nil {
+		return "", err
+	}
+
+	var approval flaggerv1.CanaryApproval
+	if err := json.Unmarshal(b, &approval); err != nil {
+		return "", fmt.Errorf("error decoding approval: %s", err.Error())
+	}
+	if approval.ID == "" {
+		return "", errors.New("approval ID not found in response")
+	}
+	return approval.ID, nil
+}
+
+// GetApproval does a HTTP GET to the approval webhook URL suffixed with the approval ID
+// and returns the approval decision
+func GetApproval(w flaggerv1.CanaryWebhook, id string, client *http.Client) (flaggerv1.ApprovalStatus, error) {
+	b, err := doWebhook("GET", strings.TrimSuffix(w.URL, "/")+"/"+url.PathEscape(id), nil, w.Timeout, client)
+	if err != nil {
+		return "", err
+	}
+
+	var approval flaggerv1.CanaryApproval
+	if err := json.Unmarshal(b, &approval); err != nil {
+		return "", fmt.Errorf("error decoding approval: %s", err.Error())
+	}
+
+	switch approval.Status {
+	case flaggerv1.ApprovalPending, flaggerv1.ApprovalApproved, flaggerv1.ApprovalRejected:
+		return approval.Status, nil
+	default:
+		return "", fmt.Errorf("approval %s unknown status %s", id, approval.Status)
+	}
+}
+
+// CallPromotion does a HTTP POST to a promotion webhook and
+// returns the promotion progress found in the response
+func CallPromotion(name string, namespace string, rolloutID string, w flaggerv1.CanaryWebhook, client *http.Client) (flaggerv1.CanaryPromotion, error) {
+	var promotion flaggerv1.CanaryPromotion
+	payloadBin, err := webhookPayload(name, namespace, rolloutID, w)
+	if err != nil {
+		return promotion, err
+	}
+
+	b, err := doWebhook("POST", w.URL, bytes.NewBuffer(payloadBin), w.Timeout, client)
+	if err != nil {
+		return promotion, err
+	}
+
+	if err := json.Unmarshal(b, &promotion); err != nil {
+		return promotion, fmt.Errorf("error decoding promotion: %s", err.Error())
+	}
+
+	switch promotion.Status {
+	case flaggerv1.PromotionPending, flaggerv1.PromotionPromoted:
+		return promotion, nil
+	default:
+		return promotion, fmt.Errorf("promotion unknown status %s", promotion.Status)
+	}
+}
+
+// CallRegionHealth does a HTTP POST to a region-health webhook and
+// returns the unhealthy regions found in the response
+func CallRegionHealth(name string, namespace string, rolloutID string, w flaggerv1.CanaryWebhook, client *http.Client) ([]string, error) {
+	payloadBin, err := webhookPayload(name, namespace, rolloutID, w)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := doWebhook("POST", w.URL, bytes.NewBuffer(payloadBin), w.Timeout, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var status flaggerv1.RegionHealthStatus
+	if err := json.Unmarshal(b, &status); err != nil {
+		return nil, fmt.Errorf("error decoding region health: %s", err.Error())
+	}
+	return status.Unhealthy, nil
+}
+
+func webhookPayload(name string, namespace string, rolloutID string, w flaggerv1.CanaryWebhook) ([]byte, error) {
 	payload := flaggerv1.CanaryWebhookPayload{
 		Name:      name,
 		Namespace: namespace,
+		RolloutID: rolloutID,
 	}
 
 	if w.Metadata != nil {
 		payload.Metadata = *w.Metadata
 	}
 
-	payloadBin, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
+	return json.Marshal(payload)
+}
 
-	hook, err := url.Parse(w.URL)
+// doWebhook sends the request and returns the response body,
+// an error is returned if the response status code is not 200, 201 or 202,
+// the request is sent with http.DefaultClient if the client is nil
+func doWebhook(method string, address string, body io.Reader, timeout string, client *http.Client) ([]byte, error) {
+	hook, err := url.Parse(address)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", hook.String(), bytes.NewBuffer(payloadBin))
+	req, err := http.NewRequest(method, hook.String(), body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	if len(w.Timeout) < 2 {
-		w.Timeout = "10s"
+	if len(timeout) < 2 {
+		timeout = "10s"
 	}
 
-	timeout, err := time.ParseDuration(w.Timeout)
+	t, err := time.ParseDuration(timeout)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	ctx, cancel := context.WithTimeout(req.Context(), t)
 	defer cancel()
 
-	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r, err := client.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer r.Body.Close()
 
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return fmt.Errorf("error reading body: %s", err.Error())
+		return nil, fmt.Errorf("error reading body: %s", err.Error())
 	}
 
 	if r.StatusCode > 202 {
-		return errors.New(string(b))
+		return nil, errors.New(string(b))
 	}
 
+	return b, nil
+}
+
+// callWebhook calls the webhook and records its latency and result,
+// for webhooks waiting for traffic it returns false if the load test is not running
+func (c *Controller) callWebhook(cd *flaggerv1.Canary, w flaggerv1.CanaryWebhook) (bool, error) {
+	begin := time.Now()
+	running := true
+	client, err := c.webhookTLSClient(cd, w)
+	if err == nil {
+		if w.WaitForTraffic {
+			var status flaggerv1.LoadTestStatus
+			status, err = CallLoadTest(cd.Name, cd.Namespace, cd.Status.RolloutID, w, client)
+			running = status.Running
+		} else {
+			err = CallWebhook(cd.Name, cd.Namespace, cd.Status.RolloutID, w, client)
+		}
+	}
+	c.recorder.SetWebhook(cd, w.Name, time.Since(begin), err)
+	return running, err
+}
+
+// checkWebhookGroups calls the grouped rollout webhooks and returns an error
+// if fewer webhooks than the quorum of their group passed
+func (c *Controller) checkWebhookGroups(cd *flaggerv1.Canary) error {
+	for _, group := range cd.Spec.CanaryAnalysis.WebhookGroups {
+		passed := 0
+		var failed []string
+		for _, webhook := range cd.Spec.CanaryAnalysis.Webhooks {
+			if webhook.Group != group.Name || !isRolloutHook(webhook) {
+				continue
+			}
+			if _, err := c.callWebhook(cd, webhook); err != nil {
+				failed = append(failed, fmt.Sprintf("%s %v", webhook.Name, err))
+				continue
+			}
+			passed++
+		}
+		if passed < group.Quorum {
+			return fmt.Errorf("webhook group %s quorum not reached %v/%v passed, failed: %s",
+				group.Name, passed, group.Quorum, strings.Join(failed, ", "))
+		}
+	}
 	return nil
 }
+
+// isRolloutHook returns true if the webhook is called on every analysis interval
+func isRolloutHook(w flaggerv1.CanaryWebhook) bool {
+	return w.Type != flaggerv1.ApprovalHook && w.Type != flaggerv1.PreRolloutHook &&
+		w.Type != flaggerv1.ReadinessGateHook && w.Type != flaggerv1.TeardownHook &&
+		w.Type != flaggerv1.PromotionHook && w.Type != flaggerv1.RegionHealthHook &&
+		w.Type != flaggerv1.PrimaryWarmupHook
+}
+
+// webhookTLSClient returns the client presenting the certificate of the webhook TLS secret,
+// the controller webhook client is used if the webhook has no TLS secret
+func (c *Controller) webhookTLSClient(cd *flaggerv1.Canary, w flaggerv1.CanaryWebhook) (*http.Client, error) {
+	if w.TLS == nil {
+		return c.webhookClient, nil
+	}
+
+	secret, err := c.kubeClient.CoreV1().Secrets(cd.Namespace).Get(w.TLS.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("webhook %s TLS secret %s.%s query error %v", w.Name, w.TLS.SecretName, cd.Namespace, err)
+	}
+
+	config, err := NewWebhookTLSConfig(secret.Data["tls.crt"], secret.Data["tls.key"], secret.Data["ca.crt"])
+	if err != nil {
+		return nil, fmt.Errorf("webhook %s TLS secret %s.%s %v", w.Name, w.TLS.SecretName, cd.Namespace, err)
+	}
+
+	// the secret can be rotated, the connections are not reused between calls
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			TLSClientConfig:   config,
+			DisableKeepAlives: true,
+		},
+	}, nil
+}
+
+// NewWebhookTLSConfig returns the TLS config presenting the PEM encoded client certificate
+// and verifying the server against the CA, the system roots are used if the CA is empty
+func NewWebhookTLSConfig(cert []byte, key []byte, ca []byte) (*tls.Config, error) {
+	config := &tls.Config{}
+	if len(cert) > 0 || len(key) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate %v", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("invalid CA certificate")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
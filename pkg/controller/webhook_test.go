@@ -1,10 +1,24 @@
 package controller
 
 import (
-	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestCallWebhook(t *testing.T) {
@@ -19,7 +33,7 @@ func TestCallWebhook(t *testing.T) {
 		Metadata: &map[string]string{"key1": "val1"},
 	}
 
-	err := CallWebhook("podinfo", "default", hook)
+	err := CallWebhook("podinfo", "default", "", hook, nil)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -35,8 +49,223 @@ func TestCallWebhook_StatusCode(t *testing.T) {
 		URL:  ts.URL,
 	}
 
-	err := CallWebhook("podinfo", "default", hook)
+	err := CallWebhook("podinfo", "default", "", hook, nil)
 	if err == nil {
 		t.Errorf("Got no error wanted %v", http.StatusInternalServerError)
 	}
 }
+
+func TestController_CallWebhookMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	hook := flaggerv1.CanaryWebhook{
+		Name: "validation",
+		URL:  ts.URL,
+	}
+
+	mocks := SetupMocks(false)
+	if _, err := mocks.ctrl.callWebhook(mocks.canary, hook); err == nil {
+		t.Errorf("Got no error wanted %v", http.StatusInternalServerError)
+	}
+
+	m := &dto.Metric{}
+	counter := mocks.ctrl.recorder.webhookTotal.WithLabelValues(mocks.canary.Spec.TargetRef.Name, "default", "validation", "failure")
+	if err := counter.Write(m); err != nil {
+		t.Fatal(err.Error())
+	}
+	if m.GetCounter().GetValue() != 1 {
+		t.Errorf("Got failed calls %v wanted %v", m.GetCounter().GetValue(), 1)
+	}
+}
+
+func TestController_CheckWebhookGroups(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ok.Close()
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failed.Close()
+
+	mocks := SetupMocks(false)
+	cd := mocks.canary.DeepCopy()
+	cd.Spec.CanaryAnalysis.Webhooks = []flaggerv1.CanaryWebhook{
+		{Name: "validator-a", URL: ok.URL, Group: "validators"},
+		{Name: "validator-b", URL: failed.URL, Group: "validators"},
+		{Name: "validator-c", URL: ok.URL, Group: "validators"},
+	}
+	cd.Spec.CanaryAnalysis.WebhookGroups = []flaggerv1.CanaryWebhookGroup{{Name: "validators", Quorum: 2}}
+	if err := validateWebhookGroups(cd); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 2 of 3 passed
+	if err := mocks.ctrl.checkWebhookGroups(cd); err != nil {
+		t.Errorf("Got error %v wanted the quorum reached", err)
+	}
+
+	cd.Spec.CanaryAnalysis.Webhooks[2].URL = failed.URL
+	if err := mocks.ctrl.checkWebhookGroups(cd); err == nil {
+		t.Errorf("Got no error wanted the quorum not reached")
+	}
+
+	cd.Spec.CanaryAnalysis.WebhookGroups[0].Quorum = 4
+	if err := validateWebhookGroups(cd); err == nil {
+		t.Errorf("Got no error for a quorum above the number of webhooks")
+	}
+	cd.Spec.CanaryAnalysis.WebhookGroups = nil
+	if err := validateWebhookGroups(cd); err == nil {
+		t.Errorf("Got no error for an undeclared group")
+	}
+}
+
+func TestApproval(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"cr-1","status":"pending"}`))
+		case r.Method == "GET" && r.URL.Path == "/approvals/cr-1":
+			w.Write([]byte(`{"id":"cr-1","status":"approved"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	hook := flaggerv1.CanaryWebhook{
+		Name: "change-request",
+		URL:  ts.URL + "/approvals/",
+		Type: flaggerv1.ApprovalHook,
+	}
+
+	id, err := CreateApproval("podinfo", "default", "", hook, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if id != "cr-1" {
+		t.Errorf("Got approval ID %s wanted %s", id, "cr-1")
+	}
+
+	status, err := GetApproval(hook, id, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if status != flaggerv1.ApprovalApproved {
+		t.Errorf("Got approval status %s wanted %s", status, flaggerv1.ApprovalApproved)
+	}
+
+	if _, err := GetApproval(hook, "cr-2", nil); err == nil {
+		t.Errorf("Got no error wanted %v", http.StatusNotFound)
+	}
+}
+
+func TestCallLoadTest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"canary":"podinfo.default","running":true}`))
+	}))
+	defer ts.Close()
+	hook := flaggerv1.CanaryWebhook{
+		Name:           "load-test",
+		URL:            ts.URL,
+		Type:           flaggerv1.PreRolloutHook,
+		WaitForTraffic: true,
+		Metadata:       &map[string]string{"cmd": "hey -z 1m http://podinfo-canary.default:9898/"},
+	}
+
+	status, err := CallLoadTest("podinfo", "default", "", hook, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !status.Running {
+		t.Errorf("Got load test not running wanted running")
+	}
+}
+
+func TestController_CallWebhookTLS(t *testing.T) {
+	caCert, caKey := newTestCertificate(t, nil, nil, true)
+	serverCert, serverKey := newTestCertificate(t, caCert, caKey, false)
+	clientCert, clientKey := newTestCertificate(t, caCert, caKey, false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	mocks := SetupMocks(false)
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-tls", Namespace: "default"},
+		Data: map[string][]byte{
+			"tls.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Raw}),
+			"tls.key": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+			"ca.crt":  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}),
+		},
+	}
+	if _, err := mocks.kubeClient.CoreV1().Secrets("default").Create(secret); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	hook := flaggerv1.CanaryWebhook{
+		Name: "validation",
+		URL:  ts.URL,
+		TLS:  &flaggerv1.CanaryWebhookTLS{SecretName: "webhook-tls"},
+	}
+	if _, err := mocks.ctrl.callWebhook(mocks.canary, hook); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the server rejects the calls without a client certificate
+	hook.TLS = nil
+	if _, err := mocks.ctrl.callWebhook(mocks.canary, hook); err == nil {
+		t.Errorf("Got no error wanted a TLS error")
+	}
+}
+
+// newTestCertificate returns a certificate for 127.0.0.1 signed by the parent, self-signed if the parent is nil
+func newTestCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, ca bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "flagger"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         ca,
+
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return cert, key
+}
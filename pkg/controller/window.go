@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+)
+
+// windowContains returns true if the time falls within the daily allowed window,
+// a window ending before its start spans midnight and belongs to the day it opens on
+func windowContains(w *flaggerv1.CanaryAllowedWindow, now time.Time) (bool, error) {
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return false, fmt.Errorf("invalid time zone %s", w.TimeZone)
+	}
+	start, err := windowMinutes(w.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := windowMinutes(w.End)
+	if err != nil {
+		return false, err
+	}
+
+	now = now.In(loc)
+	minutes := now.Hour()*60 + now.Minute()
+	day := now.Weekday()
+
+	if start < end {
+		return minutes >= start && minutes < end && windowDay(w, day), nil
+	}
+	if minutes >= start {
+		return windowDay(w, day), nil
+	}
+	if minutes < end {
+		// the window opened the day before
+		return windowDay(w, (day+6)%7), nil
+	}
+	return false, nil
+}
+
+// windowMinutes returns the minutes since midnight of a HH:MM time
+func windowMinutes(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// windowDay returns true if the window opens on the day, an empty list means every day
+func windowDay(w *flaggerv1.CanaryAllowedWindow, day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if strings.EqualFold(d, day.String()[:3]) || strings.EqualFold(d, day.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWindow checks the window times, time zone and days
+func validateWindow(w *flaggerv1.CanaryAllowedWindow) error {
+	start, err := windowMinutes(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := windowMinutes(w.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("start and end must differ")
+	}
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %s", w.TimeZone)
+	}
+	for _, d := range w.Days {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(d, day.String()[:3]) || strings.EqualFold(d, day.String()) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid day %s", d)
+		}
+	}
+	return nil
+}
+
+// windowDescription returns the window hours and time zone e.g. 09:00-17:00 UTC
+func windowDescription(w *flaggerv1.CanaryAllowedWindow) string {
+	tz := w.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	return fmt.Sprintf("%s-%s %s", w.Start, w.End, tz)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+)
+
+func TestWindow_Contains(t *testing.T) {
+	// Wednesday
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		window v1alpha3.CanaryAllowedWindow
+		at     time.Duration
+		open   bool
+	}{
+		{v1alpha3.CanaryAllowedWindow{Start: "09:00", End: "17:00"}, 10 * time.Hour, true},
+		{v1alpha3.CanaryAllowedWindow{Start: "09:00", End: "17:00"}, 17 * time.Hour, false},
+		{v1alpha3.CanaryAllowedWindow{Start: "09:00", End: "17:00"}, 8*time.Hour + 59*time.Minute, false},
+		{v1alpha3.CanaryAllowedWindow{Start: "09:00", End: "17:00", Days: []string{"Mon", "Tue"}}, 10 * time.Hour, false},
+		{v1alpha3.CanaryAllowedWindow{Start: "09:00", End: "17:00", Days: []string{"wednesday"}}, 10 * time.Hour, true},
+		// 10:00 UTC is 05:00 in New York
+		{v1alpha3.CanaryAllowedWindow{Start: "09:00", End: "17:00", TimeZone: "America/New_York"}, 10 * time.Hour, false},
+		// the window opened on Tuesday at 22:00
+		{v1alpha3.CanaryAllowedWindow{Start: "22:00", End: "06:00", Days: []string{"Tue"}}, 2 * time.Hour, true},
+		{v1alpha3.CanaryAllowedWindow{Start: "22:00", End: "06:00", Days: []string{"Tue"}}, 23 * time.Hour, false},
+		{v1alpha3.CanaryAllowedWindow{Start: "22:00", End: "06:00"}, 12 * time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		open, err := windowContains(&tt.window, day.Add(tt.at))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if open != tt.open {
+			t.Errorf("Got open %v for window %+v at %v wanted %v", open, tt.window, day.Add(tt.at), tt.open)
+		}
+	}
+}
+
+func TestWindow_Validate(t *testing.T) {
+	invalid := []v1alpha3.CanaryAllowedWindow{
+		{Start: "9am", End: "17:00"},
+		{Start: "09:00", End: "09:00"},
+		{Start: "09:00", End: "17:00", TimeZone: "Mars/Olympus"},
+		{Start: "09:00", End: "17:00", Days: []string{"Funday"}},
+	}
+	for _, w := range invalid {
+		if err := validateWindow(&w); err == nil {
+			t.Errorf("Got no error for window %+v", w)
+		}
+	}
+
+	if err := validateWindow(&v1alpha3.CanaryAllowedWindow{Start: "09:00", End: "17:00", Days: []string{"Mon", "friday"}}); err != nil {
+		t.Error(err.Error())
+	}
+}
@@ -34,6 +34,19 @@ func (tr *TaskRunner) GetTotalExecs() uint64 {
 	return atomic.LoadUint64(&tr.totalExecs)
 }
 
+// IsRunning returns true if a task is running for the canary
+func (tr *TaskRunner) IsRunning(canary string) bool {
+	running := false
+	tr.runningTasks.Range(func(key interface{}, value interface{}) bool {
+		if value.(Task).Canary() == canary {
+			running = true
+			return false
+		}
+		return true
+	})
+	return running
+}
+
 func (tr *TaskRunner) runAll() {
 	tr.todoTasks.Range(func(key interface{}, value interface{}) bool {
 		task := value.(Task)
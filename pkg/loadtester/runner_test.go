@@ -45,3 +45,29 @@ func TestTaskRunner_Start(t *testing.T) {
 		t.Errorf("Got total executed commands %v wanted %v", tr.GetTotalExecs(), 4)
 	}
 }
+
+func TestTaskRunner_IsRunning(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	logger, _ := logging.NewLogger("debug")
+	tr := NewTaskRunner(logger, time.Hour)
+
+	go tr.Start(10*time.Millisecond, stop)
+
+	taskFactory, _ := GetTaskFactory(TaskTypeShell)
+	task, _ := taskFactory(map[string]string{"cmd": "sleep 0.3"}, "podinfo.default", logger)
+
+	tr.Add(task)
+	if tr.IsRunning("podinfo.default") {
+		t.Errorf("Got running task before the runner started it")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if !tr.IsRunning("podinfo.default") {
+		t.Errorf("Got no running task for podinfo.default")
+	}
+	if tr.IsRunning("podinfo.test") {
+		t.Errorf("Got running task for podinfo.test")
+	}
+}
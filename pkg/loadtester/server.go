@@ -58,13 +58,24 @@ func ListenAndServe(port string, timeout time.Duration, logger *zap.SugaredLogge
 				return
 			}
 			taskRunner.Add(task)
-		} else {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("metadata not found in payload"))
+
+			// report if the load test is running, Flagger waits for traffic when the webhook sets waitForTraffic
+			status, err := json.Marshal(flaggerv1.LoadTestStatus{
+				Canary:  canary,
+				Running: taskRunner.IsRunning(canary),
+			})
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			w.Write(status)
 			return
 		}
 
-		w.WriteHeader(http.StatusAccepted)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("metadata not found in payload"))
 	})
 	srv := &http.Server{
 		Addr:         ":" + port,
@@ -0,0 +1,114 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// GoogleChat holds the incoming webhook URL
+type GoogleChat struct {
+	URL string
+}
+
+// GoogleChatPayload holds the card messages
+type GoogleChatPayload struct {
+	Cards []GoogleChatCard `json:"cards"`
+}
+
+// GoogleChatCard holds the header and the sections of a card message
+type GoogleChatCard struct {
+	Header   GoogleChatHeader    `json:"header"`
+	Sections []GoogleChatSection `json:"sections"`
+}
+
+// GoogleChatHeader holds the canary identity and the notification severity
+type GoogleChatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+}
+
+type GoogleChatSection struct {
+	Widgets []GoogleChatWidget `json:"widgets"`
+}
+
+// GoogleChatWidget holds either the message text or a key value pair
+type GoogleChatWidget struct {
+	TextParagraph *GoogleChatTextParagraph `json:"textParagraph,omitempty"`
+	KeyValue      *GoogleChatKeyValue      `json:"keyValue,omitempty"`
+}
+
+type GoogleChatTextParagraph struct {
+	Text string `json:"text"`
+}
+
+type GoogleChatKeyValue struct {
+	TopLabel string `json:"topLabel"`
+	Content  string `json:"content"`
+}
+
+// NewGoogleChat validates the webhook URL and returns a GoogleChat object
+func NewGoogleChat(hookURL string) (*GoogleChat, error) {
+	_, err := url.ParseRequestURI(hookURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Google Chat hook URL %s", hookURL)
+	}
+
+	return &GoogleChat{
+		URL: hookURL,
+	}, nil
+}
+
+// Post Google Chat card message
+func (g *GoogleChat) Post(workload string, namespace string, message string, fields []Field, severity Severity) error {
+	color := "#2eb886"
+	if severity != SeverityInfo {
+		color = "#db3b21"
+	}
+
+	widgets := []GoogleChatWidget{
+		{
+			TextParagraph: &GoogleChatTextParagraph{
+				Text: fmt.Sprintf(`<font color="%s">%s</font>`, color, message),
+			},
+		},
+	}
+	for _, f := range fields {
+		widgets = append(widgets, GoogleChatWidget{
+			KeyValue: &GoogleChatKeyValue{TopLabel: f.Name, Content: f.Value},
+		})
+	}
+
+	payload := GoogleChatPayload{
+		Cards: []GoogleChatCard{
+			{
+				Header: GoogleChatHeader{
+					Title:    fmt.Sprintf("%s.%s", workload, namespace),
+					Subtitle: string(severity),
+				},
+				Sections: []GoogleChatSection{{Widgets: widgets}},
+			},
+		},
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshalling google chat payload failed %v", err)
+	}
+
+	res, err := http.Post(g.URL, "application/json; charset=UTF-8", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("sending data to google chat failed %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("sending data to google chat failed %v", string(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGoogleChat_Post(t *testing.T) {
+	var payload GoogleChatPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	g, err := NewGoogleChat(ts.URL)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fields := []Field{{Name: "Target", Value: "Deployment/podinfo.default"}}
+	if err := g.Post("podinfo", "default", "Canary analysis failed", fields, SeverityError); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(payload.Cards) != 1 {
+		t.Fatalf("Got %v cards wanted %v", len(payload.Cards), 1)
+	}
+	card := payload.Cards[0]
+	if card.Header.Title != "podinfo.default" {
+		t.Errorf("Got title %s wanted %s", card.Header.Title, "podinfo.default")
+	}
+	if card.Header.Subtitle != string(SeverityError) {
+		t.Errorf("Got subtitle %s wanted %s", card.Header.Subtitle, SeverityError)
+	}
+	if len(card.Sections) != 1 || len(card.Sections[0].Widgets) != 2 {
+		t.Fatalf("Got sections %+v wanted one section with the message and the field", card.Sections)
+	}
+	widgets := card.Sections[0].Widgets
+	if widgets[0].TextParagraph == nil || !strings.Contains(widgets[0].TextParagraph.Text, "Canary analysis failed") {
+		t.Errorf("Got text %+v wanted the message", widgets[0].TextParagraph)
+	}
+	if widgets[1].KeyValue == nil || widgets[1].KeyValue.TopLabel != "Target" ||
+		widgets[1].KeyValue.Content != "Deployment/podinfo.default" {
+		t.Errorf("Got key value %+v wanted the target field", widgets[1].KeyValue)
+	}
+
+	if _, err := NewGoogleChat("chat.googleapis.com"); err == nil {
+		t.Errorf("Got no error wanted invalid URL error")
+	}
+}
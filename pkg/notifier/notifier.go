@@ -0,0 +1,90 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity of a notification
+type Severity string
+
+const (
+	SeverityInfo  Severity = "info"
+	SeverityWarn  Severity = "warn"
+	SeverityError Severity = "error"
+)
+
+// ParseSeverity returns the severity for the info, warn or error names
+func ParseSeverity(name string) (Severity, error) {
+	switch Severity(name) {
+	case SeverityInfo, SeverityWarn, SeverityError:
+		return Severity(name), nil
+	default:
+		return "", fmt.Errorf("invalid severity %s, can be info, warn or error", name)
+	}
+}
+
+// ParseSeverityMap parses a comma separated list of severity=value pairs
+// e.g. warn=ops,error=on-call
+func ParseSeverityMap(list string) (map[Severity]string, error) {
+	m := make(map[Severity]string)
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid severity mapping %s, the format is severity=value", pair)
+		}
+		severity, err := ParseSeverity(strings.TrimSpace(kv[0]))
+		if err != nil {
+			return nil, err
+		}
+		m[severity] = strings.TrimSpace(kv[1])
+	}
+	return m, nil
+}
+
+// ReleaseField is the name of the field that holds the canary release version
+const ReleaseField = "Release"
+
+// Interface describes a notification provider
+type Interface interface {
+	Post(workload string, namespace string, message string, fields []Field, severity Severity) error
+}
+
+// Field holds a notification key value pair
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Multi posts a notification to all the configured providers
+type Multi struct {
+	notifiers []Interface
+}
+
+// NewMulti returns a notifier that fans out to the given providers,
+// it returns nil if no providers are given
+func NewMulti(notifiers ...Interface) Interface {
+	switch len(notifiers) {
+	case 0:
+		return nil
+	case 1:
+		return notifiers[0]
+	default:
+		return &Multi{notifiers: notifiers}
+	}
+}
+
+// Post sends the notification to all providers and returns the last error
+func (m *Multi) Post(workload string, namespace string, message string, fields []Field, severity Severity) error {
+	var err error
+	for _, n := range m.notifiers {
+		if e := n.Post(workload, namespace, message, fields, severity); e != nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/flagger/pkg/version"
+)
+
+// Sentry holds the store endpoint and the DSN keys
+type Sentry struct {
+	URL       string
+	PublicKey string
+	SecretKey string
+}
+
+// SentryEvent holds the event sent to the Sentry store API
+type SentryEvent struct {
+	EventID   string            `json:"event_id"`
+	Timestamp string            `json:"timestamp"`
+	Level     string            `json:"level"`
+	Logger    string            `json:"logger"`
+	Platform  string            `json:"platform"`
+	Message   string            `json:"message"`
+	Release   string            `json:"release,omitempty"`
+	Tags      map[string]string `json:"tags"`
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+// NewSentry parses the DSN and returns a Sentry object,
+// the DSN format is https://<public_key>[:<secret_key>]@<host>[/<path>]/<project_id>
+func NewSentry(dsn string) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("invalid Sentry DSN")
+	}
+
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("invalid Sentry DSN public key not found")
+	}
+	secret, _ := u.User.Password()
+
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path[i+1:] == "" {
+		return nil, errors.New("invalid Sentry DSN project ID not found")
+	}
+
+	return &Sentry{
+		URL:       fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:i], path[i+1:]),
+		PublicKey: u.User.Username(),
+		SecretKey: secret,
+	}, nil
+}
+
+// Post captures an event in Sentry, notifications with a severity other than error are ignored
+func (s *Sentry) Post(workload string, namespace string, message string, fields []Field, severity Severity) error {
+	if severity != SeverityError {
+		return nil
+	}
+
+	id, err := newSentryEventID()
+	if err != nil {
+		return fmt.Errorf("generating sentry event id failed %v", err)
+	}
+
+	event := SentryEvent{
+		EventID:   id,
+		Timestamp: time.Now().UTC().Format("2006-01-02T15:04:05"),
+		Level:     string(severity),
+		Logger:    "flagger",
+		Platform:  "go",
+		Message:   message,
+		Tags: map[string]string{
+			"canary":    fmt.Sprintf("%s.%s", workload, namespace),
+			"workload":  workload,
+			"namespace": namespace,
+		},
+		Extra: make(map[string]string),
+	}
+
+	for _, f := range fields {
+		if f.Name == ReleaseField {
+			event.Release = f.Value
+			continue
+		}
+		event.Extra[f.Name] = f.Value
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshalling sentry event failed %v", err)
+	}
+
+	req, err := http.NewRequest("POST", s.URL, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("sending data to sentry failed %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.authHeader())
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending data to sentry failed %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("sending data to sentry failed %v", string(body))
+	}
+
+	return nil
+}
+
+func (s *Sentry) authHeader() string {
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=flagger/%s, sentry_timestamp=%v, sentry_key=%s",
+		version.VERSION, time.Now().Unix(), s.PublicKey)
+	if s.SecretKey != "" {
+		auth = fmt.Sprintf("%s, sentry_secret=%s", auth, s.SecretKey)
+	}
+	return auth
+}
+
+func newSentryEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -16,6 +16,15 @@ type Slack struct {
 	Username  string
 	Channel   string
 	IconEmoji string
+	// channel and hook URL overrides by notification severity
+	Routes map[Severity]SlackRoute
+}
+
+// SlackRoute holds the channel and the hook URL used for a severity,
+// empty values fall back to the default channel and URL
+type SlackRoute struct {
+	URL     string
+	Channel string
 }
 
 // SlackPayload holds the channel and attachments
@@ -66,24 +75,66 @@ func NewSlack(hookURL string, username string, channel string) (*Slack, error) {
 	}, nil
 }
 
+// SetRoute validates the hook URL and routes the notifications of the given severity
+// to the channel and URL, empty values keep the defaults
+func (s *Slack) SetRoute(severity Severity, hookURL string, channel string) error {
+	if hookURL != "" {
+		if _, err := url.ParseRequestURI(hookURL); err != nil {
+			return fmt.Errorf("invalid Slack hook URL %s for severity %s", hookURL, severity)
+		}
+	}
+	if s.Routes == nil {
+		s.Routes = make(map[Severity]SlackRoute)
+	}
+	route := s.Routes[severity]
+	if hookURL != "" {
+		route.URL = hookURL
+	}
+	if channel != "" {
+		route.Channel = channel
+	}
+	s.Routes[severity] = route
+	return nil
+}
+
+// route returns the hook URL and the channel for the severity
+func (s *Slack) route(severity Severity) (string, string) {
+	hookURL, channel := s.URL, s.Channel
+	if route, ok := s.Routes[severity]; ok {
+		if route.URL != "" {
+			hookURL = route.URL
+		}
+		if route.Channel != "" {
+			channel = route.Channel
+		}
+	}
+	return hookURL, channel
+}
+
 // Post Slack message
-func (s *Slack) Post(workload string, namespace string, message string, fields []SlackField, warn bool) error {
+func (s *Slack) Post(workload string, namespace string, message string, fields []Field, severity Severity) error {
+	hookURL, channel := s.route(severity)
 	payload := SlackPayload{
-		Channel:  s.Channel,
+		Channel:  channel,
 		Username: s.Username,
 	}
 
 	color := "good"
-	if warn {
+	if severity != SeverityInfo {
 		color = "danger"
 	}
 
+	var slackFields []SlackField
+	for _, f := range fields {
+		slackFields = append(slackFields, SlackField{Title: f.Name, Value: f.Value})
+	}
+
 	a := SlackAttachment{
 		Color:      color,
 		AuthorName: fmt.Sprintf("%s.%s", workload, namespace),
 		Text:       message,
 		MrkdwnIn:   []string{"text"},
-		Fields:     fields,
+		Fields:     slackFields,
 	}
 
 	payload.Attachments = []SlackAttachment{a}
@@ -95,7 +146,7 @@ func (s *Slack) Post(workload string, namespace string, message string, fields [
 
 	b := bytes.NewBuffer(data)
 
-	if res, err := http.Post(s.URL, "application/json", b); err != nil {
+	if res, err := http.Post(hookURL, "application/json", b); err != nil {
 		return fmt.Errorf("sending data to slack failed %v", err)
 	} else {
 		defer res.Body.Close()
@@ -0,0 +1,72 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlack_PostRoutes(t *testing.T) {
+	posts := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var payload SlackPayload
+		if err := json.Unmarshal(b, &payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		posts[r.URL.Path] = payload.Channel
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	slack, err := NewSlack(ts.URL+"/default", "flagger", "general")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := slack.SetRoute(SeverityWarn, "", "ops"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := slack.SetRoute(SeverityError, ts.URL+"/on-call", "on-call"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		severity Severity
+		path     string
+		channel  string
+	}{
+		{SeverityInfo, "/default", "general"},
+		{SeverityWarn, "/default", "ops"},
+		{SeverityError, "/on-call", "on-call"},
+	}
+
+	for _, tt := range tests {
+		posts = make(map[string]string)
+		if err := slack.Post("podinfo", "test", "message", nil, tt.severity); err != nil {
+			t.Fatal(err.Error())
+		}
+		if channel, ok := posts[tt.path]; !ok || channel != tt.channel {
+			t.Errorf("Got %v for severity %s wanted channel %s on %s", posts, tt.severity, tt.channel, tt.path)
+		}
+	}
+}
+
+func TestParseSeverityMap(t *testing.T) {
+	m, err := ParseSeverityMap("warn=ops, error=on-call")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if m[SeverityWarn] != "ops" || m[SeverityError] != "on-call" || len(m) != 2 {
+		t.Errorf("Got %v wanted warn=ops,error=on-call", m)
+	}
+
+	if _, err := ParseSeverityMap("critical=on-call"); err == nil {
+		t.Errorf("Got no error wanted invalid severity error")
+	}
+	if _, err := ParseSeverityMap("error"); err == nil {
+		t.Errorf("Got no error wanted invalid mapping error")
+	}
+}
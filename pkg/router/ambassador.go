@@ -0,0 +1,146 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	ambassadorv2 "github.com/weaveworks/flagger/pkg/apis/ambassador/v2"
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AmbassadorRouter is managing Ambassador (Emissary-ingress) mappings,
+// the primary mapping is owned by the user and Flagger manages
+// a canary mapping with the same prefix and a weight
+type AmbassadorRouter struct {
+	kubeClient       kubernetes.Interface
+	ambassadorClient clientset.Interface
+	flaggerClient    clientset.Interface
+	logger           *zap.SugaredLogger
+}
+
+// Sync creates or updates the canary mapping from the primary mapping spec
+func (ar *AmbassadorRouter) Sync(canary *flaggerv1.Canary) error {
+	if len(canary.Spec.CanaryAnalysis.Match) > 0 || len(canary.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		return fmt.Errorf("canary %s.%s match conditions are not supported by Ambassador mappings",
+			canary.Name, canary.Namespace)
+	}
+
+	targetName := canary.Spec.TargetRef.Name
+	canaryName := fmt.Sprintf("%s-canary", targetName)
+
+	primary, err := ar.ambassadorClient.AmbassadorV2().Mappings(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("Mapping %s.%s not found, the primary mapping is required", targetName, canary.Namespace)
+		}
+		return fmt.Errorf("Mapping %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	spec := primary.Spec.DeepCopy()
+	spec.Service = fmt.Sprintf("%s.%s:%v", canaryName, canary.Namespace, canary.Spec.Service.Port)
+	spec.Weight = 0
+
+	mapping, err := ar.ambassadorClient.AmbassadorV2().Mappings(canary.Namespace).Get(canaryName, metav1.GetOptions{})
+
+	// create the canary mapping
+	if errors.IsNotFound(err) {
+		mapping = &ambassadorv2.Mapping{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      canaryName,
+				Namespace: canary.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(canary, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: *spec,
+		}
+		_, err = ar.ambassadorClient.AmbassadorV2().Mappings(canary.Namespace).Create(mapping)
+		if err != nil {
+			return fmt.Errorf("Mapping %s.%s create error %v", canaryName, canary.Namespace, err)
+		}
+		ar.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("Mapping %s.%s created", canaryName, canary.Namespace)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("Mapping %s.%s query error %v", canaryName, canary.Namespace, err)
+	}
+
+	// follow the primary mapping changes but keep the canary weight
+	ignoreWeight := cmpopts.IgnoreFields(ambassadorv2.MappingSpec{}, "Weight")
+	if diff := cmp.Diff(*spec, mapping.Spec, ignoreWeight); diff != "" {
+		clone := mapping.DeepCopy()
+		spec.Weight = mapping.Spec.Weight
+		clone.Spec = *spec
+
+		_, err = ar.ambassadorClient.AmbassadorV2().Mappings(canary.Namespace).Update(clone)
+		if err != nil {
+			return fmt.Errorf("Mapping %s.%s update error %v", canaryName, canary.Namespace, err)
+		}
+		ar.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("Mapping %s.%s updated", canaryName, canary.Namespace)
+	}
+
+	return nil
+}
+
+// GetRoutes returns the canary mapping weight,
+// the primary mapping receives the rest of the traffic
+func (ar *AmbassadorRouter) GetRoutes(canary *flaggerv1.Canary) (
+	primaryWeight int,
+	canaryWeight int,
+	err error,
+) {
+	canaryName := fmt.Sprintf("%s-canary", canary.Spec.TargetRef.Name)
+	mapping, err := ar.ambassadorClient.AmbassadorV2().Mappings(canary.Namespace).Get(canaryName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = fmt.Errorf("Mapping %s.%s not found", canaryName, canary.Namespace)
+			return
+		}
+		err = fmt.Errorf("Mapping %s.%s query error %v", canaryName, canary.Namespace, err)
+		return
+	}
+
+	canaryWeight = mapping.Spec.Weight
+	primaryWeight = 100 - canaryWeight
+	return
+}
+
+// SetRoutes updates the canary mapping weight
+func (ar *AmbassadorRouter) SetRoutes(
+	canary *flaggerv1.Canary,
+	primaryWeight int,
+	canaryWeight int,
+) error {
+	canaryName := fmt.Sprintf("%s-canary", canary.Spec.TargetRef.Name)
+	mapping, err := ar.ambassadorClient.AmbassadorV2().Mappings(canary.Namespace).Get(canaryName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("Mapping %s.%s not found", canaryName, canary.Namespace)
+		}
+		return fmt.Errorf("Mapping %s.%s query error %v", canaryName, canary.Namespace, err)
+	}
+
+	clone := mapping.DeepCopy()
+	clone.Spec.Weight = canaryWeight
+
+	_, err = ar.ambassadorClient.AmbassadorV2().Mappings(canary.Namespace).Update(clone)
+	if err != nil {
+		return fmt.Errorf("Mapping %s.%s update error %v", canaryName, canary.Namespace, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"testing"
+
+	ambassadorv2 "github.com/weaveworks/flagger/pkg/apis/ambassador/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newMockMapping() *ambassadorv2.Mapping {
+	return &ambassadorv2.Mapping{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podinfo",
+			Namespace: "default",
+		},
+		Spec: ambassadorv2.MappingSpec{
+			Prefix:  "/podinfo/",
+			Host:    "app.example.com",
+			Service: "podinfo.default:9898",
+		},
+	}
+}
+
+func TestAmbassadorRouter_Sync(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &AmbassadorRouter{
+		logger:           mocks.logger,
+		flaggerClient:    mocks.flaggerClient,
+		ambassadorClient: mocks.meshClient,
+		kubeClient:       mocks.kubeClient,
+	}
+
+	// the primary mapping is required
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Errorf("Got no error wanted primary mapping not found")
+	}
+
+	primary, err := mocks.meshClient.AmbassadorV2().Mappings("default").Create(newMockMapping())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mapping, err := mocks.meshClient.AmbassadorV2().Mappings("default").Get("podinfo-canary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if mapping.Spec.Prefix != "/podinfo/" || mapping.Spec.Host != "app.example.com" {
+		t.Errorf("Got prefix %s host %s wanted the primary mapping prefix and host", mapping.Spec.Prefix, mapping.Spec.Host)
+	}
+	if mapping.Spec.Service != "podinfo-canary.default:9898" {
+		t.Errorf("Got service %s wanted %s", mapping.Spec.Service, "podinfo-canary.default:9898")
+	}
+
+	// the canary mapping follows the primary mapping changes
+	if err := router.SetRoutes(mocks.canary, 70, 30); err != nil {
+		t.Fatal(err.Error())
+	}
+	primary.Spec.Prefix = "/api/"
+	if _, err := mocks.meshClient.AmbassadorV2().Mappings("default").Update(primary); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	mapping, err = mocks.meshClient.AmbassadorV2().Mappings("default").Get("podinfo-canary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if mapping.Spec.Prefix != "/api/" {
+		t.Errorf("Got prefix %s wanted %s", mapping.Spec.Prefix, "/api/")
+	}
+	if mapping.Spec.Weight != 30 {
+		t.Errorf("Got weight %v wanted %v", mapping.Spec.Weight, 30)
+	}
+
+	// test that A/B testing is rejected
+	if err := router.Sync(mocks.abtest); err == nil {
+		t.Errorf("Got no error wanted match not supported")
+	}
+}
+
+func TestAmbassadorRouter_SetRoutes(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &AmbassadorRouter{
+		logger:           mocks.logger,
+		flaggerClient:    mocks.flaggerClient,
+		ambassadorClient: mocks.meshClient,
+		kubeClient:       mocks.kubeClient,
+	}
+
+	if _, err := mocks.meshClient.AmbassadorV2().Mappings("default").Create(newMockMapping()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 100 || c != 0 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 100, 0)
+	}
+
+	if err := router.SetRoutes(mocks.canary, 60, 40); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, c, err = router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 60 || c != 40 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 60, 40)
+	}
+}
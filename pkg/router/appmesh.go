@@ -28,6 +28,17 @@ func (ar *AppMeshRouter) Sync(canary *flaggerv1.Canary) error {
 		return fmt.Errorf("mesh name cannot be empty")
 	}
 
+	// A/B testing is not supported by App Mesh, the match conditions are ignored
+	// to keep the existing canaries in sync
+	if len(canary.Spec.CanaryAnalysis.Match) > 0 {
+		ar.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Warnf("Canary %s.%s match is not supported by App Mesh and will be ignored", canary.Name, canary.Namespace)
+	}
+
+	if err := validateAppMeshMatch(canary); err != nil {
+		return err
+	}
+
 	targetName := canary.Spec.TargetRef.Name
 	targetHost := fmt.Sprintf("%s.%s", targetName, canary.Namespace)
 	primaryName := fmt.Sprintf("%s-primary", targetName)
@@ -190,6 +201,27 @@ func (ar *AppMeshRouter) syncVirtualService(canary *flaggerv1.Canary, name strin
 		},
 	}
 
+	// weighted routing scoped to the URI prefix, the rest of the traffic goes to primary
+	if len(canary.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		vsSpec.Routes[0].Http.Match.Prefix = canary.Spec.CanaryAnalysis.WeightedMatch[0].Uri.Prefix
+		vsSpec.Routes = append(vsSpec.Routes, appmeshv1alpha1.Route{
+			Name: fmt.Sprintf("%s-%s-route-primary", targetName, canary.Namespace),
+			Http: appmeshv1alpha1.HttpRoute{
+				Match: appmeshv1alpha1.HttpRouteMatch{
+					Prefix: routePrefix,
+				},
+				Action: appmeshv1alpha1.HttpRouteAction{
+					WeightedTargets: []appmeshv1alpha1.WeightedTarget{
+						{
+							VirtualNodeName: primaryVirtualNode,
+							Weight:          100,
+						},
+					},
+				},
+			},
+		})
+	}
+
 	virtualService, err := ar.appmeshClient.AppmeshV1alpha1().VirtualServices(canary.Namespace).Get(name, metav1.GetOptions{})
 
 	// create virtual service
@@ -318,3 +350,26 @@ func (ar *AppMeshRouter) SetRoutes(
 
 	return nil
 }
+
+// validateAppMeshMatch checks if the canary weighted match conditions can be translated to App Mesh routes,
+// App Mesh supports only a single URI prefix match
+func validateAppMeshMatch(canary *flaggerv1.Canary) error {
+	match := canary.Spec.CanaryAnalysis.WeightedMatch
+	if len(match) == 0 {
+		return nil
+	}
+
+	if len(match) > 1 {
+		return fmt.Errorf("canary %s.%s weightedMatch supports a single condition with App Mesh",
+			canary.Name, canary.Namespace)
+	}
+
+	if match[0].Uri == nil || match[0].Uri.Prefix == "" ||
+		match[0].Headers != nil || match[0].Method != nil ||
+		match[0].Scheme != nil || match[0].Authority != nil {
+		return fmt.Errorf("canary %s.%s weightedMatch supports only URI prefix with App Mesh",
+			canary.Name, canary.Namespace)
+	}
+
+	return nil
+}
@@ -2,6 +2,8 @@ package router
 
 import (
 	"fmt"
+	istiov1alpha1 "github.com/weaveworks/flagger/pkg/apis/istio/common/v1alpha1"
+	istiov1alpha3 "github.com/weaveworks/flagger/pkg/apis/istio/v1alpha3"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"testing"
 )
@@ -162,3 +164,92 @@ func TestAppmeshRouter_GetSetRoutes(t *testing.T) {
 		t.Errorf("Got canary weight %v wanted %v", c, 40)
 	}
 }
+
+func TestAppmeshRouter_WeightedMatch(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &AppMeshRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		appmeshClient: mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	canary := mocks.appmeshCanary.DeepCopy()
+	canary.Spec.CanaryAnalysis.WeightedMatch = []istiov1alpha3.HTTPMatchRequest{
+		{
+			Uri: &istiov1alpha1.StringMatch{
+				Prefix: "/v2",
+			},
+		},
+	}
+
+	err := router.Sync(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vsName := fmt.Sprintf("%s.%s", canary.Spec.TargetRef.Name, canary.Namespace)
+	vs, err := router.appmeshClient.AppmeshV1alpha1().VirtualServices("default").Get(vsName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(vs.Spec.Routes) != 2 {
+		t.Fatalf("Got routes %v wanted %v", len(vs.Spec.Routes), 2)
+	}
+
+	if vs.Spec.Routes[0].Http.Match.Prefix != "/v2" {
+		t.Errorf("Got prefix %v wanted %v", vs.Spec.Routes[0].Http.Match.Prefix, "/v2")
+	}
+
+	if vs.Spec.Routes[1].Http.Match.Prefix != "/" {
+		t.Errorf("Got prefix %v wanted %v", vs.Spec.Routes[1].Http.Match.Prefix, "/")
+	}
+
+	// headers are not supported by App Mesh
+	canary.Spec.CanaryAnalysis.WeightedMatch[0].Headers = map[string]istiov1alpha1.StringMatch{
+		"x-user-type": {
+			Exact: "test",
+		},
+	}
+	err = router.Sync(canary)
+	if err == nil {
+		t.Errorf("Got no error wanted match validation error")
+	}
+}
+
+func TestAppmeshRouter_MatchIgnored(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &AppMeshRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		appmeshClient: mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	canary := mocks.appmeshCanary.DeepCopy()
+	canary.Spec.CanaryAnalysis.Match = []istiov1alpha3.HTTPMatchRequest{
+		{
+			Headers: map[string]istiov1alpha1.StringMatch{
+				"x-user-type": {
+					Exact: "test",
+				},
+			},
+		},
+	}
+
+	err := router.Sync(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vsName := fmt.Sprintf("%s.%s", canary.Spec.TargetRef.Name, canary.Namespace)
+	vs, err := router.appmeshClient.AppmeshV1alpha1().VirtualServices("default").Get(vsName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(vs.Spec.Routes) != 1 {
+		t.Errorf("Got routes %v wanted %v", len(vs.Spec.Routes), 1)
+	}
+}
@@ -0,0 +1,345 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/google/go-cmp/cmp"
+	ciliumv2 "github.com/weaveworks/flagger/pkg/apis/cilium/v2"
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	envoyListenerType    = "type.googleapis.com/envoy.config.listener.v3.Listener"
+	envoyRouteConfigType = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+	envoyClusterType     = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+)
+
+// CiliumRouter is managing Cilium Envoy configs,
+// the apex service traffic is redirected to an Envoy listener
+// that splits it between the primary and canary clusters
+type CiliumRouter struct {
+	kubeClient    kubernetes.Interface
+	ciliumClient  clientset.Interface
+	flaggerClient clientset.Interface
+	logger        *zap.SugaredLogger
+}
+
+// envoyRouteConfig is the subset of the Envoy route configuration used to split the traffic
+type envoyRouteConfig struct {
+	Type         string             `json:"@type"`
+	Name         string             `json:"name"`
+	VirtualHosts []envoyVirtualHost `json:"virtual_hosts"`
+}
+
+type envoyVirtualHost struct {
+	Name    string       `json:"name"`
+	Domains []string     `json:"domains"`
+	Routes  []envoyRoute `json:"routes"`
+}
+
+type envoyRoute struct {
+	Match struct {
+		Prefix string `json:"prefix"`
+	} `json:"match"`
+	Route struct {
+		WeightedClusters struct {
+			Clusters []envoyClusterWeight `json:"clusters"`
+		} `json:"weighted_clusters"`
+		Timeout string `json:"timeout,omitempty"`
+	} `json:"route"`
+}
+
+type envoyClusterWeight struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// Sync creates or updates the Cilium Envoy config of the apex service
+func (cr *CiliumRouter) Sync(canary *flaggerv1.Canary) error {
+	if len(canary.Spec.CanaryAnalysis.Match) > 0 || len(canary.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		return fmt.Errorf("canary %s.%s match conditions are not supported by the Cilium router",
+			canary.Name, canary.Namespace)
+	}
+
+	targetName := canary.Spec.TargetRef.Name
+	cec, err := cr.ciliumClient.CiliumV2().CiliumEnvoyConfigs(canary.Namespace).Get(targetName, metav1.GetOptions{})
+
+	// create the Envoy config
+	if errors.IsNotFound(err) {
+		spec, err := cr.spec(canary, 100, 0)
+		if err != nil {
+			return err
+		}
+		cec = &ciliumv2.CiliumEnvoyConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      targetName,
+				Namespace: canary.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(canary, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: spec,
+		}
+		_, err = cr.ciliumClient.CiliumV2().CiliumEnvoyConfigs(canary.Namespace).Create(cec)
+		if err != nil {
+			return fmt.Errorf("CiliumEnvoyConfig %s.%s create error %v", targetName, canary.Namespace, err)
+		}
+		cr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("CiliumEnvoyConfig %s.%s created", targetName, canary.Namespace)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("CiliumEnvoyConfig %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	// update the listener and clusters but keep the weights
+	primaryWeight, canaryWeight, err := cr.weights(cec)
+	if err != nil {
+		primaryWeight, canaryWeight = 100, 0
+	}
+	spec, err := cr.spec(canary, primaryWeight, canaryWeight)
+	if err != nil {
+		return err
+	}
+	if diff := cmp.Diff(spec, cec.Spec, cmp.Comparer(rawResourceEqual)); diff != "" {
+		clone := cec.DeepCopy()
+		clone.Spec = spec
+
+		_, err = cr.ciliumClient.CiliumV2().CiliumEnvoyConfigs(canary.Namespace).Update(clone)
+		if err != nil {
+			return fmt.Errorf("CiliumEnvoyConfig %s.%s update error %v", targetName, canary.Namespace, err)
+		}
+		cr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("CiliumEnvoyConfig %s.%s updated", targetName, canary.Namespace)
+	}
+
+	return nil
+}
+
+// GetRoutes returns the primary and canary clusters weight
+func (cr *CiliumRouter) GetRoutes(canary *flaggerv1.Canary) (
+	primaryWeight int,
+	canaryWeight int,
+	err error,
+) {
+	targetName := canary.Spec.TargetRef.Name
+	cec, err := cr.ciliumClient.CiliumV2().CiliumEnvoyConfigs(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = fmt.Errorf("CiliumEnvoyConfig %s.%s not found", targetName, canary.Namespace)
+			return
+		}
+		err = fmt.Errorf("CiliumEnvoyConfig %s.%s query error %v", targetName, canary.Namespace, err)
+		return
+	}
+
+	return cr.weights(cec)
+}
+
+// SetRoutes updates the primary and canary clusters weight
+func (cr *CiliumRouter) SetRoutes(
+	canary *flaggerv1.Canary,
+	primaryWeight int,
+	canaryWeight int,
+) error {
+	targetName := canary.Spec.TargetRef.Name
+	cec, err := cr.ciliumClient.CiliumV2().CiliumEnvoyConfigs(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("CiliumEnvoyConfig %s.%s not found", targetName, canary.Namespace)
+		}
+		return fmt.Errorf("CiliumEnvoyConfig %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	index, _, err := cr.routeConfig(cec)
+	if err != nil {
+		return err
+	}
+	route, err := cr.routeConfigResource(canary, primaryWeight, canaryWeight)
+	if err != nil {
+		return err
+	}
+
+	clone := cec.DeepCopy()
+	clone.Spec.Resources[index] = route
+
+	_, err = cr.ciliumClient.CiliumV2().CiliumEnvoyConfigs(canary.Namespace).Update(clone)
+	if err != nil {
+		return fmt.Errorf("CiliumEnvoyConfig %s.%s update error %v", targetName, canary.Namespace, err)
+	}
+
+	return nil
+}
+
+func (cr *CiliumRouter) weights(cec *ciliumv2.CiliumEnvoyConfig) (primaryWeight int, canaryWeight int, err error) {
+	primaryCluster := fmt.Sprintf("%s/%s-primary", cec.Namespace, cec.Name)
+	canaryCluster := fmt.Sprintf("%s/%s-canary", cec.Namespace, cec.Name)
+
+	_, route, err := cr.routeConfig(cec)
+	if err != nil {
+		return
+	}
+
+	found := 0
+	for _, host := range route.VirtualHosts {
+		for _, r := range host.Routes {
+			for _, cluster := range r.Route.WeightedClusters.Clusters {
+				switch cluster.Name {
+				case primaryCluster:
+					primaryWeight = cluster.Weight
+					found++
+				case canaryCluster:
+					canaryWeight = cluster.Weight
+					found++
+				}
+			}
+		}
+	}
+
+	if found != 2 {
+		err = fmt.Errorf("CiliumEnvoyConfig %s.%s does not contain clusters for %s and %s",
+			cec.Name, cec.Namespace, primaryCluster, canaryCluster)
+	}
+	return
+}
+
+// routeConfig returns the index and the content of the Envoy route configuration resource
+func (cr *CiliumRouter) routeConfig(cec *ciliumv2.CiliumEnvoyConfig) (int, envoyRouteConfig, error) {
+	for i, resource := range cec.Spec.Resources {
+		var route envoyRouteConfig
+		if err := json.Unmarshal(resource.Raw, &route); err != nil {
+			return 0, route, fmt.Errorf("CiliumEnvoyConfig %s.%s resource unmarshal error %v", cec.Name, cec.Namespace, err)
+		}
+		if route.Type == envoyRouteConfigType {
+			return i, route, nil
+		}
+	}
+	return 0, envoyRouteConfig{}, fmt.Errorf("CiliumEnvoyConfig %s.%s does not contain a route configuration",
+		cec.Name, cec.Namespace)
+}
+
+func (cr *CiliumRouter) spec(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) (ciliumv2.CiliumEnvoyConfigSpec, error) {
+	targetName := canary.Spec.TargetRef.Name
+	primaryName := fmt.Sprintf("%s-primary", targetName)
+	canaryName := fmt.Sprintf("%s-canary", targetName)
+
+	route, err := cr.routeConfigResource(canary, primaryWeight, canaryWeight)
+	if err != nil {
+		return ciliumv2.CiliumEnvoyConfigSpec{}, err
+	}
+	resources := []runtime.RawExtension{cr.listenerResource(targetName), route}
+	for _, name := range []string{primaryName, canaryName} {
+		resources = append(resources, cr.clusterResource(fmt.Sprintf("%s/%s", canary.Namespace, name)))
+	}
+
+	return ciliumv2.CiliumEnvoyConfigSpec{
+		Services: []ciliumv2.ServiceListener{
+			{Name: targetName, Namespace: canary.Namespace, Listener: targetName},
+		},
+		BackendServices: []ciliumv2.Service{
+			{Name: primaryName, Namespace: canary.Namespace},
+			{Name: canaryName, Namespace: canary.Namespace},
+		},
+		Resources: resources,
+	}, nil
+}
+
+// routeConfigResource returns the Envoy route configuration
+// that splits the requests between the primary and canary clusters
+func (cr *CiliumRouter) routeConfigResource(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) (runtime.RawExtension, error) {
+	targetName := canary.Spec.TargetRef.Name
+
+	var route envoyRoute
+	route.Match.Prefix = "/"
+	route.Route.Timeout = canary.Spec.Service.Timeout
+	route.Route.WeightedClusters.Clusters = []envoyClusterWeight{
+		{Name: fmt.Sprintf("%s/%s-primary", canary.Namespace, targetName), Weight: primaryWeight},
+		{Name: fmt.Sprintf("%s/%s-canary", canary.Namespace, targetName), Weight: canaryWeight},
+	}
+
+	data, err := json.Marshal(envoyRouteConfig{
+		Type: envoyRouteConfigType,
+		Name: targetName,
+		VirtualHosts: []envoyVirtualHost{
+			{Name: targetName, Domains: []string{"*"}, Routes: []envoyRoute{route}},
+		},
+	})
+	if err != nil {
+		return runtime.RawExtension{}, fmt.Errorf("route configuration %s.%s marshal error %v", targetName, canary.Namespace, err)
+	}
+	return runtime.RawExtension{Raw: data}, nil
+}
+
+// listenerResource returns the Envoy listener of the apex service,
+// the HTTP connection manager loads the routes from the route configuration of the same name
+func (cr *CiliumRouter) listenerResource(name string) runtime.RawExtension {
+	listener := map[string]interface{}{
+		"@type": envoyListenerType,
+		"name":  name,
+		"filter_chains": []interface{}{
+			map[string]interface{}{
+				"filters": []interface{}{
+					map[string]interface{}{
+						"name": "envoy.filters.network.http_connection_manager",
+						"typed_config": map[string]interface{}{
+							"@type":       "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+							"stat_prefix": name,
+							"rds":         map[string]interface{}{"route_config_name": name},
+							"http_filters": []interface{}{
+								map[string]interface{}{
+									"name": "envoy.filters.http.router",
+									"typed_config": map[string]interface{}{
+										"@type": "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	data, _ := json.Marshal(listener)
+	return runtime.RawExtension{Raw: data}
+}
+
+// clusterResource returns an Envoy cluster that gets its endpoints
+// from the backend service of the same name
+func (cr *CiliumRouter) clusterResource(name string) runtime.RawExtension {
+	cluster := map[string]interface{}{
+		"@type":           envoyClusterType,
+		"name":            name,
+		"connect_timeout": "5s",
+		"lb_policy":       "ROUND_ROBIN",
+		"type":            "EDS",
+	}
+	data, _ := json.Marshal(cluster)
+	return runtime.RawExtension{Raw: data}
+}
+
+// rawResourceEqual compares the JSON content of the Envoy resources,
+// the API server doesn't preserve the order of the fields
+func rawResourceEqual(a, b runtime.RawExtension) bool {
+	var x, y interface{}
+	if err := json.Unmarshal(a.Raw, &x); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b.Raw, &y); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCiliumRouter_Sync(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &CiliumRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		ciliumClient:  mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cec, err := mocks.meshClient.CiliumV2().CiliumEnvoyConfigs("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(cec.Spec.Services) != 1 || cec.Spec.Services[0].Name != "podinfo" || cec.Spec.Services[0].Listener != "podinfo" {
+		t.Errorf("Got services %+v wanted podinfo redirected to the podinfo listener", cec.Spec.Services)
+	}
+	if len(cec.Spec.BackendServices) != 2 || cec.Spec.BackendServices[1].Name != "podinfo-canary" {
+		t.Errorf("Got backend services %+v wanted podinfo-primary and podinfo-canary", cec.Spec.BackendServices)
+	}
+	if len(cec.Spec.Resources) != 4 {
+		t.Fatalf("Got %v resources wanted %v", len(cec.Spec.Resources), 4)
+	}
+
+	// the config follows the canary changes but keeps the weights
+	if err := router.SetRoutes(mocks.canary, 80, 20); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.canary.Spec.Service.Timeout = "30s"
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cec, err = mocks.meshClient.CiliumV2().CiliumEnvoyConfigs("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, route, err := router.routeConfig(cec)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if timeout := route.VirtualHosts[0].Routes[0].Route.Timeout; timeout != "30s" {
+		t.Errorf("Got timeout %v wanted %v", timeout, "30s")
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 80 || c != 20 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 80, 20)
+	}
+}
+
+func TestCiliumRouter_SetRoutes(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &CiliumRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		ciliumClient:  mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 100 || c != 0 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 100, 0)
+	}
+
+	if err := router.SetRoutes(mocks.canary, 50, 50); err != nil {
+		t.Fatal(err.Error())
+	}
+	p, c, err = router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 50 || c != 50 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 50, 50)
+	}
+}
@@ -0,0 +1,205 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	externaldnsv1alpha1 "github.com/weaveworks/flagger/pkg/apis/externaldns/v1alpha1"
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// weightProperty is the Route53 weighted routing setting of external-dns
+const weightProperty = "aws/weight"
+
+// ExternalDNSRouter is managing weighted DNS records with external-dns endpoints,
+// the traffic shift is limited by the record TTL and the DNS resolvers caching
+type ExternalDNSRouter struct {
+	kubeClient        kubernetes.Interface
+	externalDNSClient clientset.Interface
+	flaggerClient     clientset.Interface
+	logger            *zap.SugaredLogger
+}
+
+// Sync creates or updates the DNS endpoint with the primary and canary weighted records
+func (er *ExternalDNSRouter) Sync(canary *flaggerv1.Canary) error {
+	if len(canary.Spec.CanaryAnalysis.Match) > 0 || len(canary.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		return fmt.Errorf("canary %s.%s match conditions are not supported by DNS weighted records",
+			canary.Name, canary.Namespace)
+	}
+	dns := canary.Spec.Service.DNS
+	if dns == nil || dns.Name == "" || len(dns.PrimaryTargets) == 0 || len(dns.CanaryTargets) == 0 {
+		return fmt.Errorf("canary %s.%s service dns name, primaryTargets and canaryTargets are required",
+			canary.Name, canary.Namespace)
+	}
+
+	targetName := canary.Spec.TargetRef.Name
+	spec := externaldnsv1alpha1.DNSEndpointSpec{
+		Endpoints: er.endpoints(canary, 100, 0),
+	}
+
+	endpoint, err := er.externalDNSClient.ExternalDNSV1alpha1().DNSEndpoints(canary.Namespace).Get(targetName, metav1.GetOptions{})
+
+	// create the DNS endpoint
+	if errors.IsNotFound(err) {
+		endpoint = &externaldnsv1alpha1.DNSEndpoint{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      targetName,
+				Namespace: canary.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(canary, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: spec,
+		}
+		_, err = er.externalDNSClient.ExternalDNSV1alpha1().DNSEndpoints(canary.Namespace).Create(endpoint)
+		if err != nil {
+			return fmt.Errorf("DNSEndpoint %s.%s create error %v", targetName, canary.Namespace, err)
+		}
+		er.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("DNSEndpoint %s.%s created", targetName, canary.Namespace)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("DNSEndpoint %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	// update the records but keep the weights
+	ignoreWeights := cmpopts.IgnoreFields(externaldnsv1alpha1.Endpoint{}, "ProviderSpecific")
+	if diff := cmp.Diff(spec, endpoint.Spec, ignoreWeights); diff != "" {
+		primaryWeight, canaryWeight, err := er.weights(endpoint)
+		if err != nil {
+			primaryWeight, canaryWeight = 100, 0
+		}
+		clone := endpoint.DeepCopy()
+		clone.Spec.Endpoints = er.endpoints(canary, primaryWeight, canaryWeight)
+
+		_, err = er.externalDNSClient.ExternalDNSV1alpha1().DNSEndpoints(canary.Namespace).Update(clone)
+		if err != nil {
+			return fmt.Errorf("DNSEndpoint %s.%s update error %v", targetName, canary.Namespace, err)
+		}
+		er.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("DNSEndpoint %s.%s updated", targetName, canary.Namespace)
+	}
+
+	return nil
+}
+
+// GetRoutes returns the primary and canary records weight
+func (er *ExternalDNSRouter) GetRoutes(canary *flaggerv1.Canary) (
+	primaryWeight int,
+	canaryWeight int,
+	err error,
+) {
+	targetName := canary.Spec.TargetRef.Name
+	endpoint, err := er.externalDNSClient.ExternalDNSV1alpha1().DNSEndpoints(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = fmt.Errorf("DNSEndpoint %s.%s not found", targetName, canary.Namespace)
+			return
+		}
+		err = fmt.Errorf("DNSEndpoint %s.%s query error %v", targetName, canary.Namespace, err)
+		return
+	}
+
+	return er.weights(endpoint)
+}
+
+// SetRoutes updates the primary and canary records weight
+func (er *ExternalDNSRouter) SetRoutes(
+	canary *flaggerv1.Canary,
+	primaryWeight int,
+	canaryWeight int,
+) error {
+	targetName := canary.Spec.TargetRef.Name
+	endpoint, err := er.externalDNSClient.ExternalDNSV1alpha1().DNSEndpoints(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("DNSEndpoint %s.%s not found", targetName, canary.Namespace)
+		}
+		return fmt.Errorf("DNSEndpoint %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	clone := endpoint.DeepCopy()
+	clone.Spec.Endpoints = er.endpoints(canary, primaryWeight, canaryWeight)
+
+	_, err = er.externalDNSClient.ExternalDNSV1alpha1().DNSEndpoints(canary.Namespace).Update(clone)
+	if err != nil {
+		return fmt.Errorf("DNSEndpoint %s.%s update error %v", targetName, canary.Namespace, err)
+	}
+
+	return nil
+}
+
+func (er *ExternalDNSRouter) weights(endpoint *externaldnsv1alpha1.DNSEndpoint) (primaryWeight int, canaryWeight int, err error) {
+	primaryID := fmt.Sprintf("%s-primary", endpoint.Name)
+	canaryID := fmt.Sprintf("%s-canary", endpoint.Name)
+
+	found := 0
+	for _, e := range endpoint.Spec.Endpoints {
+		if e.SetIdentifier != primaryID && e.SetIdentifier != canaryID {
+			continue
+		}
+		for _, p := range e.ProviderSpecific {
+			if p.Name != weightProperty {
+				continue
+			}
+			weight, err := strconv.Atoi(p.Value)
+			if err != nil {
+				return 0, 0, fmt.Errorf("DNSEndpoint %s.%s record %s invalid weight %s",
+					endpoint.Name, endpoint.Namespace, e.SetIdentifier, p.Value)
+			}
+			if e.SetIdentifier == primaryID {
+				primaryWeight = weight
+			} else {
+				canaryWeight = weight
+			}
+			found++
+		}
+	}
+
+	if found != 2 {
+		err = fmt.Errorf("DNSEndpoint %s.%s does not contain weighted records for %s and %s",
+			endpoint.Name, endpoint.Namespace, primaryID, canaryID)
+	}
+	return
+}
+
+func (er *ExternalDNSRouter) endpoints(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) []externaldnsv1alpha1.Endpoint {
+	targetName := canary.Spec.TargetRef.Name
+	dns := canary.Spec.Service.DNS
+	recordType := dns.RecordType
+	if recordType == "" {
+		recordType = "CNAME"
+	}
+
+	record := func(id string, targets []string, weight int) externaldnsv1alpha1.Endpoint {
+		return externaldnsv1alpha1.Endpoint{
+			DNSName:       dns.Name,
+			Targets:       targets,
+			RecordType:    recordType,
+			SetIdentifier: id,
+			RecordTTL:     dns.TTL,
+			ProviderSpecific: []externaldnsv1alpha1.ProviderSpecificProperty{
+				{Name: weightProperty, Value: strconv.Itoa(weight)},
+			},
+		}
+	}
+
+	return []externaldnsv1alpha1.Endpoint{
+		record(fmt.Sprintf("%s-primary", targetName), dns.PrimaryTargets, primaryWeight),
+		record(fmt.Sprintf("%s-canary", targetName), dns.CanaryTargets, canaryWeight),
+	}
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExternalDNSRouter_Sync(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &ExternalDNSRouter{
+		logger:            mocks.logger,
+		flaggerClient:     mocks.flaggerClient,
+		externalDNSClient: mocks.meshClient,
+		kubeClient:        mocks.kubeClient,
+	}
+
+	// the DNS settings are required
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Errorf("Got no error wanted dns settings required")
+	}
+
+	mocks.canary.Spec.Service.DNS = &v1alpha3.CanaryDNS{
+		Name:           "app.example.com",
+		TTL:            30,
+		PrimaryTargets: []string{"primary.elb.amazonaws.com"},
+		CanaryTargets:  []string{"canary.elb.amazonaws.com"},
+	}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	endpoint, err := mocks.meshClient.ExternalDNSV1alpha1().DNSEndpoints("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(endpoint.Spec.Endpoints) != 2 {
+		t.Fatalf("Got %v records wanted %v", len(endpoint.Spec.Endpoints), 2)
+	}
+	record := endpoint.Spec.Endpoints[1]
+	if record.SetIdentifier != "podinfo-canary" || record.RecordType != "CNAME" || record.Targets[0] != "canary.elb.amazonaws.com" {
+		t.Errorf("Got record %+v wanted the canary CNAME record", record)
+	}
+
+	// the records follow the canary changes but keep their weights
+	if err := router.SetRoutes(mocks.canary, 80, 20); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.canary.Spec.Service.DNS.CanaryTargets = []string{"canary-v2.elb.amazonaws.com"}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	endpoint, err = mocks.meshClient.ExternalDNSV1alpha1().DNSEndpoints("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if endpoint.Spec.Endpoints[1].Targets[0] != "canary-v2.elb.amazonaws.com" {
+		t.Errorf("Got targets %v wanted %s", endpoint.Spec.Endpoints[1].Targets, "canary-v2.elb.amazonaws.com")
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 80 || c != 20 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 80, 20)
+	}
+}
+
+func TestExternalDNSRouter_SetRoutes(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &ExternalDNSRouter{
+		logger:            mocks.logger,
+		flaggerClient:     mocks.flaggerClient,
+		externalDNSClient: mocks.meshClient,
+		kubeClient:        mocks.kubeClient,
+	}
+	mocks.canary.Spec.Service.DNS = &v1alpha3.CanaryDNS{
+		Name:           "app.example.com",
+		PrimaryTargets: []string{"10.0.0.1"},
+		CanaryTargets:  []string{"10.0.0.2"},
+		RecordType:     "A",
+	}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 100 || c != 0 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 100, 0)
+	}
+
+	if err := router.SetRoutes(mocks.canary, 50, 50); err != nil {
+		t.Fatal(err.Error())
+	}
+	p, c, err = router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 50 || c != 50 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 50, 50)
+	}
+}
@@ -1,27 +1,40 @@
 package router
 
 import (
+	"strings"
+
 	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
 	"go.uber.org/zap"
 	"k8s.io/client-go/kubernetes"
 )
 
 type Factory struct {
-	kubeClient    kubernetes.Interface
-	meshClient    clientset.Interface
-	flaggerClient clientset.Interface
-	logger        *zap.SugaredLogger
+	kubeClient     kubernetes.Interface
+	meshClient     clientset.Interface
+	flaggerClient  clientset.Interface
+	logger         *zap.SugaredLogger
+	remoteClusters []RemoteCluster
+}
+
+// RemoteCluster holds the clients of a cluster that runs the same canary,
+// the mesh routes of the remote clusters are kept in sync with the local ones
+type RemoteCluster struct {
+	Name       string
+	KubeClient kubernetes.Interface
+	MeshClient clientset.Interface
 }
 
 func NewFactory(kubeClient kubernetes.Interface,
 	flaggerClient clientset.Interface,
 	logger *zap.SugaredLogger,
-	meshClient clientset.Interface) *Factory {
+	meshClient clientset.Interface,
+	remoteClusters ...RemoteCluster) *Factory {
 	return &Factory{
-		meshClient:    meshClient,
-		kubeClient:    kubeClient,
-		flaggerClient: flaggerClient,
-		logger:        logger,
+		meshClient:     meshClient,
+		kubeClient:     kubeClient,
+		flaggerClient:  flaggerClient,
+		logger:         logger,
+		remoteClusters: remoteClusters,
 	}
 }
 
@@ -34,20 +47,104 @@ func (factory *Factory) KubernetesRouter() *KubernetesRouter {
 	}
 }
 
-// MeshRouter returns a service mesh router (Istio or AppMesh)
+// KnativeRouter returns a Knative service traffic split router
+func (factory *Factory) KnativeRouter() *KnativeRouter {
+	return &KnativeRouter{
+		logger:        factory.logger,
+		flaggerClient: factory.flaggerClient,
+	}
+}
+
+// MeshRouter returns a service mesh router (Istio, AppMesh, SMI) or an Ambassador mapping router,
+// a comma separated list of providers returns a router that updates all of them
+// and if remote clusters are configured the routes are set in every cluster mesh
 func (factory *Factory) MeshRouter(provider string) Interface {
+	local := factory.meshRouter(provider, factory.kubeClient, factory.meshClient)
+	if len(factory.remoteClusters) == 0 {
+		return local
+	}
+
+	// the local cluster comes first so its routes are the reference for the remote ones
+	routers := []Interface{local}
+	for _, cluster := range factory.remoteClusters {
+		routers = append(routers, factory.meshRouter(provider, cluster.KubeClient, cluster.MeshClient))
+	}
+	return &MultiRouter{
+		routers: routers,
+		logger:  factory.logger,
+	}
+}
+
+func (factory *Factory) meshRouter(provider string, kubeClient kubernetes.Interface, meshClient clientset.Interface) Interface {
+	if providers := strings.Split(provider, ","); len(providers) > 1 {
+		routers := make([]Interface, 0, len(providers))
+		for _, p := range providers {
+			routers = append(routers, factory.meshRouter(strings.TrimSpace(p), kubeClient, meshClient))
+		}
+		return &MultiRouter{
+			routers: routers,
+			logger:  factory.logger,
+		}
+	}
+
+	if provider == "smi" || provider == "osm" {
+		return &SmiRouter{
+			logger:                factory.logger,
+			flaggerClient:         factory.flaggerClient,
+			kubeClient:            kubeClient,
+			smiClient:             meshClient,
+			namespacedRootService: provider == "osm",
+		}
+	}
+
+	if provider == "ambassador" {
+		return &AmbassadorRouter{
+			logger:           factory.logger,
+			flaggerClient:    factory.flaggerClient,
+			kubeClient:       kubeClient,
+			ambassadorClient: meshClient,
+		}
+	}
+
+	if provider == "externaldns" {
+		return &ExternalDNSRouter{
+			logger:            factory.logger,
+			flaggerClient:     factory.flaggerClient,
+			kubeClient:        kubeClient,
+			externalDNSClient: meshClient,
+		}
+	}
+
+	if provider == "gatewayapi" {
+		return &GatewayAPIRouter{
+			logger:           factory.logger,
+			flaggerClient:    factory.flaggerClient,
+			kubeClient:       kubeClient,
+			gatewayAPIClient: meshClient,
+		}
+	}
+
+	if provider == "cilium" {
+		return &CiliumRouter{
+			logger:        factory.logger,
+			flaggerClient: factory.flaggerClient,
+			kubeClient:    kubeClient,
+			ciliumClient:  meshClient,
+		}
+	}
+
 	if provider == "appmesh" {
 		return &AppMeshRouter{
 			logger:        factory.logger,
 			flaggerClient: factory.flaggerClient,
-			kubeClient:    factory.kubeClient,
-			appmeshClient: factory.meshClient,
+			kubeClient:    kubeClient,
+			appmeshClient: meshClient,
 		}
 	}
 	return &IstioRouter{
 		logger:        factory.logger,
 		flaggerClient: factory.flaggerClient,
-		kubeClient:    factory.kubeClient,
-		istioClient:   factory.meshClient,
+		kubeClient:    kubeClient,
+		istioClient:   meshClient,
 	}
 }
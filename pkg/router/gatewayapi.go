@@ -0,0 +1,217 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	gatewayapiv1beta1 "github.com/weaveworks/flagger/pkg/apis/gatewayapi/v1beta1"
+	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GatewayAPIRouter is managing Kubernetes Gateway API HTTP routes,
+// the traffic is split with the weights of the primary and canary backends
+type GatewayAPIRouter struct {
+	kubeClient       kubernetes.Interface
+	gatewayAPIClient clientset.Interface
+	flaggerClient    clientset.Interface
+	logger           *zap.SugaredLogger
+}
+
+// Sync creates or updates the HTTP route attached to the canary gateways
+func (gr *GatewayAPIRouter) Sync(canary *flaggerv1.Canary) error {
+	if len(canary.Spec.CanaryAnalysis.Match) > 0 || len(canary.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		return fmt.Errorf("canary %s.%s match conditions are not supported by the Gateway API router",
+			canary.Name, canary.Namespace)
+	}
+
+	targetName := canary.Spec.TargetRef.Name
+	spec := gatewayapiv1beta1.HTTPRouteSpec{
+		ParentRefs: gr.parentRefs(canary),
+		Hostnames:  canary.Spec.Service.Hosts,
+		Rules: []gatewayapiv1beta1.HTTPRouteRule{
+			{BackendRefs: gr.backendRefs(canary, 100, 0)},
+		},
+	}
+
+	route, err := gr.gatewayAPIClient.GatewayAPIV1beta1().HTTPRoutes(canary.Namespace).Get(targetName, metav1.GetOptions{})
+
+	// create the HTTP route
+	if errors.IsNotFound(err) {
+		route = &gatewayapiv1beta1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      targetName,
+				Namespace: canary.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(canary, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: spec,
+		}
+		_, err = gr.gatewayAPIClient.GatewayAPIV1beta1().HTTPRoutes(canary.Namespace).Create(route)
+		if err != nil {
+			return fmt.Errorf("HTTPRoute %s.%s create error %v", targetName, canary.Namespace, err)
+		}
+		gr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("HTTPRoute %s.%s created", targetName, canary.Namespace)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("HTTPRoute %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	// update the gateways and host names but keep the weights
+	ignoreWeights := cmpopts.IgnoreFields(gatewayapiv1beta1.BackendRef{}, "Weight")
+	if diff := cmp.Diff(spec, route.Spec, ignoreWeights); diff != "" {
+		primaryWeight, canaryWeight, err := gr.weights(route)
+		if err != nil {
+			primaryWeight, canaryWeight = 100, 0
+		}
+		clone := route.DeepCopy()
+		clone.Spec = spec
+		clone.Spec.Rules[0].BackendRefs = gr.backendRefs(canary, primaryWeight, canaryWeight)
+
+		_, err = gr.gatewayAPIClient.GatewayAPIV1beta1().HTTPRoutes(canary.Namespace).Update(clone)
+		if err != nil {
+			return fmt.Errorf("HTTPRoute %s.%s update error %v", targetName, canary.Namespace, err)
+		}
+		gr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("HTTPRoute %s.%s updated", targetName, canary.Namespace)
+	}
+
+	return nil
+}
+
+// GetRoutes returns the primary and canary backends weight
+func (gr *GatewayAPIRouter) GetRoutes(canary *flaggerv1.Canary) (
+	primaryWeight int,
+	canaryWeight int,
+	err error,
+) {
+	targetName := canary.Spec.TargetRef.Name
+	route, err := gr.gatewayAPIClient.GatewayAPIV1beta1().HTTPRoutes(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = fmt.Errorf("HTTPRoute %s.%s not found", targetName, canary.Namespace)
+			return
+		}
+		err = fmt.Errorf("HTTPRoute %s.%s query error %v", targetName, canary.Namespace, err)
+		return
+	}
+
+	return gr.weights(route)
+}
+
+// SetRoutes updates the primary and canary backends weight
+func (gr *GatewayAPIRouter) SetRoutes(
+	canary *flaggerv1.Canary,
+	primaryWeight int,
+	canaryWeight int,
+) error {
+	targetName := canary.Spec.TargetRef.Name
+	route, err := gr.gatewayAPIClient.GatewayAPIV1beta1().HTTPRoutes(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("HTTPRoute %s.%s not found", targetName, canary.Namespace)
+		}
+		return fmt.Errorf("HTTPRoute %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+	if len(route.Spec.Rules) == 0 {
+		return fmt.Errorf("HTTPRoute %s.%s does not contain any rule", targetName, canary.Namespace)
+	}
+
+	clone := route.DeepCopy()
+	clone.Spec.Rules[0].BackendRefs = gr.backendRefs(canary, primaryWeight, canaryWeight)
+
+	_, err = gr.gatewayAPIClient.GatewayAPIV1beta1().HTTPRoutes(canary.Namespace).Update(clone)
+	if err != nil {
+		return fmt.Errorf("HTTPRoute %s.%s update error %v", targetName, canary.Namespace, err)
+	}
+
+	return nil
+}
+
+func (gr *GatewayAPIRouter) weights(route *gatewayapiv1beta1.HTTPRoute) (primaryWeight int, canaryWeight int, err error) {
+	primaryName := fmt.Sprintf("%s-primary", route.Name)
+	canaryName := fmt.Sprintf("%s-canary", route.Name)
+
+	found := 0
+	for _, rule := range route.Spec.Rules {
+		for _, ref := range rule.BackendRefs {
+			if ref.Name != primaryName && ref.Name != canaryName {
+				continue
+			}
+			// a backend without weight receives the default weight of one
+			weight := 1
+			if ref.Weight != nil {
+				weight = int(*ref.Weight)
+			}
+			if ref.Name == primaryName {
+				primaryWeight = weight
+			} else {
+				canaryWeight = weight
+			}
+			found++
+		}
+	}
+
+	if found != 2 {
+		err = fmt.Errorf("HTTPRoute %s.%s does not contain backends for %s and %s",
+			route.Name, route.Namespace, primaryName, canaryName)
+	}
+	return
+}
+
+func (gr *GatewayAPIRouter) backendRefs(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) []gatewayapiv1beta1.HTTPBackendRef {
+	targetName := canary.Spec.TargetRef.Name
+	port := canary.Spec.Service.Port
+
+	backend := func(name string, weight int) gatewayapiv1beta1.HTTPBackendRef {
+		w := int32(weight)
+		p := port
+		return gatewayapiv1beta1.HTTPBackendRef{
+			BackendRef: gatewayapiv1beta1.BackendRef{
+				BackendObjectReference: gatewayapiv1beta1.BackendObjectReference{
+					Name: name,
+					Port: &p,
+				},
+				Weight: &w,
+			},
+		}
+	}
+
+	return []gatewayapiv1beta1.HTTPBackendRef{
+		backend(fmt.Sprintf("%s-primary", targetName), primaryWeight),
+		backend(fmt.Sprintf("%s-canary", targetName), canaryWeight),
+	}
+}
+
+// parentRefs maps the canary service gateways to the route parents,
+// a gateway can be specified as name or namespace/name
+func (gr *GatewayAPIRouter) parentRefs(canary *flaggerv1.Canary) []gatewayapiv1beta1.ParentReference {
+	var refs []gatewayapiv1beta1.ParentReference
+	for _, gateway := range canary.Spec.Service.Gateways {
+		if gateway == "mesh" {
+			continue
+		}
+		ref := gatewayapiv1beta1.ParentReference{Name: gateway}
+		if parts := strings.SplitN(gateway, "/", 2); len(parts) == 2 {
+			namespace := parts[0]
+			ref = gatewayapiv1beta1.ParentReference{Namespace: &namespace, Name: parts[1]}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
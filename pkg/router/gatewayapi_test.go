@@ -0,0 +1,99 @@
+package router
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGatewayAPIRouter_Sync(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &GatewayAPIRouter{
+		logger:           mocks.logger,
+		flaggerClient:    mocks.flaggerClient,
+		gatewayAPIClient: mocks.meshClient,
+		kubeClient:       mocks.kubeClient,
+	}
+
+	mocks.canary.Spec.Service.Gateways = []string{"mesh", "istio-system/public-gateway"}
+	mocks.canary.Spec.Service.Hosts = []string{"app.example.com"}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	route, err := mocks.meshClient.GatewayAPIV1beta1().HTTPRoutes("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(route.Spec.ParentRefs) != 1 {
+		t.Fatalf("Got %v parent refs wanted %v", len(route.Spec.ParentRefs), 1)
+	}
+	parent := route.Spec.ParentRefs[0]
+	if parent.Name != "public-gateway" || parent.Namespace == nil || *parent.Namespace != "istio-system" {
+		t.Errorf("Got parent ref %+v wanted istio-system/public-gateway", parent)
+	}
+	backends := route.Spec.Rules[0].BackendRefs
+	if len(backends) != 2 {
+		t.Fatalf("Got %v backends wanted %v", len(backends), 2)
+	}
+	if backends[1].Name != "podinfo-canary" || *backends[1].Port != 9898 || *backends[1].Weight != 0 {
+		t.Errorf("Got backend %+v wanted podinfo-canary:9898 with weight 0", backends[1].BackendRef)
+	}
+
+	// the route follows the canary changes but keeps the weights
+	if err := router.SetRoutes(mocks.canary, 80, 20); err != nil {
+		t.Fatal(err.Error())
+	}
+	mocks.canary.Spec.Service.Hosts = []string{"app.example.com", "www.example.com"}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	route, err = mocks.meshClient.GatewayAPIV1beta1().HTTPRoutes("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(route.Spec.Hostnames) != 2 {
+		t.Errorf("Got hostnames %v wanted %v", route.Spec.Hostnames, mocks.canary.Spec.Service.Hosts)
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 80 || c != 20 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 80, 20)
+	}
+}
+
+func TestGatewayAPIRouter_SetRoutes(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &GatewayAPIRouter{
+		logger:           mocks.logger,
+		flaggerClient:    mocks.flaggerClient,
+		gatewayAPIClient: mocks.meshClient,
+		kubeClient:       mocks.kubeClient,
+	}
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 100 || c != 0 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 100, 0)
+	}
+
+	if err := router.SetRoutes(mocks.canary, 50, 50); err != nil {
+		t.Fatal(err.Error())
+	}
+	p, c, err = router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 50 || c != 50 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 50, 50)
+	}
+}
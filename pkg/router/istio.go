@@ -25,8 +25,39 @@ type IstioRouter struct {
 
 // Sync creates or updates the Istio virtual service
 func (ir *IstioRouter) Sync(canary *flaggerv1.Canary) error {
+	if len(canary.Spec.CanaryAnalysis.Match) > 0 && len(canary.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		return fmt.Errorf("canary %s.%s match and weightedMatch are mutually exclusive",
+			canary.Name, canary.Namespace)
+	}
+
+	if canary.Spec.Service.Subsets != nil {
+		if canary.Spec.CanaryAnalysis.Baseline {
+			return fmt.Errorf("canary %s.%s baseline is not supported with subsets",
+				canary.Name, canary.Namespace)
+		}
+		if err := ir.validateSubsets(canary); err != nil {
+			return err
+		}
+	}
+
+	if canary.Spec.Service.External != nil {
+		if canary.Spec.Service.Subsets != nil || canary.Spec.CanaryAnalysis.Baseline {
+			return fmt.Errorf("canary %s.%s external service is not supported with subsets and baseline",
+				canary.Name, canary.Namespace)
+		}
+		if err := ir.validateExternal(canary); err != nil {
+			return err
+		}
+	}
+
+	delegation := canary.Spec.Service.Delegation
+	if delegation != nil && (len(canary.Spec.Service.Hosts) > 0 || len(canary.Spec.Service.Gateways) > 0 ||
+		len(canary.Spec.Service.CanaryGateways) > 0) {
+		return fmt.Errorf("canary %s.%s hosts and gateways are not supported with delegation",
+			canary.Name, canary.Namespace)
+	}
+
 	targetName := canary.Spec.TargetRef.Name
-	primaryName := fmt.Sprintf("%s-primary", targetName)
 
 	// set hosts and add the ClusterIP service host if it doesn't exists
 	hosts := canary.Spec.Service.Hosts
@@ -54,25 +85,37 @@ func (ir *IstioRouter) Sync(canary *flaggerv1.Canary) error {
 		gateways = append(gateways, "mesh")
 	}
 
+	// the canary traffic can be scoped only to the gateways bound to the virtual service
+	for _, g := range canary.Spec.Service.CanaryGateways {
+		found := false
+		for _, vg := range gateways {
+			if g == vg {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("canary %s.%s canary gateway %s is not in the service gateways %v",
+				canary.Name, canary.Namespace, g, gateways)
+		}
+	}
+
+	// a delegate virtual service can't have hosts and gateways,
+	// those are set on the root virtual service
+	if delegation != nil {
+		hosts = nil
+		gateways = nil
+	}
+
 	// create destinations with primary weight 100% and canary weight 0%
 	canaryRoute := []istiov1alpha3.DestinationWeight{
 		{
-			Destination: istiov1alpha3.Destination{
-				Host: primaryName,
-				Port: istiov1alpha3.PortSelector{
-					Number: uint32(canary.Spec.Service.Port),
-				},
-			},
-			Weight: 100,
+			Destination: primaryDestination(canary),
+			Weight:      100,
 		},
 		{
-			Destination: istiov1alpha3.Destination{
-				Host: fmt.Sprintf("%s-canary", targetName),
-				Port: istiov1alpha3.PortSelector{
-					Number: uint32(canary.Spec.Service.Port),
-				},
-			},
-			Weight: 0,
+			Destination: canaryDestination(canary),
+			Weight:      0,
 		},
 	}
 
@@ -92,8 +135,8 @@ func (ir *IstioRouter) Sync(canary *flaggerv1.Canary) error {
 		},
 	}
 
-	if len(canary.Spec.CanaryAnalysis.Match) > 0 {
-		canaryMatch := mergeMatchConditions(canary.Spec.CanaryAnalysis.Match, canary.Spec.Service.Match)
+	if match := canaryMatchConditions(canary); len(match) > 0 {
+		canaryMatch := mergeMatchConditions(match, canary.Spec.Service.Match)
 		newSpec.Http = []istiov1alpha3.HTTPRoute{
 			{
 				Match:         canaryMatch,
@@ -113,13 +156,8 @@ func (ir *IstioRouter) Sync(canary *flaggerv1.Canary) error {
 				AppendHeaders: addHeaders(canary),
 				Route: []istiov1alpha3.DestinationWeight{
 					{
-						Destination: istiov1alpha3.Destination{
-							Host: primaryName,
-							Port: istiov1alpha3.PortSelector{
-								Number: uint32(canary.Spec.Service.Port),
-							},
-						},
-						Weight: 100,
+						Destination: primaryDestination(canary),
+						Weight:      100,
 					},
 				},
 			},
@@ -149,7 +187,7 @@ func (ir *IstioRouter) Sync(canary *flaggerv1.Canary) error {
 		}
 		ir.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
 			Infof("VirtualService %s.%s created", virtualService.GetName(), canary.Namespace)
-		return nil
+		return ir.syncRoot(canary)
 	}
 
 	if err != nil {
@@ -173,6 +211,62 @@ func (ir *IstioRouter) Sync(canary *flaggerv1.Canary) error {
 		}
 	}
 
+	return ir.syncRoot(canary)
+}
+
+// syncRoot registers the delegate virtual service with the root virtual service,
+// the root is not owned by the canary and only its delegate route is updated
+func (ir *IstioRouter) syncRoot(canary *flaggerv1.Canary) error {
+	delegation := canary.Spec.Service.Delegation
+	if delegation == nil || delegation.Root == "" {
+		return nil
+	}
+
+	rootNamespace := delegation.RootNamespace
+	if rootNamespace == "" {
+		rootNamespace = canary.Namespace
+	}
+
+	root, err := ir.istioClient.NetworkingV1alpha3().VirtualServices(rootNamespace).Get(delegation.Root, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("root VirtualService %s.%s not found", delegation.Root, rootNamespace)
+		}
+		return fmt.Errorf("root VirtualService %s.%s query error %v", delegation.Root, rootNamespace, err)
+	}
+
+	delegate := istiov1alpha3.Delegate{
+		Name:      canary.Spec.TargetRef.Name,
+		Namespace: canary.Namespace,
+	}
+
+	rootClone := root.DeepCopy()
+	index := -1
+	for i, route := range rootClone.Spec.Http {
+		if route.Delegate != nil && *route.Delegate == delegate {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		rootClone.Spec.Http = append(rootClone.Spec.Http, istiov1alpha3.HTTPRoute{
+			Match:    delegation.Match,
+			Delegate: &delegate,
+		})
+	} else if diff := cmp.Diff(delegation.Match, rootClone.Spec.Http[index].Match, cmpopts.EquateEmpty()); diff != "" {
+		rootClone.Spec.Http[index].Match = delegation.Match
+	} else {
+		return nil
+	}
+
+	_, err = ir.istioClient.NetworkingV1alpha3().VirtualServices(rootNamespace).Update(rootClone)
+	if err != nil {
+		return fmt.Errorf("root VirtualService %s.%s update error %v", delegation.Root, rootNamespace, err)
+	}
+	ir.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+		Infof("VirtualService %s.%s delegate registered with root %s.%s",
+			delegate.Name, delegate.Namespace, delegation.Root, rootNamespace)
 	return nil
 }
 
@@ -194,10 +288,13 @@ func (ir *IstioRouter) GetRoutes(canary *flaggerv1.Canary) (
 		return
 	}
 
+	primary := primaryDestination(canary)
+	canaryDst := canaryDestination(canary)
+
 	var httpRoute istiov1alpha3.HTTPRoute
 	for _, http := range vs.Spec.Http {
 		for _, r := range http.Route {
-			if r.Destination.Host == fmt.Sprintf("%s-canary", targetName) {
+			if sameDestination(r.Destination, canaryDst) {
 				httpRoute = http
 				break
 			}
@@ -205,17 +302,19 @@ func (ir *IstioRouter) GetRoutes(canary *flaggerv1.Canary) (
 	}
 
 	for _, route := range httpRoute.Route {
-		if route.Destination.Host == fmt.Sprintf("%s-primary", targetName) {
-			primaryWeight = route.Weight
+		// the baseline weight is taken from the primary share
+		if sameDestination(route.Destination, primary) ||
+			route.Destination.Host == fmt.Sprintf("%s-baseline", targetName) {
+			primaryWeight += route.Weight
 		}
-		if route.Destination.Host == fmt.Sprintf("%s-canary", targetName) {
+		if sameDestination(route.Destination, canaryDst) {
 			canaryWeight = route.Weight
 		}
 	}
 
 	if primaryWeight == 0 && canaryWeight == 0 {
-		err = fmt.Errorf("VirtualService %s.%s does not contain routes for %s-primary and %s-canary",
-			targetName, canary.Namespace, targetName, targetName)
+		err = fmt.Errorf("VirtualService %s.%s does not contain routes for %s and %s",
+			targetName, canary.Namespace, destinationName(primary), destinationName(canaryDst))
 	}
 
 	return
@@ -250,31 +349,40 @@ func (ir *IstioRouter) SetRoutes(
 			AppendHeaders: addHeaders(canary),
 			Route: []istiov1alpha3.DestinationWeight{
 				{
-					Destination: istiov1alpha3.Destination{
-						Host: fmt.Sprintf("%s-primary", targetName),
-						Port: istiov1alpha3.PortSelector{
-							Number: uint32(canary.Spec.Service.Port),
-						},
-					},
-					Weight: primaryWeight,
+					Destination: primaryDestination(canary),
+					Weight:      primaryWeight,
 				},
 				{
-					Destination: istiov1alpha3.Destination{
-						Host: fmt.Sprintf("%s-canary", targetName),
-						Port: istiov1alpha3.PortSelector{
-							Number: uint32(canary.Spec.Service.Port),
-						},
-					},
-					Weight: canaryWeight,
+					Destination: canaryDestination(canary),
+					Weight:      canaryWeight,
 				},
 			},
 		},
 	}
 
-	// fix routing (A/B testing)
-	if len(canary.Spec.CanaryAnalysis.Match) > 0 {
+	// route the canary weight to the baseline as well so both receive the same traffic
+	if canary.Spec.CanaryAnalysis.Baseline && canaryWeight > 0 {
+		baselineWeight := canaryWeight
+		if baselineWeight > primaryWeight {
+			baselineWeight = primaryWeight
+		}
+		route := vsCopy.Spec.Http[0].Route
+		route[0].Weight = primaryWeight - baselineWeight
+		vsCopy.Spec.Http[0].Route = append(route, istiov1alpha3.DestinationWeight{
+			Destination: istiov1alpha3.Destination{
+				Host: fmt.Sprintf("%s-baseline", targetName),
+				Port: istiov1alpha3.PortSelector{
+					Number: uint32(canary.Spec.Service.Port),
+				},
+			},
+			Weight: baselineWeight,
+		})
+	}
+
+	// fix routing (A/B testing) or weighted routing scoped to the matching requests
+	if match := canaryMatchConditions(canary); len(match) > 0 {
 		// merge the common routes with the canary ones
-		canaryMatch := mergeMatchConditions(canary.Spec.CanaryAnalysis.Match, canary.Spec.Service.Match)
+		canaryMatch := mergeMatchConditions(match, canary.Spec.Service.Match)
 		vsCopy.Spec.Http = []istiov1alpha3.HTTPRoute{
 			{
 				Match:         canaryMatch,
@@ -285,22 +393,12 @@ func (ir *IstioRouter) SetRoutes(
 				AppendHeaders: addHeaders(canary),
 				Route: []istiov1alpha3.DestinationWeight{
 					{
-						Destination: istiov1alpha3.Destination{
-							Host: fmt.Sprintf("%s-primary", targetName),
-							Port: istiov1alpha3.PortSelector{
-								Number: uint32(canary.Spec.Service.Port),
-							},
-						},
-						Weight: primaryWeight,
+						Destination: primaryDestination(canary),
+						Weight:      primaryWeight,
 					},
 					{
-						Destination: istiov1alpha3.Destination{
-							Host: fmt.Sprintf("%s-canary", targetName),
-							Port: istiov1alpha3.PortSelector{
-								Number: uint32(canary.Spec.Service.Port),
-							},
-						},
-						Weight: canaryWeight,
+						Destination: canaryDestination(canary),
+						Weight:      canaryWeight,
 					},
 				},
 			},
@@ -313,13 +411,8 @@ func (ir *IstioRouter) SetRoutes(
 				AppendHeaders: addHeaders(canary),
 				Route: []istiov1alpha3.DestinationWeight{
 					{
-						Destination: istiov1alpha3.Destination{
-							Host: fmt.Sprintf("%s-primary", targetName),
-							Port: istiov1alpha3.PortSelector{
-								Number: uint32(canary.Spec.Service.Port),
-							},
-						},
-						Weight: primaryWeight,
+						Destination: primaryDestination(canary),
+						Weight:      primaryWeight,
 					},
 				},
 			},
@@ -334,6 +427,174 @@ func (ir *IstioRouter) SetRoutes(
 	return nil
 }
 
+// validateSubsets checks that the destination rule defines the primary and canary subsets
+func (ir *IstioRouter) validateSubsets(canary *flaggerv1.Canary) error {
+	subsets := canary.Spec.Service.Subsets
+	if subsets.Primary == "" || subsets.Canary == "" {
+		return fmt.Errorf("canary %s.%s primary and canary subsets are required", canary.Name, canary.Namespace)
+	}
+
+	drName := subsets.DestinationRule
+	if drName == "" {
+		drName = canary.Spec.TargetRef.Name
+	}
+
+	dr, err := ir.istioClient.NetworkingV1alpha3().DestinationRules(canary.Namespace).Get(drName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("DestinationRule %s.%s not found", drName, canary.Namespace)
+		}
+		return fmt.Errorf("DestinationRule %s.%s query error %v", drName, canary.Namespace, err)
+	}
+
+	for _, name := range []string{subsets.Primary, subsets.Canary} {
+		var found bool
+		for _, s := range dr.Spec.Subsets {
+			if s.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("DestinationRule %s.%s does not contain subset %s", drName, canary.Namespace, name)
+		}
+	}
+
+	return nil
+}
+
+// validateExternal checks that the service entry registers the external host and port,
+// and that the destination rule, if any, applies to the external host
+func (ir *IstioRouter) validateExternal(canary *flaggerv1.Canary) error {
+	external := canary.Spec.Service.External
+	if external.Target != flaggerv1.ExternalPrimary && external.Target != flaggerv1.ExternalCanary {
+		return fmt.Errorf("canary %s.%s external target %s must be primary or canary",
+			canary.Name, canary.Namespace, external.Target)
+	}
+	if external.ServiceEntry == "" || external.Host == "" {
+		return fmt.Errorf("canary %s.%s external service entry and host are required", canary.Name, canary.Namespace)
+	}
+
+	se, err := ir.istioClient.NetworkingV1alpha3().ServiceEntries(canary.Namespace).Get(external.ServiceEntry, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("ServiceEntry %s.%s not found", external.ServiceEntry, canary.Namespace)
+		}
+		return fmt.Errorf("ServiceEntry %s.%s query error %v", external.ServiceEntry, canary.Namespace, err)
+	}
+
+	var hasHost bool
+	for _, h := range se.Spec.Hosts {
+		if h == external.Host {
+			hasHost = true
+			break
+		}
+	}
+	if !hasHost {
+		return fmt.Errorf("ServiceEntry %s.%s does not contain host %s", external.ServiceEntry, canary.Namespace, external.Host)
+	}
+
+	port := externalDestination(canary).Port.Number
+	var hasPort bool
+	for _, p := range se.Spec.Ports {
+		if p.Number == port {
+			hasPort = true
+			break
+		}
+	}
+	if len(se.Spec.Ports) > 0 && !hasPort {
+		return fmt.Errorf("ServiceEntry %s.%s does not contain port %v", external.ServiceEntry, canary.Namespace, port)
+	}
+
+	if external.DestinationRule == "" {
+		return nil
+	}
+
+	dr, err := ir.istioClient.NetworkingV1alpha3().DestinationRules(canary.Namespace).Get(external.DestinationRule, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("DestinationRule %s.%s not found", external.DestinationRule, canary.Namespace)
+		}
+		return fmt.Errorf("DestinationRule %s.%s query error %v", external.DestinationRule, canary.Namespace, err)
+	}
+	if dr.Spec.Host != external.Host {
+		return fmt.Errorf("DestinationRule %s.%s host %s does not match the external host %s",
+			external.DestinationRule, canary.Namespace, dr.Spec.Host, external.Host)
+	}
+
+	return nil
+}
+
+// primaryDestination returns the primary route destination,
+// the primary service or the primary subset if the canary subsets are set
+func primaryDestination(canary *flaggerv1.Canary) istiov1alpha3.Destination {
+	if isExternal(canary, flaggerv1.ExternalPrimary) {
+		return externalDestination(canary)
+	}
+	if subsets := canary.Spec.Service.Subsets; subsets != nil {
+		return subsetDestination(canary, subsets.Primary)
+	}
+	return serviceDestination(canary, fmt.Sprintf("%s-primary", canary.Spec.TargetRef.Name))
+}
+
+// canaryDestination returns the canary route destination,
+// the canary service or the canary subset if the canary subsets are set
+func canaryDestination(canary *flaggerv1.Canary) istiov1alpha3.Destination {
+	if isExternal(canary, flaggerv1.ExternalCanary) {
+		return externalDestination(canary)
+	}
+	if subsets := canary.Spec.Service.Subsets; subsets != nil {
+		return subsetDestination(canary, subsets.Canary)
+	}
+	return serviceDestination(canary, fmt.Sprintf("%s-canary", canary.Spec.TargetRef.Name))
+}
+
+func serviceDestination(canary *flaggerv1.Canary, host string) istiov1alpha3.Destination {
+	return istiov1alpha3.Destination{
+		Host: host,
+		Port: istiov1alpha3.PortSelector{
+			Number: uint32(canary.Spec.Service.Port),
+		},
+	}
+}
+
+func subsetDestination(canary *flaggerv1.Canary, subset string) istiov1alpha3.Destination {
+	host := canary.Spec.Service.Subsets.Host
+	if host == "" {
+		host = canary.Spec.TargetRef.Name
+	}
+	destination := serviceDestination(canary, host)
+	destination.Subset = subset
+	return destination
+}
+
+// isExternal returns true if the route destination is served by the external service
+func isExternal(canary *flaggerv1.Canary, target flaggerv1.ExternalTarget) bool {
+	return canary.Spec.Service.External != nil && canary.Spec.Service.External.Target == target
+}
+
+// externalDestination returns the external host destination on the external port or the service port
+func externalDestination(canary *flaggerv1.Canary) istiov1alpha3.Destination {
+	external := canary.Spec.Service.External
+	destination := serviceDestination(canary, external.Host)
+	if external.Port > 0 {
+		destination.Port.Number = external.Port
+	}
+	return destination
+}
+
+// sameDestination compares the host and the subset of two destinations
+func sameDestination(a istiov1alpha3.Destination, b istiov1alpha3.Destination) bool {
+	return a.Host == b.Host && a.Subset == b.Subset
+}
+
+func destinationName(d istiov1alpha3.Destination) string {
+	if d.Subset != "" {
+		return fmt.Sprintf("%s/%s", d.Host, d.Subset)
+	}
+	return d.Host
+}
+
 // addHeaders applies headers before forwarding a request to the destination service
 // compatible with Istio 1.0.x and 1.1.0
 func addHeaders(canary *flaggerv1.Canary) (headers map[string]string) {
@@ -346,15 +607,53 @@ func addHeaders(canary *flaggerv1.Canary) (headers map[string]string) {
 	return
 }
 
-// mergeMatchConditions appends the URI match rules to canary conditions
+// canaryMatchConditions returns the conditions of the canary route scoped to the canary gateways,
+// the current stage match takes precedence over the A/B testing and the weighted match
+func canaryMatchConditions(canary *flaggerv1.Canary) []istiov1alpha3.HTTPMatchRequest {
+	match := canary.Spec.CanaryAnalysis.WeightedMatch
+	if stage := canary.GetStage(); stage != nil {
+		match = stage.Match
+	} else if len(canary.Spec.CanaryAnalysis.Match) > 0 {
+		match = canary.Spec.CanaryAnalysis.Match
+	}
+
+	return gatewayMatchConditions(match, canary.Spec.Service.CanaryGateways)
+}
+
+// gatewayMatchConditions restricts the conditions to the requests coming through the gateways,
+// a condition matching only the gateways is returned if there are no conditions
+func gatewayMatchConditions(match []istiov1alpha3.HTTPMatchRequest, gateways []string) []istiov1alpha3.HTTPMatchRequest {
+	if len(gateways) == 0 {
+		return match
+	}
+	if len(match) == 0 {
+		return []istiov1alpha3.HTTPMatchRequest{{Gateways: gateways}}
+	}
+
+	scoped := make([]istiov1alpha3.HTTPMatchRequest, len(match))
+	for i, m := range match {
+		m.Gateways = gateways
+		scoped[i] = m
+	}
+	return scoped
+}
+
+// mergeMatchConditions returns a copy of the canary conditions with the URI match rules
+// appended to the conditions that don't set their own URI
 func mergeMatchConditions(canary, defaults []istiov1alpha3.HTTPMatchRequest) []istiov1alpha3.HTTPMatchRequest {
+	merged := make([]istiov1alpha3.HTTPMatchRequest, len(canary))
 	for i := range canary {
+		canary[i].DeepCopyInto(&merged[i])
+		if merged[i].Uri != nil {
+			continue
+		}
 		for _, d := range defaults {
 			if d.Uri != nil {
-				canary[i].Uri = d.Uri
+				uri := *d.Uri
+				merged[i].Uri = &uri
 			}
 		}
 	}
 
-	return canary
+	return merged
 }
@@ -2,6 +2,8 @@ package router
 
 import (
 	"fmt"
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	istiov1alpha1 "github.com/weaveworks/flagger/pkg/apis/istio/common/v1alpha1"
 	istiov1alpha3 "github.com/weaveworks/flagger/pkg/apis/istio/v1alpha3"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"testing"
@@ -150,6 +152,312 @@ func TestIstioRouter_SetRoutes(t *testing.T) {
 	}
 }
 
+func TestIstioRouter_SetRoutesBaseline(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+	mocks.canary.Spec.CanaryAnalysis.Baseline = true
+
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := router.SetRoutes(mocks.canary, 70, 30); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	weights := make(map[string]int)
+	for _, route := range vs.Spec.Http[0].Route {
+		weights[route.Destination.Host] = route.Weight
+	}
+	if weights["podinfo-primary"] != 40 || weights["podinfo-canary"] != 30 || weights["podinfo-baseline"] != 30 {
+		t.Errorf("Got weights %v wanted primary 40 canary 30 baseline 30", weights)
+	}
+
+	// the baseline weight is reported as part of the primary weight
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 70 || c != 30 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 70, 30)
+	}
+}
+
+func TestIstioRouter_Subsets(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+	mocks.canary.Spec.Service.Subsets = &v1alpha3.CanarySubsets{
+		DestinationRule: "reviews",
+		Primary:         "stable",
+		Canary:          "next",
+	}
+
+	// the destination rule is required
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Fatal("Sync should fail when the destination rule doesn't exist")
+	}
+
+	dr := &istiov1alpha3.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "default"},
+		Spec: istiov1alpha3.DestinationRuleSpec{
+			Host:    "podinfo",
+			Subsets: []istiov1alpha3.Subset{{Name: "stable"}},
+		},
+	}
+	if _, err := mocks.meshClient.NetworkingV1alpha3().DestinationRules("default").Create(dr); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the canary subset is missing
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Fatal("Sync should fail when the canary subset doesn't exist")
+	}
+
+	dr.Spec.Subsets = append(dr.Spec.Subsets, istiov1alpha3.Subset{Name: "next"})
+	if _, err := mocks.meshClient.NetworkingV1alpha3().DestinationRules("default").Update(dr); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := router.SetRoutes(mocks.canary, 60, 40); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	weights := make(map[string]int)
+	for _, route := range vs.Spec.Http[0].Route {
+		if route.Destination.Host != "podinfo" {
+			t.Errorf("Got destination host %s wanted %s", route.Destination.Host, "podinfo")
+		}
+		weights[route.Destination.Subset] = route.Weight
+	}
+	if weights["stable"] != 60 || weights["next"] != 40 {
+		t.Errorf("Got weights %v wanted stable 60 next 40", weights)
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 60 || c != 40 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 60, 40)
+	}
+}
+
+func TestIstioRouter_External(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+	mocks.canary.Spec.Service.External = &v1alpha3.CanaryExternal{
+		Target:          v1alpha3.ExternalCanary,
+		ServiceEntry:    "podinfo-external",
+		Host:            "podinfo.example.com",
+		Port:            443,
+		DestinationRule: "podinfo-external",
+	}
+
+	// the service entry is required
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Fatal("Sync should fail when the service entry doesn't exist")
+	}
+
+	se := &istiov1alpha3.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo-external", Namespace: "default"},
+		Spec: istiov1alpha3.ServiceEntrySpec{
+			Hosts:      []string{"podinfo.example.com"},
+			Location:   "MESH_EXTERNAL",
+			Resolution: "DNS",
+			Ports:      []istiov1alpha3.Port{{Number: 80, Name: "http", Protocol: "HTTP"}},
+		},
+	}
+	if _, err := mocks.meshClient.NetworkingV1alpha3().ServiceEntries("default").Create(se); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the external port is missing
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Fatal("Sync should fail when the service entry doesn't contain the external port")
+	}
+
+	se.Spec.Ports = append(se.Spec.Ports, istiov1alpha3.Port{Number: 443, Name: "tls", Protocol: "TLS"})
+	if _, err := mocks.meshClient.NetworkingV1alpha3().ServiceEntries("default").Update(se); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the destination rule is required
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Fatal("Sync should fail when the destination rule doesn't exist")
+	}
+
+	dr := &istiov1alpha3.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "podinfo-external", Namespace: "default"},
+		Spec:       istiov1alpha3.DestinationRuleSpec{Host: "podinfo.example.com"},
+	}
+	if _, err := mocks.meshClient.NetworkingV1alpha3().DestinationRules("default").Create(dr); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := router.SetRoutes(mocks.canary, 70, 30); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	weights := make(map[string]int)
+	for _, route := range vs.Spec.Http[0].Route {
+		weights[fmt.Sprintf("%s:%v", route.Destination.Host, route.Destination.Port.Number)] = route.Weight
+	}
+	primary := fmt.Sprintf("podinfo-primary:%v", mocks.canary.Spec.Service.Port)
+	if weights[primary] != 70 || weights["podinfo.example.com:443"] != 30 {
+		t.Errorf("Got weights %v wanted %s 70 podinfo.example.com:443 30", weights, primary)
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 70 || c != 30 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 70, 30)
+	}
+
+	// migrate to the cluster with the primary served by the external service
+	mocks.canary.Spec.Service.External.Target = v1alpha3.ExternalPrimary
+	if err := router.SetRoutes(mocks.canary, 90, 10); err != nil {
+		t.Fatal(err.Error())
+	}
+	vs, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if host := vs.Spec.Http[0].Route[0].Destination.Host; host != "podinfo.example.com" {
+		t.Errorf("Got primary destination %s wanted %s", host, "podinfo.example.com")
+	}
+}
+
+func TestIstioRouter_Delegation(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+	match := []istiov1alpha3.HTTPMatchRequest{
+		{
+			Uri: &istiov1alpha1.StringMatch{
+				Prefix: "/podinfo",
+			},
+		},
+	}
+	mocks.canary.Spec.Service.Delegation = &v1alpha3.CanaryDelegation{
+		Root:  "public",
+		Match: match,
+	}
+
+	// the root virtual service is required
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Fatal("Sync should fail when the root virtual service doesn't exist")
+	}
+
+	root := &istiov1alpha3.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "public", Namespace: "default"},
+		Spec: istiov1alpha3.VirtualServiceSpec{
+			Hosts:    []string{"app.example.com"},
+			Gateways: []string{"public-gateway"},
+		},
+	}
+	if _, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Create(root); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(vs.Spec.Hosts) > 0 || len(vs.Spec.Gateways) > 0 {
+		t.Errorf("Got delegate hosts %v gateways %v wanted none", vs.Spec.Hosts, vs.Spec.Gateways)
+	}
+
+	root, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("public", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(root.Spec.Http) != 1 {
+		t.Fatalf("Got root Http %v wanted %v", len(root.Spec.Http), 1)
+	}
+	delegate := root.Spec.Http[0].Delegate
+	if delegate == nil || delegate.Name != "podinfo" || delegate.Namespace != "default" {
+		t.Errorf("Got root delegate %v wanted podinfo.default", delegate)
+	}
+	if root.Spec.Http[0].Match[0].Uri.Prefix != "/podinfo" {
+		t.Errorf("Got root match %v wanted %v", root.Spec.Http[0].Match[0].Uri.Prefix, "/podinfo")
+	}
+
+	// the delegate route is registered only once
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+	root, err = mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("public", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(root.Spec.Http) != 1 {
+		t.Errorf("Got root Http %v wanted %v", len(root.Spec.Http), 1)
+	}
+
+	// traffic shifting targets the delegate
+	if err := router.SetRoutes(mocks.canary, 70, 30); err != nil {
+		t.Fatal(err.Error())
+	}
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 70 || c != 30 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 70, 30)
+	}
+
+	// hosts and gateways belong to the root
+	mocks.canary.Spec.Service.Hosts = []string{"app.example.com"}
+	if err := router.Sync(mocks.canary); err == nil {
+		t.Fatal("Sync should fail when hosts are set with delegation")
+	}
+}
+
 func TestIstioRouter_GetRoutes(t *testing.T) {
 	mocks := setupfakeClients()
 	router := &IstioRouter{
@@ -299,3 +607,200 @@ func TestIstioRouter_ABTest(t *testing.T) {
 		t.Errorf("Got canary weight %v wanted %v", cRoute.Weight, c)
 	}
 }
+
+func TestIstioRouter_WeightedMatch(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.CanaryAnalysis.WeightedMatch = []istiov1alpha3.HTTPMatchRequest{
+		{
+			Uri: &istiov1alpha1.StringMatch{
+				Prefix: "/v2",
+			},
+		},
+	}
+
+	err := router.Sync(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = router.SetRoutes(canary, 60, 40)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(vs.Spec.Http) != 2 {
+		t.Fatalf("Got Istio VS Http %v wanted %v", len(vs.Spec.Http), 2)
+	}
+
+	if vs.Spec.Http[0].Match[0].Uri.Prefix != "/v2" {
+		t.Errorf("Got match prefix %v wanted %v", vs.Spec.Http[0].Match[0].Uri.Prefix, "/v2")
+	}
+
+	primaryHost := fmt.Sprintf("%s-primary", canary.Spec.TargetRef.Name)
+	if len(vs.Spec.Http[1].Route) != 1 || vs.Spec.Http[1].Route[0].Destination.Host != primaryHost {
+		t.Errorf("Got default route %v wanted %v", vs.Spec.Http[1].Route, primaryHost)
+	}
+
+	p, c, err := router.GetRoutes(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if p != 60 || c != 40 {
+		t.Errorf("Got weights %v/%v wanted %v/%v", p, c, 60, 40)
+	}
+
+	// match and weighted match are mutually exclusive
+	canary.Spec.CanaryAnalysis.Match = mocks.abtest.Spec.CanaryAnalysis.Match
+	err = router.Sync(canary)
+	if err == nil {
+		t.Errorf("Got no error wanted match validation error")
+	}
+}
+
+func TestIstioRouter_WeightedMatchServiceMatch(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Service.Match = []istiov1alpha3.HTTPMatchRequest{
+		{
+			Uri: &istiov1alpha1.StringMatch{
+				Prefix: "/podinfo",
+			},
+		},
+	}
+	canary.Spec.CanaryAnalysis.WeightedMatch = []istiov1alpha3.HTTPMatchRequest{
+		{
+			Uri: &istiov1alpha1.StringMatch{
+				Prefix: "/v2",
+			},
+		},
+		{
+			Headers: map[string]istiov1alpha1.StringMatch{
+				"x-canary": {
+					Exact: "insider",
+				},
+			},
+		},
+	}
+
+	err := router.Sync(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = router.SetRoutes(canary, 60, 40)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(vs.Spec.Http) != 2 || len(vs.Spec.Http[0].Match) != 2 {
+		t.Fatalf("Got Istio VS Http %v wanted two routes with two canary conditions", vs.Spec.Http)
+	}
+
+	// the weighted match URI takes precedence over the service match
+	if vs.Spec.Http[0].Match[0].Uri.Prefix != "/v2" {
+		t.Errorf("Got match prefix %v wanted %v", vs.Spec.Http[0].Match[0].Uri.Prefix, "/v2")
+	}
+
+	// the service match URI is appended to the conditions without one
+	if vs.Spec.Http[0].Match[1].Uri == nil || vs.Spec.Http[0].Match[1].Uri.Prefix != "/podinfo" {
+		t.Errorf("Got match URI %v wanted prefix %v", vs.Spec.Http[0].Match[1].Uri, "/podinfo")
+	}
+
+	if vs.Spec.Http[1].Match[0].Uri.Prefix != "/podinfo" {
+		t.Errorf("Got default match prefix %v wanted %v", vs.Spec.Http[1].Match[0].Uri.Prefix, "/podinfo")
+	}
+
+	// the canary spec is left untouched
+	if canary.Spec.CanaryAnalysis.WeightedMatch[0].Uri.Prefix != "/v2" {
+		t.Errorf("Got weighted match prefix %v wanted %v", canary.Spec.CanaryAnalysis.WeightedMatch[0].Uri.Prefix, "/v2")
+	}
+	if canary.Spec.CanaryAnalysis.WeightedMatch[1].Uri != nil {
+		t.Errorf("Got weighted match URI %v wanted none", canary.Spec.CanaryAnalysis.WeightedMatch[1].Uri)
+	}
+}
+
+func TestIstioRouter_CanaryGateways(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &IstioRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		istioClient:   mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	canary := mocks.canary.DeepCopy()
+	canary.Spec.Service.Gateways = []string{"istio-system/internal", "istio-system/external"}
+	canary.Spec.Service.CanaryGateways = []string{"istio-system/internal"}
+
+	err := router.Sync(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = router.SetRoutes(canary, 60, 40)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := mocks.meshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(vs.Spec.Http) != 2 {
+		t.Fatalf("Got Istio VS Http %v wanted %v", len(vs.Spec.Http), 2)
+	}
+
+	if len(vs.Spec.Http[0].Match) != 1 || len(vs.Spec.Http[0].Match[0].Gateways) != 1 ||
+		vs.Spec.Http[0].Match[0].Gateways[0] != "istio-system/internal" {
+		t.Errorf("Got canary route match %v wanted the internal gateway", vs.Spec.Http[0].Match)
+	}
+
+	// the external gateway traffic stays on the primary
+	primaryHost := fmt.Sprintf("%s-primary", canary.Spec.TargetRef.Name)
+	if len(vs.Spec.Http[1].Route) != 1 || vs.Spec.Http[1].Route[0].Destination.Host != primaryHost {
+		t.Errorf("Got default route %v wanted %v", vs.Spec.Http[1].Route, primaryHost)
+	}
+
+	p, c, err := router.GetRoutes(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if p != 60 || c != 40 {
+		t.Errorf("Got weights %v/%v wanted %v/%v", p, c, 60, 40)
+	}
+
+	// the canary gateways must be bound to the virtual service
+	canary.Spec.Service.CanaryGateways = []string{"istio-system/staging"}
+	err = router.Sync(canary)
+	if err == nil {
+		t.Errorf("Got no error wanted canary gateway validation error")
+	}
+}
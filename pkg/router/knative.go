@@ -0,0 +1,96 @@
+package router
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	knative "github.com/weaveworks/flagger/pkg/apis/knative/v1"
+	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KnativeRouter is managing the traffic split of Knative services
+type KnativeRouter struct {
+	flaggerClient clientset.Interface
+	logger        *zap.SugaredLogger
+}
+
+// Sync checks that the service traffic is split between the primary and the latest revision
+func (kr *KnativeRouter) Sync(canary *flaggerv1.Canary) error {
+	ksvc, err := kr.getService(canary)
+	if err != nil {
+		return err
+	}
+
+	if _, i := ksvc.GetTrafficTarget(knative.PrimaryTrafficTag); i < 0 {
+		return fmt.Errorf("service %s.%s traffic has no %s target", ksvc.Name, ksvc.Namespace, knative.PrimaryTrafficTag)
+	}
+	if _, i := ksvc.GetTrafficTarget(knative.CanaryTrafficTag); i < 0 {
+		return fmt.Errorf("service %s.%s traffic has no %s target", ksvc.Name, ksvc.Namespace, knative.CanaryTrafficTag)
+	}
+	return nil
+}
+
+// SetRoutes updates the traffic percentages of the primary and latest revisions
+func (kr *KnativeRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) error {
+	ksvc, err := kr.getService(canary)
+	if err != nil {
+		return err
+	}
+
+	primary, _ := ksvc.GetTrafficTarget(knative.PrimaryTrafficTag)
+	if primary == nil || primary.RevisionName == "" {
+		return fmt.Errorf("service %s.%s has no primary revision", ksvc.Name, ksvc.Namespace)
+	}
+
+	ksvcCopy := ksvc.DeepCopy()
+	ksvcCopy.Spec.Traffic = knative.NewCanaryTraffic(primary.RevisionName, primaryWeight, canaryWeight)
+
+	_, err = kr.flaggerClient.ServingV1().Services(canary.Namespace).Update(ksvcCopy)
+	if err != nil {
+		return fmt.Errorf("service %s.%s update failed: %v", ksvc.Name, ksvc.Namespace, err)
+	}
+	return nil
+}
+
+// GetRoutes returns the traffic percentages of the primary and latest revisions
+func (kr *KnativeRouter) GetRoutes(canary *flaggerv1.Canary) (
+	primaryWeight int,
+	canaryWeight int,
+	err error,
+) {
+	ksvc, err := kr.getService(canary)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	primary, _ := ksvc.GetTrafficTarget(knative.PrimaryTrafficTag)
+	latest, _ := ksvc.GetTrafficTarget(knative.CanaryTrafficTag)
+	if primary == nil || latest == nil {
+		err = fmt.Errorf("service %s.%s traffic targets %s and %s not found",
+			ksvc.Name, ksvc.Namespace, knative.PrimaryTrafficTag, knative.CanaryTrafficTag)
+		return 0, 0, err
+	}
+
+	if primary.Percent != nil {
+		primaryWeight = int(*primary.Percent)
+	}
+	if latest.Percent != nil {
+		canaryWeight = int(*latest.Percent)
+	}
+	return
+}
+
+func (kr *KnativeRouter) getService(canary *flaggerv1.Canary) (*knative.Service, error) {
+	targetName := canary.Spec.TargetRef.Name
+	ksvc, err := kr.flaggerClient.ServingV1().Services(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("service %s.%s not found", targetName, canary.Namespace)
+		}
+		return nil, fmt.Errorf("service %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+	return ksvc, nil
+}
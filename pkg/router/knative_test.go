@@ -0,0 +1,84 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	knative "github.com/weaveworks/flagger/pkg/apis/knative/v1"
+	fakeFlagger "github.com/weaveworks/flagger/pkg/client/clientset/versioned/fake"
+	"github.com/weaveworks/flagger/pkg/logging"
+	hpav1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestKnativeRouter_SetRoutes(t *testing.T) {
+	canary := newMockCanaryKnative()
+	ksvc := &knative.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "podinfo"},
+		Spec: knative.ServiceSpec{
+			Traffic: knative.NewCanaryTraffic("podinfo-00001", 100, 0),
+		},
+	}
+	flaggerClient := fakeFlagger.NewSimpleClientset(canary, ksvc)
+	logger, _ := logging.NewLogger("debug")
+
+	router := &KnativeRouter{
+		flaggerClient: flaggerClient,
+		logger:        logger,
+	}
+
+	err := router.Sync(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = router.SetRoutes(canary, 70, 30)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, c, err := router.GetRoutes(canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if p != 70 {
+		t.Errorf("Got primary weight %v wanted %v", p, 70)
+	}
+
+	if c != 30 {
+		t.Errorf("Got canary weight %v wanted %v", c, 30)
+	}
+
+	ksvc, err = flaggerClient.ServingV1().Services("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	primary, _ := ksvc.GetTrafficTarget(knative.PrimaryTrafficTag)
+	if primary.RevisionName != "podinfo-00001" {
+		t.Errorf("Got primary revision %s wanted %s", primary.RevisionName, "podinfo-00001")
+	}
+}
+
+func newMockCanaryKnative() *v1alpha3.Canary {
+	return &v1alpha3.Canary{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1alpha3.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "podinfo",
+		},
+		Spec: v1alpha3.CanarySpec{
+			TargetRef: hpav1.CrossVersionObjectReference{
+				Name:       "podinfo",
+				APIVersion: "serving.knative.dev/v1",
+				Kind:       "Service",
+			},
+			CanaryAnalysis: v1alpha3.CanaryAnalysis{
+				Threshold:  10,
+				StepWeight: 10,
+				MaxWeight:  50,
+			},
+		},
+	}
+}
@@ -146,6 +146,47 @@ func (c *KubernetesRouter) Sync(cd *flaggerv1.Canary) error {
 		c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Infof("Service %s.%s created", primaryService.GetName(), cd.Namespace)
 	}
 
+	if cd.Spec.CanaryAnalysis.Baseline {
+		baselineName := fmt.Sprintf("%s-baseline", targetName)
+		_, err := c.kubeClient.CoreV1().Services(cd.Namespace).Get(baselineName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			baselineService := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baselineName,
+					Namespace: cd.Namespace,
+					OwnerReferences: []metav1.OwnerReference{
+						*metav1.NewControllerRef(cd, schema.GroupVersionKind{
+							Group:   flaggerv1.SchemeGroupVersion.Group,
+							Version: flaggerv1.SchemeGroupVersion.Version,
+							Kind:    flaggerv1.CanaryKind,
+						}),
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Type:     corev1.ServiceTypeClusterIP,
+					Selector: map[string]string{"app": baselineName},
+					Ports: []corev1.ServicePort{
+						{
+							Name:     portName,
+							Protocol: corev1.ProtocolTCP,
+							Port:     cd.Spec.Service.Port,
+							TargetPort: intstr.IntOrString{
+								Type:   intstr.Int,
+								IntVal: cd.Spec.Service.Port,
+							},
+						},
+					},
+				},
+			}
+
+			_, err = c.kubeClient.CoreV1().Services(cd.Namespace).Create(baselineService)
+			if err != nil {
+				return err
+			}
+			c.logger.With("canary", fmt.Sprintf("%s.%s", cd.Name, cd.Namespace)).Infof("Service %s.%s created", baselineName, cd.Namespace)
+		}
+	}
+
 	return nil
 }
 
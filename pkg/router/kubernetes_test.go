@@ -44,3 +44,25 @@ func TestServiceRouter_Sync(t *testing.T) {
 		t.Errorf("Got primary svc port %v wanted %v", primarySvc.Spec.Ports[0].Port, 9898)
 	}
 }
+
+func TestServiceRouter_SyncBaseline(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &KubernetesRouter{
+		kubeClient:    mocks.kubeClient,
+		flaggerClient: mocks.flaggerClient,
+		logger:        mocks.logger,
+	}
+	mocks.canary.Spec.CanaryAnalysis.Baseline = true
+
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	baselineSvc, err := mocks.kubeClient.CoreV1().Services("default").Get("podinfo-baseline", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if baselineSvc.Spec.Selector["app"] != "podinfo-baseline" {
+		t.Errorf("Got baseline svc selector %v wanted %v", baselineSvc.Spec.Selector, "app: podinfo-baseline")
+	}
+}
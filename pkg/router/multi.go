@@ -0,0 +1,69 @@
+package router
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	"go.uber.org/zap"
+)
+
+// MultiRouter is managing the traffic of a canary across multiple routers,
+// e.g. an ingress controller for north-south and a service mesh for east-west traffic
+type MultiRouter struct {
+	routers []Interface
+	logger  *zap.SugaredLogger
+}
+
+// Sync creates or updates the routing objects of all routers,
+// it stops at the first router that fails
+func (mr *MultiRouter) Sync(canary *flaggerv1.Canary) error {
+	for _, router := range mr.routers {
+		if err := router.Sync(canary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRoutes updates the destination weights of all routers,
+// it stops at the first router that fails
+func (mr *MultiRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) error {
+	for _, router := range mr.routers {
+		if err := router.SetRoutes(canary, primaryWeight, canaryWeight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRoutes returns the destination weights of the first router,
+// the other routers are reconciled if their weights have drifted
+func (mr *MultiRouter) GetRoutes(canary *flaggerv1.Canary) (
+	primaryWeight int,
+	canaryWeight int,
+	err error,
+) {
+	if len(mr.routers) == 0 {
+		return 0, 0, fmt.Errorf("no routers configured")
+	}
+
+	primaryWeight, canaryWeight, err = mr.routers[0].GetRoutes(canary)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, router := range mr.routers[1:] {
+		p, c, err := router.GetRoutes(canary)
+		if err != nil {
+			return 0, 0, err
+		}
+		if p != primaryWeight || c != canaryWeight {
+			mr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+				Infof("Reconciling routes %v/%v to %v/%v", p, c, primaryWeight, canaryWeight)
+			if err := router.SetRoutes(canary, primaryWeight, canaryWeight); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	return primaryWeight, canaryWeight, nil
+}
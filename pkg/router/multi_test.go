@@ -0,0 +1,128 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	fakeFlagger "github.com/weaveworks/flagger/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeRouter struct {
+	primaryWeight int
+	canaryWeight  int
+	err           error
+}
+
+func (fr *fakeRouter) Sync(canary *flaggerv1.Canary) error {
+	return fr.err
+}
+
+func (fr *fakeRouter) SetRoutes(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) error {
+	if fr.err != nil {
+		return fr.err
+	}
+	fr.primaryWeight = primaryWeight
+	fr.canaryWeight = canaryWeight
+	return nil
+}
+
+func (fr *fakeRouter) GetRoutes(canary *flaggerv1.Canary) (int, int, error) {
+	return fr.primaryWeight, fr.canaryWeight, fr.err
+}
+
+func TestMultiRouter_SetRoutes(t *testing.T) {
+	mocks := setupfakeClients()
+	ingress := &fakeRouter{primaryWeight: 100}
+	mesh := &fakeRouter{primaryWeight: 100}
+	router := &MultiRouter{routers: []Interface{ingress, mesh}, logger: mocks.logger}
+
+	err := router.SetRoutes(mocks.canary, 80, 20)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, r := range []*fakeRouter{ingress, mesh} {
+		if r.primaryWeight != 80 || r.canaryWeight != 20 {
+			t.Errorf("Got routes %v/%v wanted %v/%v", r.primaryWeight, r.canaryWeight, 80, 20)
+		}
+	}
+
+	// reconcile drifted routes
+	mesh.primaryWeight = 100
+	mesh.canaryWeight = 0
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 80 || c != 20 {
+		t.Errorf("Got routes %v/%v wanted %v/%v", p, c, 80, 20)
+	}
+	if mesh.primaryWeight != 80 || mesh.canaryWeight != 20 {
+		t.Errorf("Got mesh routes %v/%v wanted %v/%v", mesh.primaryWeight, mesh.canaryWeight, 80, 20)
+	}
+
+	// a failing router halts the advancement
+	mesh.err = fmt.Errorf("mesh unavailable")
+	if err := router.SetRoutes(mocks.canary, 70, 30); err == nil {
+		t.Errorf("Expected error from the mesh router")
+	}
+}
+
+func TestFactory_MeshRouterMultiple(t *testing.T) {
+	mocks := setupfakeClients()
+	factory := NewFactory(mocks.kubeClient, mocks.flaggerClient, mocks.logger, mocks.meshClient)
+
+	router, ok := factory.MeshRouter("istio, appmesh").(*MultiRouter)
+	if !ok {
+		t.Fatalf("Expected a multi router")
+	}
+
+	if len(router.routers) != 2 {
+		t.Errorf("Got %v routers wanted %v", len(router.routers), 2)
+	}
+}
+
+func TestFactory_MeshRouterRemoteClusters(t *testing.T) {
+	mocks := setupfakeClients()
+	remote := RemoteCluster{
+		Name:       "remote",
+		KubeClient: fake.NewSimpleClientset(newMockDeployment()),
+		MeshClient: fakeFlagger.NewSimpleClientset(),
+	}
+	factory := NewFactory(mocks.kubeClient, mocks.flaggerClient, mocks.logger, mocks.meshClient, remote)
+
+	router := factory.MeshRouter("istio")
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := router.SetRoutes(mocks.canary, 60, 40); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vs, err := remote.MeshClient.NetworkingV1alpha3().VirtualServices("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if w := vs.Spec.Http[0].Route[1].Weight; w != 40 {
+		t.Errorf("Got remote canary weight %v wanted %v", w, 40)
+	}
+
+	// the remote routes are reconciled with the local ones
+	remoteRouter := factory.meshRouter("istio", remote.KubeClient, remote.MeshClient)
+	if err := remoteRouter.SetRoutes(mocks.canary, 100, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, _, err := router.GetRoutes(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+	p, c, err := remoteRouter.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 60 || c != 40 {
+		t.Errorf("Got remote routes %v/%v wanted %v/%v", p, c, 60, 40)
+	}
+}
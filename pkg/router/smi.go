@@ -0,0 +1,173 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	flaggerv1 "github.com/weaveworks/flagger/pkg/apis/flagger/v1alpha3"
+	smiv1alpha2 "github.com/weaveworks/flagger/pkg/apis/smi/v1alpha2"
+	clientset "github.com/weaveworks/flagger/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SmiRouter is managing SMI traffic splits
+type SmiRouter struct {
+	kubeClient    kubernetes.Interface
+	smiClient     clientset.Interface
+	flaggerClient clientset.Interface
+	logger        *zap.SugaredLogger
+	// qualify the root service with the namespace, required by Open Service Mesh
+	namespacedRootService bool
+}
+
+// Sync creates or updates the traffic split with the primary and canary services as backends
+func (sr *SmiRouter) Sync(canary *flaggerv1.Canary) error {
+	if len(canary.Spec.CanaryAnalysis.Match) > 0 || len(canary.Spec.CanaryAnalysis.WeightedMatch) > 0 {
+		return fmt.Errorf("canary %s.%s match conditions are not supported by SMI traffic splits",
+			canary.Name, canary.Namespace)
+	}
+
+	targetName := canary.Spec.TargetRef.Name
+	tsSpec := smiv1alpha2.TrafficSplitSpec{
+		Service:  sr.rootService(canary),
+		Backends: sr.backends(canary, 100, 0),
+	}
+
+	ts, err := sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Get(targetName, metav1.GetOptions{})
+
+	// create traffic split
+	if errors.IsNotFound(err) {
+		ts = &smiv1alpha2.TrafficSplit{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      targetName,
+				Namespace: canary.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(canary, schema.GroupVersionKind{
+						Group:   flaggerv1.SchemeGroupVersion.Group,
+						Version: flaggerv1.SchemeGroupVersion.Version,
+						Kind:    flaggerv1.CanaryKind,
+					}),
+				},
+			},
+			Spec: tsSpec,
+		}
+		_, err = sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Create(ts)
+		if err != nil {
+			return fmt.Errorf("TrafficSplit %s.%s create error %v", targetName, canary.Namespace, err)
+		}
+		sr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("TrafficSplit %s.%s created", ts.GetName(), canary.Namespace)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("TrafficSplit %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	// update the root service but keep the backend weights
+	ignoreWeights := cmpopts.IgnoreFields(smiv1alpha2.TrafficSplitBackend{}, "Weight")
+	if diff := cmp.Diff(tsSpec, ts.Spec, ignoreWeights); diff != "" {
+		tsClone := ts.DeepCopy()
+		tsClone.Spec.Service = tsSpec.Service
+		if d := cmp.Diff(tsSpec.Backends, ts.Spec.Backends, ignoreWeights); d != "" {
+			tsClone.Spec.Backends = tsSpec.Backends
+		}
+
+		_, err = sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Update(tsClone)
+		if err != nil {
+			return fmt.Errorf("TrafficSplit %s.%s update error %v", targetName, canary.Namespace, err)
+		}
+		sr.logger.With("canary", fmt.Sprintf("%s.%s", canary.Name, canary.Namespace)).
+			Infof("TrafficSplit %s.%s updated", ts.GetName(), canary.Namespace)
+	}
+
+	return nil
+}
+
+// GetRoutes returns the backends weight for primary and canary
+func (sr *SmiRouter) GetRoutes(canary *flaggerv1.Canary) (
+	primaryWeight int,
+	canaryWeight int,
+	err error,
+) {
+	targetName := canary.Spec.TargetRef.Name
+	ts, err := sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = fmt.Errorf("TrafficSplit %s.%s not found", targetName, canary.Namespace)
+			return
+		}
+		err = fmt.Errorf("TrafficSplit %s.%s query error %v", targetName, canary.Namespace, err)
+		return
+	}
+
+	for _, b := range ts.Spec.Backends {
+		if b.Service == fmt.Sprintf("%s-primary", targetName) {
+			primaryWeight = b.Weight
+		}
+		if b.Service == fmt.Sprintf("%s-canary", targetName) {
+			canaryWeight = b.Weight
+		}
+	}
+
+	if primaryWeight == 0 && canaryWeight == 0 {
+		err = fmt.Errorf("TrafficSplit %s.%s does not contain backends for %s-primary and %s-canary",
+			targetName, canary.Namespace, targetName, targetName)
+	}
+
+	return
+}
+
+// SetRoutes updates the backends weight for primary and canary
+func (sr *SmiRouter) SetRoutes(
+	canary *flaggerv1.Canary,
+	primaryWeight int,
+	canaryWeight int,
+) error {
+	targetName := canary.Spec.TargetRef.Name
+	ts, err := sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("TrafficSplit %s.%s not found", targetName, canary.Namespace)
+		}
+		return fmt.Errorf("TrafficSplit %s.%s query error %v", targetName, canary.Namespace, err)
+	}
+
+	tsClone := ts.DeepCopy()
+	tsClone.Spec.Backends = sr.backends(canary, primaryWeight, canaryWeight)
+
+	_, err = sr.smiClient.SplitV1alpha2().TrafficSplits(canary.Namespace).Update(tsClone)
+	if err != nil {
+		return fmt.Errorf("TrafficSplit %s.%s update error %v", targetName, canary.Namespace, err)
+	}
+
+	return nil
+}
+
+// rootService returns the service used by clients to reach the target,
+// Open Service Mesh expects the root service in the <name>.<namespace> format
+func (sr *SmiRouter) rootService(canary *flaggerv1.Canary) string {
+	if sr.namespacedRootService {
+		return fmt.Sprintf("%s.%s", canary.Spec.TargetRef.Name, canary.Namespace)
+	}
+	return canary.Spec.TargetRef.Name
+}
+
+func (sr *SmiRouter) backends(canary *flaggerv1.Canary, primaryWeight int, canaryWeight int) []smiv1alpha2.TrafficSplitBackend {
+	targetName := canary.Spec.TargetRef.Name
+	return []smiv1alpha2.TrafficSplitBackend{
+		{
+			Service: fmt.Sprintf("%s-primary", targetName),
+			Weight:  primaryWeight,
+		},
+		{
+			Service: fmt.Sprintf("%s-canary", targetName),
+			Weight:  canaryWeight,
+		},
+	}
+}
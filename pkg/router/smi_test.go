@@ -0,0 +1,84 @@
+package router
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSmiRouter_Sync(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &SmiRouter{
+		logger:                mocks.logger,
+		flaggerClient:         mocks.flaggerClient,
+		smiClient:             mocks.meshClient,
+		kubeClient:            mocks.kubeClient,
+		namespacedRootService: true,
+	}
+
+	err := router.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ts, err := mocks.meshClient.SplitV1alpha2().TrafficSplits("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if ts.Spec.Service != "podinfo.default" {
+		t.Errorf("Got root service %s wanted %s", ts.Spec.Service, "podinfo.default")
+	}
+	if len(ts.Spec.Backends) != 2 {
+		t.Fatalf("Got backends %v wanted %v", len(ts.Spec.Backends), 2)
+	}
+
+	// test that A/B testing is rejected
+	if err := router.Sync(mocks.abtest); err == nil {
+		t.Errorf("Got no error wanted match not supported")
+	}
+}
+
+func TestSmiRouter_SetRoutes(t *testing.T) {
+	mocks := setupfakeClients()
+	router := &SmiRouter{
+		logger:        mocks.logger,
+		flaggerClient: mocks.flaggerClient,
+		smiClient:     mocks.meshClient,
+		kubeClient:    mocks.kubeClient,
+	}
+
+	err := router.Sync(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = router.SetRoutes(mocks.canary, 60, 40)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	p, c, err := router.GetRoutes(mocks.canary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p != 60 || c != 40 {
+		t.Errorf("Got primary %v canary %v wanted %v %v", p, c, 60, 40)
+	}
+
+	// sync must not reset the weights
+	if err := router.Sync(mocks.canary); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, c, _ := router.GetRoutes(mocks.canary); c != 40 {
+		t.Errorf("Got canary weight %v wanted %v", c, 40)
+	}
+
+	ts, err := mocks.meshClient.SplitV1alpha2().TrafficSplits("default").Get("podinfo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ts.Spec.Service != "podinfo" {
+		t.Errorf("Got root service %s wanted %s", ts.Spec.Service, "podinfo")
+	}
+}